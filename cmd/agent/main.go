@@ -9,17 +9,26 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/kserve/kserve/pkg/agent"
+	"github.com/kserve/kserve/pkg/agent/modelserver"
+	"github.com/kserve/kserve/pkg/agent/status"
 	"github.com/kserve/kserve/pkg/agent/storage"
 	"github.com/kserve/kserve/pkg/apis/serving/v1beta1"
 	"github.com/kserve/kserve/pkg/batcher"
+	"github.com/kserve/kserve/pkg/constants"
 	kfslogger "github.com/kserve/kserve/pkg/logger"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	flag "github.com/spf13/pflag"
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	network "knative.dev/networking/pkg"
 	pkglogging "knative.dev/pkg/logging"
 	pkgnet "knative.dev/pkg/network"
@@ -33,10 +42,26 @@ import (
 var (
 	port          = flag.String("port", "9081", "Agent port")
 	componentPort = flag.String("component-port", "8080", "Component port")
+	metricsPort   = flag.String("metrics-port", constants.AgentDefaultMetricsPortStr, "Port to expose agent prometheus metrics on")
 	// model puller flags
-	enablePuller = flag.Bool("enable-puller", false, "Enable model puller")
-	configDir    = flag.String("config-dir", "/mnt/configs", "directory for model config files")
-	modelDir     = flag.String("model-dir", "/mnt/models", "directory for model files")
+	enablePuller          = flag.Bool("enable-puller", false, "Enable model puller")
+	configDir             = flag.String("config-dir", "/mnt/configs", "directory for model config files")
+	modelConfigName       = flag.String("model-config-name", "", "Name of the model ConfigMap to watch through the Kubernetes API, the config dir is watched if empty or the ConfigMap can not be watched")
+	modelConfigNamespace  = flag.String("model-config-namespace", os.Getenv("POD_NAMESPACE"), "Namespace of the model ConfigMap, defaults to the POD_NAMESPACE env")
+	modelDir              = flag.String("model-dir", "/mnt/models", "directory for model files")
+	managementPort        = flag.String("management-port", constants.AgentDefaultManagementPortStr, "Port to serve the model status on")
+	loadAttempts          = flag.Int("load-attempts", agent.DefaultRetryConfig().MaxAttempts, "Number of attempts to download and load a model before it is marked as failed")
+	loadBackoff           = flag.Duration("load-backoff", agent.DefaultRetryConfig().InitialBackoff, "Wait before the first retry of a failed model, doubled on every retry")
+	loadMaxBackoff        = flag.Duration("load-max-backoff", agent.DefaultRetryConfig().MaxBackoff, "Max wait between the retries of a failed model")
+	modelServerProtocol   = flag.String("model-server-protocol", string(modelserver.V2), "Model management api of the model server, one of 'v2', 'torchserve', 'tfserving' or 'triton-grpc'")
+	modelServerEndpoint   = flag.String("model-server-endpoint", "", "Model management endpoint of the model server, defaults to the endpoint of the model server protocol")
+	modelServerTimeout    = flag.Duration("model-server-timeout", agent.DefaultModelServerTimeout, "Max time of a model load or unload request to the model server, a request which times out is retried as a failed attempt")
+	modelDirQuota         = flag.String("model-dir-quota", "", "Max size of the model dir, e.g. 10Gi, the least recently requested models are evicted to stay within it, no limit if empty")
+	modelServerConfigFile = flag.String("model-server-config-file", "", "Model config file polled by TF Serving, defaults to models.config in the model-dir")
+	downloadParallelism   = flag.Int("download-parallelism", storage.DefaultDownloadParallelism, "Number of objects of a model downloaded concurrently")
+	skipChecksums         = flag.Bool("skip-download-checksums", false, "Do not verify the downloaded objects against the checksums of the storage")
+	localLinkMode         = flag.String("local-link-mode", string(storage.LinkModeCopy), "How the files of file:// and pvc:// models are placed in the model-dir, one of 'copy', 'hardlink' or 'symlink'")
+	localRoots            = flag.String("local-roots", "", "Mounted dirs separated by commas which file:// models may be read from besides the PVC dir")
 	// logger flags
 	logUrl           = flag.String("log-url", "", "The URL to send request/response logs to")
 	workers          = flag.Int("workers", 5, "Number of workers")
@@ -46,10 +71,31 @@ var (
 	namespace        = flag.String("namespace", "", "The namespace to add as header to log events")
 	endpoint         = flag.String("endpoint", "", "The endpoint name to add as header to log events")
 	component        = flag.String("component", "", "The component name (predictor, explainer, transformer) to add as header to log events")
+	logQueueSize     = flag.Int("log-queue-size", kfslogger.LoggerWorkerQueueSize, "Max number of log events waiting to be sent before events are dropped")
+	logDropPolicy    = flag.String("log-drop-policy", string(kfslogger.DropNewest), "Whether to drop the 'newest' or 'oldest' log event when the queue is full")
+	logMaxRetries    = flag.Int("log-max-retries", kfslogger.DefaultMaxRetries, "Number of times a failed log event is retried")
+	logRetryBackoff  = flag.Duration("log-retry-backoff", kfslogger.DefaultRetryBackoff, "Wait before the first retry of a failed log event, doubled on every retry")
+	logSpoolDir      = flag.String("log-spool-dir", "", "Directory to keep undelivered log events in until they can be resent, disabled if empty")
+	logSpoolMaxBytes = flag.Int64("log-spool-max-bytes", 1<<30, "Max size of the log spool directory in bytes, 0 means no limit")
+	logSampleRate    = flag.Float64("log-sample-rate", 1, "Fraction of requests to log between 0 and 1")
+	logForceSample   = flag.String("log-force-sample-header", "", "Requests with this header set to true are always logged")
+	logMaxBodyBytes  = flag.Int64("log-max-body-bytes", 0, "Max size in bytes of the logged payloads, larger payloads are truncated, 0 means no limit")
+	logRedactFields  = flag.String("log-redact-fields", "", "Comma separated JSON paths of the payload fields to redact before logging")
+	logEncoding      = flag.String("log-encoding", string(kfslogger.EncodingBinary), "Whether to send 'binary' or 'structured' mode CloudEvents")
+	logRequestType   = flag.String("log-request-type", kfslogger.CEInferenceRequest, "CloudEvents type of the logged requests")
+	logResponseType  = flag.String("log-response-type", kfslogger.CEInferenceResponse, "CloudEvents type of the logged responses")
+	logEnvelopeType  = flag.String("log-envelope-type", kfslogger.CEInferenceEnvelope, "CloudEvents type of the logged envelopes")
+	logEventSource   = flag.String("log-event-source", "", "CloudEvents source of the logged events, defaults to the source-uri")
+	logDataSchema    = flag.String("log-data-schema", "", "CloudEvents dataschema of the logged events, e.g. the payload schema url in a schema registry")
+	logCredentials   = flag.String("log-credentials-dir", "", "Directory with the ca.crt, tls.crt, tls.key, token or username and password files used to connect to the log url")
+	logFlushTimeout  = flag.Duration("log-flush-timeout", 10*time.Second, "Max time to deliver the queued log events on shutdown, undelivered events are spooled or dropped")
+	logEnvelope      = flag.Bool("log-envelope", false, "Log the request and response of an inference as a single envelope event")
 	// batcher flags
 	enableBatcher = flag.Bool("enable-batcher", false, "Enable request batcher")
 	maxBatchSize  = flag.String("max-batchsize", "32", "Max Batch Size")
 	maxLatency    = flag.String("max-latency", "5000", "Max Latency in milliseconds")
+	maxQueueSize  = flag.String("max-queue-size", "1000", "Max number of inputs waiting to be batched before requests are rejected")
+	maxPayload    = flag.String("max-payload-size", "0", "Max request payload size in bytes, 0 means no limit")
 	// probing flags
 	readinessProbeTimeout = flag.Duration("probe-period", -1, "run readiness probe with given timeout")
 	// This creates an abstract socket instead of an actual file.
@@ -89,11 +135,15 @@ type loggerArgs struct {
 	namespace        string
 	endpoint         string
 	component        string
+	filter           *kfslogger.PayloadFilter
+	dispatcher       *kfslogger.Dispatcher
 }
 
 type batcherArgs struct {
-	maxBatchSize int
-	maxLatency   int
+	maxBatchSize   int
+	maxLatency     int
+	maxQueueSize   int
+	maxPayloadSize int64
 }
 
 func main() {
@@ -112,9 +162,38 @@ func main() {
 		probe = buildProbe(logger, env.ServingReadinessProbe).ProbeContainer
 	}
 
+	ctx := signals.NewContext()
+	var managementServer *http.Server
+	var diskQuota *agent.DiskQuota
 	if *enablePuller {
+		// the model status is served before the models are pulled so that the controller can follow the
+		// progress of the models pulled on startup
+		modelStatus := status.NewStore()
 		logger.Infof("Initializing model agent with config-dir %s, model-dir %s", *configDir, *modelDir)
-		startModelPuller(logger)
+		if *modelDirQuota != "" {
+			quota, err := resource.ParseQuantity(*modelDirQuota)
+			if err != nil {
+				logger.Errorw("Invalid model dir quota", zap.String("quota", *modelDirQuota), zap.Error(err))
+				os.Exit(1)
+			}
+			diskQuota = agent.NewDiskQuota(*modelDir, quota.Value())
+		}
+		watcher, puller := buildModelPuller(modelStatus, diskQuota, logger)
+		modelManager := agent.NewModelManager(watcher, puller, modelStatus, logger)
+		managementServer = buildManagementServer(*managementPort, modelStatus, modelManager)
+		l, err := net.Listen("tcp", managementServer.Addr)
+		if err != nil {
+			logger.Errorw("Management server failed to listen", zap.Error(err))
+			os.Exit(1)
+		}
+		go func() {
+			if err := managementServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Errorw("Management server failed to serve", zap.Error(err))
+			}
+		}()
+		logger.Info("Starting puller")
+		puller.ProcessModels(watcher.ModelEvents)
+		go watchModelConfig(ctx, watcher, logger)
 	}
 
 	var loggerArgs *loggerArgs
@@ -123,16 +202,33 @@ func main() {
 		loggerArgs = startLogger(*workers, logger)
 	}
 
+	promRegistry := prometheus.NewRegistry()
+	if *enablePuller {
+		if err := agent.RegisterMetrics(promRegistry); err != nil {
+			logger.Errorw("Failed to register model agent metrics", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+	if loggerArgs != nil {
+		if err := kfslogger.RegisterMetrics(promRegistry); err != nil {
+			logger.Errorw("Failed to register logger metrics", zap.Error(err))
+			os.Exit(1)
+		}
+	}
 	var batcherArgs *batcherArgs
 	if *enableBatcher {
 		logger.Info("Starting batcher")
 		batcherArgs = startBatcher(logger)
+		if err := batcher.RegisterMetrics(promRegistry); err != nil {
+			logger.Errorw("Failed to register batcher metrics", zap.Error(err))
+			os.Exit(1)
+		}
 	}
 	logger.Info("Starting agent http server...")
-	ctx := signals.NewContext()
-	mainServer, drain := buildServer(ctx, *port, *componentPort, loggerArgs, batcherArgs, probe, logger)
+	mainServer, drain := buildServer(ctx, *port, *componentPort, loggerArgs, batcherArgs, diskQuota, probe, logger)
 	servers := map[string]*http.Server{
-		"main": mainServer,
+		"main":    mainServer,
+		"metrics": buildMetricsServer(*metricsPort, promRegistry),
 	}
 	errCh := make(chan error)
 	listenCh := make(chan struct{})
@@ -191,12 +287,24 @@ func main() {
 		logger.Infof("Sleeping %v to allow K8s propagation of non-ready state", drainSleepDuration)
 		drain()
 
+		if managementServer != nil {
+			servers["management"] = managementServer
+		}
 		for serverName, srv := range servers {
 			logger.Info("Shutting down server: ", serverName)
 			if err := srv.Shutdown(context.Background()); err != nil {
 				logger.Errorw("Failed to shutdown server", zap.String("server", serverName), zap.Error(err))
 			}
 		}
+		if loggerArgs != nil {
+			// the servers no longer take requests so every inference is queued, flush them before exiting
+			logger.Info("Flushing log events")
+			flushCtx, cancel := context.WithTimeout(context.Background(), *logFlushTimeout)
+			if err := loggerArgs.dispatcher.Stop(flushCtx); err != nil {
+				logger.Errorw("Failed to flush log events", zap.Error(err))
+			}
+			cancel()
+		}
 		logger.Info("Shutdown complete, exiting...")
 	}
 }
@@ -214,9 +322,23 @@ func startBatcher(logger *zap.SugaredLogger) *batcherArgs {
 		os.Exit(1)
 	}
 
+	maxQueueSizeInt, err := strconv.Atoi(*maxQueueSize)
+	if err != nil || maxQueueSizeInt <= 0 {
+		logger.Error(errors.New("Invalid max queue size"), *maxQueueSize)
+		os.Exit(1)
+	}
+
+	maxPayloadInt, err := strconv.ParseInt(*maxPayload, 10, 64)
+	if err != nil || maxPayloadInt < 0 {
+		logger.Error(errors.New("Invalid max payload size"), *maxPayload)
+		os.Exit(1)
+	}
+
 	return &batcherArgs{
-		maxLatency:   maxLatencyInt,
-		maxBatchSize: maxBatchSizeInt,
+		maxLatency:     maxLatencyInt,
+		maxBatchSize:   maxBatchSizeInt,
+		maxQueueSize:   maxQueueSizeInt,
+		maxPayloadSize: maxPayloadInt,
 	}
 }
 
@@ -234,6 +356,41 @@ func startLogger(workers int, logger *zap.SugaredLogger) *loggerArgs {
 		logger.Errorf("Malformed log-url %s", *logUrl)
 		os.Exit(-1)
 	}
+	deliveryConfig := kfslogger.DefaultDeliveryConfig()
+	deliveryConfig.Event = kfslogger.EventConfig{
+		Encoding:     kfslogger.Encoding(*logEncoding),
+		RequestType:  *logRequestType,
+		ResponseType: *logResponseType,
+		EnvelopeType: *logEnvelopeType,
+		Source:       *logEventSource,
+		DataSchema:   *logDataSchema,
+		Envelope:     *logEnvelope,
+	}
+	deliveryConfig.QueueSize = *logQueueSize
+	deliveryConfig.DropPolicy = kfslogger.DropPolicy(*logDropPolicy)
+	deliveryConfig.MaxRetries = *logMaxRetries
+	deliveryConfig.RetryBackoff = *logRetryBackoff
+	if deliveryConfig.MaxRetryBackoff < deliveryConfig.RetryBackoff {
+		deliveryConfig.MaxRetryBackoff = deliveryConfig.RetryBackoff
+	}
+	deliveryConfig.SpoolDir = *logSpoolDir
+	deliveryConfig.SpoolMaxBytes = *logSpoolMaxBytes
+	if *logCredentials != "" {
+		if deliveryConfig.Credentials, err = kfslogger.LoadCredentials(*logCredentials); err != nil {
+			logger.Errorf("Invalid log credentials: %v", err)
+			os.Exit(-1)
+		}
+	}
+	dispatcher, err := kfslogger.NewDispatcher(workers, deliveryConfig, logger)
+	if err != nil {
+		logger.Errorf("Invalid log delivery config: %v", err)
+		os.Exit(-1)
+	}
+	// only checks the url and the sink config, the sinks connect to the log url when they first send
+	if _, err := dispatcher.GetSink(logUrlParsed); err != nil {
+		logger.Errorf("Unsupported log-url %s: %v", *logUrl, err)
+		os.Exit(-1)
+	}
 
 	if *sourceUri == "" {
 		*sourceUri = fmt.Sprintf("http://localhost:%s/", *port)
@@ -244,8 +401,18 @@ func startLogger(workers int, logger *zap.SugaredLogger) *loggerArgs {
 		logger.Errorf("Malformed source_uri %s", *sourceUri)
 		os.Exit(-1)
 	}
+	var redactFields []string
+	if *logRedactFields != "" {
+		redactFields = strings.Split(*logRedactFields, ",")
+	}
+	filter, err := kfslogger.NewPayloadFilter(*logSampleRate, *logForceSample, *logMaxBodyBytes, redactFields)
+	if err != nil {
+		logger.Errorf("Invalid payload logging config: %v", err)
+		os.Exit(-1)
+	}
+
 	logger.Info("Starting the log dispatcher")
-	kfslogger.StartDispatcher(workers, logger)
+	dispatcher.Start()
 	return &loggerArgs{
 		loggerType:       loggingMode,
 		logUrl:           logUrlParsed,
@@ -254,19 +421,91 @@ func startLogger(workers int, logger *zap.SugaredLogger) *loggerArgs {
 		endpoint:         *endpoint,
 		namespace:        *namespace,
 		component:        *component,
+		filter:           filter,
+		dispatcher:       dispatcher,
 	}
 }
 
-func startModelPuller(logger *zap.SugaredLogger) {
+func buildModelPuller(modelStatus *status.Store, diskQuota *agent.DiskQuota, logger *zap.SugaredLogger) (*agent.Watcher, *agent.Puller) {
+	linkMode := storage.LinkMode(*localLinkMode)
+	if !isValidLinkMode(linkMode) {
+		logger.Errorf("Invalid local link mode %q, must be one of %v", *localLinkMode, storage.LinkModes)
+		os.Exit(1)
+	}
+	var roots []string
+	if *localRoots != "" {
+		roots = strings.Split(*localRoots, ",")
+	}
 	downloader := agent.Downloader{
 		ModelDir:  *modelDir,
 		Providers: map[storage.Protocol]storage.Provider{},
-		Logger:    logger,
+		DownloadOptions: storage.DownloadOptions{
+			Parallelism:   *downloadParallelism,
+			SkipChecksums: *skipChecksums,
+			LocalLinkMode: linkMode,
+			LocalRoots:    roots,
+		},
+		Logger: logger,
 	}
+	protocol := modelserver.Protocol(*modelServerProtocol)
+	endpoint := *modelServerEndpoint
+	if endpoint == "" {
+		endpoint = modelserver.DefaultEndpoint(protocol, *componentPort)
+	}
+	modelServer, err := modelserver.NewAdapter(modelserver.Config{
+		Protocol:   protocol,
+		Endpoint:   endpoint,
+		ModelDir:   *modelDir,
+		ConfigFile: *modelServerConfigFile,
+	})
+	if err != nil {
+		logger.Errorw("Failed to create model server adapter", zap.Error(err))
+		os.Exit(1)
+	}
+	logger.Infof("Loading models with the %s protocol on %s", protocol, endpoint)
 	watcher := agent.NewWatcher(*configDir, *modelDir, logger)
-	logger.Info("Starting puller")
-	agent.StartPullerAndProcessModels(&downloader, watcher.ModelEvents, logger)
-	go watcher.Start()
+	retryConfig := agent.RetryConfig{
+		MaxAttempts:        *loadAttempts,
+		InitialBackoff:     *loadBackoff,
+		MaxBackoff:         *loadMaxBackoff,
+		ModelServerTimeout: *modelServerTimeout,
+	}
+	return &watcher, agent.NewPuller(&downloader, modelServer, diskQuota, modelStatus, retryConfig, logger)
+}
+
+func isValidLinkMode(linkMode storage.LinkMode) bool {
+	for _, mode := range storage.LinkModes {
+		if mode == linkMode {
+			return true
+		}
+	}
+	return false
+}
+
+// watchModelConfig watches the model ConfigMap through the Kubernetes API when its name is set, and the projected
+// ConfigMap in the config dir otherwise or when the service account is not allowed to watch the ConfigMap
+func watchModelConfig(ctx context.Context, watcher *agent.Watcher, logger *zap.SugaredLogger) {
+	if *modelConfigName != "" {
+		err := startModelConfigInformer(ctx, watcher)
+		if err == nil {
+			return
+		}
+		logger.Errorw("Failed to watch the model config through the Kubernetes API, watching the config dir instead",
+			zap.Error(err))
+	}
+	watcher.Start(ctx)
+}
+
+func startModelConfigInformer(ctx context.Context, watcher *agent.Watcher) error {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	return watcher.StartInformer(ctx, client, *modelConfigNamespace, *modelConfigName)
 }
 
 func buildProbe(logger *zap.SugaredLogger, probeJSON string) *readiness.Probe {
@@ -282,8 +521,26 @@ func buildProbe(logger *zap.SugaredLogger, probeJSON string) *readiness.Probe {
 	return newProbe
 }
 
+func buildMetricsServer(port string, registry *prometheus.Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(constants.DefaultPrometheusPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return pkgnet.NewServer(":"+port, mux)
+}
+
+// buildManagementServer serves the model status to the controller and the model management api on the management
+// port the injector declares on the agent container. It is kept off the agent port, which proxies the inference
+// requests, so that the callers of the inference endpoint cannot re-pull the models.
+func buildManagementServer(port string, modelStatus *status.Store, modelManager *agent.ModelManager) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(status.ModelsPath, modelStatus)
+	mux.Handle(status.ModelsPath+"/", modelStatus)
+	mux.Handle(agent.ManagementPath, modelManager)
+	mux.Handle(agent.ManagementPath+"/", modelManager)
+	return pkgnet.NewServer(":"+port, mux)
+}
+
 func buildServer(ctx context.Context, port string, userPort string, loggerArgs *loggerArgs, batcherArgs *batcherArgs,
-	probeContainer func() bool, logging *zap.SugaredLogger) (server *http.Server, drain func()) {
+	diskQuota *agent.DiskQuota, probeContainer func() bool, logging *zap.SugaredLogger) (server *http.Server, drain func()) {
 
 	logging.Infof("Building server user port %s port %s", userPort, port)
 	target := &url.URL{
@@ -304,13 +561,15 @@ func buildServer(ctx context.Context, port string, userPort string, loggerArgs *
 	var composedHandler http.Handler = httpProxy
 
 	if batcherArgs != nil {
-		composedHandler = batcher.New(batcherArgs.maxBatchSize, batcherArgs.maxLatency, composedHandler, logging)
+		composedHandler = batcher.New(batcherArgs.maxBatchSize, batcherArgs.maxLatency, batcherArgs.maxQueueSize,
+			batcherArgs.maxPayloadSize, composedHandler, logging)
 	}
 	if loggerArgs != nil {
 		composedHandler = kfslogger.New(loggerArgs.logUrl, loggerArgs.sourceUrl, loggerArgs.loggerType,
-			loggerArgs.inferenceService, loggerArgs.namespace, loggerArgs.endpoint, loggerArgs.component, composedHandler)
+			loggerArgs.inferenceService, loggerArgs.namespace, loggerArgs.endpoint, loggerArgs.component, loggerArgs.filter, loggerArgs.dispatcher, composedHandler)
 	}
 
+	composedHandler = agent.TrackModelRequests(composedHandler, diskQuota)
 	composedHandler = queue.ForwardedShimHandler(composedHandler)
 
 	drainer := &pkghandler.Drainer{
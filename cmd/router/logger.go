@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	guuid "github.com/google/uuid"
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	kfslogger "github.com/kserve/kserve/pkg/logger"
+	"github.com/kserve/kserve/pkg/utils"
+	"go.uber.org/zap"
+	"knative.dev/pkg/network"
+)
+
+const (
+	// ContentTypeJSON is the content type of the graph and step payloads, the router only handles json
+	ContentTypeJSON = "application/json"
+	// ContentTypeText is the content type of the logged error responses
+	ContentTypeText = "text/plain"
+)
+
+// graphLogger logs the requests and responses of the graph and of each of its steps
+type graphLogger struct {
+	graphName  string
+	namespace  string
+	logUrl     *url.URL
+	sourceUri  *url.URL
+	mode       v1alpha1.LoggerType
+	filter     *kfslogger.PayloadFilter
+	dispatcher *kfslogger.Dispatcher
+}
+
+// newGraphLogger validates the logger spec of the graph and creates the dispatcher of its events, the
+// dispatcher is started by the caller.
+func newGraphLogger(spec *v1alpha1.LoggerSpec, graphName string, namespace string, sourceUri *url.URL,
+	workers int, deliveryConfig kfslogger.DeliveryConfig, logger *zap.SugaredLogger) (*graphLogger, error) {
+	mode := spec.Mode
+	switch mode {
+	case "":
+		mode = v1alpha1.LogAll
+	case v1alpha1.LogAll, v1alpha1.LogRequest, v1alpha1.LogResponse:
+	default:
+		return nil, fmt.Errorf("invalid logger mode %q", spec.Mode)
+	}
+	if spec.URL == nil {
+		return nil, fmt.Errorf("logger url is not set")
+	}
+	logUrl, err := url.Parse(*spec.URL)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing logger url %q: %s", *spec.URL, err)
+	}
+
+	sampleRate := 1.0
+	if spec.SampleRate != nil {
+		if sampleRate, err = utils.ParseSampleRate(*spec.SampleRate); err != nil {
+			return nil, fmt.Errorf("invalid logger sample rate: %s", err)
+		}
+	}
+	forceSampleHeader := ""
+	if spec.ForceSampleHeader != nil {
+		forceSampleHeader = *spec.ForceSampleHeader
+	}
+	var maxBodyBytes int64
+	if spec.MaxBodyBytes != nil {
+		maxBodyBytes = *spec.MaxBodyBytes
+	}
+	filter, err := kfslogger.NewPayloadFilter(sampleRate, forceSampleHeader, maxBodyBytes, spec.RedactFields)
+	if err != nil {
+		return nil, fmt.Errorf("while creating payload filter: %s", err)
+	}
+
+	dispatcher, err := kfslogger.NewDispatcher(workers, deliveryConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("while creating log dispatcher: %s", err)
+	}
+	if _, err := dispatcher.GetSink(logUrl); err != nil {
+		return nil, fmt.Errorf("unsupported logger url %q: %s", *spec.URL, err)
+	}
+	return &graphLogger{
+		graphName:  graphName,
+		namespace:  namespace,
+		logUrl:     logUrl,
+		sourceUri:  sourceUri,
+		mode:       mode,
+		filter:     filter,
+		dispatcher: dispatcher,
+	}, nil
+}
+
+// requestLogger logs the events of a single graph request, a nil requestLogger logs nothing
+type requestLogger struct {
+	*graphLogger
+	id   string
+	path string
+}
+
+// forRequest returns the logger of the graph request, nil when the graph has no logger or the request is not
+// sampled. The steps of a request are sampled together with the request.
+func (l *graphLogger) forRequest(r *http.Request) *requestLogger {
+	if l == nil || network.IsKubeletProbe(r) {
+		return nil
+	}
+	id := r.Header.Get(kfslogger.CloudEventsIdHeader)
+	if id == "" {
+		id = guuid.New().String()
+	}
+	if !l.filter.Sample(r, id) {
+		return nil
+	}
+	return &requestLogger{
+		graphLogger: l,
+		id:          id,
+		path:        r.URL.Path,
+	}
+}
+
+// logRequest logs the request of the graph when nodeName is empty and the request of a step otherwise
+func (l *requestLogger) logRequest(nodeName string, stepName string, body []byte) {
+	if l == nil || l.mode == v1alpha1.LogResponse {
+		return
+	}
+	logReq := l.newLogRequest(nodeName, stepName, kfslogger.InferenceRequest, ContentTypeJSON, l.filter.Filter(body))
+	if err := l.dispatcher.QueueLogRequest(logReq); err != nil {
+		log.Error(err, "Failed to log request", "node", nodeName, "step", stepName)
+	}
+}
+
+// logResponse logs the response of the graph when nodeName is empty and the response of a step otherwise
+func (l *requestLogger) logResponse(nodeName string, stepName string, contentType string, body []byte,
+	statusCode int, latency time.Duration) {
+	if l == nil || l.mode == v1alpha1.LogRequest {
+		return
+	}
+	logReq := l.newLogRequest(nodeName, stepName, kfslogger.InferenceResponse, contentType, l.filter.Filter(body))
+	logReq.StatusCode = statusCode
+	logReq.Latency = latency
+	if err := l.dispatcher.QueueLogRequest(logReq); err != nil {
+		log.Error(err, "Failed to log response", "node", nodeName, "step", stepName)
+	}
+}
+
+// newLogRequest tags the event with the graph, node and step names. Step events get their own id derived from
+// the id of the graph request so that they can be correlated with it.
+func (l *requestLogger) newLogRequest(nodeName string, stepName string, reqType kfslogger.LogRequestType,
+	contentType string, body []byte) kfslogger.LogRequest {
+	id := l.id
+	if nodeName != "" {
+		id = fmt.Sprintf("%s/%s/%s", l.id, nodeName, stepName)
+	}
+	return kfslogger.LogRequest{
+		Url:            l.logUrl,
+		Bytes:          &body,
+		ContentType:    contentType,
+		ReqType:        reqType,
+		Id:             id,
+		SourceUri:      l.sourceUri,
+		Namespace:      l.namespace,
+		Path:           l.path,
+		InferenceGraph: l.graphName,
+		NodeName:       nodeName,
+		StepName:       stepName,
+	}
+}
+
+// stepLogName names the step in the logged events, unnamed steps are named after their target
+func stepLogName(step *v1alpha1.InferenceStep) string {
+	switch {
+	case step.StepName != "":
+		return step.StepName
+	case step.ServiceName != "":
+		return step.ServiceName
+	case step.NodeName != "":
+		return step.NodeName
+	default:
+		return step.ServiceURL
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	kfslogger "github.com/kserve/kserve/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type loggedEvent struct {
+	id          string
+	eventType   string
+	nodeName    string
+	stepName    string
+	graphName   string
+	statusCode  string
+	contentType string
+	body        string
+}
+
+// newLogService collects the binary mode events it receives in events
+func newLogService(mu *sync.Mutex, events *[]loggedEvent) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		mu.Lock()
+		*events = append(*events, loggedEvent{
+			id:          req.Header.Get("Ce-Id"),
+			eventType:   req.Header.Get("Ce-Type"),
+			nodeName:    req.Header.Get("Ce-" + kfslogger.NodeNameAttr),
+			stepName:    req.Header.Get("Ce-" + kfslogger.StepNameAttr),
+			graphName:   req.Header.Get("Ce-" + kfslogger.InferenceGraphAttr),
+			statusCode:  req.Header.Get("Ce-" + kfslogger.StatusCodeAttr),
+			contentType: req.Header.Get("Content-Type"),
+			body:        string(body),
+		})
+		mu.Unlock()
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+func TestGraphLogger(t *testing.T) {
+	model := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+		rw.Header().Set("Content-Type", ContentTypeJSON)
+		rw.Write([]byte(`{"predictions":[1]}`))
+	}))
+	defer model.Close()
+
+	var mu sync.Mutex
+	var events []loggedEvent
+	logSvc := newLogService(&mu, &events)
+	defer logSvc.Close()
+
+	inferenceGraph = &v1alpha1.InferenceGraphSpec{
+		Nodes: map[string]v1alpha1.InferenceRouter{
+			v1alpha1.GraphRootNodeName: {
+				RouterType: v1alpha1.Sequence,
+				Steps: []v1alpha1.InferenceStep{
+					{
+						StepName: "model1",
+						InferenceTarget: v1alpha1.InferenceTarget{
+							ServiceURL: model.URL,
+						},
+					},
+				},
+			},
+		},
+		Logger: &v1alpha1.LoggerSpec{
+			URL:  proto.String(logSvc.URL),
+			Mode: v1alpha1.LogAll,
+		},
+	}
+	sourceUri, _ := url.Parse("http://localhost:8080/")
+	var err error
+	graphLog, err = newGraphLogger(inferenceGraph.Logger, "mygraph", "default", sourceUri, 1,
+		kfslogger.DefaultDeliveryConfig(), zap.NewNop().Sugar())
+	assert.Nil(t, err)
+	defer func() {
+		graphLog = nil
+	}()
+	graphLog.dispatcher.Start()
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"instances":[[1,2,3]]}`))
+	req.Header.Set("Ce-Id", "1")
+	rr := httptest.NewRecorder()
+	graphHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.Nil(t, graphLog.dispatcher.Stop(ctx))
+
+	byId := map[string]map[string]loggedEvent{}
+	for _, event := range events {
+		if byId[event.id] == nil {
+			byId[event.id] = map[string]loggedEvent{}
+		}
+		byId[event.id][event.eventType] = event
+		assert.Equal(t, "mygraph", event.graphName)
+	}
+	assert.Len(t, events, 4)
+	graphEvents := byId["1"]
+	assert.Equal(t, `{"instances":[[1,2,3]]}`, graphEvents[kfslogger.CEInferenceRequest].body)
+	assert.Equal(t, `{"predictions":[1]}`, graphEvents[kfslogger.CEInferenceResponse].body)
+	assert.Equal(t, "200", graphEvents[kfslogger.CEInferenceResponse].statusCode)
+	assert.Equal(t, "", graphEvents[kfslogger.CEInferenceRequest].nodeName)
+
+	stepEvents := byId["1/root/model1"]
+	assert.Equal(t, v1alpha1.GraphRootNodeName, stepEvents[kfslogger.CEInferenceRequest].nodeName)
+	assert.Equal(t, "model1", stepEvents[kfslogger.CEInferenceRequest].stepName)
+	assert.Equal(t, `{"predictions":[1]}`, stepEvents[kfslogger.CEInferenceResponse].body)
+	assert.Equal(t, "200", stepEvents[kfslogger.CEInferenceResponse].statusCode)
+}
+
+func TestGraphLoggerFailedStep(t *testing.T) {
+	model := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+		rw.Header().Set("Content-Type", ContentTypeText)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		rw.Write([]byte("model not ready"))
+	}))
+	defer model.Close()
+
+	var mu sync.Mutex
+	var events []loggedEvent
+	logSvc := newLogService(&mu, &events)
+	defer logSvc.Close()
+
+	inferenceGraph = &v1alpha1.InferenceGraphSpec{
+		Nodes: map[string]v1alpha1.InferenceRouter{
+			v1alpha1.GraphRootNodeName: {
+				RouterType: v1alpha1.Sequence,
+				Steps: []v1alpha1.InferenceStep{
+					{
+						StepName:        "model1",
+						InferenceTarget: v1alpha1.InferenceTarget{ServiceURL: model.URL},
+					},
+				},
+			},
+		},
+		Logger: &v1alpha1.LoggerSpec{
+			URL:  proto.String(logSvc.URL),
+			Mode: v1alpha1.LogResponse,
+		},
+	}
+	var err error
+	graphLog, err = newGraphLogger(inferenceGraph.Logger, "mygraph", "default", graphSourceUri("mygraph", "default"), 1,
+		kfslogger.DefaultDeliveryConfig(), zap.NewNop().Sugar())
+	assert.Nil(t, err)
+	defer func() {
+		graphLog = nil
+	}()
+	graphLog.dispatcher.Start()
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"instances":[[1,2,3]]}`))
+	req.Header.Set("Ce-Id", "1")
+	rr := httptest.NewRecorder()
+	graphHandler(rr, req)
+	// the graph responds with the body of the last step whatever its status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "model not ready", rr.Body.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.Nil(t, graphLog.dispatcher.Stop(ctx))
+
+	assert.Len(t, events, 2)
+	for _, event := range events {
+		// the step response is logged with the status code of the service
+		if event.stepName == "model1" {
+			assert.Equal(t, "503", event.statusCode)
+		} else {
+			assert.Equal(t, "200", event.statusCode)
+		}
+		assert.Equal(t, ContentTypeJSON, event.contentType)
+		assert.Equal(t, "model not ready", event.body)
+	}
+}
+
+func TestGraphSourceUri(t *testing.T) {
+	assert.Equal(t, "http://mygraph.default/", graphSourceUri("mygraph", "default").String())
+	assert.Equal(t, "http://localhost:8080/", graphSourceUri("", "").String())
+}
+
+func TestGraphLoggerMode(t *testing.T) {
+	scenarios := map[string]struct {
+		mode     v1alpha1.LoggerType
+		expected v1alpha1.LoggerType
+	}{
+		"Default":  {mode: "", expected: v1alpha1.LogAll},
+		"Request":  {mode: v1alpha1.LogRequest, expected: v1alpha1.LogRequest},
+		"Response": {mode: v1alpha1.LogResponse, expected: v1alpha1.LogResponse},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			l, err := newGraphLogger(&v1alpha1.LoggerSpec{URL: proto.String("http://localhost:8081"), Mode: scenario.mode},
+				"mygraph", "default", nil, 1, kfslogger.DefaultDeliveryConfig(), zap.NewNop().Sugar())
+			assert.Nil(t, err)
+			assert.Equal(t, scenario.expected, l.mode)
+		})
+	}
+
+	_, err := newGraphLogger(&v1alpha1.LoggerSpec{URL: proto.String("http://localhost:8081"), Mode: "none"},
+		"mygraph", "default", nil, 1, kfslogger.DefaultDeliveryConfig(), zap.NewNop().Sugar())
+	assert.NotNil(t, err)
+	_, err = newGraphLogger(&v1alpha1.LoggerSpec{URL: proto.String("ftp://localhost")},
+		"mygraph", "default", nil, 1, kfslogger.DefaultDeliveryConfig(), zap.NewNop().Sugar())
+	assert.NotNil(t, err)
+}
+
+func TestRequestLoggerNil(t *testing.T) {
+	var l *graphLogger
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	reqLog := l.forRequest(req)
+	assert.Nil(t, reqLog)
+	// a nil request logger is a no-op so routing does not need to check whether the request is logged
+	reqLog.logRequest("root", "step", []byte(`{}`))
+	reqLog.logResponse("root", "step", ContentTypeJSON, []byte(`{}`), http.StatusOK, time.Millisecond)
+}
@@ -18,29 +18,35 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/kserve/kserve/pkg/constants"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/tidwall/gjson"
+	pkglogging "knative.dev/pkg/logging"
+	"knative.dev/pkg/signals"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"math/rand"
 
 	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	kfslogger "github.com/kserve/kserve/pkg/logger"
 	flag "github.com/spf13/pflag"
 )
 
 var log = logf.Log.WithName("InferenceGraphRouter")
 
-func callService(serviceUrl string, input []byte, headers http.Header) ([]byte, error) {
+func callService(serviceUrl string, input []byte, headers http.Header) ([]byte, int, error) {
 	req, err := http.NewRequest("POST", serviceUrl, bytes.NewBuffer(input))
 	for _, h := range headersToPropagate {
 		if values, ok := headers[h]; ok {
@@ -54,14 +60,14 @@ func callService(serviceUrl string, input []byte, headers http.Header) ([]byte,
 
 	if err != nil {
 		log.Error(err, "An error has occurred from service", "service", serviceUrl)
-		return nil, err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Error(err, "error while reading the response")
 	}
-	return body, err
+	return body, resp.StatusCode, err
 }
 
 func pickupRoute(routes []v1alpha1.InferenceStep) *v1alpha1.InferenceStep {
@@ -95,19 +101,19 @@ func timeTrack(start time.Time, name string) {
 	log.Info("elapsed time", "node", name, "time", elapsed)
 }
 
-func routeStep(nodeName string, graph v1alpha1.InferenceGraphSpec, input []byte, headers http.Header) ([]byte, error) {
+func routeStep(nodeName string, graph v1alpha1.InferenceGraphSpec, input []byte, headers http.Header, reqLog *requestLogger) ([]byte, error) {
 	defer timeTrack(time.Now(), nodeName)
 	currentNode := graph.Nodes[nodeName]
 
 	if currentNode.RouterType == v1alpha1.Splitter {
-		return executeStep(pickupRoute(currentNode.Steps), graph, input, headers)
+		return executeStep(nodeName, pickupRoute(currentNode.Steps), graph, input, headers, reqLog)
 	}
 	if currentNode.RouterType == v1alpha1.Switch {
 		route := pickupRouteByCondition(input, currentNode.Steps)
 		if route == nil {
 			return input, nil //TODO maybe should fail in this case?
 		}
-		return executeStep(route, graph, input, headers)
+		return executeStep(nodeName, route, graph, input, headers, reqLog)
 	}
 	if currentNode.RouterType == v1alpha1.Ensemble {
 		ensembleRes := make([]chan map[string]interface{}, len(currentNode.Steps))
@@ -117,7 +123,7 @@ func routeStep(nodeName string, graph v1alpha1.InferenceGraphSpec, input []byte,
 			resultChan := make(chan map[string]interface{})
 			ensembleRes[i] = resultChan
 			go func() {
-				output, err := executeStep(step, graph, input, headers)
+				output, err := executeStep(nodeName, step, graph, input, headers, reqLog)
 				if err == nil {
 					var res map[string]interface{}
 					if err = json.Unmarshal(output, &res); err == nil {
@@ -162,7 +168,7 @@ func routeStep(nodeName string, graph v1alpha1.InferenceGraphSpec, input []byte,
 					return responseBytes, nil
 				}
 			}
-			if responseBytes, err = executeStep(step, graph, request, headers); err != nil {
+			if responseBytes, err = executeStep(nodeName, step, graph, request, headers, reqLog); err != nil {
 				return nil, err
 			}
 		}
@@ -172,32 +178,92 @@ func routeStep(nodeName string, graph v1alpha1.InferenceGraphSpec, input []byte,
 	return nil, fmt.Errorf("invalid route type: %v", currentNode.RouterType)
 }
 
-func executeStep(step *v1alpha1.InferenceStep, graph v1alpha1.InferenceGraphSpec, input []byte, headers http.Header) ([]byte, error) {
+func executeStep(nodeName string, step *v1alpha1.InferenceStep, graph v1alpha1.InferenceGraphSpec, input []byte, headers http.Header, reqLog *requestLogger) ([]byte, error) {
+	stepName := stepLogName(step)
+	reqLog.logRequest(nodeName, stepName, input)
+	start := time.Now()
+	var response []byte
+	var err error
+	statusCode := http.StatusOK
 	if step.NodeName != "" {
 		// when nodeName is specified make a recursive call for routing to next step
-		return routeStep(step.NodeName, graph, input, headers)
+		response, err = routeStep(step.NodeName, graph, input, headers, reqLog)
+	} else {
+		response, statusCode, err = callService(step.ServiceURL, input, headers)
 	}
-	return callService(step.ServiceURL, input, headers)
+	if err != nil {
+		reqLog.logResponse(nodeName, stepName, ContentTypeText, []byte(err.Error()), http.StatusInternalServerError, time.Since(start))
+		return nil, err
+	}
+	reqLog.logResponse(nodeName, stepName, ContentTypeJSON, response, statusCode, time.Since(start))
+	return response, nil
 }
 
-var inferenceGraph *v1alpha1.InferenceGraphSpec
+var (
+	inferenceGraph *v1alpha1.InferenceGraphSpec
+	// graphLog is nil when the graph has no logger
+	graphLog *graphLogger
+)
 
 func graphHandler(w http.ResponseWriter, req *http.Request) {
 	inputBytes, _ := ioutil.ReadAll(req.Body)
-	if response, err := routeStep(v1alpha1.GraphRootNodeName, *inferenceGraph, inputBytes, req.Header); err != nil {
+	reqLog := graphLog.forRequest(req)
+	reqLog.logRequest("", "", inputBytes)
+	start := time.Now()
+	if response, err := routeStep(v1alpha1.GraphRootNodeName, *inferenceGraph, inputBytes, req.Header, reqLog); err != nil {
 		log.Error(err, "failed to process request")
+		errorBytes := []byte(fmt.Sprintf("Failed to process request: %v", err))
 		w.WriteHeader(500) //TODO status code tbd
-		w.Write([]byte(fmt.Sprintf("Failed to process request: %v", err)))
+		w.Write(errorBytes)
+		reqLog.logResponse("", "", ContentTypeText, errorBytes, http.StatusInternalServerError, time.Since(start))
 	} else {
 		w.Write(response)
+		reqLog.logResponse("", "", ContentTypeJSON, response, http.StatusOK, time.Since(start))
 	}
 }
 
 var (
 	jsonGraph          = flag.String("graph-json", "", "serialized json graph def")
+	graphName          = flag.String("graph-name", "", "name of the inference graph, set on the logged events")
+	namespace          = flag.String("namespace", "", "namespace of the inference graph, set on the logged events")
+	logWorkers         = flag.Int("log-workers", 5, "Number of workers delivering the logged events")
+	logCredentials     = flag.String("log-credentials-dir", "", "Dir holding the TLS and auth credentials of the log url")
+	logFlushTimeout    = flag.Duration("log-flush-timeout", 10*time.Second, "Max time to deliver the queued log events on shutdown")
 	headersToPropagate = strings.Split(os.Getenv(constants.RouterHeadersPropagateEnvVar), ",")
 )
 
+// graphSourceUri is the source of the graph events, the address of the graph service when the graph name and
+// namespace are known and the local router otherwise
+func graphSourceUri(graphName string, namespace string) *url.URL {
+	if graphName == "" || namespace == "" {
+		return &url.URL{Scheme: "http", Host: "localhost:8080", Path: "/"}
+	}
+	return &url.URL{Scheme: "http", Host: graphName + "." + namespace, Path: "/"}
+}
+
+// startGraphLogger starts the dispatcher of the graph events, it returns nil when the graph has no logger
+func startGraphLogger(spec *v1alpha1.LoggerSpec) (*graphLogger, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	logger, _ := pkglogging.NewLogger("", "")
+	deliveryConfig := kfslogger.DefaultDeliveryConfig()
+	if *logCredentials != "" {
+		credentials, err := kfslogger.LoadCredentials(*logCredentials)
+		if err != nil {
+			return nil, err
+		}
+		deliveryConfig.Credentials = credentials
+	}
+	l, err := newGraphLogger(spec, *graphName, *namespace, graphSourceUri(*graphName, *namespace), *logWorkers,
+		deliveryConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+	l.dispatcher.Start()
+	return l, nil
+}
+
 func main() {
 	flag.Parse()
 	logf.SetLogger(zap.New())
@@ -208,11 +274,37 @@ func main() {
 		os.Exit(1)
 	}
 
+	graphLog, err = startGraphLogger(inferenceGraph.Logger)
+	if err != nil {
+		log.Error(err, "failed to start inference graph logger")
+		os.Exit(1)
+	}
+
 	http.HandleFunc("/", graphHandler)
 
-	err = http.ListenAndServe(":8080", nil)
-	if err != nil {
+	server := &http.Server{Addr: ":8080"}
+	errCh := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+	select {
+	case err = <-errCh:
 		log.Error(err, "failed to listen on 8080")
 		os.Exit(1)
+	case <-signals.NewContext().Done():
+		log.Info("Received TERM signal, shutting down")
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Error(err, "failed to shutdown server")
+		}
+		if graphLog != nil {
+			// the server no longer takes requests so every graph event is queued, flush them before exiting
+			flushCtx, cancel := context.WithTimeout(context.Background(), *logFlushTimeout)
+			if err := graphLog.dispatcher.Stop(flushCtx); err != nil {
+				log.Error(err, "failed to flush log events")
+			}
+			cancel()
+		}
 	}
 }
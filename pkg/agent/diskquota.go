@@ -0,0 +1,270 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kserve/kserve/pkg/agent/storage"
+)
+
+// modelPathRegex matches the model name of the v1 and v2 inference paths,
+// e.g. /v1/models/{name}:predict and /v2/models/{name}/infer
+var modelPathRegex = regexp.MustCompile(`^/v[12]/models/([^/:]+)`)
+
+// DiskQuota bounds the size of the model dir. Space for a model is reserved before it is downloaded, the least
+// recently requested models are evicted when the reservation does not fit. A nil DiskQuota has no limit.
+type DiskQuota struct {
+	ModelDir string
+	// Limit is the max size of the model dir in bytes
+	Limit int64
+
+	mu            sync.Mutex
+	lastRequested map[string]time.Time
+	// inFlight holds the size reserved for the models being downloaded and loaded, they are never evicted
+	inFlight map[string]int64
+	// evicting holds the models chosen for eviction until they are forgotten, their space counts as freed
+	evicting map[string]bool
+	now      func() time.Time
+}
+
+func NewDiskQuota(modelDir string, limit int64) *DiskQuota {
+	return &DiskQuota{
+		ModelDir:      modelDir,
+		Limit:         limit,
+		lastRequested: map[string]time.Time{},
+		inFlight:      map[string]int64{},
+		evicting:      map[string]bool{},
+		now:           time.Now,
+	}
+}
+
+// Touch records a request for the model. The model name comes from the request path, a model which is neither
+// tracked by the quota nor in the model dir is ignored so that requests for unknown models do not grow the quota.
+func (q *DiskQuota) Touch(modelName string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	_, requested := q.lastRequested[modelName]
+	_, reserved := q.inFlight[modelName]
+	q.mu.Unlock()
+	// the models synced from the model dir on startup are only tracked once they are requested
+	if !requested && !reserved && !q.onDisk(modelName) {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.evicting[modelName] {
+		return
+	}
+	q.lastRequested[modelName] = q.now()
+}
+
+// onDisk returns true when the model has a dir in the model dir
+func (q *DiskQuota) onDisk(modelName string) bool {
+	if modelName == "" || modelName == "." || modelName == ".." || strings.ContainsAny(modelName, `/\`) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(q.ModelDir, modelName))
+	return err == nil && info.IsDir()
+}
+
+// Reserve reserves size bytes of the model and returns the least recently requested models on disk which have to
+// be evicted for the reservation to fit. The caller evicts them without holding the quota and forgets them once
+// they are evicted, until then they are not chosen again and their space counts as freed. A model can be reserved
+// again with its actual size once it is downloaded. An error is returned when the model does not fit even with
+// every other model evicted.
+func (q *DiskQuota) Reserve(modelName string, size int64) ([]string, error) {
+	if q == nil || q.Limit <= 0 {
+		return nil, nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight[modelName] = size
+	usage, candidates, err := q.usage()
+	if err != nil {
+		return nil, err
+	}
+	// do not evict any model when the reservation does not fit anyway
+	evictable := int64(0)
+	for _, candidate := range candidates {
+		evictable += candidate.size
+	}
+	if usage-evictable > q.Limit {
+		delete(q.inFlight, modelName)
+		return nil, fmt.Errorf("model %s needs %d bytes which exceeds the model dir quota of %d bytes", modelName, size, q.Limit)
+	}
+	var evicted []string
+	for usage > q.Limit {
+		candidate := candidates[0]
+		candidates = candidates[1:]
+		q.evicting[candidate.name] = true
+		delete(q.lastRequested, candidate.name)
+		evicted = append(evicted, candidate.name)
+		usage -= candidate.size
+	}
+	return evicted, nil
+}
+
+// Release ends the reservation of the model once it is loaded or failed, a loaded model counts as requested
+// so that it is not evicted before it is used
+func (q *DiskQuota) Release(modelName string, loaded bool) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inFlight, modelName)
+	if _, ok := q.lastRequested[modelName]; loaded && !ok {
+		q.lastRequested[modelName] = q.now()
+	}
+}
+
+// Forget drops the model once it is removed or evicted
+func (q *DiskQuota) Forget(modelName string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inFlight, modelName)
+	delete(q.evicting, modelName)
+	delete(q.lastRequested, modelName)
+}
+
+type evictionCandidate struct {
+	name          string
+	size          int64
+	lastRequested time.Time
+}
+
+// usage returns the size of the model dir with the reservations in place of the models in flight and without the
+// models being evicted, and the models which can be evicted, least recently requested first
+func (q *DiskQuota) usage() (int64, []evictionCandidate, error) {
+	var usage int64
+	for _, size := range q.inFlight {
+		usage += size
+	}
+	entries, err := ioutil.ReadDir(q.ModelDir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, nil, fmt.Errorf("while reading model dir: %s", err)
+	}
+	var candidates []evictionCandidate
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			usage += entry.Size()
+			continue
+		}
+		if entry.Name() == PreviousDirName {
+			// the previous versions of the models being updated are never evicted
+			size, err := dirSize(filepath.Join(q.ModelDir, PreviousDirName))
+			if err != nil {
+				return 0, nil, err
+			}
+			usage += size
+			continue
+		}
+		if entry.Name() == storage.StagingDirName {
+			size, err := q.stagingSize()
+			if err != nil {
+				return 0, nil, err
+			}
+			usage += size
+			continue
+		}
+		if _, ok := q.inFlight[entry.Name()]; ok || q.evicting[entry.Name()] {
+			continue
+		}
+		size, err := dirSize(filepath.Join(q.ModelDir, entry.Name()))
+		if err != nil {
+			return 0, nil, err
+		}
+		usage += size
+		candidates = append(candidates, evictionCandidate{
+			name:          entry.Name(),
+			size:          size,
+			lastRequested: q.lastRequested[entry.Name()],
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].lastRequested.Before(candidates[j].lastRequested)
+	})
+	return usage, candidates, nil
+}
+
+// stagingSize returns the size of the partial downloads left in the staging dir, the downloads of the models in
+// flight are covered by their reservations
+func (q *DiskQuota) stagingSize() (int64, error) {
+	stagingDir := filepath.Join(q.ModelDir, storage.StagingDirName)
+	entries, err := ioutil.ReadDir(stagingDir)
+	if err != nil {
+		return 0, fmt.Errorf("while reading staging dir: %s", err)
+	}
+	var usage int64
+	for _, entry := range entries {
+		if _, ok := q.inFlight[entry.Name()]; ok {
+			continue
+		}
+		size, err := dirSize(filepath.Join(stagingDir, entry.Name()))
+		if err != nil {
+			return 0, err
+		}
+		usage += size
+	}
+	return usage, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("while measuring %s: %s", dir, err)
+	}
+	return size, nil
+}
+
+// TrackModelRequests records the inference requests of the models on the quota so that the least recently
+// requested models are evicted first
+func TrackModelRequests(next http.Handler, quota *DiskQuota) http.Handler {
+	if quota == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if match := modelPathRegex.FindStringSubmatch(r.URL.Path); match != nil {
+			quota.Touch(match[1])
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func writeModel(g *gomega.WithT, modelDir string, modelName string, size int) {
+	g.Expect(os.MkdirAll(filepath.Join(modelDir, modelName), os.ModePerm)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(modelDir, modelName, "model.bin"), make([]byte, size), 0644)).
+		To(gomega.Succeed())
+}
+
+func newTestDiskQuota(g *gomega.WithT, limit int64) (*DiskQuota, func(time.Duration)) {
+	modelDir, err := ioutil.TempDir("", "diskquota")
+	g.Expect(err).To(gomega.BeNil())
+	quota := NewDiskQuota(modelDir, limit)
+	now := time.Unix(0, 0)
+	quota.now = func() time.Time { return now }
+	return quota, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestDiskQuotaReserve(t *testing.T) {
+	scenarios := map[string]struct {
+		limit           int64
+		size            int64
+		requests        []string
+		expectedEvicted []string
+		expectedErr     bool
+	}{
+		"Fits": {
+			limit:           400,
+			size:            100,
+			requests:        []string{"model1", "model2"},
+			expectedEvicted: nil,
+		},
+		"EvictsLeastRecentlyRequested": {
+			limit:           250,
+			size:            100,
+			requests:        []string{"model2", "model1"},
+			expectedEvicted: []string{"model2"},
+		},
+		"EvictsUnrequestedFirst": {
+			limit:           250,
+			size:            100,
+			requests:        []string{"model1"},
+			expectedEvicted: []string{"model2"},
+		},
+		"EvictsUntilItFits": {
+			limit:           150,
+			size:            100,
+			requests:        []string{"model1", "model2"},
+			expectedEvicted: []string{"model1", "model2"},
+		},
+		"ExceedsQuota": {
+			limit:           150,
+			size:            200,
+			requests:        []string{"model1", "model2"},
+			expectedEvicted: nil,
+			expectedErr:     true,
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			quota, advance := newTestDiskQuota(g, scenario.limit)
+			defer os.RemoveAll(quota.ModelDir)
+			writeModel(g, quota.ModelDir, "model1", 100)
+			writeModel(g, quota.ModelDir, "model2", 100)
+			for _, modelName := range scenario.requests {
+				advance(time.Second)
+				quota.Touch(modelName)
+			}
+
+			evicted, err := quota.Reserve("model3", scenario.size)
+			if scenario.expectedErr {
+				g.Expect(err).NotTo(gomega.BeNil())
+			} else {
+				g.Expect(err).To(gomega.BeNil())
+			}
+			g.Expect(evicted).To(gomega.Equal(scenario.expectedEvicted))
+		})
+	}
+}
+
+func TestDiskQuotaInFlight(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	quota, advance := newTestDiskQuota(g, 250)
+	defer os.RemoveAll(quota.ModelDir)
+	// model1 is downloading, its reservation counts in place of its files
+	evicted, err := quota.Reserve("model1", 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(evicted).To(gomega.BeEmpty())
+	writeModel(g, quota.ModelDir, "model1", 100)
+	evicted, err = quota.Reserve("model2", 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(evicted).To(gomega.BeEmpty())
+	writeModel(g, quota.ModelDir, "model2", 100)
+
+	// models in flight are never evicted
+	_, err = quota.Reserve("model3", 100)
+	g.Expect(err).NotTo(gomega.BeNil())
+
+	// a loaded model counts as requested when it is released
+	quota.Release("model1", true)
+	advance(time.Second)
+	quota.Release("model2", true)
+	evicted, err = quota.Reserve("model3", 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(evicted).To(gomega.Equal([]string{"model1"}))
+
+	// a model being evicted is not chosen again and its space counts as freed
+	evicted, err = quota.Reserve("model4", 50)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(evicted).To(gomega.BeEmpty())
+	g.Expect(os.RemoveAll(filepath.Join(quota.ModelDir, "model1"))).To(gomega.Succeed())
+	quota.Forget("model1")
+	g.Expect(quota.evicting).To(gomega.BeEmpty())
+}
+
+func TestDiskQuotaUnlimited(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var quota *DiskQuota
+	quota.Touch("model1")
+	quota.Release("model1", true)
+	quota.Forget("model1")
+	evicted, err := quota.Reserve("model1", 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(evicted).To(gomega.BeEmpty())
+	evicted, err = NewDiskQuota("/does/not/exist", 0).Reserve("model1", 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(evicted).To(gomega.BeEmpty())
+}
+
+func TestTrackModelRequests(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	quota, _ := newTestDiskQuota(g, 100)
+	defer os.RemoveAll(quota.ModelDir)
+	writeModel(g, quota.ModelDir, "model1", 10)
+	writeModel(g, quota.ModelDir, "model2", 10)
+	handler := TrackModelRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), quota)
+
+	// the requests of unknown models are not tracked
+	for _, path := range []string{"/v1/models/model1:predict", "/v2/models/model2/infer", "/v2/health/ready",
+		"/v1/models/unknown:predict", "/v2/models/../infer", "/v1/models/model1.bin:predict"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+	}
+	g.Expect(quota.lastRequested).To(gomega.HaveLen(2))
+	g.Expect(quota.lastRequested).To(gomega.HaveKey("model1"))
+	g.Expect(quota.lastRequested).To(gomega.HaveKey("model2"))
+}
+
+func TestDiskQuotaTouch(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	quota, _ := newTestDiskQuota(g, 350)
+	defer os.RemoveAll(quota.ModelDir)
+	writeModel(g, quota.ModelDir, "model1", 100)
+	writeModel(g, quota.ModelDir, "model2", 100)
+
+	quota.Touch("model3")
+	g.Expect(quota.lastRequested).To(gomega.BeEmpty())
+	// a model being downloaded is tracked before it is on disk
+	evicted, err := quota.Reserve("model3", 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(evicted).To(gomega.BeEmpty())
+	quota.Touch("model3")
+	g.Expect(quota.lastRequested).To(gomega.HaveKey("model3"))
+
+	// a model being evicted is not tracked again
+	quota.Touch("model1")
+	quota.Release("model3", true)
+	writeModel(g, quota.ModelDir, "model3", 100)
+	evicted, err = quota.Reserve("model4", 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(evicted).To(gomega.Equal([]string{"model2"}))
+	quota.Touch("model2")
+	g.Expect(quota.lastRequested).NotTo(gomega.HaveKey("model2"))
+}
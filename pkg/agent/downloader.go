@@ -26,44 +26,77 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/kserve/kserve/pkg/agent/status"
 	"github.com/kserve/kserve/pkg/agent/storage"
 	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
+const (
+	// SuccessFilePrefix prefixes the name of the success file written with the model spec once a model is downloaded
+	SuccessFilePrefix = "SUCCESS."
+	// PreviousDirName is the dir of the model dir which keeps the version of a model replaced by an update until the
+	// new version is loaded
+	PreviousDirName = ".previous"
+)
+
+// successFileName returns the name of the success file of the model spec, which holds the version of the spec that
+// the agent also reports in the model status
+func successFileName(modelSpec *v1alpha1.ModelSpec) string {
+	return SuccessFilePrefix + status.SpecVersion(modelSpec)
+}
+
 type Downloader struct {
 	ModelDir  string
 	mu        sync.Mutex
 	Providers map[storage.Protocol]storage.Provider
-	Logger    *zap.SugaredLogger
+	// DownloadOptions are passed to the providers created by the downloader
+	DownloadOptions storage.DownloadOptions
+	Logger          *zap.SugaredLogger
 }
 
+// DownloadModel downloads the model into the staging dir and moves it to the model dir once it is complete along
+// with its success file, so that the model dir never holds a partially downloaded model
 func (d *Downloader) DownloadModel(modelName string, modelSpec *v1alpha1.ModelSpec) error {
+	return d.downloadModel(modelName, modelSpec, false)
+}
+
+// RedownloadModel downloads the model like DownloadModel even when its success file exists, the downloaded version
+// replaces the model once it is complete
+func (d *Downloader) RedownloadModel(modelName string, modelSpec *v1alpha1.ModelSpec) error {
+	return d.downloadModel(modelName, modelSpec, true)
+}
+
+func (d *Downloader) downloadModel(modelName string, modelSpec *v1alpha1.ModelSpec, force bool) error {
 	if modelSpec != nil {
-		sha256 := storage.AsSha256(modelSpec)
-		successFile := filepath.Join(d.ModelDir, modelName,
-			fmt.Sprintf("SUCCESS.%s", sha256))
+		successFile := filepath.Join(d.ModelDir, modelName, successFileName(modelSpec))
 		d.Logger.Infof("Downloading %s to model dir %s", modelSpec.StorageURI, d.ModelDir)
 		// Download if the event there is a success file and the event is one which we wish to Download
 		_, err := os.Stat(successFile)
-		if os.IsNotExist(err) {
-			if err := d.download(modelName, modelSpec.StorageURI); err != nil {
+		if force || os.IsNotExist(err) {
+			stagingDir := filepath.Join(d.ModelDir, storage.StagingDirName)
+			if err := d.download(stagingDir, modelName, modelSpec.StorageURI); err != nil {
 				return errors.Wrapf(err, "failed to download model")
 			}
-			file, createErr := storage.Create(successFile)
-			defer file.Close()
+			stagingSuccessFile := filepath.Join(stagingDir, modelName, filepath.Base(successFile))
+			file, createErr := storage.Create(stagingSuccessFile)
 			if createErr != nil {
 				return errors.Wrapf(createErr, "failed to create success file")
 			}
+			file.Close()
 			encodedJson, err := json.Marshal(modelSpec)
 			if err != nil {
-				return errors.Wrapf(createErr, "failed to encode model spec")
+				return errors.Wrapf(err, "failed to encode model spec")
 			}
-			err = ioutil.WriteFile(successFile, encodedJson, 0644)
+			err = ioutil.WriteFile(stagingSuccessFile, encodedJson, 0644)
 			if err != nil {
-				return errors.Wrapf(createErr, "failed to write the success file")
+				return errors.Wrapf(err, "failed to write the success file")
+			}
+			if err := d.moveInPlace(stagingDir, modelName); err != nil {
+				return errors.Wrapf(err, "failed to move model to model dir")
 			}
 			d.Logger.Infof("Creating successFile %s", successFile)
 		} else if err == nil {
@@ -75,20 +108,97 @@ func (d *Downloader) DownloadModel(modelName string, modelSpec *v1alpha1.ModelSp
 	return nil
 }
 
-func (d *Downloader) download(modelName string, storageUri string) error {
+// moveInPlace moves the model downloaded to the staging dir to the model dir, the version it replaces is kept in the
+// previous dir until the new version is loaded
+func (d *Downloader) moveInPlace(stagingDir string, modelName string) error {
+	modelPath := filepath.Join(d.ModelDir, modelName)
+	if _, err := os.Stat(modelPath); err == nil {
+		previousPath := d.previousPath(modelName)
+		if err := os.RemoveAll(previousPath); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(previousPath), 0777); err != nil {
+			return err
+		}
+		if err := os.Rename(modelPath, previousPath); err != nil {
+			return err
+		}
+	}
+	return os.Rename(filepath.Join(stagingDir, modelName), modelPath)
+}
+
+func (d *Downloader) previousPath(modelName string) string {
+	return filepath.Join(d.ModelDir, PreviousDirName, modelName)
+}
+
+// RemovePreviousVersion deletes the version of the model replaced by the last download once the new version is loaded
+func (d *Downloader) RemovePreviousVersion(modelName string) error {
+	return os.RemoveAll(d.previousPath(modelName))
+}
+
+// RestorePreviousVersion moves the version of the model replaced by the last download back in place, it returns false
+// when there is no previous version
+func (d *Downloader) RestorePreviousVersion(modelName string) (bool, error) {
+	previousPath := d.previousPath(modelName)
+	if _, err := os.Stat(previousPath); os.IsNotExist(err) {
+		return false, nil
+	}
+	modelPath := filepath.Join(d.ModelDir, modelName)
+	if err := os.RemoveAll(modelPath); err != nil {
+		return false, err
+	}
+	if err := os.Rename(previousPath, modelPath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveStaging removes the partial download of the model
+func (d *Downloader) RemoveStaging(modelName string) error {
+	return os.RemoveAll(filepath.Join(d.ModelDir, storage.StagingDirName, modelName))
+}
+
+// ModelSize returns the size of the model from the metadata of its storage, -1 when the provider of the storage
+// can not tell the size before the download
+func (d *Downloader) ModelSize(modelSpec *v1alpha1.ModelSpec) (int64, error) {
+	protocol, err := extractProtocol(modelSpec.StorageURI)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unsupported protocol")
+	}
+	d.mu.Lock()
+	provider, err := storage.GetProvider(d.Providers, protocol, d.DownloadOptions)
+	d.mu.Unlock()
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to create or get provider for protocol %s", protocol)
+	}
+	sizer, ok := provider.(storage.Sizer)
+	if !ok {
+		return -1, nil
+	}
+	return sizer.ModelSize(modelSpec.StorageURI)
+}
+
+func (d *Downloader) download(modelDir string, modelName string, storageUri string) error {
 	protocol, err := extractProtocol(storageUri)
 	if err != nil {
 		return errors.Wrapf(err, "unsupported protocol")
 	}
 	d.mu.Lock()
-	provider, err := storage.GetProvider(d.Providers, protocol)
+	provider, err := storage.GetProvider(d.Providers, protocol, d.DownloadOptions)
 	d.mu.Unlock()
 	if err != nil {
 		return errors.Wrapf(err, "unable to create or get provider for protocol %s", protocol)
 	}
-	if err := provider.DownloadModel(d.ModelDir, modelName, storageUri); err != nil {
+	start := time.Now()
+	err = provider.DownloadModel(modelDir, modelName, storageUri)
+	downloadDurationSeconds.WithLabelValues(protocolLabel(protocol), resultLabel(err)).Observe(time.Since(start).Seconds())
+	downloadsTotal.WithLabelValues(protocolLabel(protocol), resultLabel(err)).Inc()
+	if err != nil {
 		return errors.Wrapf(err, "failed to download model")
 	}
+	if size, err := dirSize(filepath.Join(modelDir, modelName)); err == nil {
+		downloadBytesTotal.WithLabelValues(protocolLabel(protocol)).Add(float64(size))
+	}
 	return nil
 }
 
@@ -108,6 +218,11 @@ func extractProtocol(storageURI string) (storage.Protocol, error) {
 		return "", fmt.Errorf("there is no protocol specified for the storageUri")
 	}
 
+	// the blobs of azure are downloaded with the azure api rather than as a single https file
+	if storage.IsAzureBlobURI(storageURI) {
+		return storage.AzureBlob, nil
+	}
+
 	for _, prefix := range storage.SupportedProtocols {
 		if strings.HasPrefix(storageURI, string(prefix)) {
 			return prefix, nil
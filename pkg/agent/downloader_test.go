@@ -17,9 +17,12 @@ limitations under the License.
 package agent
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	logger "log"
 	"os"
+	"path/filepath"
 
 	"github.com/kserve/kserve/pkg/agent/mocks"
 	"github.com/kserve/kserve/pkg/agent/storage"
@@ -99,4 +102,26 @@ var _ = Describe("Downloader", func() {
 			Expect(err).ShouldNot(BeNil())
 		})
 	})
+
+	Context("When the download completes", func() {
+		It("Should move the model from the staging dir to the model dir", func() {
+			ctx := context.Background()
+			client := mocks.NewMockClient()
+			bkt := client.Bucket("testBucket")
+			Expect(bkt.Create(ctx, "test", nil)).To(Succeed())
+			w := bkt.Object("model1/model.pt").NewWriter(ctx)
+			fmt.Fprint(w, "Model Contents")
+			downloader.Providers[storage.GCS] = &storage.GCSProvider{Client: client}
+
+			modelSpec := &v1alpha1.ModelSpec{
+				StorageURI: "gs://testBucket/model1",
+				Framework:  "sklearn",
+			}
+			Expect(downloader.DownloadModel("model1", modelSpec)).To(Succeed())
+			Expect(filepath.Join(downloader.ModelDir, "model1", "model.pt")).To(BeAnExistingFile())
+			successFile := filepath.Join(downloader.ModelDir, "model1", successFileName(modelSpec))
+			Expect(successFile).To(BeAnExistingFile())
+			Expect(filepath.Join(downloader.ModelDir, storage.StagingDirName, "model1")).NotTo(BeADirectory())
+		})
+	})
 })
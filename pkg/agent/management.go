@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kserve/kserve/pkg/agent/status"
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// ManagementPath is the path of the model management api served by the agent
+	ManagementPath = "/v1/agent/models"
+	// repullSuffix is the suffix of the path of a model which requests a re-pull
+	repullSuffix = "/repull"
+)
+
+// ModelInfo is the state of a model pulled by the agent as served by the model management api
+type ModelInfo struct {
+	Name string `json:"name"`
+	// Spec is nil for the models which are no longer in the model config but not removed yet
+	Spec *v1alpha1.ModelSpec `json:"spec,omitempty"`
+	// SizeBytes is the size of the model in the model dir, -1 when it is not downloaded
+	SizeBytes int64               `json:"sizeBytes"`
+	Status    *status.ModelStatus `json:"status,omitempty"`
+	// OpStats is the number of ops of each type completed for the model
+	OpStats map[OpType]int `json:"opStats,omitempty"`
+}
+
+// ModelManager serves the model management api of the agent. The models tracked by the watcher and the models
+// still known to the puller are listed on ManagementPath, a single model on ManagementPath/{name}, and a model
+// is downloaded and loaded again with a POST on ManagementPath/{name}/repull.
+type ModelManager struct {
+	watcher     *Watcher
+	puller      *Puller
+	modelStatus *status.Store
+	logger      *zap.SugaredLogger
+}
+
+func NewModelManager(watcher *Watcher, puller *Puller, modelStatus *status.Store, logger *zap.SugaredLogger) *ModelManager {
+	return &ModelManager{
+		watcher:     watcher,
+		puller:      puller,
+		modelStatus: modelStatus,
+		logger:      logger,
+	}
+}
+
+// ListModels returns the models sorted by name
+func (m *ModelManager) ListModels() []ModelInfo {
+	specs := m.watcher.TrackedModels()
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	// the models removed from the model config are listed until they are unloaded
+	for _, modelStatus := range m.modelStatus.List() {
+		if _, ok := specs[modelStatus.Name]; !ok {
+			names = append(names, modelStatus.Name)
+		}
+	}
+	sort.Strings(names)
+	models := make([]ModelInfo, 0, len(names))
+	for _, name := range names {
+		models = append(models, m.modelInfo(name, specs[name]))
+	}
+	return models
+}
+
+// GetModel returns the model and whether the agent knows about it
+func (m *ModelManager) GetModel(name string) (ModelInfo, bool) {
+	spec, tracked := m.watcher.TrackedModels()[name]
+	if _, ok := m.modelStatus.Get(name); !tracked && !ok {
+		return ModelInfo{}, false
+	}
+	return m.modelInfo(name, spec), true
+}
+
+func (m *ModelManager) modelInfo(name string, spec *v1alpha1.ModelSpec) ModelInfo {
+	info := ModelInfo{
+		Name:      name,
+		Spec:      spec,
+		SizeBytes: -1,
+		OpStats:   m.puller.OpStats(name),
+	}
+	if modelStatus, ok := m.modelStatus.Get(name); ok {
+		info.Status = &modelStatus
+	}
+	modelPath := filepath.Join(m.puller.Downloader.ModelDir, name)
+	if _, err := os.Stat(modelPath); err == nil {
+		size, err := dirSize(modelPath)
+		if err != nil {
+			m.logger.Errorf("Failed to get the size of model %s with err %v", name, err)
+		} else {
+			info.SizeBytes = size
+		}
+	}
+	return info
+}
+
+func (m *ModelManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, ManagementPath), "/")
+	if strings.HasSuffix(r.URL.Path, repullSuffix) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		m.repull(w, strings.TrimSuffix(name, repullSuffix))
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body interface{}
+	if name == "" {
+		body = m.ListModels()
+	} else {
+		model, ok := m.GetModel(name)
+		if !ok {
+			http.Error(w, "model not found", http.StatusNotFound)
+			return
+		}
+		body = model
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (m *ModelManager) repull(w http.ResponseWriter, name string) {
+	err := m.watcher.Repull(name)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, ErrModelNotTracked):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrWatcherNotStarted):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, ErrRepullPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrModelEventsFull):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kserve/kserve/pkg/agent/status"
+	"github.com/kserve/kserve/pkg/agent/storage"
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	"github.com/kserve/kserve/pkg/modelconfig"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+func TestModelManager(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var requests int32
+	storageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, "Model Contents")
+	}))
+	defer storageServer.Close()
+	modelDir, err := ioutil.TempDir("", "management")
+	g.Expect(err).To(gomega.BeNil())
+	defer os.RemoveAll(modelDir)
+	zapLogger, _ := zap.NewProduction()
+	sugar := zapLogger.Sugar()
+
+	watcher := NewWatcher(filepath.Join(modelDir, "configs"), modelDir, sugar)
+	watcher.parseConfig(modelconfig.ModelConfigs{
+		{
+			Name: "model1",
+			Spec: v1alpha1.ModelSpec{
+				StorageURI: storageServer.URL + "/model.bin",
+				Framework:  "sklearn",
+			},
+		},
+	}, false)
+	modelStatus := status.NewStore()
+	downloader := &Downloader{
+		ModelDir: modelDir,
+		Providers: map[storage.Protocol]storage.Provider{
+			storage.HTTP: &storage.HTTPSProvider{Client: storageServer.Client()},
+		},
+		Logger: sugar,
+	}
+	downloads := testutil.ToFloat64(downloadsTotal.WithLabelValues("http", ResultSuccess))
+	downloadBytes := testutil.ToFloat64(downloadBytesTotal.WithLabelValues("http"))
+	puller := NewPuller(downloader, testModelServer, nil, modelStatus, RetryConfig{}, sugar)
+	go puller.processCommands(watcher.ModelEvents)
+	manager := NewModelManager(&watcher, puller, modelStatus, sugar)
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		manager.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	g.Eventually(func() int { return puller.OpStats("model1")[Add] }).Should(gomega.Equal(1))
+	w := serve(http.MethodGet, ManagementPath)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+	var models []ModelInfo
+	g.Expect(json.NewDecoder(w.Body).Decode(&models)).To(gomega.Succeed())
+	g.Expect(models).To(gomega.HaveLen(1))
+	g.Expect(models[0].Name).To(gomega.Equal("model1"))
+	g.Expect(models[0].Spec.StorageURI).To(gomega.Equal(storageServer.URL + "/model.bin"))
+	// the model file and its success file
+	g.Expect(models[0].SizeBytes).To(gomega.BeNumerically(">", len("Model Contents")))
+	// no model server runs in the tests
+	g.Expect(models[0].Status.State).To(gomega.Equal(status.ModelFailedToLoad))
+	g.Expect(models[0].Status.LastError).NotTo(gomega.BeEmpty())
+	g.Expect(models[0].OpStats).To(gomega.Equal(map[OpType]int{Add: 1}))
+
+	g.Expect(testutil.ToFloat64(downloadsTotal.WithLabelValues("http", ResultSuccess))).To(gomega.Equal(downloads + 1))
+	g.Expect(testutil.ToFloat64(downloadBytesTotal.WithLabelValues("http"))).
+		To(gomega.Equal(downloadBytes + float64(len("Model Contents"))))
+	g.Expect(testutil.ToFloat64(modelsTracked)).To(gomega.Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(modelDirBytes)).To(gomega.BeNumerically(">", 0))
+
+	g.Expect(serve(http.MethodGet, ManagementPath+"/model1").Code).To(gomega.Equal(http.StatusOK))
+	g.Expect(serve(http.MethodGet, ManagementPath+"/model2").Code).To(gomega.Equal(http.StatusNotFound))
+	g.Expect(serve(http.MethodGet, ManagementPath+"/model1/repull").Code).To(gomega.Equal(http.StatusMethodNotAllowed))
+	g.Expect(serve(http.MethodPost, ManagementPath+"/model2/repull").Code).To(gomega.Equal(http.StatusNotFound))
+
+	// a re-pull is only accepted once the watcher is started
+	g.Expect(serve(http.MethodPost, ManagementPath+"/model1/repull").Code).To(gomega.Equal(http.StatusServiceUnavailable))
+	watcher.started = true
+	g.Expect(serve(http.MethodPost, ManagementPath+"/model1/repull").Code).To(gomega.Equal(http.StatusAccepted))
+	g.Eventually(func() int { return puller.OpStats("model1")[Add] }).Should(gomega.Equal(2))
+	// the model is downloaded again although its success file exists
+	g.Expect(atomic.LoadInt32(&requests)).To(gomega.Equal(int32(2)))
+}
+
+func TestModelManagerRepullCoalesced(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelDir, err := ioutil.TempDir("", "management")
+	g.Expect(err).To(gomega.BeNil())
+	defer os.RemoveAll(modelDir)
+	sugar := zap.NewNop().Sugar()
+	watcher := NewWatcher(filepath.Join(modelDir, "configs"), modelDir, sugar)
+	modelConfigs := modelconfig.ModelConfigs{
+		{Name: "model1", Spec: v1alpha1.ModelSpec{StorageURI: "s3://models/model1", Framework: "sklearn"}},
+		{Name: "model2", Spec: v1alpha1.ModelSpec{StorageURI: "s3://models/model2", Framework: "sklearn"}},
+	}
+	watcher.parseConfig(modelConfigs, false)
+	<-watcher.ModelEvents
+	<-watcher.ModelEvents
+	watcher.started = true
+	// no puller takes the model events
+	manager := NewModelManager(&watcher, nil, status.NewStore(), sugar)
+	repull := func(name string) int {
+		w := httptest.NewRecorder()
+		manager.ServeHTTP(w, httptest.NewRequest(http.MethodPost, ManagementPath+"/"+name+"/repull", nil))
+		return w.Code
+	}
+
+	// a re-pull is queued once until the puller starts it
+	g.Expect(repull("model1")).To(gomega.Equal(http.StatusAccepted))
+	g.Expect(repull("model1")).To(gomega.Equal(http.StatusConflict))
+	g.Expect(watcher.ModelEvents).To(gomega.HaveLen(1))
+	modelOp := <-watcher.ModelEvents
+	modelOp.started()
+	g.Expect(repull("model1")).To(gomega.Equal(http.StatusAccepted))
+	(<-watcher.ModelEvents).started()
+
+	// a re-pull is refused rather than blocking on the backed up model events
+	for len(watcher.ModelEvents) < cap(watcher.ModelEvents) {
+		watcher.ModelEvents <- ModelOp{ModelName: "model2", Op: Add}
+	}
+	g.Expect(repull("model2")).To(gomega.Equal(http.StatusTooManyRequests))
+	g.Expect(watcher.repulls).To(gomega.BeEmpty())
+
+	// the config sync waiting on the model events does not block the management api
+	synced := make(chan struct{})
+	go func() {
+		watcher.parseConfig(modelConfigs[:1], false)
+		close(synced)
+	}()
+	g.Eventually(func() map[string]*v1alpha1.ModelSpec { return watcher.TrackedModels() }).
+		Should(gomega.HaveLen(1))
+	g.Expect(repull("model1")).To(gomega.Equal(http.StatusTooManyRequests))
+	g.Consistently(synced).ShouldNot(gomega.BeClosed())
+	<-watcher.ModelEvents
+	g.Eventually(synced).Should(gomega.BeClosed())
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"strings"
+
+	"github.com/kserve/kserve/pkg/agent/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "kserve"
+	metricsSubsystem = "agent"
+
+	// results
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	// model server operations
+	OperationLoad   = "load"
+	OperationUnload = "unload"
+)
+
+var (
+	downloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "downloads_total",
+		Help:      "Number of model downloads by storage protocol and result.",
+	}, []string{"protocol", "result"})
+	downloadBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "download_bytes_total",
+		Help:      "Size of the models downloaded by storage protocol.",
+	}, []string{"protocol"})
+	downloadDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "download_duration_seconds",
+		Help:      "Duration of the model downloads by storage protocol and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 14),
+	}, []string{"protocol", "result"})
+	modelServerRequestSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "model_server_request_duration_seconds",
+		Help:      "Latency of the load and unload requests to the model server by operation and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"operation", "result"})
+	evictionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "evictions_total",
+		Help:      "Number of models evicted to free disk space for another model.",
+	})
+	modelsTracked = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "models_tracked",
+		Help:      "Number of models in the model config of the agent.",
+	})
+	modelDirBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "model_dir_bytes",
+		Help:      "Size of the model dir, updated after every model op.",
+	})
+	modelDirQuotaBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "model_dir_quota_bytes",
+		Help:      "Max size of the model dir, 0 when it is not bounded.",
+	})
+)
+
+// RegisterMetrics registers the puller and downloader metrics with the given registerer
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{downloadsTotal, downloadBytesTotal, downloadDurationSeconds,
+		modelServerRequestSeconds, evictionsTotal, modelsTracked, modelDirBytes, modelDirQuotaBytes} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protocolLabel returns the protocol without its separator, e.g. s3 for s3://
+func protocolLabel(protocol storage.Protocol) string {
+	return strings.TrimSuffix(string(protocol), "://")
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
@@ -20,9 +20,11 @@ import (
 	"bytes"
 	gstorage "cloud.google.com/go/storage"
 	"context"
+	"crypto/md5"
 	"fmt"
 	"github.com/googleapis/google-cloud-go-testing/storage/stiface"
 	"google.golang.org/api/iterator"
+	"hash/crc32"
 	"strings"
 )
 
@@ -32,8 +34,9 @@ type mockGCSClient struct {
 }
 
 type mockBucket struct {
-	attrs   *gstorage.BucketAttrs
-	objects map[string]*gstorage.ObjectAttrs
+	attrs    *gstorage.BucketAttrs
+	objects  map[string]*gstorage.ObjectAttrs
+	contents map[string][]byte
 }
 
 func NewMockClient() stiface.Client {
@@ -58,7 +61,7 @@ func (b mockBucketHandle) Create(_ context.Context, _ string, attrs *gstorage.Bu
 		attrs = &gstorage.BucketAttrs{}
 	}
 	attrs.Name = b.name
-	b.c.buckets[b.name] = &mockBucket{attrs: attrs, objects: map[string]*gstorage.ObjectAttrs{}}
+	b.c.buckets[b.name] = &mockBucket{attrs: attrs, objects: map[string]*gstorage.ObjectAttrs{}, contents: map[string][]byte{}}
 	return nil
 }
 
@@ -111,23 +114,35 @@ func (o mockObjectHandle) Attrs(context.Context) (*gstorage.ObjectAttrs, error)
 	return contents, nil
 }
 
-func (o mockObjectHandle) NewReader(context.Context) (stiface.Reader, error) {
+func (o mockObjectHandle) NewReader(ctx context.Context) (stiface.Reader, error) {
+	return o.NewRangeReader(ctx, 0, -1)
+}
+
+func (o mockObjectHandle) NewRangeReader(_ context.Context, offset, length int64) (stiface.Reader, error) {
 	bkt, ok := o.c.buckets[o.bucketName]
 	if !ok {
 		return nil, fmt.Errorf("bucket %q not found", o.bucketName)
 	}
-	contents, ok := bkt.objects[o.name]
+	contents, ok := bkt.contents[o.name]
 	if !ok {
 		return nil, fmt.Errorf("object %q not found in bucket %q", o.name, o.bucketName)
 	}
-	return mockReader{r: bytes.NewReader(contents.MD5)}, nil
+	if offset > int64(len(contents)) {
+		return nil, fmt.Errorf("offset %d is past the end of object %q", offset, o.name)
+	}
+	contents = contents[offset:]
+	if length >= 0 && length < int64(len(contents)) {
+		contents = contents[:length]
+	}
+	return mockReader{r: bytes.NewReader(contents)}, nil
 }
 
 func (o mockObjectHandle) NewWriter(context.Context) stiface.Writer {
 	attrs := &gstorage.ObjectAttrs{
-		Bucket: o.bucketName,
-		Name:   o.name,
-		MD5:    nil,
+		Bucket:     o.bucketName,
+		Name:       o.name,
+		MD5:        nil,
+		Generation: 1,
 	}
 	o.c.buckets[o.bucketName].objects[o.name] = attrs
 	return &mockWriter{o: o, obj: attrs}
@@ -155,6 +170,11 @@ type mockWriter struct {
 
 func (w *mockWriter) Write(data []byte) (int, error) {
 	int, err := w.buf.Write(data)
-	w.obj.MD5 = data
+	contents := w.buf.Bytes()
+	md5Sum := md5.Sum(contents)
+	w.o.c.buckets[w.o.bucketName].contents[w.o.name] = contents
+	w.obj.MD5 = md5Sum[:]
+	w.obj.CRC32C = crc32.Checksum(contents, crc32.MakeTable(crc32.Castagnoli))
+	w.obj.Size = int64(len(contents))
 	return int, err
 }
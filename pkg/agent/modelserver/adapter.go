@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package modelserver loads and unloads the models pulled by the agent on the model server
+package modelserver
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+)
+
+// Protocol is the model management api of the model server
+type Protocol string
+
+const (
+	// V2 is the model repository extension of the v2 inference protocol served over http
+	V2 Protocol = "v2"
+	// TorchServe is the TorchServe management api
+	TorchServe Protocol = "torchserve"
+	// TFServing is the model config file polled by TF Serving
+	TFServing Protocol = "tfserving"
+	// TritonGRPC is the Triton model repository extension served over grpc
+	TritonGRPC Protocol = "triton-grpc"
+)
+
+var SupportedProtocols = []Protocol{V2, TorchServe, TFServing, TritonGRPC}
+
+// Adapter loads and unloads the models on the model server once they are downloaded to the model dir. LoadModel is
+// also called for a model which is already loaded once its new version is in place, the model server reloads it,
+// and with a nil spec when the previous version of a model is restored after a failed update.
+type Adapter interface {
+	LoadModel(ctx context.Context, modelName string, spec *v1alpha1.ModelSpec) error
+	UnloadModel(ctx context.Context, modelName string) error
+}
+
+// Config selects and configures the adapter of the model server
+type Config struct {
+	Protocol Protocol
+	// Endpoint is the model management endpoint, the default endpoint of the protocol is used when empty
+	Endpoint string
+	// ModelDir is the directory the models are downloaded to
+	ModelDir string
+	// ConfigFile is the model config file polled by TF Serving, defaults to models.config in the model dir
+	ConfigFile string
+}
+
+// DefaultEndpoint returns the management endpoint the model server of the protocol listens on by default, the
+// v2 endpoint is served on the component port
+func DefaultEndpoint(protocol Protocol, componentPort string) string {
+	switch protocol {
+	case TorchServe:
+		return "http://localhost:8081"
+	case TFServing:
+		return "http://localhost:" + componentPort
+	case TritonGRPC:
+		return "localhost:9000"
+	default:
+		return "http://localhost:" + componentPort
+	}
+}
+
+func NewAdapter(config Config) (Adapter, error) {
+	switch config.Protocol {
+	case V2:
+		return NewV2Adapter(config.Endpoint), nil
+	case TorchServe:
+		return NewTorchServeAdapter(config.Endpoint, config.ModelDir), nil
+	case TFServing:
+		configFile := config.ConfigFile
+		if configFile == "" {
+			configFile = filepath.Join(config.ModelDir, TFServingConfigFileName)
+		}
+		return NewTFServingAdapter(config.Endpoint, configFile, config.ModelDir), nil
+	case TritonGRPC:
+		return NewTritonGRPCAdapter(config.Endpoint)
+	default:
+		return nil, fmt.Errorf("unsupported model server protocol %q, must be one of %v", config.Protocol, SupportedProtocols)
+	}
+}
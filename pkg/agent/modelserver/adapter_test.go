@@ -0,0 +1,395 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelserver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type recordedRequest struct {
+	method string
+	path   string
+	query  string
+}
+
+func newRecordingServer(statusCode int) (*httptest.Server, *[]recordedRequest) {
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, recordedRequest{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery})
+		w.WriteHeader(statusCode)
+		fmt.Fprint(w, "{}")
+	}))
+	return server, &requests
+}
+
+func TestNewAdapter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scenarios := map[string]struct {
+		config   Config
+		expected Adapter
+	}{
+		"V2":         {config: Config{Protocol: V2}, expected: &V2Adapter{}},
+		"TorchServe": {config: Config{Protocol: TorchServe}, expected: &TorchServeAdapter{}},
+		"TFServing":  {config: Config{Protocol: TFServing, ModelDir: "/mnt/models"}, expected: &TFServingAdapter{}},
+		"TritonGRPC": {config: Config{Protocol: TritonGRPC, Endpoint: "localhost:9000"}, expected: &TritonGRPCAdapter{}},
+	}
+	for name, scenario := range scenarios {
+		adapter, err := NewAdapter(scenario.config)
+		g.Expect(err).To(gomega.BeNil(), name)
+		g.Expect(adapter).To(gomega.BeAssignableToTypeOf(scenario.expected), name)
+	}
+	tfServing, _ := NewAdapter(Config{Protocol: TFServing, ModelDir: "/mnt/models"})
+	g.Expect(tfServing.(*TFServingAdapter).ConfigFile).To(gomega.Equal("/mnt/models/models.config"))
+
+	_, err := NewAdapter(Config{Protocol: "foo"})
+	g.Expect(err).NotTo(gomega.BeNil())
+}
+
+func TestV2Adapter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	server, requests := newRecordingServer(http.StatusOK)
+	defer server.Close()
+	adapter := NewV2Adapter(server.URL + "/")
+
+	g.Expect(adapter.LoadModel(context.Background(), "model1", nil)).To(gomega.Succeed())
+	g.Expect(adapter.UnloadModel(context.Background(), "model1")).To(gomega.Succeed())
+	g.Expect(*requests).To(gomega.Equal([]recordedRequest{
+		{method: http.MethodPost, path: "/v2/repository/models/model1/load"},
+		{method: http.MethodPost, path: "/v2/repository/models/model1/unload"},
+	}))
+
+	failing, _ := newRecordingServer(http.StatusBadRequest)
+	defer failing.Close()
+	err := NewV2Adapter(failing.URL).LoadModel(context.Background(), "model1", nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("status 400")))
+}
+
+// fakeTorchServe keeps the registered versions and the default version of the models like the TorchServe
+// management api, it refuses to register a version twice or to unregister the default version while other
+// versions are registered and fails the requests of failMethod
+type fakeTorchServe struct {
+	versions   map[string][]string
+	defaults   map[string]string
+	failMethod string
+	requests   []recordedRequest
+}
+
+func newFakeTorchServe() (*httptest.Server, *fakeTorchServe) {
+	fake := &fakeTorchServe{versions: map[string][]string{}, defaults: map[string]string{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.requests = append(fake.requests, recordedRequest{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery})
+		if r.Method == fake.failMethod {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/models/"), "/")
+		switch r.Method {
+		case http.MethodGet:
+			if len(fake.versions[parts[0]]) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var described []string
+			for _, version := range fake.versions[parts[0]] {
+				described = append(described, fmt.Sprintf(`{"modelName":%q,"modelVersion":%q}`, parts[0], version))
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(described, ","))
+		case http.MethodPost:
+			name, version := r.URL.Query().Get("model_name"), r.URL.Query().Get("model_version")
+			for _, registered := range fake.versions[name] {
+				if registered == version {
+					w.WriteHeader(http.StatusConflict)
+					return
+				}
+			}
+			if len(fake.versions[name]) == 0 {
+				fake.defaults[name] = version
+			}
+			fake.versions[name] = append(fake.versions[name], version)
+		case http.MethodPut:
+			fake.defaults[parts[0]] = parts[1]
+		case http.MethodDelete:
+			version := fake.defaults[parts[0]]
+			if len(parts) > 1 {
+				version = parts[1]
+			}
+			if version == fake.defaults[parts[0]] && len(fake.versions[parts[0]]) > 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var remaining []string
+			for _, registered := range fake.versions[parts[0]] {
+				if registered != version {
+					remaining = append(remaining, registered)
+				}
+			}
+			fake.versions[parts[0]] = remaining
+		}
+	}))
+	return server, fake
+}
+
+func newTorchServeModelDir(g *gomega.WithT) string {
+	modelDir, err := ioutil.TempDir("", "torchserve")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(os.MkdirAll(filepath.Join(modelDir, "model1"), os.ModePerm)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(modelDir, "model1", "mnist.mar"), []byte{}, 0644)).To(gomega.Succeed())
+	g.Expect(os.MkdirAll(filepath.Join(modelDir, "model2"), os.ModePerm)).To(gomega.Succeed())
+	return modelDir
+}
+
+// setModelVersion gives the downloaded model a new version, which is the modification time of its archive
+func setModelVersion(g *gomega.WithT, path string, version int64) string {
+	g.Expect(os.Chtimes(path, time.Unix(0, version), time.Unix(0, version))).To(gomega.Succeed())
+	return fmt.Sprint(version)
+}
+
+func TestTorchServeAdapter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelDir := newTorchServeModelDir(g)
+	defer os.RemoveAll(modelDir)
+	archive := filepath.Join(modelDir, "model1", "mnist.mar")
+	version := setModelVersion(g, archive, 1)
+
+	server, fake := newFakeTorchServe()
+	defer server.Close()
+	adapter := NewTorchServeAdapter(server.URL, modelDir)
+
+	g.Expect(adapter.LoadModel(context.Background(), "model1", nil)).To(gomega.Succeed())
+	// a model without an archive is registered from its directory
+	g.Expect(adapter.LoadModel(context.Background(), "model2", nil)).To(gomega.Succeed())
+	g.Expect(adapter.UnloadModel(context.Background(), "model1")).To(gomega.Succeed())
+
+	g.Expect(fake.requests).To(gomega.HaveLen(6))
+	g.Expect(fake.requests[0]).To(gomega.Equal(recordedRequest{method: http.MethodGet, path: "/models/model1/all"}))
+	g.Expect(fake.requests[1].method).To(gomega.Equal(http.MethodPost))
+	g.Expect(fake.requests[1].path).To(gomega.Equal("/models"))
+	register, _ := url.ParseQuery(fake.requests[1].query)
+	g.Expect(register.Get("model_name")).To(gomega.Equal("model1"))
+	g.Expect(register.Get("model_version")).To(gomega.Equal(version))
+	g.Expect(register.Get("synchronous")).To(gomega.Equal("true"))
+	g.Expect(register.Get("url")).To(gomega.Equal(archive))
+	register, _ = url.ParseQuery(fake.requests[3].query)
+	g.Expect(register.Get("url")).To(gomega.Equal(filepath.Join(modelDir, "model2")))
+	g.Expect(fake.requests[4:]).To(gomega.Equal([]recordedRequest{
+		{method: http.MethodGet, path: "/models/model1/all"},
+		{method: http.MethodDelete, path: "/models/model1/" + version},
+	}))
+	g.Expect(fake.versions["model1"]).To(gomega.BeEmpty())
+
+	// unloading a model which is not registered does nothing
+	g.Expect(adapter.UnloadModel(context.Background(), "model1")).To(gomega.Succeed())
+}
+
+func TestTorchServeAdapterUnloadVersions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelDir := newTorchServeModelDir(g)
+	defer os.RemoveAll(modelDir)
+	server, fake := newFakeTorchServe()
+	defer server.Close()
+	adapter := NewTorchServeAdapter(server.URL, modelDir)
+
+	// an update failed to unregister the previous versions, every version is unregistered starting with the
+	// ones which are not the default
+	fake.versions["model1"] = []string{"1", "2", "3"}
+	fake.defaults["model1"] = "1"
+	g.Expect(adapter.UnloadModel(context.Background(), "model1")).To(gomega.Succeed())
+	g.Expect(fake.versions["model1"]).To(gomega.BeEmpty())
+	g.Expect(fake.requests).To(gomega.Equal([]recordedRequest{
+		{method: http.MethodGet, path: "/models/model1/all"},
+		{method: http.MethodDelete, path: "/models/model1/1"},
+		{method: http.MethodDelete, path: "/models/model1/2"},
+		{method: http.MethodDelete, path: "/models/model1/3"},
+		{method: http.MethodDelete, path: "/models/model1/1"},
+	}))
+
+	fake.versions["model1"] = []string{"1", "2"}
+	fake.defaults["model1"] = "1"
+	fake.failMethod = http.MethodDelete
+	err := adapter.UnloadModel(context.Background(), "model1")
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("status 500")))
+	g.Expect(fake.versions["model1"]).To(gomega.Equal([]string{"1", "2"}))
+}
+
+func TestTorchServeAdapterUpdate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelDir := newTorchServeModelDir(g)
+	defer os.RemoveAll(modelDir)
+	archive := filepath.Join(modelDir, "model1", "mnist.mar")
+	server, fake := newFakeTorchServe()
+	defer server.Close()
+	adapter := NewTorchServeAdapter(server.URL, modelDir)
+	oldVersion := setModelVersion(g, archive, 1)
+	g.Expect(adapter.LoadModel(context.Background(), "model1", nil)).To(gomega.Succeed())
+	fake.requests = nil
+
+	// the new version is made the default before the old version is unregistered
+	newVersion := setModelVersion(g, archive, 2)
+	g.Expect(adapter.LoadModel(context.Background(), "model1", nil)).To(gomega.Succeed())
+	g.Expect(fake.requests).To(gomega.HaveLen(4))
+	g.Expect(fake.requests[1].method).To(gomega.Equal(http.MethodPost))
+	g.Expect(fake.requests[2:]).To(gomega.Equal([]recordedRequest{
+		{method: http.MethodPut, path: "/models/model1/" + newVersion + "/set-default"},
+		{method: http.MethodDelete, path: "/models/model1/" + oldVersion},
+	}))
+	g.Expect(fake.versions["model1"]).To(gomega.Equal([]string{newVersion}))
+	g.Expect(fake.defaults["model1"]).To(gomega.Equal(newVersion))
+
+	// loading the same version again does not register it twice
+	g.Expect(adapter.LoadModel(context.Background(), "model1", nil)).To(gomega.Succeed())
+	g.Expect(fake.versions["model1"]).To(gomega.Equal([]string{newVersion}))
+}
+
+func TestTorchServeAdapterUpdateFailure(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelDir := newTorchServeModelDir(g)
+	defer os.RemoveAll(modelDir)
+	archive := filepath.Join(modelDir, "model1", "mnist.mar")
+	server, fake := newFakeTorchServe()
+	defer server.Close()
+	adapter := NewTorchServeAdapter(server.URL, modelDir)
+	oldVersion := setModelVersion(g, archive, 1)
+	g.Expect(adapter.LoadModel(context.Background(), "model1", nil)).To(gomega.Succeed())
+
+	// the new version fails to start its workers, the old version keeps serving
+	setModelVersion(g, archive, 2)
+	fake.failMethod = http.MethodPost
+	err := adapter.LoadModel(context.Background(), "model1", nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("status 500")))
+	g.Expect(fake.versions["model1"]).To(gomega.Equal([]string{oldVersion}))
+	g.Expect(fake.defaults["model1"]).To(gomega.Equal(oldVersion))
+
+	// the new version fails to become the default, it is unregistered and the old version keeps serving
+	fake.failMethod = http.MethodPut
+	err = adapter.LoadModel(context.Background(), "model1", nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("the default")))
+	g.Expect(fake.versions["model1"]).To(gomega.Equal([]string{oldVersion}))
+	g.Expect(fake.defaults["model1"]).To(gomega.Equal(oldVersion))
+}
+
+func TestTFServingAdapter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelDir, err := ioutil.TempDir("", "tfserving")
+	g.Expect(err).To(gomega.BeNil())
+	defer os.RemoveAll(modelDir)
+
+	// the fake TF Serving reports the models of the config file as available
+	configFile := filepath.Join(modelDir, TFServingConfigFileName)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config, _ := ioutil.ReadFile(configFile)
+		name := filepath.Base(r.URL.Path)
+		switch {
+		case name == "broken":
+			fmt.Fprint(w, `{"model_version_status":[{"version":"1","state":"END","status":{"error_code":"NOT_FOUND","error_message":"no versions"}}]}`)
+		case strings.Contains(string(config), fmt.Sprintf("name: %q", name)):
+			fmt.Fprint(w, `{"model_version_status":[{"version":"1","state":"AVAILABLE","status":{"error_code":"OK"}}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	adapter := NewTFServingAdapter(server.URL, configFile, modelDir)
+	adapter.PollInterval = 10 * time.Millisecond
+	adapter.Timeout = time.Second
+
+	g.Expect(adapter.LoadModel(context.Background(), "model2", nil)).To(gomega.Succeed())
+	g.Expect(adapter.LoadModel(context.Background(), "model1", nil)).To(gomega.Succeed())
+	config, err := ioutil.ReadFile(configFile)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(string(config)).To(gomega.Equal(fmt.Sprintf(`model_config_list {
+  config {
+    name: "model1"
+    base_path: %q
+    model_platform: "tensorflow"
+  }
+  config {
+    name: "model2"
+    base_path: %q
+    model_platform: "tensorflow"
+  }
+}
+`, filepath.Join(modelDir, "model1"), filepath.Join(modelDir, "model2"))))
+
+	g.Expect(adapter.UnloadModel(context.Background(), "model2")).To(gomega.Succeed())
+	config, _ = ioutil.ReadFile(configFile)
+	g.Expect(string(config)).NotTo(gomega.ContainSubstring("model2"))
+
+	err = adapter.LoadModel(context.Background(), "broken", nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("no versions")))
+}
+
+func TestTritonGRPCAdapter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var mu sync.Mutex
+	var calls []string
+	// the fake Triton decodes the model name of the repository requests
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		num, typ, n := protowire.ConsumeTag(req)
+		if num != tritonModelNameField || typ != protowire.BytesType {
+			return status.Errorf(codes.InvalidArgument, "unexpected field %d", num)
+		}
+		name, _ := protowire.ConsumeString(req[n:])
+		mu.Lock()
+		calls = append(calls, method+" "+name)
+		mu.Unlock()
+		if name == "broken" {
+			return status.Errorf(codes.Internal, "failed to load 'broken'")
+		}
+		return stream.SendMsg(&[]byte{})
+	}), grpc.ForceServerCodec(rawCodec{}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).To(gomega.BeNil())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	adapter, err := NewTritonGRPCAdapter(listener.Addr().String())
+	g.Expect(err).To(gomega.BeNil())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	g.Expect(adapter.LoadModel(ctx, "model1", nil)).To(gomega.Succeed())
+	g.Expect(adapter.UnloadModel(ctx, "model1")).To(gomega.Succeed())
+	err = adapter.LoadModel(ctx, "broken", nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("failed to load 'broken'")))
+	g.Expect(calls).To(gomega.Equal([]string{
+		tritonLoadMethod + " model1",
+		tritonUnloadMethod + " model1",
+		tritonLoadMethod + " broken",
+	}))
+}
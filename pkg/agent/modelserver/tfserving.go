@@ -0,0 +1,208 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// TFServingConfigFileName is the default name of the model config file in the model dir
+	TFServingConfigFileName = "models.config"
+	// TFServingDefaultPollInterval is how often the model status is checked while TF Serving reloads its config
+	TFServingDefaultPollInterval = time.Second
+	// TFServingDefaultTimeout bounds the wait for TF Serving to pick up the config change
+	TFServingDefaultTimeout = 5 * time.Minute
+)
+
+// TFServingAdapter writes the models to the model config file TF Serving polls, started with
+// --model_config_file and --model_config_file_poll_wait_seconds, and waits on the model status api
+// GET /v1/models/{name} until the reload is applied.
+type TFServingAdapter struct {
+	Endpoint     string
+	ConfigFile   string
+	ModelDir     string
+	PollInterval time.Duration
+	Timeout      time.Duration
+	HTTPClient   *http.Client
+
+	mu     sync.Mutex
+	models map[string]string
+}
+
+func NewTFServingAdapter(endpoint string, configFile string, modelDir string) *TFServingAdapter {
+	return &TFServingAdapter{
+		Endpoint:     strings.TrimSuffix(endpoint, "/"),
+		ConfigFile:   configFile,
+		ModelDir:     modelDir,
+		PollInterval: TFServingDefaultPollInterval,
+		Timeout:      TFServingDefaultTimeout,
+		HTTPClient:   http.DefaultClient,
+		models:       map[string]string{},
+	}
+}
+
+// tfServingModelStatus is the response of the TF Serving model status api
+type tfServingModelStatus struct {
+	ModelVersionStatus []struct {
+		Version string `json:"version"`
+		State   string `json:"state"`
+		Status  struct {
+			ErrorCode    string `json:"error_code"`
+			ErrorMessage string `json:"error_message"`
+		} `json:"status"`
+	} `json:"model_version_status"`
+}
+
+func (a *TFServingAdapter) LoadModel(ctx context.Context, modelName string, _ *v1alpha1.ModelSpec) error {
+	if err := a.updateConfig(func(models map[string]string) {
+		models[modelName] = filepath.Join(a.ModelDir, modelName)
+	}); err != nil {
+		return err
+	}
+	return a.waitForModel(ctx, modelName, func(status *tfServingModelStatus) (bool, error) {
+		if status == nil {
+			return false, nil
+		}
+		for _, version := range status.ModelVersionStatus {
+			if version.State == "AVAILABLE" {
+				return true, nil
+			}
+			if version.Status.ErrorCode != "" && version.Status.ErrorCode != "OK" {
+				return false, fmt.Errorf("model version %s failed to load: %s", version.Version, version.Status.ErrorMessage)
+			}
+		}
+		return false, nil
+	})
+}
+
+func (a *TFServingAdapter) UnloadModel(ctx context.Context, modelName string) error {
+	if err := a.updateConfig(func(models map[string]string) {
+		delete(models, modelName)
+	}); err != nil {
+		return err
+	}
+	return a.waitForModel(ctx, modelName, func(status *tfServingModelStatus) (bool, error) {
+		if status == nil {
+			return true, nil
+		}
+		for _, version := range status.ModelVersionStatus {
+			if version.State != "END" {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// updateConfig applies the change to the loaded models and rewrites the config file, the file is replaced
+// atomically so that TF Serving never reads a partial config
+func (a *TFServingAdapter) updateConfig(update func(models map[string]string)) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	update(a.models)
+	tmpFile := a.ConfigFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, []byte(tfServingConfig(a.models)), 0644); err != nil {
+		return fmt.Errorf("while writing TF Serving model config: %s", err)
+	}
+	if err := os.Rename(tmpFile, a.ConfigFile); err != nil {
+		return fmt.Errorf("while replacing TF Serving model config: %s", err)
+	}
+	return nil
+}
+
+// tfServingConfig renders the ModelServerConfig text proto of the models
+func tfServingConfig(models map[string]string) string {
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var config strings.Builder
+	config.WriteString("model_config_list {\n")
+	for _, name := range names {
+		fmt.Fprintf(&config, "  config {\n    name: %q\n    base_path: %q\n    model_platform: \"tensorflow\"\n  }\n",
+			name, models[name])
+	}
+	config.WriteString("}\n")
+	return config.String()
+}
+
+// waitForModel polls the model status until done reports the reload is applied, status is nil when TF Serving
+// does not know about the model
+func (a *TFServingAdapter) waitForModel(ctx context.Context, modelName string,
+	done func(status *tfServingModelStatus) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, a.Timeout)
+	defer cancel()
+	var lastErr error
+	err := wait.PollImmediateUntil(a.PollInterval, func() (bool, error) {
+		status, err := a.getModelStatus(ctx, modelName)
+		if err != nil {
+			// TF Serving may not be up yet
+			lastErr = err
+			return false, nil
+		}
+		return done(status)
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout && lastErr != nil {
+		return fmt.Errorf("timed out waiting for TF Serving to reload model %s: %s", modelName, lastErr)
+	}
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out waiting for TF Serving to reload model %s", modelName)
+	}
+	return err
+}
+
+func (a *TFServingAdapter) getModelStatus(ctx context.Context, modelName string) (*tfServingModelStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/models/%s", a.Endpoint, modelName), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("model server returned status %d: %s", resp.StatusCode, body)
+	}
+	status := &tfServingModelStatus{}
+	if err := json.Unmarshal(body, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+)
+
+// TorchServeAdapter registers the models with the TorchServe management api,
+// POST /models?url={archive}&model_name={name}&model_version={version}, PUT /models/{name}/{version}/set-default
+// and DELETE /models/{name}/{version}
+type TorchServeAdapter struct {
+	Endpoint   string
+	ModelDir   string
+	HTTPClient *http.Client
+}
+
+func NewTorchServeAdapter(endpoint string, modelDir string) *TorchServeAdapter {
+	return &TorchServeAdapter{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		ModelDir:   modelDir,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// torchServeModelVersion is an entry of the TorchServe description of the versions of a model,
+// GET /models/{name}/all
+type torchServeModelVersion struct {
+	ModelVersion string `json:"modelVersion"`
+}
+
+// LoadModel registers the model archive downloaded for the model, or the model directory when it has no archive.
+// The registration is synchronous so that a model which fails to start its workers is reported as failed. An updated
+// model is registered as a new version next to the running one, made the default version once its workers are up,
+// and only then the previous versions are unregistered, so the running version keeps serving when the update fails.
+func (a *TorchServeAdapter) LoadModel(ctx context.Context, modelName string, _ *v1alpha1.ModelSpec) error {
+	modelUrl := filepath.Join(a.ModelDir, modelName)
+	archives, err := filepath.Glob(filepath.Join(modelUrl, "*.mar"))
+	if err != nil {
+		return err
+	}
+	if len(archives) > 1 {
+		return fmt.Errorf("found %d model archives for model %s, expected one", len(archives), modelName)
+	}
+	if len(archives) == 1 {
+		modelUrl = archives[0]
+	}
+	// every download of the model is a new version
+	info, err := os.Stat(modelUrl)
+	if err != nil {
+		return err
+	}
+	version := strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	previous, err := a.versions(ctx, modelName)
+	if err != nil {
+		return fmt.Errorf("while listing the versions of model %s: %s", modelName, err)
+	}
+
+	query := url.Values{}
+	query.Set("url", modelUrl)
+	query.Set("model_name", modelName)
+	query.Set("model_version", version)
+	query.Set("initial_workers", "1")
+	query.Set("synchronous", "true")
+	err = a.do(ctx, http.MethodPost, fmt.Sprintf("%s/models?%s", a.Endpoint, query.Encode()))
+	var statusErr *statusError
+	// the version is already registered when a previous load failed after registering it
+	if err != nil && !(errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict) {
+		return err
+	}
+	var stale []string
+	for _, previousVersion := range previous {
+		if previousVersion != version {
+			stale = append(stale, previousVersion)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	if err := a.do(ctx, http.MethodPut, a.versionUrl(modelName, version)+"/set-default"); err != nil {
+		// the previous version stays the default, the new version is registered again on the next load
+		if unregisterErr := a.do(ctx, http.MethodDelete, a.versionUrl(modelName, version)); unregisterErr != nil {
+			return fmt.Errorf("while making version %s of model %s the default: %s, and unregistering it: %s",
+				version, modelName, err, unregisterErr)
+		}
+		return fmt.Errorf("while making version %s of model %s the default: %s", version, modelName, err)
+	}
+	for _, staleVersion := range stale {
+		if err := a.do(ctx, http.MethodDelete, a.versionUrl(modelName, staleVersion)); err != nil {
+			return fmt.Errorf("while unregistering the previous version %s of model %s: %s", staleVersion, modelName, err)
+		}
+	}
+	return nil
+}
+
+// versions returns the registered versions of the model, none when the model is not registered
+func (a *TorchServeAdapter) versions(ctx context.Context, modelName string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/models/%s/all", a.Endpoint, url.PathEscape(modelName)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	var described []torchServeModelVersion
+	if err := json.Unmarshal(body, &described); err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(described))
+	for _, modelVersion := range described {
+		versions = append(versions, modelVersion.ModelVersion)
+	}
+	return versions, nil
+}
+
+func (a *TorchServeAdapter) versionUrl(modelName string, version string) string {
+	return fmt.Sprintf("%s/models/%s/%s", a.Endpoint, url.PathEscape(modelName), url.PathEscape(version))
+}
+
+// UnloadModel unregisters every version of the model, DELETE /models/{name} would only unregister the default version
+// and leave a version registered by an update which failed to unregister the previous one. TorchServe refuses to
+// unregister the default version while other versions are registered, so the versions which fail are retried as long
+// as the others get unregistered.
+func (a *TorchServeAdapter) UnloadModel(ctx context.Context, modelName string) error {
+	versions, err := a.versions(ctx, modelName)
+	if err != nil {
+		return fmt.Errorf("while listing the versions of model %s: %s", modelName, err)
+	}
+	for len(versions) > 0 {
+		var failed []string
+		for _, version := range versions {
+			if err = a.do(ctx, http.MethodDelete, a.versionUrl(modelName, version)); err != nil {
+				failed = append(failed, version)
+			}
+		}
+		if len(failed) == len(versions) {
+			return fmt.Errorf("while unregistering the versions %v of model %s: %s", failed, modelName, err)
+		}
+		versions = failed
+	}
+	return nil
+}
+
+func (a *TorchServeAdapter) do(ctx context.Context, method string, url string) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp)
+}
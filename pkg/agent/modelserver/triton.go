@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	tritonLoadMethod   = "/inference.GRPCInferenceService/RepositoryModelLoad"
+	tritonUnloadMethod = "/inference.GRPCInferenceService/RepositoryModelUnload"
+	// tritonModelNameField is the field number of model_name in the RepositoryModelLoadRequest and
+	// RepositoryModelUnloadRequest messages of the Triton grpc_service.proto
+	tritonModelNameField = 2
+)
+
+// TritonGRPCAdapter calls the RepositoryModelLoad and RepositoryModelUnload rpcs of the Triton grpc api. The
+// requests only set the model name, they are encoded directly so that the agent does not depend on the
+// generated Triton client.
+type TritonGRPCAdapter struct {
+	conn *grpc.ClientConn
+}
+
+func NewTritonGRPCAdapter(endpoint string) (*TritonGRPCAdapter, error) {
+	// the connection is established lazily so that the agent can start before the model server
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})))
+	if err != nil {
+		return nil, fmt.Errorf("while connecting to Triton at %s: %s", endpoint, err)
+	}
+	return &TritonGRPCAdapter{conn: conn}, nil
+}
+
+func (a *TritonGRPCAdapter) LoadModel(ctx context.Context, modelName string, _ *v1alpha1.ModelSpec) error {
+	return a.invoke(ctx, tritonLoadMethod, modelName)
+}
+
+func (a *TritonGRPCAdapter) UnloadModel(ctx context.Context, modelName string) error {
+	return a.invoke(ctx, tritonUnloadMethod, modelName)
+}
+
+func (a *TritonGRPCAdapter) invoke(ctx context.Context, method string, modelName string) error {
+	req := protowire.AppendTag(nil, tritonModelNameField, protowire.BytesType)
+	req = protowire.AppendString(req, modelName)
+	var resp []byte
+	if err := a.conn.Invoke(ctx, method, &req, &resp); err != nil {
+		return fmt.Errorf("model server returned %s", err)
+	}
+	return nil
+}
+
+// rawCodec passes the encoded messages through, the responses of the repository rpcs are empty
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+)
+
+// V2Adapter calls the model repository extension of the v2 protocol,
+// POST /v2/repository/models/{name}/load and POST /v2/repository/models/{name}/unload
+type V2Adapter struct {
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+func NewV2Adapter(endpoint string) *V2Adapter {
+	return &V2Adapter{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+func (a *V2Adapter) LoadModel(ctx context.Context, modelName string, _ *v1alpha1.ModelSpec) error {
+	return a.post(ctx, fmt.Sprintf("%s/v2/repository/models/%s/load", a.Endpoint, modelName))
+}
+
+func (a *V2Adapter) UnloadModel(ctx context.Context, modelName string) error {
+	return a.post(ctx, fmt.Sprintf("%s/v2/repository/models/%s/unload", a.Endpoint, modelName))
+}
+
+func (a *V2Adapter) post(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString("{}"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp)
+}
+
+// statusError is a non 200 response of the model server
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("model server returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// checkResponse closes the response and turns a non 200 response into an error with the response body
+func checkResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return &statusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
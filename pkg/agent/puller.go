@@ -17,17 +17,20 @@ limitations under the License.
 package agent
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"os"
 	"path/filepath"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/kserve/kserve/pkg/agent/modelserver"
+	"github.com/kserve/kserve/pkg/agent/status"
 	"github.com/kserve/kserve/pkg/agent/storage"
 	v1 "github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 type OpType string
@@ -40,41 +43,116 @@ const (
 type Puller struct {
 	channelMap  map[string]*ModelChannel
 	completions chan *ModelOp
-	opStats     map[string]map[OpType]int
-	waitGroup   WaitGroupWrapper
-	Downloader  *Downloader
+	// evictions takes the Remove ops of the evicted models to the processors of the models
+	evictions chan *ModelOp
+	opStats   map[string]map[OpType]int
+	// statsMu guards opStats, which the management api reads while the models are processed
+	statsMu    sync.RWMutex
+	waitGroup  WaitGroupWrapper
+	Downloader *Downloader
+	// modelServer loads and unloads the downloaded models on the model server
+	modelServer modelserver.Adapter
+	// diskQuota bounds the size of the model dir, it may be nil
+	diskQuota *DiskQuota
+	// modelStatus publishes the load state of the models, it may be nil
+	modelStatus *status.Store
+	retryConfig RetryConfig
 	logger      *zap.SugaredLogger
 }
 
+// RetryConfig bounds the attempts to download and load a model. A model is attempted once when MaxAttempts is
+// not set.
+type RetryConfig struct {
+	// MaxAttempts is the number of attempts before the model is dead lettered
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, it doubles on every retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+	// ModelServerTimeout bounds every load and unload request to the model server so that a hung model server
+	// fails the attempt, DefaultModelServerTimeout is used when not set
+	ModelServerTimeout time.Duration
+}
+
+const DefaultModelServerTimeout = 5 * time.Minute
+
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:        5,
+		InitialBackoff:     2 * time.Second,
+		MaxBackoff:         2 * time.Minute,
+		ModelServerTimeout: DefaultModelServerTimeout,
+	}
+}
+
+// modelServerContext returns the context of a model server request
+func (c RetryConfig) modelServerContext() (context.Context, context.CancelFunc) {
+	timeout := c.ModelServerTimeout
+	if timeout <= 0 {
+		timeout = DefaultModelServerTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func (c RetryConfig) backoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: c.InitialBackoff,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    c.MaxAttempts,
+		Cap:      c.MaxBackoff,
+	}
+}
+
 type ModelOp struct {
 	OnStartup bool
 	ModelName string
 	Op        OpType
 	Spec      *v1.ModelSpec
+	// Repull downloads the model again even when it is already downloaded
+	Repull bool
+	// EvictedFor is set on the Remove op of a model evicted to free disk space for the named model
+	EvictedFor string
+	// evicted receives the result of the eviction
+	evicted chan error
+	// started is called once the processor of the model takes the op
+	started func()
 }
 
 type WaitGroupWrapper struct {
 	wg sync.WaitGroup
 }
 
-func StartPullerAndProcessModels(downloader *Downloader, commands <-chan ModelOp, logger *zap.SugaredLogger) {
-	puller := Puller{
+func NewPuller(downloader *Downloader, modelServer modelserver.Adapter, diskQuota *DiskQuota,
+	modelStatus *status.Store, retryConfig RetryConfig, logger *zap.SugaredLogger) *Puller {
+	if diskQuota != nil {
+		modelDirQuotaBytes.Set(float64(diskQuota.Limit))
+	}
+	return &Puller{
 		channelMap:  make(map[string]*ModelChannel),
 		completions: make(chan *ModelOp, 4),
+		evictions:   make(chan *ModelOp, 4),
 		opStats:     make(map[string]map[OpType]int),
 		waitGroup:   WaitGroupWrapper{sync.WaitGroup{}},
 		Downloader:  downloader,
+		modelServer: modelServer,
+		diskQuota:   diskQuota,
+		modelStatus: modelStatus,
+		retryConfig: retryConfig,
 		logger:      logger,
 	}
+}
 
+// ProcessModels processes the model ops of the commands, it returns once the models pulled on startup are processed
+func (p *Puller) ProcessModels(commands <-chan ModelOp) {
 	// Change umask to ensure we have control over the downloaded file
 	// permissions:
 	// https://stackoverflow.com/a/61645606/5015573
 	syscall.Umask(0)
 
-	puller.waitGroup.wg.Add(len(commands))
-	go puller.processCommands(commands)
-	puller.waitGroup.wg.Wait()
+	p.waitGroup.wg.Add(len(commands))
+	go p.processCommands(commands)
+	p.waitGroup.wg.Wait()
 }
 
 func (p *Puller) processCommands(commands <-chan ModelOp) {
@@ -87,6 +165,8 @@ func (p *Puller) processCommands(commands <-chan ModelOp) {
 			} else {
 				commands = nil
 			}
+		case evicted := <-p.evictions:
+			p.enqueueModelOp(evicted)
 		case completed := <-p.completions:
 			p.modelOpComplete(completed, commands == nil)
 		}
@@ -116,12 +196,14 @@ func (p *Puller) modelOpComplete(modelOp *ModelOp, closed bool) {
 	if modelOp.OnStartup {
 		defer p.waitGroup.wg.Done()
 	}
+	p.statsMu.Lock()
 	if opMap, ok := p.opStats[modelOp.ModelName]; ok {
 		opMap[modelOp.Op] += 1
 	} else {
 		p.opStats[modelOp.ModelName] = make(map[OpType]int)
 		p.opStats[modelOp.ModelName][modelOp.Op] = 1
 	}
+	p.statsMu.Unlock()
 	modelChan, ok := p.channelMap[modelOp.ModelName]
 	if ok {
 		modelChan.opsInFlight -= 1
@@ -139,67 +221,269 @@ func (p *Puller) modelOpComplete(modelOp *ModelOp, closed bool) {
 	}
 }
 
+// OpStats returns the number of ops of each type completed for the model
+func (p *Puller) OpStats(modelName string) map[OpType]int {
+	p.statsMu.RLock()
+	defer p.statsMu.RUnlock()
+	stats := make(map[OpType]int, len(p.opStats[modelName]))
+	for op, count := range p.opStats[modelName] {
+		stats[op] = count
+	}
+	return stats
+}
+
 func (p *Puller) modelProcessor(modelName string, ops <-chan *ModelOp) {
 	p.logger.Infof("Worker is started for %s", modelName)
 	// TODO: Instead of going through each event, one-by-one, we need to drain and combine
 	// this is important for handling Load --> Unload requests sent in tandem
 	// Load --> Unload = 0 (cancel first load)
 	// Load --> Unload --> Load = 1 Load (cancel second load?)
-	for modelOp := range ops {
+	var next *ModelOp
+	for {
+		modelOp := next
+		next = nil
+		if modelOp == nil {
+			op, ok := <-ops
+			if !ok {
+				return
+			}
+			modelOp = op
+		}
+		if modelOp.started != nil {
+			modelOp.started()
+		}
 		switch modelOp.Op {
 		case Add:
-			p.logger.Infof("Downloading model from %s", modelOp.Spec.StorageURI)
-			err := p.Downloader.DownloadModel(modelName, modelOp.Spec)
-			if err != nil {
-				// If there is an error, we will NOT send a request. As such, to know about errors, you will
-				// need to call the error endpoint of the puller
-				p.logger.Errorf("Failed to download model %s with err %v", modelName, err)
-			} else {
-				// Load the model onto the model server
-				resp, err := http.Post(fmt.Sprintf("http://localhost:8080/v2/repository/models/%s/load", modelName),
-					"application/json",
-					bytes.NewBufferString("{}"))
-				if err != nil {
-					// handle error
-					p.logger.Errorf("Failed to Load model %s", modelName)
-				} else {
-					defer resp.Body.Close()
-					if resp.StatusCode == 200 {
-						p.logger.Infof("Successfully loaded model %s", modelName)
-					} else {
-						body, err := ioutil.ReadAll(resp.Body)
-						if err == nil {
-							p.logger.Infof("Failed to load model %s with status [%d] and resp:%v", modelName, resp.StatusCode, body)
-						}
-					}
-				}
-			}
+			next = p.addModel(modelName, modelOp, ops)
 		case Remove:
-			p.logger.Infof("unloading model %s", modelName)
-			// If there is an error, we will NOT do a delete... that could be problematic
-			if err := storage.RemoveDir(filepath.Join(p.Downloader.ModelDir, modelName)); err != nil {
-				p.logger.Error(err, "failing to delete model directory")
+			if modelOp.EvictedFor != "" {
+				modelOp.evicted <- p.evictModel(modelName, modelOp.EvictedFor)
 			} else {
-				// unload model from model server
-				resp, err := http.Post(fmt.Sprintf("http://localhost:8080/v2/repository/models/%s/unload", modelName),
-					"application/json",
-					bytes.NewBufferString("{}"))
-				if err != nil {
-					// handle error
-					p.logger.Errorf("Failed to Unload model %s", modelName)
-				} else {
-					defer resp.Body.Close()
-					if resp.StatusCode == 200 {
-						p.logger.Infof("Successfully unloaded model %s", modelName)
-					} else {
-						body, err := ioutil.ReadAll(resp.Body)
-						if err == nil {
-							p.logger.Infof("Failed to unload model %s with status [%d] and resp:%v", modelName, resp.StatusCode, body)
-						}
-					}
-				}
+				p.removeModel(modelName)
 			}
 		}
+		p.updateDiskUsage()
 		p.completions <- modelOp
 	}
 }
+
+// addModel downloads and loads the model, retrying with an exponential backoff until the attempts are exhausted
+// and the model is dead lettered as FailedToLoad. An op received for the model while backing off cancels the
+// retries and is returned to be processed next. When the model is updated, the previous version is deleted once
+// the new version is loaded, or restored when the new version fails so that the model is still served.
+func (p *Puller) addModel(modelName string, modelOp *ModelOp, ops <-chan *ModelOp) *ModelOp {
+	loaded := false
+	// the disk space stays reserved for the model while its download is retried
+	defer func() {
+		p.diskQuota.Release(modelName, loaded)
+	}()
+	if modelOp.Repull {
+		// a re-pull starts over rather than resuming the partial download
+		if err := p.Downloader.RemoveStaging(modelName); err != nil {
+			p.logger.Errorf("Failed to remove the partial download of model %s with err %v", modelName, err)
+		}
+	}
+	backoff := p.retryConfig.backoff()
+	for attempt := 1; ; attempt++ {
+		err := p.downloadAndLoadModel(modelName, modelOp.Spec, modelOp.Repull)
+		if err == nil {
+			loaded = true
+			if err := p.Downloader.RemovePreviousVersion(modelName); err != nil {
+				p.logger.Errorf("Failed to delete the previous version of model %s with err %v", modelName, err)
+			}
+			p.logger.Infof("Successfully loaded model %s", modelName)
+			p.modelStatus.Set(modelName, status.ModelLoaded, "")
+			return nil
+		}
+		if attempt >= p.retryConfig.MaxAttempts {
+			p.logger.Errorf("Failed to load model %s after %d attempts with err %v", modelName, attempt, err)
+			message := fmt.Sprintf("failed after %d attempts: %v", attempt, err)
+			if p.restorePreviousVersion(modelName, true) {
+				loaded = true
+				message += ", the previous version is still served"
+			}
+			p.modelStatus.Set(modelName, status.ModelFailedToLoad, message)
+			return nil
+		}
+		delay := backoff.Step()
+		p.logger.Errorf("Failed to load model %s on attempt %d with err %v, retrying in %s", modelName, attempt, err, delay)
+		p.modelStatus.Set(modelName, status.ModelRetrying,
+			fmt.Sprintf("attempt %d failed, retrying in %s: %v", attempt, delay, err))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case next, ok := <-ops:
+			timer.Stop()
+			p.logger.Infof("Cancelling retries of model %s", modelName)
+			// the next op starts from the previous version, it is loaded again by the next add
+			p.restorePreviousVersion(modelName, false)
+			if ok {
+				return next
+			}
+			return nil
+		}
+	}
+}
+
+// restorePreviousVersion moves the previous version of the model back in place after its update failed, and loads
+// it again in case the model server dropped it. It returns true when the previous version is restored and loaded.
+func (p *Puller) restorePreviousVersion(modelName string, load bool) bool {
+	restored, err := p.Downloader.RestorePreviousVersion(modelName)
+	if err != nil {
+		p.logger.Errorf("Failed to restore the previous version of model %s with err %v", modelName, err)
+		return false
+	}
+	if !restored || !load {
+		return restored
+	}
+	p.logger.Infof("Loading the previous version of model %s", modelName)
+	if err := p.loadModel(modelName, nil); err != nil {
+		p.logger.Errorf("Failed to load the previous version of model %s with err %v", modelName, err)
+		return false
+	}
+	return true
+}
+
+func (p *Puller) downloadAndLoadModel(modelName string, spec *v1.ModelSpec, repull bool) error {
+	p.logger.Infof("Downloading model from %s", spec.StorageURI)
+	p.modelStatus.Start(modelName, status.SpecVersion(spec))
+	if p.diskQuota != nil {
+		// the size is only an estimate for archives and unknown for the providers without object metadata,
+		// the reservation is updated with the size on disk once the model is downloaded
+		size, err := p.Downloader.ModelSize(spec)
+		if err != nil {
+			return fmt.Errorf("failed to get model size: %v", err)
+		}
+		if size < 0 {
+			size = 0
+		}
+		if err := p.reserveDisk(modelName, size); err != nil {
+			return err
+		}
+	}
+	download := p.Downloader.DownloadModel
+	if repull {
+		download = p.Downloader.RedownloadModel
+	}
+	if err := download(modelName, spec); err != nil {
+		// If there is an error, we will NOT send a request
+		return err
+	}
+	if p.diskQuota != nil {
+		modelPath := filepath.Join(p.Downloader.ModelDir, modelName)
+		size, err := dirSize(modelPath)
+		if err != nil {
+			return err
+		}
+		if err := p.reserveDisk(modelName, size); err != nil {
+			// the model does not fit, free the space it took
+			if removeErr := storage.RemoveDir(modelPath); removeErr != nil {
+				p.logger.Errorf("Failed to delete model %s which exceeds the quota with err %v", modelName, removeErr)
+			}
+			return err
+		}
+	}
+	// Load the model onto the model server
+	p.modelStatus.Set(modelName, status.ModelLoading, "")
+	if err := p.loadModel(modelName, spec); err != nil {
+		return fmt.Errorf("failed to load model: %v", err)
+	}
+	return nil
+}
+
+// reserveDisk makes room for size bytes of the model in the model dir, evicting the least recently requested models.
+// The evicted models are removed by their own processors, ordered with their other ops, and waited for.
+func (p *Puller) reserveDisk(modelName string, size int64) error {
+	evicted, err := p.diskQuota.Reserve(modelName, size)
+	if err != nil {
+		return err
+	}
+	results := make([]chan error, len(evicted))
+	for i, evictedName := range evicted {
+		results[i] = make(chan error, 1)
+		p.evictions <- &ModelOp{ModelName: evictedName, Op: Remove, EvictedFor: modelName, evicted: results[i]}
+	}
+	for i, result := range results {
+		if evictErr := <-result; evictErr != nil && err == nil {
+			err = fmt.Errorf("while evicting model %s: %s", evicted[i], evictErr)
+		}
+	}
+	return err
+}
+
+// evictModel unloads and deletes the model to free disk space for another model, the model is not pulled again
+// until its config changes or a retry is requested
+func (p *Puller) evictModel(modelName string, forModel string) error {
+	// the model is chosen again by a later reservation when its eviction fails
+	defer p.diskQuota.Forget(modelName)
+	modelPath := filepath.Join(p.Downloader.ModelDir, modelName)
+	if _, err := os.Stat(modelPath); os.IsNotExist(err) {
+		// the model was removed before its eviction was processed
+		return nil
+	}
+	p.logger.Infof("Evicting model %s to free disk space for model %s", modelName, forModel)
+	if err := p.unloadModel(modelName); err != nil {
+		// the model is deleted anyway, it may have failed to load
+		p.logger.Errorf("Failed to unload evicted model %s with err %v", modelName, err)
+	}
+	if err := storage.RemoveDir(modelPath); err != nil {
+		return err
+	}
+	evictionsTotal.Inc()
+	p.modelStatus.Set(modelName, status.ModelEvicted,
+		fmt.Sprintf("evicted to free disk space for model %s", forModel))
+	return nil
+}
+
+// loadModel loads the model on the model server and records the latency of the request
+func (p *Puller) loadModel(modelName string, spec *v1.ModelSpec) error {
+	ctx, cancel := p.retryConfig.modelServerContext()
+	defer cancel()
+	start := time.Now()
+	err := p.modelServer.LoadModel(ctx, modelName, spec)
+	modelServerRequestSeconds.WithLabelValues(OperationLoad, resultLabel(err)).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// unloadModel unloads the model from the model server and records the latency of the request
+func (p *Puller) unloadModel(modelName string) error {
+	ctx, cancel := p.retryConfig.modelServerContext()
+	defer cancel()
+	start := time.Now()
+	err := p.modelServer.UnloadModel(ctx, modelName)
+	modelServerRequestSeconds.WithLabelValues(OperationUnload, resultLabel(err)).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// updateDiskUsage records the size of the model dir
+func (p *Puller) updateDiskUsage() {
+	size, err := dirSize(p.Downloader.ModelDir)
+	if err != nil {
+		p.logger.Errorf("Failed to get the size of the model dir with err %v", err)
+		return
+	}
+	modelDirBytes.Set(float64(size))
+}
+
+func (p *Puller) removeModel(modelName string) {
+	p.logger.Infof("unloading model %s", modelName)
+	p.diskQuota.Forget(modelName)
+	if err := p.Downloader.RemoveStaging(modelName); err != nil {
+		p.logger.Errorf("Failed to remove the partial download of model %s with err %v", modelName, err)
+	}
+	// If there is an error, we will NOT do a delete... that could be problematic
+	if err := p.Downloader.RemovePreviousVersion(modelName); err != nil {
+		p.logger.Errorf("Failed to delete the previous version of model %s with err %v", modelName, err)
+	}
+	if err := storage.RemoveDir(filepath.Join(p.Downloader.ModelDir, modelName)); err != nil {
+		p.logger.Error(err, "failing to delete model directory")
+		return
+	}
+	// unload model from model server
+	if err := p.unloadModel(modelName); err != nil {
+		p.logger.Errorf("Failed to Unload model %s with err %v", modelName, err)
+		return
+	}
+	p.logger.Infof("Successfully unloaded model %s", modelName)
+	p.modelStatus.Delete(modelName)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kserve/kserve/pkg/agent/modelserver"
+	"github.com/kserve/kserve/pkg/agent/status"
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	"github.com/onsi/gomega"
+	"go.uber.org/zap"
+)
+
+func TestPullerModelServerTimeout(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	// the model server does not answer until the test is done
+	release := make(chan struct{})
+	modelServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer modelServer.Close()
+	defer close(release)
+	puller := NewPuller(&Downloader{}, modelserver.NewV2Adapter(modelServer.URL), nil, nil,
+		RetryConfig{ModelServerTimeout: 50 * time.Millisecond}, zap.NewNop().Sugar())
+
+	start := time.Now()
+	err := puller.loadModel("model1", &v1alpha1.ModelSpec{})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("context deadline exceeded")))
+	err = puller.unloadModel("model1")
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("context deadline exceeded")))
+	g.Expect(time.Since(start)).To(gomega.BeNumerically("<", 5*time.Second))
+}
+
+func TestPullerEvictionDoesNotBlockRequests(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	quota, _ := newTestDiskQuota(g, 150)
+	defer os.RemoveAll(quota.ModelDir)
+	writeModel(g, quota.ModelDir, "model1", 100)
+	// the model server holds the unload of the evicted model until the requests are served
+	unloading := make(chan struct{})
+	release := make(chan struct{})
+	modelServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(unloading)
+		<-release
+	}))
+	defer modelServer.Close()
+	modelStatus := status.NewStore()
+	puller := NewPuller(&Downloader{ModelDir: quota.ModelDir}, modelserver.NewV2Adapter(modelServer.URL), quota,
+		modelStatus, RetryConfig{}, zap.NewNop().Sugar())
+	commands := make(chan ModelOp)
+	defer close(commands)
+	go puller.processCommands(commands)
+
+	reserved := make(chan error, 1)
+	go func() {
+		reserved <- puller.reserveDisk("model2", 100)
+	}()
+	<-unloading
+
+	// the quota is not held while model1 is evicted
+	requested := make(chan struct{})
+	go func() {
+		handler := TrackModelRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), quota)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/models/model1:predict", nil))
+		quota.Touch("model2")
+		close(requested)
+	}()
+	g.Eventually(requested, 5*time.Second).Should(gomega.BeClosed())
+	g.Consistently(reserved, 100*time.Millisecond).ShouldNot(gomega.Receive())
+
+	close(release)
+	g.Eventually(reserved, 5*time.Second).Should(gomega.Receive(gomega.BeNil()))
+	_, err := os.Stat(filepath.Join(quota.ModelDir, "model1"))
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+	modelState, ok := modelStatus.Get("model1")
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(modelState.State).To(gomega.Equal(status.ModelEvicted))
+	g.Expect(quota.evicting).To(gomega.BeEmpty())
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultClientTimeout bounds a status request so that an unresponsive agent does not hold up a reconcile
+const DefaultClientTimeout = 5 * time.Second
+
+// Client reads the model status from the agents
+type Client struct {
+	HTTPClient *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: DefaultClientTimeout},
+	}
+}
+
+// GetModelStatus returns the status of the model from the agent listening on host, nil when the agent
+// does not know about the model yet
+func (c *Client) GetModelStatus(ctx context.Context, host string, name string) (*ModelStatus, error) {
+	statusUrl := url.URL{
+		Scheme: "http",
+		Host:   host,
+		Path:   ModelsPath + "/" + name,
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, statusUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("while getting status of model %s from %s: %s", name, host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d getting status of model %s from %s", resp.StatusCode, name, host)
+	}
+	status := &ModelStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, fmt.Errorf("while decoding status of model %s from %s: %s", name, host, err)
+	}
+	return status, nil
+}
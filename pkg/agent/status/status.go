@@ -0,0 +1,197 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package status keeps the load state of the models pulled by the agent and serves it to the controller
+package status
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+)
+
+// ModelState is the load state of a model on the agent
+type ModelState string
+
+const (
+	// ModelDownloading is set while the model is downloaded to the model dir
+	ModelDownloading ModelState = "Downloading"
+	// ModelLoading is set while the model server loads the model
+	ModelLoading ModelState = "Loading"
+	// ModelLoaded is set once the model server loaded the model
+	ModelLoaded ModelState = "Loaded"
+	// ModelRetrying is set while the agent backs off before attempting the download and load again, the message
+	// has the cause of the failed attempt
+	ModelRetrying ModelState = "Retrying"
+	// ModelEvicted is set when the model is unloaded and deleted to free disk space for another model, it is not
+	// pulled again until its config changes or a retry is requested
+	ModelEvicted ModelState = "Evicted"
+	// ModelFailedToLoad is set when the download or the load failed on every attempt, the message has the cause.
+	// The model is not attempted again until its config changes or a retry is requested.
+	ModelFailedToLoad ModelState = "FailedToLoad"
+)
+
+// ModelsPath is the path of the model status api served by the agent
+const ModelsPath = "/v1/models"
+
+// specVersionHashVersion is bumped whenever the fields of the spec version hash change, the success files of other
+// versions are migrated by the agent on startup
+const specVersionHashVersion = "v1"
+
+// SpecVersion returns the version of the model spec, which names the success file of the downloaded model and is
+// reported with its state. The hash only covers the fields which change the downloaded model and does not depend on
+// the layout of the spec, so that adding a field to the spec does not download every model again.
+func SpecVersion(modelSpec *v1alpha1.ModelSpec) string {
+	h := sha256.New()
+	// the fields are length prefixed so that their boundaries are unambiguous
+	for _, field := range []string{modelSpec.StorageURI, modelSpec.Framework} {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return fmt.Sprintf("%s-%x", specVersionHashVersion, h.Sum(nil))
+}
+
+// ModelStatus is the state of a model as reported by the agent
+type ModelStatus struct {
+	Name string `json:"name"`
+	// Version is the SpecVersion of the model spec the state applies to, a state of another version is stale
+	Version            string     `json:"version,omitempty"`
+	State              ModelState `json:"state"`
+	Message            string     `json:"message,omitempty"`
+	LastTransitionTime time.Time  `json:"lastTransitionTime"`
+	// LastError is the cause of the last failed attempt of the model, it is kept once the model is loaded
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Store holds the status of the models of the agent, it is safe for concurrent use and a nil Store drops
+// every update so that the puller does not need to check whether the status is published.
+type Store struct {
+	mu     sync.RWMutex
+	models map[string]ModelStatus
+}
+
+func NewStore() *Store {
+	return &Store{
+		models: map[string]ModelStatus{},
+	}
+}
+
+// Start records that the version of the model is downloaded, the states set afterwards apply to the version
+func (s *Store) Start(name string, version string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, ok := s.models[name]
+	if !ok || status.State != ModelDownloading || status.Version != version {
+		status.LastTransitionTime = time.Now().UTC()
+	}
+	status.Name = name
+	status.Version = version
+	status.State = ModelDownloading
+	status.Message = ""
+	s.models[name] = status
+}
+
+// Set records the state of the model, the transition time is only updated when the state changes
+func (s *Store) Set(name string, state ModelState, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, ok := s.models[name]
+	if !ok || status.State != state {
+		status.LastTransitionTime = time.Now().UTC()
+	}
+	status.Name = name
+	status.State = state
+	status.Message = message
+	if state == ModelRetrying || state == ModelFailedToLoad {
+		status.LastError = message
+	}
+	s.models[name] = status
+}
+
+// Delete forgets the model once it is unloaded
+func (s *Store) Delete(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.models, name)
+}
+
+// Get returns the status of the model and whether the agent knows about it
+func (s *Store) Get(name string) (ModelStatus, bool) {
+	if s == nil {
+		return ModelStatus{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status, ok := s.models[name]
+	return status, ok
+}
+
+// List returns the status of every model sorted by name
+func (s *Store) List() []ModelStatus {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]ModelStatus, 0, len(s.models))
+	for _, status := range s.models {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// ServeHTTP serves the status of every model on ModelsPath and the status of a single model on
+// ModelsPath/{name}, unknown models are reported as not found.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body interface{}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, ModelsPath), "/")
+	if name == "" {
+		body = s.List()
+	} else {
+		status, ok := s.Get(name)
+		if !ok {
+			http.Error(w, "model not found", http.StatusNotFound)
+			return
+		}
+		body = status
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestStore(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	store := NewStore()
+	store.Set("model2", ModelDownloading, "")
+	store.Set("model1", ModelLoading, "")
+	loading, _ := store.Get("model1")
+
+	store.Set("model1", ModelLoading, "still loading")
+	status, ok := store.Get("model1")
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(status.Message).To(gomega.Equal("still loading"))
+	// the transition time only moves when the state changes
+	g.Expect(status.LastTransitionTime).To(gomega.Equal(loading.LastTransitionTime))
+
+	store.Set("model1", ModelFailedToLoad, "out of memory")
+	status, _ = store.Get("model1")
+	g.Expect(status.State).To(gomega.Equal(ModelFailedToLoad))
+	g.Expect(status.LastTransitionTime).NotTo(gomega.BeTemporally("<", loading.LastTransitionTime))
+	store.Set("model1", ModelLoaded, "")
+	status, _ = store.Get("model1")
+	// the last error is kept once the model is loaded
+	g.Expect(status.LastError).To(gomega.Equal("out of memory"))
+
+	statuses := store.List()
+	g.Expect(statuses).To(gomega.HaveLen(2))
+	g.Expect(statuses[0].Name).To(gomega.Equal("model1"))
+	g.Expect(statuses[1].Name).To(gomega.Equal("model2"))
+
+	store.Delete("model2")
+	_, ok = store.Get("model2")
+	g.Expect(ok).To(gomega.BeFalse())
+
+	// the states set once a version is started apply to the version
+	store.Start("model1", "v1-new")
+	store.Set("model1", ModelLoaded, "")
+	status, _ = store.Get("model1")
+	g.Expect(status.Version).To(gomega.Equal("v1-new"))
+	g.Expect(status.State).To(gomega.Equal(ModelLoaded))
+
+	// a nil store drops every update
+	var nilStore *Store
+	nilStore.Start("model1", "v1-new")
+	nilStore.Set("model1", ModelLoaded, "")
+	_, ok = nilStore.Get("model1")
+	g.Expect(ok).To(gomega.BeFalse())
+	g.Expect(nilStore.List()).To(gomega.BeEmpty())
+}
+
+func TestClient(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	store := NewStore()
+	store.Set("model1", ModelFailedToLoad, "failed to download model")
+	server := httptest.NewServer(store)
+	defer server.Close()
+	serverUrl, _ := url.Parse(server.URL)
+
+	client := NewClient()
+	status, err := client.GetModelStatus(context.Background(), serverUrl.Host, "model1")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(status.State).To(gomega.Equal(ModelFailedToLoad))
+	g.Expect(status.Message).To(gomega.Equal("failed to download model"))
+
+	status, err = client.GetModelStatus(context.Background(), serverUrl.Host, "model2")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(status).To(gomega.BeNil())
+
+	resp, err := http.Get(server.URL + ModelsPath)
+	g.Expect(err).To(gomega.BeNil())
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK))
+
+	resp, err = http.Post(server.URL+ModelsPath+"/model1", "application/json", nil)
+	g.Expect(err).To(gomega.BeNil())
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusMethodNotAllowed))
+}
+
+func TestSpecVersion(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelSpec := &v1alpha1.ModelSpec{StorageURI: "s3://models/model1", Framework: "sklearn", Memory: resource.MustParse("1Gi")}
+	g.Expect(SpecVersion(modelSpec)).To(gomega.HavePrefix("v1-"))
+	// the fields which do not change the downloaded model do not change the version
+	g.Expect(SpecVersion(&v1alpha1.ModelSpec{StorageURI: "s3://models/model1", Framework: "sklearn"})).
+		To(gomega.Equal(SpecVersion(modelSpec)))
+	g.Expect(SpecVersion(&v1alpha1.ModelSpec{StorageURI: "s3://models/model1/v2", Framework: "sklearn"})).
+		NotTo(gomega.Equal(SpecVersion(modelSpec)))
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// azureBlobURIRegex matches https://{account}.blob.core.windows.net/{container}/{prefix} uris
+var azureBlobURIRegex = regexp.MustCompile(`^https://([^./]+)\.blob\.core\.windows\.net/([^/?]+)/?([^?]*)`)
+
+// IsAzureBlobURI returns true for the uris of Azure Blob Storage, which are downloaded by the AzureBlob provider
+// rather than as an HTTPS download
+func IsAzureBlobURI(storageUri string) bool {
+	return azureBlobURIRegex.MatchString(storageUri)
+}
+
+type azureBlobURI struct {
+	account   string
+	container string
+	prefix    string
+	// sas is the shared access signature of the uri query
+	sas string
+}
+
+func parseAzureBlobURI(storageUri string) (*azureBlobURI, error) {
+	match := azureBlobURIRegex.FindStringSubmatch(storageUri)
+	if match == nil {
+		return nil, fmt.Errorf("invalid azure blob uri %s", storageUri)
+	}
+	uri := &azureBlobURI{account: match[1], container: match[2], prefix: match[3]}
+	if i := strings.Index(storageUri, "?"); i >= 0 {
+		uri.sas = storageUri[i+1:]
+	}
+	return uri, nil
+}
+
+// AzureBlobProvider downloads the blobs under the prefix of https://{account}.blob.core.windows.net/{container}/{prefix}
+// uris. A shared access signature in the uri query takes precedence over the credentials of the provider, the
+// public containers are read anonymously when the provider has no credentials.
+type AzureBlobProvider struct {
+	// TokenCredential authenticates with a service principal
+	TokenCredential azcore.TokenCredential
+	// AccountKey authenticates with the shared key of the storage account of the uri
+	AccountKey string
+	// ServiceURL overrides the https://{account}.blob.core.windows.net/ service url of the accounts, e.g. for a
+	// storage emulator
+	ServiceURL string
+	Options    DownloadOptions
+
+	mu      sync.Mutex
+	clients map[string]*azblob.Client
+}
+
+func (p *AzureBlobProvider) DownloadModel(modelDir string, modelName string, storageUri string) error {
+	log.Info("Downloading model ", "modelName", modelName, "storageUri", storageUri, "modelDir", modelDir)
+	uri, err := parseAzureBlobURI(storageUri)
+	if err != nil {
+		return err
+	}
+	client, err := p.client(uri)
+	if err != nil {
+		return fmt.Errorf("unable to create azure blob client because: %v", err)
+	}
+	downloader := &AzureBlobDownloader{
+		Context:   context.Background(),
+		Client:    client,
+		ModelDir:  modelDir,
+		ModelName: modelName,
+		Container: uri.container,
+		Prefix:    uri.prefix,
+		Options:   p.Options,
+	}
+	blobs, err := downloader.ListBlobs()
+	if err != nil {
+		return fmt.Errorf("unable to list blobs because: %v", err)
+	}
+	if err := downloader.Download(blobs); err != nil {
+		return fmt.Errorf("unable to download blob/s because: %v", err)
+	}
+	return nil
+}
+
+var _ Sizer = (*AzureBlobProvider)(nil)
+
+// ModelSize sums the size of the blobs under the model prefix
+func (p *AzureBlobProvider) ModelSize(storageUri string) (int64, error) {
+	uri, err := parseAzureBlobURI(storageUri)
+	if err != nil {
+		return 0, err
+	}
+	client, err := p.client(uri)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create azure blob client because: %v", err)
+	}
+	blobs, err := (&AzureBlobDownloader{Context: context.Background(), Client: client, Container: uri.container,
+		Prefix: uri.prefix}).ListBlobs()
+	if err != nil {
+		return 0, fmt.Errorf("unable to list blobs because: %v", err)
+	}
+	var size int64
+	for _, item := range blobs {
+		if item.Properties.ContentLength != nil {
+			size += *item.Properties.ContentLength
+		}
+	}
+	return size, nil
+}
+
+// client returns the client of the storage account of the uri, the clients are reused across downloads
+func (p *AzureBlobProvider) client(uri *azureBlobURI) (*azblob.Client, error) {
+	serviceURL := p.ServiceURL
+	if serviceURL == "" {
+		serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", uri.account)
+	}
+	if uri.sas != "" {
+		serviceURL = strings.TrimSuffix(serviceURL, "/") + "/?" + uri.sas
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[serviceURL]; ok {
+		return client, nil
+	}
+	var client *azblob.Client
+	var err error
+	switch {
+	case uri.sas != "":
+		client, err = azblob.NewClientWithNoCredential(serviceURL, nil)
+	case p.AccountKey != "":
+		var credential *azblob.SharedKeyCredential
+		credential, err = azblob.NewSharedKeyCredential(uri.account, p.AccountKey)
+		if err != nil {
+			return nil, err
+		}
+		client, err = azblob.NewClientWithSharedKeyCredential(serviceURL, credential, nil)
+	case p.TokenCredential != nil:
+		client, err = azblob.NewClient(serviceURL, p.TokenCredential, nil)
+	default:
+		client, err = azblob.NewClientWithNoCredential(serviceURL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.clients == nil {
+		p.clients = map[string]*azblob.Client{}
+	}
+	p.clients[serviceURL] = client
+	return client, nil
+}
+
+type AzureBlobDownloader struct {
+	Context   context.Context
+	Client    *azblob.Client
+	ModelDir  string
+	ModelName string
+	Container string
+	Prefix    string
+	Options   DownloadOptions
+}
+
+// ListBlobs returns the blobs under the prefix, an error is returned when there are none
+func (a *AzureBlobDownloader) ListBlobs() ([]*container.BlobItem, error) {
+	var blobs []*container.BlobItem
+	pager := a.Client.NewListBlobsFlatPager(a.Container, &azblob.ListBlobsFlatOptions{Prefix: &a.Prefix})
+	for pager.More() {
+		page, err := pager.NextPage(a.Context)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil || item.Properties == nil || strings.HasSuffix(*item.Name, "/") {
+				continue
+			}
+			blobs = append(blobs, item)
+		}
+	}
+	if len(blobs) == 0 {
+		return nil, fmt.Errorf("no blobs found under %s/%s", a.Container, a.Prefix)
+	}
+	return blobs, nil
+}
+
+// Download fetches the blobs with up to Options.Parallelism blobs at a time
+func (a *AzureBlobDownloader) Download(blobs []*container.BlobItem) error {
+	errs := runParallel(len(blobs), a.Options.parallelism(), func(i int) error {
+		return a.DownloadFile(blobs[i])
+	})
+	if len(errs) > 0 {
+		return awserr.NewBatchError("AzureBlobDownloadIncomplete", "some blobs failed to download.", errs)
+	}
+	return nil
+}
+
+// DownloadFile fetches the blob into its partial file, resuming from the end of a partial file of the same etag,
+// and moves it in place once its checksum is verified
+func (a *AzureBlobDownloader) DownloadFile(item *container.BlobItem) error {
+	name := *item.Name
+	fileName := filepath.Join(a.ModelDir, a.ModelName, strings.TrimPrefix(name, a.Prefix))
+	file, err := openPartialFile(fileName)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+	version := ""
+	if item.Properties.ETag != nil {
+		version = string(*item.Properties.ETag)
+	}
+	size := int64(-1)
+	if item.Properties.ContentLength != nil {
+		size = *item.Properties.ContentLength
+	}
+	if err := file.resume(version, size); err != nil {
+		return fmt.Errorf("unable to resume file: %v", err)
+	}
+	// the md5 of the blobs uploaded in blocks is only set when the uploader computed it
+	if !a.Options.SkipChecksums && len(item.Properties.ContentMD5) > 0 {
+		file.checksum = MD5Checksum(item.Properties.ContentMD5)
+	}
+	if !file.complete() {
+		options := &azblob.DownloadStreamOptions{Range: blob.HTTPRange{Offset: file.offset}}
+		if version != "" {
+			// a blob replaced while it is downloaded fails rather than mixing its versions
+			etag := azcore.ETag(version)
+			options.AccessConditions = &blob.AccessConditions{
+				ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: &etag},
+			}
+		}
+		resp, err := a.Client.DownloadStream(a.Context, a.Container, name, options)
+		if err != nil {
+			return fmt.Errorf("failed to download blob(%s) in container(%s): %v", name, a.Container, err)
+		}
+		defer resp.Body.Close()
+		if _, err := io.Copy(file, resp.Body); err != nil {
+			return fmt.Errorf("failed to write data to file(%s): from blob(%s) in container(%s): %v",
+				fileName, name, a.Container, err)
+		}
+	}
+	if err := file.commit(); err != nil {
+		return err
+	}
+	log.Info("Wrote " + name + " to file " + fileName)
+	return nil
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+type stubBlob struct {
+	contents string
+	md5      string
+}
+
+// newAzureBlobServer stubs the List Blobs and Get Blob operations of the blob service for the container
+func newAzureBlobServer(containerName string, blobs map[string]stubBlob, requests *[]*http.Request) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*requests = append(*requests, r)
+		mu.Unlock()
+		path := strings.TrimPrefix(r.URL.Path, "/"+containerName)
+		if path == "" && r.URL.Query().Get("comp") == "list" {
+			prefix := r.URL.Query().Get("prefix")
+			var items strings.Builder
+			for name, blob := range blobs {
+				if !strings.HasPrefix(name, prefix) {
+					continue
+				}
+				fmt.Fprintf(&items, "<Blob><Name>%s</Name><Properties><Content-Length>%d</Content-Length>"+
+					"<Content-MD5>%s</Content-MD5><Etag>\"0x1\"</Etag><BlobType>BlockBlob</BlobType></Properties></Blob>",
+					name, len(blob.contents), base64.StdEncoding.EncodeToString(md5Sum(blob.md5)))
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="%s">`+
+				`<Prefix>%s</Prefix><Blobs>%s</Blobs><NextMarker /></EnumerationResults>`, containerName, prefix, items.String())
+			return
+		}
+		blob, ok := blobs[strings.TrimPrefix(path, "/")]
+		if !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"0x1"`)
+		// the blob service takes the range in x-ms-range
+		if rangeHeader := r.Header.Get("x-ms-range"); rangeHeader != "" {
+			r.Header.Set("Range", rangeHeader)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader([]byte(blob.contents)))
+	}))
+}
+
+func TestAzureBlobProviderDownloadModel(t *testing.T) {
+	blobs := map[string]stubBlob{
+		"models/model1/":                 {},
+		"models/model1/model.bin":        {contents: modelContents, md5: modelContents},
+		"models/model1/1/variables.data": {contents: "Variables", md5: "Variables"},
+		"models/model2/model.bin":        {contents: "Other Model", md5: "Other Model"},
+		"models/corrupted/model.bin":     {contents: modelContents, md5: "other contents"},
+	}
+	scenarios := map[string]struct {
+		storageUri    string
+		partial       string
+		expectedFiles map[string]string
+		expectedSas   string
+		expectedRange string
+		expectedErr   string
+	}{
+		"Prefix": {
+			storageUri: "https://account.blob.core.windows.net/container/models/model1/",
+			expectedFiles: map[string]string{
+				"model.bin":        modelContents,
+				"1/variables.data": "Variables",
+			},
+		},
+		"SharedAccessSignature": {
+			storageUri:    "https://account.blob.core.windows.net/container/models/model2/?sv=2021-06-08&sig=signature",
+			expectedFiles: map[string]string{"model.bin": "Other Model"},
+			expectedSas:   "signature",
+		},
+		"Resumed": {
+			storageUri:    "https://account.blob.core.windows.net/container/models/model2/",
+			partial:       "Other",
+			expectedFiles: map[string]string{"model.bin": "Other Model"},
+			expectedRange: "bytes=5-",
+		},
+		"ChecksumMismatch": {
+			storageUri:  "https://account.blob.core.windows.net/container/models/corrupted/",
+			expectedErr: "md5 checksum mismatch",
+		},
+		"NoBlobs": {
+			storageUri:  "https://account.blob.core.windows.net/container/models/model3/",
+			expectedErr: "no blobs found",
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			var requests []*http.Request
+			server := newAzureBlobServer("container", blobs, &requests)
+			defer server.Close()
+			modelDir, _ := ioutil.TempDir("", "azure")
+			defer os.RemoveAll(modelDir)
+			if scenario.partial != "" {
+				writePartialFile(g, filepath.Join(modelDir, "model1", "model.bin"), scenario.partial, `"0x1"`)
+			}
+			provider := &AzureBlobProvider{ServiceURL: server.URL}
+
+			err := provider.DownloadModel(modelDir, "model1", scenario.storageUri)
+			if scenario.expectedErr != "" {
+				g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(scenario.expectedErr)))
+				g.Expect(filepath.Join(modelDir, "model1", "model.bin")).NotTo(gomega.BeAnExistingFile())
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			for file, expected := range scenario.expectedFiles {
+				contents, err := ioutil.ReadFile(filepath.Join(modelDir, "model1", file))
+				g.Expect(err).To(gomega.BeNil())
+				g.Expect(string(contents)).To(gomega.Equal(expected))
+			}
+			for _, r := range requests {
+				g.Expect(r.URL.Query().Get("sig")).To(gomega.Equal(scenario.expectedSas))
+				if r.URL.Query().Get("comp") == "" {
+					g.Expect(r.Header.Get("If-Match")).To(gomega.Equal(`"0x1"`))
+					g.Expect(r.Header.Get("x-ms-range")).To(gomega.Equal(scenario.expectedRange))
+				}
+			}
+		})
+	}
+}
+
+func TestAzureBlobProviderModelSize(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var requests []*http.Request
+	server := newAzureBlobServer("container", map[string]stubBlob{
+		"model1/model.bin":        {contents: modelContents},
+		"model1/1/variables.data": {contents: "Variables"},
+	}, &requests)
+	defer server.Close()
+	provider := &AzureBlobProvider{ServiceURL: server.URL}
+
+	size, err := provider.ModelSize("https://account.blob.core.windows.net/container/model1")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(size).To(gomega.Equal(int64(len(modelContents) + len("Variables"))))
+}
+
+func TestIsAzureBlobURI(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	g.Expect(IsAzureBlobURI("https://account.blob.core.windows.net/container/model1")).To(gomega.BeTrue())
+	g.Expect(IsAzureBlobURI("https://account.blob.core.windows.net/container")).To(gomega.BeTrue())
+	g.Expect(IsAzureBlobURI("https://example.com/model1/model.bin")).To(gomega.BeFalse())
+	g.Expect(IsAzureBlobURI("https://account.blob.core.windows.net.example.com/container")).To(gomega.BeFalse())
+}
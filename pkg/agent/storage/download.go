@@ -0,0 +1,264 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// StagingDirName is the dir of the model dir the models are downloaded to before they are moved in place
+	StagingDirName = ".staging"
+	// DefaultDownloadParallelism is the number of objects of a model downloaded concurrently
+	DefaultDownloadParallelism = 4
+
+	partialFileSuffix = ".partial"
+	versionFileSuffix = ".version"
+)
+
+// md5ETagRegex matches the etags which are the md5 of the object, the etags of multipart uploads have a part count suffix
+var md5ETagRegex = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// DownloadOptions tunes how the providers download the objects of a model
+type DownloadOptions struct {
+	// Parallelism is the number of objects downloaded concurrently, DefaultDownloadParallelism when not set
+	Parallelism int
+	// SkipChecksums disables the verification of the downloaded objects against the checksums of the storage
+	SkipChecksums bool
+	// LocalLinkMode is how the files of the file:// and pvc:// uris are placed in the model dir, LinkModeCopy when
+	// not set
+	LocalLinkMode LinkMode
+	// LocalRoots are the mounted dirs the file:// uris may read from besides the PVC dir
+	LocalRoots []string
+}
+
+func (o DownloadOptions) parallelism() int {
+	if o.Parallelism <= 0 {
+		return DefaultDownloadParallelism
+	}
+	return o.Parallelism
+}
+
+// Checksum is the expected digest of a downloaded object
+type Checksum struct {
+	Algorithm string
+	Expected  []byte
+	newHash   func() hash.Hash
+}
+
+func MD5Checksum(expected []byte) *Checksum {
+	return &Checksum{Algorithm: "md5", Expected: expected, newHash: md5.New}
+}
+
+func CRC32CChecksum(expected uint32) *Checksum {
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, expected)
+	return &Checksum{Algorithm: "crc32c", Expected: sum, newHash: func() hash.Hash {
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	}}
+}
+
+// ETagChecksum returns the md5 checksum of an S3 etag, nil when the etag is not the md5 of the object as for
+// multipart uploads
+func ETagChecksum(etag string) *Checksum {
+	etag = strings.Trim(etag, `"`)
+	if !md5ETagRegex.MatchString(etag) {
+		return nil
+	}
+	expected, _ := hex.DecodeString(etag)
+	return MD5Checksum(expected)
+}
+
+func (c *Checksum) verify(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	h := c.newHash()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, c.Expected) {
+		return fmt.Errorf("%s checksum mismatch for %s, expected %x but got %x", c.Algorithm, fileName, c.Expected, actual)
+	}
+	return nil
+}
+
+// partialFile is an object being downloaded. The content is written to <file>.partial which is only renamed to the
+// file once it is complete and verified, a partial file of the same object version is resumed from where it stopped.
+type partialFile struct {
+	fileName string
+	// version identifies the object content, e.g. the etag, a partial file is only resumed for the same version
+	version  string
+	size     int64
+	checksum *Checksum
+	offset   int64
+	file     *os.File
+}
+
+// openPartialFile opens the partial file of the object at its current offset, resume decides whether the
+// existing content is kept
+func openPartialFile(fileName string) (*partialFile, error) {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return nil, err
+	}
+	// an earlier attempt of the model may have completed the file, it is downloaded again
+	if FileExists(fileName) {
+		if err := os.Remove(fileName); err != nil {
+			return nil, fmt.Errorf("file is unable to be deleted: %v", err)
+		}
+	}
+	file, err := os.OpenFile(fileName+partialFileSuffix, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	p := &partialFile{fileName: fileName, size: -1, file: file}
+	if version, err := ioutil.ReadFile(fileName + versionFileSuffix); err == nil {
+		p.version = string(version)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	p.offset = info.Size()
+	return p, nil
+}
+
+// resume keeps the content of the partial file when it is of the same object version, the download starts over
+// otherwise. The size is -1 when it is not known.
+func (p *partialFile) resume(version string, size int64) error {
+	p.size = size
+	if version == "" || version != p.version || (size >= 0 && p.offset > size) {
+		return p.restart(version)
+	}
+	_, err := p.file.Seek(p.offset, io.SeekStart)
+	return err
+}
+
+// restart truncates the partial file to download the object version from the start
+func (p *partialFile) restart(version string) error {
+	if err := p.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	p.offset = 0
+	p.version = version
+	if version == "" {
+		os.Remove(p.fileName + versionFileSuffix)
+		return nil
+	}
+	return ioutil.WriteFile(p.fileName+versionFileSuffix, []byte(version), 0666)
+}
+
+// complete is true when the partial file already has the whole object
+func (p *partialFile) complete() bool {
+	return p.size >= 0 && p.offset == p.size
+}
+
+func (p *partialFile) Write(b []byte) (int, error) {
+	return p.file.Write(b)
+}
+
+// WriteAt writes at the offset relative to where the download resumed
+func (p *partialFile) WriteAt(b []byte, off int64) (int, error) {
+	return p.file.WriteAt(b, p.offset+off)
+}
+
+// Close closes the partial file and keeps it to be resumed
+func (p *partialFile) Close() error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	return err
+}
+
+// commit verifies the size and the checksum of the partial file and renames it to the file. A file which does not
+// match its checksum is removed so that the next attempt downloads it again.
+func (p *partialFile) commit() error {
+	if err := p.Close(); err != nil {
+		return err
+	}
+	partialFileName := p.fileName + partialFileSuffix
+	info, err := os.Stat(partialFileName)
+	if err != nil {
+		return err
+	}
+	if p.size >= 0 && info.Size() != p.size {
+		return fmt.Errorf("downloaded %d of %d bytes of %s", info.Size(), p.size, p.fileName)
+	}
+	if p.checksum != nil {
+		if err := p.checksum.verify(partialFileName); err != nil {
+			os.Remove(partialFileName)
+			os.Remove(p.fileName + versionFileSuffix)
+			return err
+		}
+	}
+	if err := os.Rename(partialFileName, p.fileName); err != nil {
+		return err
+	}
+	os.Remove(p.fileName + versionFileSuffix)
+	return nil
+}
+
+// fileVersion identifies the content of a file of a filesystem without etags by its size and modification time
+func fileVersion(info os.FileInfo) string {
+	return fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// runParallel calls fn for the items 0 to n-1 with at most parallelism calls at a time, and returns their errors
+func runParallel(n int, parallelism int, fn func(i int) error) []error {
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(i); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
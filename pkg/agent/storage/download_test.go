@@ -0,0 +1,277 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kserve/kserve/pkg/agent/mocks"
+	"github.com/onsi/gomega"
+)
+
+const modelContents = "Model Contents"
+
+func md5Sum(s string) []byte {
+	sum := md5.Sum([]byte(s))
+	return sum[:]
+}
+
+// writePartialFile leaves the partial file of an earlier download attempt
+func writePartialFile(g *gomega.WithT, fileName string, contents string, version string) {
+	g.Expect(os.MkdirAll(filepath.Dir(fileName), 0777)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(fileName+partialFileSuffix, []byte(contents), 0666)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(fileName+versionFileSuffix, []byte(version), 0666)).To(gomega.Succeed())
+}
+
+func TestETagChecksum(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	checksum := ETagChecksum(fmt.Sprintf(`"%x"`, md5Sum(modelContents)))
+	g.Expect(checksum).NotTo(gomega.BeNil())
+	g.Expect(checksum.Expected).To(gomega.Equal(md5Sum(modelContents)))
+	// the etag of a multipart upload is not the md5 of the object
+	g.Expect(ETagChecksum(`"d41d8cd98f00b204e9800998ecf8427e-2"`)).To(gomega.BeNil())
+}
+
+func TestPartialFile(t *testing.T) {
+	scenarios := map[string]struct {
+		partial          string
+		partialVersion   string
+		version          string
+		size             int64
+		expectedOffset   int64
+		expectedComplete bool
+	}{
+		"NoPartialFile": {
+			version:        "v1",
+			size:           int64(len(modelContents)),
+			expectedOffset: 0,
+		},
+		"SameVersion": {
+			partial:        modelContents[:5],
+			partialVersion: "v1",
+			version:        "v1",
+			size:           int64(len(modelContents)),
+			expectedOffset: 5,
+		},
+		"Complete": {
+			partial:          modelContents,
+			partialVersion:   "v1",
+			version:          "v1",
+			size:             int64(len(modelContents)),
+			expectedOffset:   int64(len(modelContents)),
+			expectedComplete: true,
+		},
+		"ChangedVersion": {
+			partial:        modelContents[:5],
+			partialVersion: "v1",
+			version:        "v2",
+			size:           int64(len(modelContents)),
+			expectedOffset: 0,
+		},
+		"UnknownVersion": {
+			partial:        modelContents[:5],
+			partialVersion: "v1",
+			version:        "",
+			size:           -1,
+			expectedOffset: 0,
+		},
+		"LargerThanObject": {
+			partial:        modelContents + "extra",
+			partialVersion: "v1",
+			version:        "v1",
+			size:           int64(len(modelContents)),
+			expectedOffset: 0,
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			tmpDir, _ := ioutil.TempDir("", "partial")
+			defer os.RemoveAll(tmpDir)
+			fileName := filepath.Join(tmpDir, "model1", "model.bin")
+			if scenario.partialVersion != "" {
+				writePartialFile(g, fileName, scenario.partial, scenario.partialVersion)
+			}
+
+			file, err := openPartialFile(fileName)
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(file.resume(scenario.version, scenario.size)).To(gomega.Succeed())
+			g.Expect(file.offset).To(gomega.Equal(scenario.expectedOffset))
+			g.Expect(file.complete()).To(gomega.Equal(scenario.expectedComplete))
+
+			_, err = file.Write([]byte(modelContents[scenario.expectedOffset:]))
+			g.Expect(err).To(gomega.BeNil())
+			file.checksum = MD5Checksum(md5Sum(modelContents))
+			g.Expect(file.commit()).To(gomega.Succeed())
+			contents, err := ioutil.ReadFile(fileName)
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(string(contents)).To(gomega.Equal(modelContents))
+			g.Expect(fileName + partialFileSuffix).NotTo(gomega.BeAnExistingFile())
+			g.Expect(fileName + versionFileSuffix).NotTo(gomega.BeAnExistingFile())
+		})
+	}
+}
+
+func TestPartialFileCommitFailure(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	tmpDir, _ := ioutil.TempDir("", "partial")
+	defer os.RemoveAll(tmpDir)
+	fileName := filepath.Join(tmpDir, "model.bin")
+
+	// an incomplete file is kept to be resumed
+	file, err := openPartialFile(fileName)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(file.resume("v1", int64(len(modelContents)))).To(gomega.Succeed())
+	file.Write([]byte(modelContents[:5]))
+	g.Expect(file.commit()).To(gomega.MatchError(gomega.ContainSubstring("downloaded 5 of 14 bytes")))
+	g.Expect(fileName + partialFileSuffix).To(gomega.BeAnExistingFile())
+	g.Expect(fileName).NotTo(gomega.BeAnExistingFile())
+
+	// a corrupted file is removed to be downloaded again
+	file, err = openPartialFile(fileName)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(file.resume("v1", int64(len(modelContents)))).To(gomega.Succeed())
+	file.Write([]byte("corrupted"))
+	file.checksum = MD5Checksum(md5Sum(modelContents))
+	g.Expect(file.commit()).To(gomega.MatchError(gomega.ContainSubstring("md5 checksum mismatch")))
+	g.Expect(fileName + partialFileSuffix).NotTo(gomega.BeAnExistingFile())
+	g.Expect(fileName + versionFileSuffix).NotTo(gomega.BeAnExistingFile())
+	g.Expect(fileName).NotTo(gomega.BeAnExistingFile())
+}
+
+func TestHTTPSDownloaderResume(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") == "" {
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum(r.URL.Query().Get("md5"))))
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader([]byte(modelContents)))
+	}))
+	defer server.Close()
+
+	scenarios := map[string]struct {
+		partialVersion string
+		md5            string
+		expectedRange  string
+		expectedErr    string
+	}{
+		"NoPartialFile": {
+			md5:           modelContents,
+			expectedRange: "",
+		},
+		"SameVersion": {
+			partialVersion: `"v1"`,
+			md5:            modelContents,
+			expectedRange:  "bytes=5-",
+		},
+		"ChangedVersion": {
+			partialVersion: `"v0"`,
+			md5:            modelContents,
+			expectedRange:  "bytes=5-",
+		},
+		"ChecksumMismatch": {
+			md5:           "other contents",
+			expectedRange: "",
+			expectedErr:   "md5 checksum mismatch",
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			ranges = nil
+			modelDir, _ := ioutil.TempDir("", "https")
+			defer os.RemoveAll(modelDir)
+			fileName := filepath.Join(modelDir, "model1", "model.bin")
+			if scenario.partialVersion != "" {
+				writePartialFile(g, fileName, modelContents[:5], scenario.partialVersion)
+			}
+			storageUri := server.URL + "/model.bin?md5=" + url.QueryEscape(scenario.md5)
+			uri, _ := url.Parse(storageUri)
+			downloader := &HTTPSDownloader{StorageUri: storageUri, ModelDir: modelDir, ModelName: "model1", Uri: uri}
+
+			err := downloader.Download(*server.Client())
+			g.Expect(ranges).To(gomega.Equal([]string{scenario.expectedRange}))
+			if scenario.expectedErr != "" {
+				g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(scenario.expectedErr)))
+				g.Expect(fileName).NotTo(gomega.BeAnExistingFile())
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			contents, err := ioutil.ReadFile(fileName)
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(string(contents)).To(gomega.Equal(modelContents))
+		})
+	}
+}
+
+func TestGCSObjectDownloaderResume(t *testing.T) {
+	scenarios := map[string]struct {
+		partial     string
+		expectedErr string
+	}{
+		"Resumed": {
+			partial: modelContents[:5],
+		},
+		"Corrupted": {
+			partial:     "Corru",
+			expectedErr: "md5 checksum mismatch",
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			ctx := context.Background()
+			client := mocks.NewMockClient()
+			bkt := client.Bucket("testBucket")
+			g.Expect(bkt.Create(ctx, "test", nil)).To(gomega.Succeed())
+			w := bkt.Object("model1/model.bin").NewWriter(ctx)
+			fmt.Fprint(w, modelContents)
+			attrs, err := bkt.Object("model1/model.bin").Attrs(ctx)
+			g.Expect(err).To(gomega.BeNil())
+
+			modelDir, _ := ioutil.TempDir("", "gcs")
+			defer os.RemoveAll(modelDir)
+			fileName := filepath.Join(modelDir, "model1", "model.bin")
+			writePartialFile(g, fileName, scenario.partial, fmt.Sprint(attrs.Generation))
+
+			downloader := &GCSObjectDownloader{Context: ctx, ModelDir: modelDir, ModelName: "model1", Item: "model1/"}
+			err = downloader.DownloadFile(client, attrs)
+			if scenario.expectedErr != "" {
+				g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(scenario.expectedErr)))
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			contents, err := ioutil.ReadFile(fileName)
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(string(contents)).To(gomega.Equal(modelContents))
+		})
+	}
+}
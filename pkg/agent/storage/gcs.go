@@ -23,14 +23,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/googleapis/google-cloud-go-testing/storage/stiface"
 	"google.golang.org/api/iterator"
-	"io/ioutil"
-	"os"
+	"io"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 type GCSProvider struct {
-	Client stiface.Client
+	Client  stiface.Client
+	Options DownloadOptions
 }
 
 func (p *GCSProvider) DownloadModel(modelDir string, modelName string, storageUri string) error {
@@ -49,6 +50,7 @@ func (p *GCSProvider) DownloadModel(modelDir string, modelName string, storageUr
 		ModelName:  modelName,
 		Bucket:     tokens[0],
 		Item:       prefix,
+		Options:    p.Options,
 	}
 	it, err := gcsObjectDownloader.GetObjectIterator(p.Client)
 	if err != nil {
@@ -60,6 +62,31 @@ func (p *GCSProvider) DownloadModel(modelDir string, modelName string, storageUr
 	return nil
 }
 
+var _ Sizer = (*GCSProvider)(nil)
+
+// ModelSize sums the size of the objects under the model prefix
+func (p *GCSProvider) ModelSize(storageUri string) (int64, error) {
+	gcsUri := strings.TrimPrefix(storageUri, string(GCS))
+	tokens := strings.SplitN(gcsUri, "/", 2)
+	prefix := ""
+	if len(tokens) == 2 {
+		prefix = tokens[1]
+	}
+	it := p.Client.Bucket(tokens[0]).Objects(context.Background(), &gstorage.Query{Prefix: prefix})
+	var size int64
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("an error occurred while iterating: %v", err)
+		}
+		size += attrs.Size
+	}
+	return size, nil
+}
+
 type GCSObjectDownloader struct {
 	Context    context.Context
 	StorageUri string
@@ -67,6 +94,7 @@ type GCSObjectDownloader struct {
 	ModelName  string
 	Bucket     string
 	Item       string
+	Options    DownloadOptions
 }
 
 func (g *GCSObjectDownloader) GetObjectIterator(client stiface.Client) (stiface.ObjectIterator, error) {
@@ -74,11 +102,9 @@ func (g *GCSObjectDownloader) GetObjectIterator(client stiface.Client) (stiface.
 	return client.Bucket(g.Bucket).Objects(g.Context, query), nil
 }
 
+// Download fetches the objects of the iterator with up to Options.Parallelism objects at a time
 func (g *GCSObjectDownloader) Download(client stiface.Client, it stiface.ObjectIterator) error {
-	var errs []error
-	// flag to help determine if query prefix returned an empty iterator
-	var foundObject = false
-
+	var objects []*gstorage.ObjectAttrs
 	for {
 		attrs, err := it.Next()
 		if err == iterator.Done {
@@ -87,65 +113,66 @@ func (g *GCSObjectDownloader) Download(client stiface.Client, it stiface.ObjectI
 		if err != nil {
 			return fmt.Errorf("an error occurred while iterating: %v", err)
 		}
-		objectValue := strings.TrimPrefix(attrs.Name, g.Item)
-		fileName := filepath.Join(g.ModelDir, g.ModelName, objectValue)
-
-		foundObject = true
-		if FileExists(fileName) {
-			log.Info("Deleting", fileName)
-			if err := os.Remove(fileName); err != nil {
-				return fmt.Errorf("file is unable to be deleted: %v", err)
-			}
-		}
-		file, err := Create(fileName)
-		if err != nil {
-			return fmt.Errorf("file is already created: %v", err)
-		}
-		if err := g.DownloadFile(client, attrs, file); err != nil {
-			errs = append(errs, err)
+		if strings.HasSuffix(attrs.Name, "/") {
+			continue
 		}
+		objects = append(objects, attrs)
 	}
-	if !foundObject {
+	if len(objects) == 0 {
 		return gstorage.ErrObjectNotExist
 	}
+	errs := runParallel(len(objects), g.Options.parallelism(), func(i int) error {
+		return g.DownloadFile(client, objects[i])
+	})
 	if len(errs) > 0 {
 		return awserr.NewBatchError("GCSDownloadIncomplete", "some objects failed to download.", errs)
 	}
 	return nil
 }
 
-func (g *GCSObjectDownloader) DownloadFile(client stiface.Client, attrs *gstorage.ObjectAttrs, file *os.File) error {
-	reader, err := client.Bucket(attrs.Bucket).Object(attrs.Name).NewReader(g.Context)
+// DownloadFile fetches the object into its partial file, resuming from the end of a partial file of the same
+// generation, and moves it in place once its checksum is verified
+func (g *GCSObjectDownloader) DownloadFile(client stiface.Client, attrs *gstorage.ObjectAttrs) error {
+	objectValue := strings.TrimPrefix(attrs.Name, g.Item)
+	fileName := filepath.Join(g.ModelDir, g.ModelName, objectValue)
+	file, err := openPartialFile(fileName)
 	if err != nil {
-		return fmt.Errorf("failed to create reader for object(%s) in bucket(%s): %v",
-			attrs.Name,
-			attrs.Bucket,
-			err,
-		)
+		return fmt.Errorf("unable to create file: %v", err)
 	}
-	defer reader.Close()
-	data, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return fmt.Errorf("failed to read object(%s) in bucket(%s): %v",
-			attrs.Name,
-			attrs.Bucket,
-			err,
-		)
-	}
-	return g.WriteToFile(data, attrs, file)
-}
-
-func (g *GCSObjectDownloader) WriteToFile(data []byte, attrs *gstorage.ObjectAttrs, file *os.File) error {
-	_, err := file.Write(data)
 	defer file.Close()
-	if err != nil {
-		return fmt.Errorf("failed to write data to file(%s): from object(%s) in bucket(%s): %v",
-			file.Name(),
-			attrs.Name,
-			attrs.Bucket,
-			err,
-		)
+	if err := file.resume(strconv.FormatInt(attrs.Generation, 10), attrs.Size); err != nil {
+		return fmt.Errorf("unable to resume file: %v", err)
+	}
+	if !g.Options.SkipChecksums {
+		// composite objects have no md5 but every object has a crc32c
+		if len(attrs.MD5) > 0 {
+			file.checksum = MD5Checksum(attrs.MD5)
+		} else {
+			file.checksum = CRC32CChecksum(attrs.CRC32C)
+		}
+	}
+	if !file.complete() {
+		reader, err := client.Bucket(attrs.Bucket).Object(attrs.Name).NewRangeReader(g.Context, file.offset, -1)
+		if err != nil {
+			return fmt.Errorf("failed to create reader for object(%s) in bucket(%s): %v",
+				attrs.Name,
+				attrs.Bucket,
+				err,
+			)
+		}
+		defer reader.Close()
+		if _, err := io.Copy(file, reader); err != nil {
+			return fmt.Errorf("failed to write data to file(%s): from object(%s) in bucket(%s): %v",
+				fileName,
+				attrs.Name,
+				attrs.Bucket,
+				err,
+			)
+		}
+	}
+	if err := file.commit(); err != nil {
+		return err
 	}
-	log.Info("Wrote " + attrs.Prefix + " to file " + file.Name())
+	log.Info("Wrote " + attrs.Name + " to file " + fileName)
 	return nil
 }
@@ -0,0 +1,426 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/colinmarc/hdfs/v2"
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	hdfscredential "github.com/kserve/kserve/pkg/credentials/hdfs"
+)
+
+const (
+	// HDFSSecretDirEnv overrides the dir of the mounted hdfs secret, as for the storage-initializer
+	HDFSSecretDirEnv = "HDFS_SECRET_DIR"
+	// krb5ConfigEnv is the path of the kerberos config, /etc/krb5.conf by default
+	krb5ConfigEnv     = "KRB5_CONFIG"
+	defaultKrb5Config = "/etc/krb5.conf"
+	// defaultNamenodePrincipal is the service principal of the namenodes, _HOST is replaced with their address
+	defaultNamenodePrincipal = "nn/_HOST"
+	// DefaultHDFSTimeout is the max time to connect to a namenode or datanode, to wait for a webhdfs response and
+	// for a read or write of a connection
+	DefaultHDFSTimeout = time.Minute
+)
+
+// HDFSConfig is the config of the hdfs and webhdfs providers. It is read from the files of the hdfs secret, which the
+// credential builder mounts in the agent container, in the format of the storage-initializer.
+type HDFSConfig struct {
+	// Namenode is the webhdfs url of the namenode, e.g. https://namenode:9871, or the rpc addresses of the namenodes
+	// separated by commas, e.g. namenode1:8020,namenode2:8020
+	Namenode string
+	// RootPath is the dir of the uri paths which are not absolute, / when empty
+	RootPath string
+	// UserProxy is the user the requests are made on behalf of
+	UserProxy string
+	// Headers are added to the webhdfs requests
+	Headers map[string]string
+	// TLSCert, TLSKey and TLSCA are the paths of the pem files of the webhdfs client certificate and the namenode ca
+	TLSCert       string
+	TLSKey        string
+	TLSCA         string
+	TLSSkipVerify bool
+	// KerberosPrincipal authenticates with the keytab at the KerberosKeytab path when set
+	KerberosPrincipal string
+	KerberosKeytab    string
+	// Timeout bounds the connects, the webhdfs metadata requests and every read and write of the connections, so that
+	// an unresponsive namenode or datanode fails the download rather than blocking it, DefaultHDFSTimeout when not set
+	Timeout time.Duration
+}
+
+func (c *HDFSConfig) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultHDFSTimeout
+	}
+	return c.Timeout
+}
+
+// dialWithDeadline connects within the timeout and returns connections which fail a read or write stalled for longer
+// than the timeout. The deadline is moved on every read and write rather than set for the whole transfer, which
+// takes as long as the model files are large.
+func dialWithDeadline(timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &deadlineConn{Conn: conn, timeout: timeout}, nil
+	}
+}
+
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// LoadHDFSConfig reads the hdfs config from the files of the secret dir, the dir of HDFSSecretDirEnv or the mount
+// path of the hdfs secret is used when it is empty
+func LoadHDFSConfig(secretDir string) (*HDFSConfig, error) {
+	if secretDir == "" {
+		secretDir = os.Getenv(HDFSSecretDirEnv)
+	}
+	if secretDir == "" {
+		secretDir = hdfscredential.MountPath
+	}
+	value := func(key string) (string, error) {
+		data, err := ioutil.ReadFile(filepath.Join(secretDir, key))
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return strings.TrimSpace(string(data)), err
+	}
+	// the keytab and the tls files are passed by path rather than read
+	file := func(key string) string {
+		fileName := filepath.Join(secretDir, key)
+		if !FileExists(fileName) {
+			return ""
+		}
+		return fileName
+	}
+
+	config := &HDFSConfig{
+		TLSCert:        file(hdfscredential.TlsCert),
+		TLSKey:         file(hdfscredential.TlsKey),
+		TLSCA:          file(hdfscredential.TlsCa),
+		KerberosKeytab: file(hdfscredential.KerberosKeytab),
+	}
+	var err error
+	if config.Namenode, err = value(hdfscredential.HdfsNamenode); err != nil {
+		return nil, fmt.Errorf("unable to read hdfs secret: %v", err)
+	}
+	if config.Namenode == "" {
+		return nil, fmt.Errorf("no %s found in hdfs secret dir %s", hdfscredential.HdfsNamenode, secretDir)
+	}
+	if config.RootPath, err = value(hdfscredential.HdfsRootPath); err != nil {
+		return nil, fmt.Errorf("unable to read hdfs secret: %v", err)
+	}
+	if config.UserProxy, err = value(hdfscredential.UserProxy); err != nil {
+		return nil, fmt.Errorf("unable to read hdfs secret: %v", err)
+	}
+	if config.KerberosPrincipal, err = value(hdfscredential.KerberosPrincipal); err != nil {
+		return nil, fmt.Errorf("unable to read hdfs secret: %v", err)
+	}
+	skipVerify, err := value(hdfscredential.TlsSkipVerify)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read hdfs secret: %v", err)
+	}
+	config.TLSSkipVerify = strings.ToLower(skipVerify) == "true"
+	headers, err := value(hdfscredential.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read hdfs secret: %v", err)
+	}
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &config.Headers); err != nil {
+			return nil, fmt.Errorf("unable to parse hdfs %s: %v", hdfscredential.Headers, err)
+		}
+	}
+	return config, nil
+}
+
+// IsWebHDFS returns true when the namenode is a webhdfs url rather than rpc addresses
+func (c *HDFSConfig) IsWebHDFS() bool {
+	return strings.HasPrefix(c.Namenode, string(HTTP)) || strings.HasPrefix(c.Namenode, string(HTTPS))
+}
+
+// Path returns the hdfs path of the uri, e.g. /models/model1 for hdfs://models/model1 with the default root path
+func (c *HDFSConfig) Path(storageUri string) string {
+	p := storageUri
+	if i := strings.Index(storageUri, "://"); i >= 0 {
+		p = storageUri[i+len("://"):]
+	}
+	if strings.HasPrefix(p, "/") {
+		return path.Clean(p)
+	}
+	return path.Join("/", c.RootPath, p)
+}
+
+// KerberosClient logs in with the keytab of the principal, the realm of a principal without one is the default realm
+// of the kerberos config
+func (c *HDFSConfig) KerberosClient() (*krbclient.Client, error) {
+	configPath := os.Getenv(krb5ConfigEnv)
+	if configPath == "" {
+		configPath = defaultKrb5Config
+	}
+	krb5Config, err := krbconfig.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load kerberos config %s: %v", configPath, err)
+	}
+	kt, err := keytab.Load(c.KerberosKeytab)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load kerberos keytab: %v", err)
+	}
+	username, realm := c.KerberosPrincipal, krb5Config.LibDefaults.DefaultRealm
+	if i := strings.LastIndex(c.KerberosPrincipal, "@"); i >= 0 {
+		username, realm = c.KerberosPrincipal[:i], c.KerberosPrincipal[i+1:]
+	}
+	client := krbclient.NewWithKeytab(username, realm, kt, krb5Config, krbclient.DisablePAFXFAST(true))
+	if err := client.Login(); err != nil {
+		return nil, fmt.Errorf("unable to login as kerberos principal %s: %v", c.KerberosPrincipal, err)
+	}
+	return client, nil
+}
+
+// HDFSFileSystem is the part of the hdfs api used to download the models, implemented with the webhdfs rest api and
+// with the hdfs rpc protocol
+type HDFSFileSystem interface {
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	// OpenAt reads the file from the offset
+	OpenAt(name string, offset int64) (io.ReadCloser, error)
+}
+
+// HDFSProvider downloads the hdfs:// uris with the hdfs rpc protocol
+type HDFSProvider struct {
+	Client  *hdfs.Client
+	Config  HDFSConfig
+	Options DownloadOptions
+}
+
+// NewHDFSClient connects to the namenodes of the config, authenticating with kerberos when a principal is set. The
+// connections to the namenodes and datanodes fail once a connect, read or write exceeds the timeout of the config.
+func NewHDFSClient(config *HDFSConfig) (*hdfs.Client, error) {
+	options := hdfs.ClientOptions{
+		Addresses:        strings.Split(config.Namenode, ","),
+		User:             config.UserProxy,
+		NamenodeDialFunc: dialWithDeadline(config.timeout()),
+		DatanodeDialFunc: dialWithDeadline(config.timeout()),
+	}
+	if config.KerberosPrincipal != "" {
+		krbClient, err := config.KerberosClient()
+		if err != nil {
+			return nil, err
+		}
+		options.KerberosClient = krbClient
+		options.KerberosServicePrincipleName = defaultNamenodePrincipal
+	} else if options.User == "" {
+		currentUser, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the hdfs user: %v", err)
+		}
+		options.User = currentUser.Username
+	}
+	return hdfs.NewClient(options)
+}
+
+func (p *HDFSProvider) DownloadModel(modelDir string, modelName string, storageUri string) error {
+	log.Info("Downloading model ", "modelName", modelName, "storageUri", storageUri, "modelDir", modelDir)
+	downloader := &HDFSDownloader{
+		FileSystem: &hdfsRPCFileSystem{client: p.Client},
+		ModelDir:   modelDir,
+		ModelName:  modelName,
+		Path:       p.Config.Path(storageUri),
+		Options:    p.Options,
+	}
+	return downloader.DownloadModel()
+}
+
+var _ Sizer = (*HDFSProvider)(nil)
+
+func (p *HDFSProvider) ModelSize(storageUri string) (int64, error) {
+	return (&HDFSDownloader{FileSystem: &hdfsRPCFileSystem{client: p.Client}, Path: p.Config.Path(storageUri)}).ModelSize()
+}
+
+// hdfsRPCFileSystem reads the files with the hdfs rpc protocol
+type hdfsRPCFileSystem struct {
+	client *hdfs.Client
+}
+
+func (h *hdfsRPCFileSystem) Stat(name string) (os.FileInfo, error) {
+	return h.client.Stat(name)
+}
+
+func (h *hdfsRPCFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return h.client.ReadDir(name)
+}
+
+func (h *hdfsRPCFileSystem) OpenAt(name string, offset int64) (io.ReadCloser, error) {
+	reader, err := h.client.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// HDFSDownloader downloads the file of the path or every file of the dir tree of the path
+type HDFSDownloader struct {
+	FileSystem HDFSFileSystem
+	ModelDir   string
+	ModelName  string
+	Path       string
+	Options    DownloadOptions
+}
+
+// HDFSFile is a file to download, Name is its path relative to the model
+type HDFSFile struct {
+	Path string
+	Name string
+	Info os.FileInfo
+}
+
+func (h *HDFSDownloader) DownloadModel() error {
+	files, err := h.ListFiles()
+	if err != nil {
+		return fmt.Errorf("unable to list files because: %v", err)
+	}
+	if err := h.Download(files); err != nil {
+		return fmt.Errorf("unable to download file/s because: %v", err)
+	}
+	return nil
+}
+
+// ModelSize sums the size of the files of the path
+func (h *HDFSDownloader) ModelSize() (int64, error) {
+	files, err := h.ListFiles()
+	if err != nil {
+		return 0, fmt.Errorf("unable to list files because: %v", err)
+	}
+	var size int64
+	for _, file := range files {
+		size += file.Info.Size()
+	}
+	return size, nil
+}
+
+// ListFiles returns the file of the path, or the files of its dir tree, an error is returned when there are none
+func (h *HDFSDownloader) ListFiles() ([]HDFSFile, error) {
+	info, err := h.FileSystem.Stat(h.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []HDFSFile{{Path: h.Path, Name: path.Base(h.Path), Info: info}}, nil
+	}
+	var files []HDFSFile
+	if err := h.listDir(h.Path, "", &files); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found under %s", h.Path)
+	}
+	return files, nil
+}
+
+func (h *HDFSDownloader) listDir(dir string, name string, files *[]HDFSFile) error {
+	infos, err := h.FileSystem.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		filePath, fileName := path.Join(dir, info.Name()), path.Join(name, info.Name())
+		if info.IsDir() {
+			if err := h.listDir(filePath, fileName, files); err != nil {
+				return err
+			}
+			continue
+		}
+		*files = append(*files, HDFSFile{Path: filePath, Name: fileName, Info: info})
+	}
+	return nil
+}
+
+// Download fetches the files with up to Options.Parallelism files at a time
+func (h *HDFSDownloader) Download(files []HDFSFile) error {
+	errs := runParallel(len(files), h.Options.parallelism(), func(i int) error {
+		return h.DownloadFile(files[i])
+	})
+	if len(errs) > 0 {
+		return awserr.NewBatchError("HDFSDownloadIncomplete", "some files failed to download.", errs)
+	}
+	return nil
+}
+
+// DownloadFile fetches the file into its partial file, resuming from the end of a partial file of the same size and
+// modification time. The hdfs checksums are not the digest of the content, the files are only verified by size.
+func (h *HDFSDownloader) DownloadFile(hdfsFile HDFSFile) error {
+	fileName := filepath.Join(h.ModelDir, h.ModelName, filepath.FromSlash(hdfsFile.Name))
+	file, err := openPartialFile(fileName)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+	if err := file.resume(fileVersion(hdfsFile.Info), hdfsFile.Info.Size()); err != nil {
+		return fmt.Errorf("unable to resume file: %v", err)
+	}
+	if !file.complete() {
+		reader, err := h.FileSystem.OpenAt(hdfsFile.Path, file.offset)
+		if err != nil {
+			return fmt.Errorf("failed to open hdfs file(%s): %v", hdfsFile.Path, err)
+		}
+		defer reader.Close()
+		if _, err := io.Copy(file, reader); err != nil {
+			return fmt.Errorf("failed to write data to file(%s): from hdfs file(%s): %v", fileName, hdfsFile.Path, err)
+		}
+	}
+	if err := file.commit(); err != nil {
+		return err
+	}
+	log.Info("Wrote " + hdfsFile.Path + " to file " + fileName)
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -37,7 +38,8 @@ const (
 )
 
 type HTTPSProvider struct {
-	Client *http.Client
+	Client  *http.Client
+	Options DownloadOptions
 }
 
 func (m *HTTPSProvider) DownloadModel(modelDir string, modelName string, storageUri string) error {
@@ -51,6 +53,7 @@ func (m *HTTPSProvider) DownloadModel(modelDir string, modelName string, storage
 		ModelDir:   modelDir,
 		ModelName:  modelName,
 		Uri:        uri,
+		Options:    m.Options,
 	}
 	if err := HTTPSDownloader.Download(*m.Client); err != nil {
 		return err
@@ -58,14 +61,61 @@ func (m *HTTPSProvider) DownloadModel(modelDir string, modelName string, storage
 	return nil
 }
 
+var _ Sizer = (*HTTPSProvider)(nil)
+
+// ModelSize returns the content length of the model from a HEAD request, -1 when the server does not send it
+func (m *HTTPSProvider) ModelSize(storageUri string) (int64, error) {
+	uri, err := url.Parse(storageUri)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse storage uri: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodHead, storageUri, nil)
+	if err != nil {
+		return 0, err
+	}
+	headers, err := (&HTTPSDownloader{Uri: uri}).extractHeaders()
+	if err != nil {
+		return 0, err
+	}
+	for key, element := range headers {
+		req.Header.Add(key, element)
+	}
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make a request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// the server may not support HEAD requests, the size is checked once the model is downloaded
+		return -1, nil
+	}
+	return resp.ContentLength, nil
+}
+
 type HTTPSDownloader struct {
 	StorageUri string
 	ModelDir   string
 	ModelName  string
 	Uri        *url.URL
+	Options    DownloadOptions
 }
 
+// Download fetches the uri into a partial file, a partial file of an earlier attempt is resumed with a range
+// request when the server still has the same version of the content. Archives are extracted once complete.
 func (h *HTTPSDownloader) Download(client http.Client) error {
+	fileDirectory := filepath.Join(h.ModelDir, h.ModelName)
+	paths := strings.Split(h.Uri.Path, "/")
+	fileName := paths[len(paths)-1]
+	if fileName == "" {
+		fileName = "model"
+	}
+	fileFullName := filepath.Join(fileDirectory, fileName)
+	file, err := openPartialFile(fileFullName)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
 	// Create request
 	req, err := http.NewRequest("GET", h.StorageUri, nil)
 	if err != nil {
@@ -79,6 +129,11 @@ func (h *HTTPSDownloader) Download(client http.Client) error {
 	for key, element := range headers {
 		req.Header.Add(key, element)
 	}
+	if file.offset > 0 && file.version != "" {
+		// the server sends the whole content instead of the range when the version changed
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", file.offset))
+		req.Header.Set("If-Range", file.version)
+	}
 
 	// Query request
 	resp, err := client.Do(req)
@@ -87,39 +142,75 @@ func (h *HTTPSDownloader) Download(client http.Client) error {
 	}
 
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if err := file.resume(file.version, contentSize(file.offset, resp.ContentLength)); err != nil {
+			return fmt.Errorf("unable to resume file: %v", err)
+		}
+	case http.StatusOK:
+		if err := file.restart(contentVersion(resp.Header)); err != nil {
+			return fmt.Errorf("unable to create file: %v", err)
+		}
+		file.size = resp.ContentLength
+		// the content md5 is only known for the whole content, a resumed download is checked by its size
+		if contentMD5 := resp.Header.Get("Content-MD5"); contentMD5 != "" && !h.Options.SkipChecksums {
+			expected, err := base64.StdEncoding.DecodeString(contentMD5)
+			if err != nil {
+				return fmt.Errorf("invalid Content-MD5 header %q: %v", contentMD5, err)
+			}
+			file.checksum = MD5Checksum(expected)
+		}
+	default:
 		return fmt.Errorf("URI: %s returned a %d response code", h.StorageUri, resp.StatusCode)
 	}
+	if _, err = io.Copy(file, resp.Body); err != nil {
+		return fmt.Errorf("unable to copy file content: %v", err)
+	}
+	if err := file.commit(); err != nil {
+		return err
+	}
 
 	// Write content into file(s)
 	contentType := resp.Header.Get("Content-type")
-	fileDirectory := filepath.Join(h.ModelDir, h.ModelName)
-
 	if strings.Contains(contentType, "application/zip") {
-		if err := extractZipFiles(resp.Body, fileDirectory); err != nil {
-			return err
-		}
+		return extractArchive(fileFullName, fileDirectory, extractZipFiles)
 	} else if strings.Contains(contentType, "application/x-tar") || strings.Contains(contentType, "application/x-gtar") ||
 		strings.Contains(contentType, "application/x-gzip") || strings.Contains(contentType, "application/gzip") {
-		if err := extractTarFiles(resp.Body, fileDirectory); err != nil {
-			return err
-		}
-	} else {
-		paths := strings.Split(h.Uri.Path, "/")
-		fileName := paths[len(paths)-1]
-		fileFullName := filepath.Join(fileDirectory, fileName)
-		file, err := createNewFile(fileFullName)
-		if err != nil {
-			return err
-		}
-		if _, err = io.Copy(file, resp.Body); err != nil {
-			return fmt.Errorf("unable to copy file content: %v", err)
-		}
+		return extractArchive(fileFullName, fileDirectory, extractTarFiles)
 	}
-
 	return nil
 }
 
+// contentVersion identifies the content of a response for a later range request
+func contentVersion(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// contentSize is the size of the whole content of a range response, -1 when it is not known
+func contentSize(offset int64, contentLength int64) int64 {
+	if contentLength < 0 {
+		return -1
+	}
+	return offset + contentLength
+}
+
+// extractArchive extracts the downloaded archive into the dest dir and removes it
+func extractArchive(archive string, dest string, extract func(reader io.Reader, dest string) error) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	err = extract(file, dest)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(archive)
+}
+
 func (h *HTTPSDownloader) extractHeaders() (map[string]string, error) {
 	var headers map[string]string
 	hostname := h.Uri.Hostname()
@@ -0,0 +1,272 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// LinkMode is how the local provider places the files of a model in the model dir
+type LinkMode string
+
+const (
+	// LinkModeCopy copies the files
+	LinkModeCopy LinkMode = "copy"
+	// LinkModeHardlink hard links the files, the files which can not be linked, e.g. of another filesystem, are copied
+	LinkModeHardlink LinkMode = "hardlink"
+	// LinkModeSymlink symlinks the files, their path must resolve in the model server container as well
+	LinkModeSymlink LinkMode = "symlink"
+)
+
+var LinkModes = []LinkMode{LinkModeCopy, LinkModeHardlink, LinkModeSymlink}
+
+func (o DownloadOptions) localLinkMode() LinkMode {
+	if o.LocalLinkMode == "" {
+		return LinkModeCopy
+	}
+	return o.LocalLinkMode
+}
+
+// LocalProvider places the file or the dir tree of file:///{path} uris, and of pvc://{pvc}/{path} uris of the PVCs
+// mounted in PVCDir/{pvc}, in the model dir. The file:// uris are restricted to PVCDir and the LocalRoots of the
+// options, and the files of both stay in their root once their symlinks are resolved.
+type LocalProvider struct {
+	PVCDir  string
+	Options DownloadOptions
+}
+
+// localFile is a file to place, name is its path relative to the model
+type localFile struct {
+	path string
+	name string
+	info os.FileInfo
+}
+
+// Path returns the local path of the uri
+func (p *LocalProvider) Path(storageUri string) (string, error) {
+	localPath, _, err := p.resolve(storageUri)
+	return localPath, err
+}
+
+// resolve returns the local path of the uri and the root its files must stay in, the mounted pvc of a pvc:// uri
+// or the local root of a file:// uri
+func (p *LocalProvider) resolve(storageUri string) (string, string, error) {
+	pvcDir, err := filepath.Abs(p.PVCDir)
+	if err != nil {
+		return "", "", err
+	}
+	switch {
+	case strings.HasPrefix(storageUri, string(File)):
+		localPath, err := filepath.Abs(strings.TrimPrefix(storageUri, string(File)))
+		if err != nil {
+			return "", "", err
+		}
+		for _, root := range append([]string{pvcDir}, p.Options.LocalRoots...) {
+			root, err := filepath.Abs(root)
+			if err != nil {
+				return "", "", err
+			}
+			if isUnder(localPath, root) {
+				return localPath, root, nil
+			}
+		}
+		return "", "", fmt.Errorf("invalid uri %s is not under the local roots %s", storageUri,
+			strings.Join(append([]string{pvcDir}, p.Options.LocalRoots...), ","))
+	case strings.HasPrefix(storageUri, string(PVC)):
+		pvcPath := strings.TrimPrefix(storageUri, string(PVC))
+		localPath := filepath.Join(pvcDir, pvcPath)
+		// the path must stay in the mounted pvc
+		if pvcPath == "" || strings.HasPrefix(pvcPath, "/") || !isUnder(localPath, pvcDir) || localPath == pvcDir {
+			return "", "", fmt.Errorf("invalid uri must be pvc://<pvcname>/[path]: %s", storageUri)
+		}
+		pvcName := strings.SplitN(strings.TrimPrefix(localPath, pvcDir+string(filepath.Separator)),
+			string(filepath.Separator), 2)[0]
+		return localPath, filepath.Join(pvcDir, pvcName), nil
+	}
+	return "", "", fmt.Errorf("unsupported local uri %s", storageUri)
+}
+
+// isUnder returns true when the path is the dir or in its tree
+func isUnder(path string, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func (p *LocalProvider) DownloadModel(modelDir string, modelName string, storageUri string) error {
+	log.Info("Copying model ", "modelName", modelName, "storageUri", storageUri, "modelDir", modelDir,
+		"linkMode", p.Options.localLinkMode())
+	files, err := p.listFiles(storageUri)
+	if err != nil {
+		return fmt.Errorf("unable to list files because: %v", err)
+	}
+	errs := runParallel(len(files), p.Options.parallelism(), func(i int) error {
+		return p.placeFile(files[i], filepath.Join(modelDir, modelName, files[i].name))
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to copy file/s because: %v",
+			awserr.NewBatchError("LocalCopyIncomplete", "some files failed to copy.", errs))
+	}
+	return nil
+}
+
+var _ Sizer = (*LocalProvider)(nil)
+
+// ModelSize sums the size of the files, the symlinked files take no space in the model dir
+func (p *LocalProvider) ModelSize(storageUri string) (int64, error) {
+	if p.Options.localLinkMode() == LinkModeSymlink {
+		return 0, nil
+	}
+	files, err := p.listFiles(storageUri)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list files because: %v", err)
+	}
+	var size int64
+	for _, file := range files {
+		size += file.info.Size()
+	}
+	return size, nil
+}
+
+// listFiles returns the file of the uri, or the files of its dir tree, an error is returned when there are none or
+// when a file resolves outside of the root of the uri through a symlink
+func (p *LocalProvider) listFiles(storageUri string) ([]localFile, error) {
+	localPath, root, err := p.resolve(storageUri)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	// resolve returns the file placed for the path, which must stay in the root
+	resolve := func(path string) (string, error) {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return "", err
+		}
+		if !isUnder(resolved, root) {
+			return "", fmt.Errorf("file %s resolves to %s outside of %s", path, resolved, root)
+		}
+		return resolved, nil
+	}
+	name := filepath.Base(localPath)
+	localPath, err = resolve(localPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []localFile{{path: localPath, name: name, info: info}}, nil
+	}
+	var files []localFile
+	err = filepath.Walk(localPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// the symlinks of the dir tree are placed as the file they point to
+		if info.Mode()&os.ModeSymlink != 0 {
+			if info, err = os.Stat(path); err != nil {
+				return err
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(localPath, path)
+		if err != nil {
+			return err
+		}
+		resolved, err := resolve(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, localFile{path: resolved, name: name, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found under %s", localPath)
+	}
+	return files, nil
+}
+
+func (p *LocalProvider) placeFile(file localFile, fileName string) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return err
+	}
+	// an earlier attempt of the model may have placed the file
+	if _, err := os.Lstat(fileName); err == nil {
+		if err := os.Remove(fileName); err != nil {
+			return fmt.Errorf("file is unable to be deleted: %v", err)
+		}
+	}
+	switch p.Options.localLinkMode() {
+	case LinkModeSymlink:
+		if err := os.Symlink(file.path, fileName); err != nil {
+			return fmt.Errorf("failed to link file(%s) to %s: %v", fileName, file.path, err)
+		}
+		return nil
+	case LinkModeHardlink:
+		err := os.Link(file.path, fileName)
+		if err == nil {
+			return nil
+		}
+		log.Info("Copying file which can not be hard linked", "file", file.path, "error", err.Error())
+	}
+	return copyFile(file, fileName)
+}
+
+// copyFile copies the file through its partial file, resuming from the end of a partial file of the same size and
+// modification time
+func copyFile(file localFile, fileName string) error {
+	partial, err := openPartialFile(fileName)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer partial.Close()
+	if err := partial.resume(fileVersion(file.info), file.info.Size()); err != nil {
+		return fmt.Errorf("unable to resume file: %v", err)
+	}
+	if !partial.complete() {
+		source, err := os.Open(file.path)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+		if _, err := source.Seek(partial.offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.Copy(partial, source); err != nil {
+			return fmt.Errorf("failed to write data to file(%s): from file(%s): %v", fileName, file.path, err)
+		}
+	}
+	if err := partial.commit(); err != nil {
+		return err
+	}
+	log.Info("Wrote " + file.path + " to file " + fileName)
+	return nil
+}
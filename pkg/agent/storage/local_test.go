@@ -0,0 +1,212 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestLocalProviderDownloadModel(t *testing.T) {
+	pvcDir := t.TempDir()
+	modelPath := filepath.Join(pvcDir, "models", "model1")
+	for name, contents := range map[string]string{
+		"model.bin":        modelContents,
+		"1/variables.data": "Variables",
+	} {
+		g := gomega.NewGomegaWithT(t)
+		g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(modelPath, name)), 0777)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(filepath.Join(modelPath, name), []byte(contents), 0644)).To(gomega.Succeed())
+	}
+	expectedFiles := map[string]string{
+		"model.bin":        modelContents,
+		"1/variables.data": "Variables",
+	}
+
+	scenarios := map[string]struct {
+		storageUri    string
+		linkMode      LinkMode
+		partial       string
+		expectedFiles map[string]string
+		expectedErr   string
+	}{
+		"File": {
+			storageUri:    "file://" + modelPath,
+			expectedFiles: expectedFiles,
+		},
+		"SingleFile": {
+			storageUri:    "file://" + filepath.Join(modelPath, "model.bin"),
+			expectedFiles: map[string]string{"model.bin": modelContents},
+		},
+		"PVC": {
+			storageUri:    "pvc://models/model1",
+			expectedFiles: expectedFiles,
+		},
+		"Resumed": {
+			storageUri:    "pvc://models/model1",
+			partial:       modelContents[:5],
+			expectedFiles: expectedFiles,
+		},
+		"Hardlink": {
+			storageUri:    "pvc://models/model1",
+			linkMode:      LinkModeHardlink,
+			expectedFiles: expectedFiles,
+		},
+		"Symlink": {
+			storageUri:    "pvc://models/model1",
+			linkMode:      LinkModeSymlink,
+			expectedFiles: expectedFiles,
+		},
+		"NotFound": {
+			storageUri:  "pvc://models/model2",
+			expectedErr: "no such file or directory",
+		},
+		"OutsidePVCDir": {
+			storageUri:  "pvc://models/../../model1",
+			expectedErr: "invalid uri",
+		},
+		"NoPVC": {
+			storageUri:  "pvc://",
+			expectedErr: "invalid uri",
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			modelDir := t.TempDir()
+			if scenario.partial != "" {
+				info, err := os.Stat(filepath.Join(modelPath, "model.bin"))
+				g.Expect(err).To(gomega.BeNil())
+				writePartialFile(g, filepath.Join(modelDir, "model1", "model.bin"), scenario.partial,
+					fileVersion(info))
+			}
+			provider := &LocalProvider{PVCDir: pvcDir, Options: DownloadOptions{LocalLinkMode: scenario.linkMode}}
+
+			err := provider.DownloadModel(modelDir, "model1", scenario.storageUri)
+			if scenario.expectedErr != "" {
+				g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(scenario.expectedErr)))
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			for file, expected := range scenario.expectedFiles {
+				fileName := filepath.Join(modelDir, "model1", file)
+				contents, err := ioutil.ReadFile(fileName)
+				g.Expect(err).To(gomega.BeNil())
+				g.Expect(string(contents)).To(gomega.Equal(expected))
+
+				info, err := os.Lstat(fileName)
+				g.Expect(err).To(gomega.BeNil())
+				source, err := os.Stat(filepath.Join(modelPath, file))
+				g.Expect(err).To(gomega.BeNil())
+				switch scenario.linkMode {
+				case LinkModeSymlink:
+					g.Expect(info.Mode() & os.ModeSymlink).NotTo(gomega.BeZero())
+				case LinkModeHardlink:
+					g.Expect(os.SameFile(info, source)).To(gomega.BeTrue())
+				default:
+					g.Expect(info.Mode().IsRegular()).To(gomega.BeTrue())
+					g.Expect(os.SameFile(info, source)).To(gomega.BeFalse())
+				}
+			}
+		})
+	}
+}
+
+func TestLocalProviderModelSize(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pvcDir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(pvcDir, "models", "model1"), 0777)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(pvcDir, "models", "model1", "model.bin"), []byte(modelContents), 0644)).
+		To(gomega.Succeed())
+
+	size, err := (&LocalProvider{PVCDir: pvcDir}).ModelSize("pvc://models/model1")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(size).To(gomega.Equal(int64(len(modelContents))))
+	// the symlinked files take no space in the model dir
+	size, err = (&LocalProvider{PVCDir: pvcDir, Options: DownloadOptions{LocalLinkMode: LinkModeSymlink}}).
+		ModelSize("pvc://models/model1")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(size).To(gomega.BeZero())
+}
+
+func TestLocalProviderRoots(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pvcDir := t.TempDir()
+	localRoot := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{filepath.Join(pvcDir, "models", "model1"), filepath.Join(localRoot, "model1"),
+		filepath.Join(pvcDir, "shared")} {
+		g.Expect(os.MkdirAll(dir, 0777)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(filepath.Join(dir, "model.bin"), []byte(modelContents), 0644)).To(gomega.Succeed())
+	}
+	g.Expect(ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("Secret"), 0644)).To(gomega.Succeed())
+	// a link in the pvc to a file of the pvc is placed, a link out of the pvc is refused
+	g.Expect(os.Symlink(filepath.Join(pvcDir, "models", "model1", "model.bin"),
+		filepath.Join(pvcDir, "models", "linked.bin"))).To(gomega.Succeed())
+	g.Expect(os.MkdirAll(filepath.Join(pvcDir, "models", "escape"), 0777)).To(gomega.Succeed())
+	g.Expect(os.Symlink(filepath.Join(outside, "secret"),
+		filepath.Join(pvcDir, "models", "escape", "model.bin"))).To(gomega.Succeed())
+	g.Expect(os.Symlink(filepath.Join(pvcDir, "shared", "model.bin"),
+		filepath.Join(pvcDir, "models", "other-pvc.bin"))).To(gomega.Succeed())
+	g.Expect(os.Symlink(outside, filepath.Join(localRoot, "escape"))).To(gomega.Succeed())
+
+	scenarios := map[string]struct {
+		storageUri  string
+		expectedErr string
+	}{
+		"FileUnderPVCDir":  {storageUri: "file://" + filepath.Join(pvcDir, "models", "model1")},
+		"FileUnderRoot":    {storageUri: "file://" + filepath.Join(localRoot, "model1")},
+		"FileOutsideRoots": {storageUri: "file://" + outside, expectedErr: "is not under the local roots"},
+		"FileDotDot": {
+			storageUri:  "file://" + filepath.Join(localRoot, "..", filepath.Base(outside)),
+			expectedErr: "is not under the local roots",
+		},
+		"LinkedDirOutsideRoot": {
+			storageUri:  "file://" + filepath.Join(localRoot, "escape"),
+			expectedErr: "outside of",
+		},
+		"LinkInPVC":         {storageUri: "pvc://models/linked.bin"},
+		"LinkOutsidePVCDir": {storageUri: "pvc://models/escape", expectedErr: "outside of"},
+		"LinkToOtherPVC":    {storageUri: "pvc://models/other-pvc.bin", expectedErr: "outside of"},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			modelDir := t.TempDir()
+			provider := &LocalProvider{PVCDir: pvcDir, Options: DownloadOptions{LocalRoots: []string{localRoot}}}
+
+			err := provider.DownloadModel(modelDir, "model1", scenario.storageUri)
+			if scenario.expectedErr != "" {
+				g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(scenario.expectedErr)))
+				_, err := os.Stat(filepath.Join(modelDir, "model1"))
+				g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			files, err := ioutil.ReadDir(filepath.Join(modelDir, "model1"))
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(files).To(gomega.HaveLen(1))
+			contents, err := ioutil.ReadFile(filepath.Join(modelDir, "model1", files[0].Name()))
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(string(contents)).To(gomega.Equal(modelContents))
+		})
+	}
+}
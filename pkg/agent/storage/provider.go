@@ -20,18 +20,29 @@ type Provider interface {
 	DownloadModel(modelDir string, modelName string, storageUri string) error
 }
 
+// Sizer is implemented by the providers which can tell the size of a model from the object metadata before it is
+// downloaded. The size of an archive is the compressed size.
+type Sizer interface {
+	// ModelSize returns the size of the model in bytes, -1 when it is not known
+	ModelSize(storageUri string) (int64, error)
+}
+
 type Protocol string
 
 const (
-	S3  Protocol = "s3://"
-	GCS Protocol = "gs://"
-	//PVC   Protocol = "pvc://"
-	//File  Protocol = "file://"
-	HTTPS Protocol = "https://"
-	HTTP  Protocol = "http://"
+	S3      Protocol = "s3://"
+	GCS     Protocol = "gs://"
+	PVC     Protocol = "pvc://"
+	File    Protocol = "file://"
+	HTTPS   Protocol = "https://"
+	HTTP    Protocol = "http://"
+	HDFS    Protocol = "hdfs://"
+	WebHDFS Protocol = "webhdfs://"
+	// AzureBlob is the provider of the https://{account}.blob.core.windows.net/ uris, it is not a uri prefix
+	AzureBlob Protocol = "azureblob://"
 )
 
-var SupportedProtocols = []Protocol{S3, GCS, HTTPS, HTTP}
+var SupportedProtocols = []Protocol{S3, GCS, PVC, File, HTTPS, HTTP, HDFS, WebHDFS}
 
 func GetAllProtocol() (protocols []string) {
 	for _, protocol := range SupportedProtocols {
@@ -19,11 +19,11 @@ package storage
 import (
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
-	"os"
 	"path/filepath"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"strings"
@@ -32,6 +32,7 @@ import (
 type S3Provider struct {
 	Client     s3iface.S3API
 	Downloader s3manageriface.DownloadWithIterator
+	Options    DownloadOptions
 }
 
 var log = logf.Log.WithName("modelAgent")
@@ -44,7 +45,10 @@ type S3ObjectDownloader struct {
 	ModelName  string
 	Bucket     string
 	Prefix     string
+	Options    DownloadOptions
 	downloader s3manageriface.DownloadWithIterator
+	// files are the partial files of the objects, including the ones already complete which are not downloaded
+	files []*partialFile
 }
 
 func (m *S3Provider) DownloadModel(modelDir string, modelName string, storageUri string) error {
@@ -61,6 +65,7 @@ func (m *S3Provider) DownloadModel(modelDir string, modelName string, storageUri
 		ModelName:  modelName,
 		Bucket:     tokens[0],
 		Prefix:     prefix,
+		Options:    m.Options,
 		downloader: m.Downloader,
 	}
 	objects, err := s3ObjectDownloader.GetAllObjects(m.Client)
@@ -73,6 +78,32 @@ func (m *S3Provider) DownloadModel(modelDir string, modelName string, storageUri
 	return nil
 }
 
+var _ Sizer = (*S3Provider)(nil)
+
+// ModelSize sums the size of the objects under the model prefix
+func (m *S3Provider) ModelSize(storageUri string) (int64, error) {
+	s3Uri := strings.TrimPrefix(storageUri, string(S3))
+	tokens := strings.SplitN(s3Uri, "/", 2)
+	prefix := ""
+	if len(tokens) == 2 {
+		prefix = tokens[1]
+	}
+	resp, err := m.Client.ListObjects(&s3.ListObjectsInput{
+		Bucket: aws.String(tokens[0]),
+		Prefix: aws.String(prefix),
+	})
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, object := range resp.Contents {
+		if object.Size != nil {
+			size += *object.Size
+		}
+	}
+	return size, nil
+}
+
 func (s *S3ObjectDownloader) GetAllObjects(s3Svc s3iface.S3API) ([]s3manager.BatchDownloadObject, error) {
 	resp, err := s3Svc.ListObjects(&s3.ListObjectsInput{
 		Bucket: aws.String(s.Bucket),
@@ -96,30 +127,39 @@ func (s *S3ObjectDownloader) GetAllObjects(s3Svc s3iface.S3API) ([]s3manager.Bat
 		subObjectKey := strings.TrimPrefix(*object.Key, s.Prefix)
 		fileName := filepath.Join(s.ModelDir, s.ModelName, subObjectKey)
 
-		if FileExists(fileName) {
-			// File got corrupted or is mid-download :(
-			// TODO: Figure out if we can maybe continue?
-			if err := os.Remove(fileName); err != nil {
-				return nil, fmt.Errorf("file is unable to be deleted: %v", err)
-			}
-		}
-		file, err := Create(fileName)
+		file, err := openPartialFile(fileName)
 		if err != nil {
-			return nil, fmt.Errorf("file is already created: %v", err)
+			s.closeFiles()
+			return nil, fmt.Errorf("unable to create file: %v", err)
 		}
-		object := s3manager.BatchDownloadObject{
-			Object: &s3.GetObjectInput{
-				Key:    aws.String(*object.Key),
-				Bucket: aws.String(s.Bucket),
-			},
-			Writer: file,
-			After: func() error {
-				defer file.Close()
-				return nil
-			},
+		size := int64(-1)
+		if object.Size != nil {
+			size = *object.Size
+		}
+		s.files = append(s.files, file)
+		if err := file.resume(aws.StringValue(object.ETag), size); err != nil {
+			s.closeFiles()
+			return nil, fmt.Errorf("unable to resume file: %v", err)
+		}
+		if !s.Options.SkipChecksums {
+			file.checksum = ETagChecksum(aws.StringValue(object.ETag))
 		}
 		foundObject = true
-		results = append(results, object)
+		if file.complete() {
+			continue
+		}
+		input := &s3.GetObjectInput{
+			Key:    aws.String(*object.Key),
+			Bucket: aws.String(s.Bucket),
+		}
+		if file.offset > 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", file.offset))
+		}
+		results = append(results, s3manager.BatchDownloadObject{
+			Object: input,
+			Writer: file,
+			After:  file.Close,
+		})
 	}
 
 	if !foundObject {
@@ -129,10 +169,41 @@ func (s *S3ObjectDownloader) GetAllObjects(s3Svc s3iface.S3API) ([]s3manager.Bat
 	return results, nil
 }
 
+func (s *S3ObjectDownloader) closeFiles() {
+	for _, file := range s.files {
+		file.Close()
+	}
+}
+
+// Download fetches the objects with up to Options.Parallelism batches at a time, the files are only moved in place
+// once every object is downloaded so that a failed download is resumed on the next attempt
 func (s *S3ObjectDownloader) Download(objects []s3manager.BatchDownloadObject) error {
-	iter := &s3manager.DownloadObjectsIterator{Objects: objects}
-	if err := s.downloader.DownloadWithIterator(aws.BackgroundContext(), iter); err != nil {
-		return err
+	parallelism := s.Options.parallelism()
+	batches := make([][]s3manager.BatchDownloadObject, parallelism)
+	for i, object := range objects {
+		batches[i%parallelism] = append(batches[i%parallelism], object)
+	}
+	errs := runParallel(len(batches), parallelism, func(i int) error {
+		if len(batches[i]) == 0 {
+			return nil
+		}
+		iter := &s3manager.DownloadObjectsIterator{Objects: batches[i]}
+		return s.downloader.DownloadWithIterator(aws.BackgroundContext(), iter)
+	})
+	if len(errs) > 0 {
+		s.closeFiles()
+	} else {
+		for _, file := range s.files {
+			if err := file.commit(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	if len(errs) > 0 {
+		return awserr.NewBatchError("S3DownloadIncomplete", "some objects failed to download.", errs)
 	}
 	return nil
 }
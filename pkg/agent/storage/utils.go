@@ -26,12 +26,15 @@ import (
 	"strings"
 
 	gstorage "cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/googleapis/google-cloud-go-testing/storage/stiface"
+	"github.com/kserve/kserve/pkg/constants"
+	azurecredential "github.com/kserve/kserve/pkg/credentials/azure"
 	gcscredential "github.com/kserve/kserve/pkg/credentials/gcs"
 	s3credential "github.com/kserve/kserve/pkg/credentials/s3"
 	"google.golang.org/api/option"
@@ -91,7 +94,9 @@ func RemoveDir(dir string) error {
 	return nil
 }
 
-func GetProvider(providers map[Protocol]Provider, protocol Protocol) (Provider, error) {
+// GetProvider returns the provider of the protocol, the provider is created with the download options unless it is
+// already in providers
+func GetProvider(providers map[Protocol]Provider, protocol Protocol, options DownloadOptions) (Provider, error) {
 	if provider, ok := providers[protocol]; ok {
 		return provider, nil
 	}
@@ -115,7 +120,8 @@ func GetProvider(providers map[Protocol]Provider, protocol Protocol) (Provider,
 		}
 
 		providers[GCS] = &GCSProvider{
-			Client: stiface.AdaptClient(gcsClient),
+			Client:  stiface.AdaptClient(gcsClient),
+			Options: options,
 		}
 	case S3:
 		var sess *session.Session
@@ -151,16 +157,66 @@ func GetProvider(providers map[Protocol]Provider, protocol Protocol) (Provider,
 		providers[S3] = &S3Provider{
 			Client:     sessionClient,
 			Downloader: s3manager.NewDownloaderWithClient(sessionClient, func(d *s3manager.Downloader) {}),
+			Options:    options,
+		}
+	case AzureBlob:
+		provider := &AzureBlobProvider{
+			AccountKey: os.Getenv(azurecredential.AzureStorageAccessKey),
+			Options:    options,
+		}
+		clientId, hasClientId := os.LookupEnv(azurecredential.AzureClientId)
+		if provider.AccountKey == "" && hasClientId {
+			credential, err := azidentity.NewClientSecretCredential(os.Getenv(azurecredential.AzureTenantId), clientId,
+				os.Getenv(azurecredential.AzureClientSecret), nil)
+			if err != nil {
+				return nil, err
+			}
+			provider.TokenCredential = credential
+		}
+		providers[AzureBlob] = provider
+	case File, PVC:
+		providers[protocol] = &LocalProvider{
+			PVCDir:  constants.ModelPVCDir,
+			Options: options,
+		}
+	case HDFS, WebHDFS:
+		config, err := LoadHDFSConfig("")
+		if err != nil {
+			return nil, err
+		}
+		// the namenode of the storage-initializer secrets is a webhdfs url, their hdfs:// uris are read with webhdfs
+		if protocol == WebHDFS || config.IsWebHDFS() {
+			client, err := NewWebHDFSClient(config)
+			if err != nil {
+				return nil, err
+			}
+			providers[protocol] = &WebHDFSProvider{
+				Client:  client,
+				Config:  *config,
+				Options: options,
+			}
+		} else {
+			client, err := NewHDFSClient(config)
+			if err != nil {
+				return nil, err
+			}
+			providers[protocol] = &HDFSProvider{
+				Client:  client,
+				Config:  *config,
+				Options: options,
+			}
 		}
 	case HTTPS:
 		httpsClient := &http.Client{}
 		providers[HTTPS] = &HTTPSProvider{
-			Client: httpsClient,
+			Client:  httpsClient,
+			Options: options,
 		}
 	case HTTP:
 		httpsClient := &http.Client{}
 		providers[HTTP] = &HTTPSProvider{
-			Client: httpsClient,
+			Client:  httpsClient,
+			Options: options,
 		}
 	}
 
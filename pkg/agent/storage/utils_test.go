@@ -25,13 +25,14 @@ import (
 	"testing"
 
 	"github.com/kserve/kserve/pkg/agent/mocks"
+	hdfscredential "github.com/kserve/kserve/pkg/credentials/hdfs"
 	"github.com/onsi/gomega"
 )
 
 func TestCreate(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 
-	// This would get called in StartPullerAndProcessModels
+	// This would get called in Puller.ProcessModels
 	syscall.Umask(0)
 
 	tmpDir, _ := ioutil.TempDir("", "test-create-")
@@ -99,14 +100,23 @@ func TestGetProvider(t *testing.T) {
 			Downloader: &mocks.MockS3Downloader{},
 		},
 	}
-	provider, err := GetProvider(mockProviders, S3)
+	provider, err := GetProvider(mockProviders, S3, DownloadOptions{})
 	g.Expect(err).To(gomega.BeNil())
 	g.Expect(provider).Should(gomega.Equal(mockProviders[S3]))
 
 	// When providers map does not have specified provider
+	secretDir := t.TempDir()
+	g.Expect(ioutil.WriteFile(filepath.Join(secretDir, hdfscredential.HdfsNamenode), []byte("http://namenode:9870"), 0644)).
+		To(gomega.Succeed())
+	t.Setenv(HDFSSecretDirEnv, secretDir)
 	for _, protocol := range SupportedProtocols {
-		provider, err = GetProvider(map[Protocol]Provider{}, protocol)
+		provider, err = GetProvider(map[Protocol]Provider{}, protocol, DownloadOptions{})
 		g.Expect(err).To(gomega.BeNil())
 		g.Expect(provider).ShouldNot(gomega.BeNil())
 	}
+
+	// The azure blob uris are https uris with a provider of their own
+	provider, err = GetProvider(map[Protocol]Provider{}, AzureBlob, DownloadOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(provider).Should(gomega.BeAssignableToTypeOf(&AzureBlobProvider{}))
 }
@@ -0,0 +1,242 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+const webHDFSPath = "/webhdfs/v1"
+
+// WebHDFSClient sends the webhdfs requests, an http.Client or a spnego client of a kerberized cluster
+type WebHDFSClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// WebHDFSProvider downloads the webhdfs:// uris, and the hdfs:// uris of a namenode given as a webhdfs url, with the
+// webhdfs rest api of the namenode
+type WebHDFSProvider struct {
+	Client  WebHDFSClient
+	Config  HDFSConfig
+	Options DownloadOptions
+}
+
+// NewWebHDFSClient returns an http client with the tls config of the config, which authenticates with spnego when a
+// kerberos principal is set. The connects, the responses and every read of the file contents are bounded by the
+// timeout of the config, the client has no overall timeout as the model files take as long as they are large.
+func NewWebHDFSClient(config *HDFSConfig) (WebHDFSClient, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.TLSSkipVerify}
+	if config.TLSCA != "" {
+		ca, err := ioutil.ReadFile(config.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read hdfs tls ca: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in hdfs tls ca %s", config.TLSCA)
+		}
+	}
+	if config.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load hdfs tls certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DialContext = dialWithDeadline(config.timeout())
+	transport.TLSHandshakeTimeout = config.timeout()
+	transport.ResponseHeaderTimeout = config.timeout()
+	httpClient := &http.Client{Transport: transport}
+	if config.KerberosPrincipal == "" {
+		return httpClient, nil
+	}
+	krbClient, err := config.KerberosClient()
+	if err != nil {
+		return nil, err
+	}
+	// the service principal is derived from the host of the namenode, HTTP/<host>
+	return spnego.NewClient(krbClient, httpClient, ""), nil
+}
+
+func (p *WebHDFSProvider) DownloadModel(modelDir string, modelName string, storageUri string) error {
+	log.Info("Downloading model ", "modelName", modelName, "storageUri", storageUri, "modelDir", modelDir)
+	downloader := &HDFSDownloader{
+		FileSystem: &webHDFSFileSystem{client: p.Client, config: &p.Config},
+		ModelDir:   modelDir,
+		ModelName:  modelName,
+		Path:       p.Config.Path(storageUri),
+		Options:    p.Options,
+	}
+	return downloader.DownloadModel()
+}
+
+var _ Sizer = (*WebHDFSProvider)(nil)
+
+func (p *WebHDFSProvider) ModelSize(storageUri string) (int64, error) {
+	fileSystem := &webHDFSFileSystem{client: p.Client, config: &p.Config}
+	return (&HDFSDownloader{FileSystem: fileSystem, Path: p.Config.Path(storageUri)}).ModelSize()
+}
+
+// webHDFSFileStatus is the FileStatus json object of the webhdfs api
+type webHDFSFileStatus struct {
+	PathSuffix       string `json:"pathSuffix"`
+	Type             string `json:"type"`
+	Length           int64  `json:"length"`
+	ModificationTime int64  `json:"modificationTime"`
+}
+
+var _ os.FileInfo = (*webHDFSFileStatus)(nil)
+
+func (s *webHDFSFileStatus) Name() string {
+	return s.PathSuffix
+}
+
+func (s *webHDFSFileStatus) Size() int64 {
+	return s.Length
+}
+
+func (s *webHDFSFileStatus) Mode() os.FileMode {
+	if s.IsDir() {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (s *webHDFSFileStatus) ModTime() time.Time {
+	return time.UnixMilli(s.ModificationTime)
+}
+
+func (s *webHDFSFileStatus) IsDir() bool {
+	return s.Type == "DIRECTORY"
+}
+
+func (s *webHDFSFileStatus) Sys() interface{} {
+	return nil
+}
+
+// webHDFSFileSystem reads the files with the webhdfs rest api, the file contents are redirected to the datanodes
+type webHDFSFileSystem struct {
+	client WebHDFSClient
+	config *HDFSConfig
+}
+
+func (w *webHDFSFileSystem) Stat(name string) (os.FileInfo, error) {
+	var body struct {
+		FileStatus webHDFSFileStatus `json:"FileStatus"`
+	}
+	if err := w.getJSON(name, "GETFILESTATUS", &body); err != nil {
+		return nil, err
+	}
+	body.FileStatus.PathSuffix = path.Base(name)
+	return &body.FileStatus, nil
+}
+
+func (w *webHDFSFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	var body struct {
+		FileStatuses struct {
+			FileStatus []webHDFSFileStatus `json:"FileStatus"`
+		} `json:"FileStatuses"`
+	}
+	if err := w.getJSON(name, "LISTSTATUS", &body); err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, len(body.FileStatuses.FileStatus))
+	for i := range body.FileStatuses.FileStatus {
+		infos[i] = &body.FileStatuses.FileStatus[i]
+	}
+	return infos, nil
+}
+
+func (w *webHDFSFileSystem) OpenAt(name string, offset int64) (io.ReadCloser, error) {
+	params := url.Values{}
+	if offset > 0 {
+		params.Set("offset", strconv.FormatInt(offset, 10))
+	}
+	resp, err := w.get(context.Background(), name, "OPEN", params)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// getJSON sends the webhdfs metadata operation on the path, the whole request is bounded by the timeout of the config
+func (w *webHDFSFileSystem) getJSON(name string, op string, body interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.timeout())
+	defer cancel()
+	resp, err := w.get(ctx, name, op, url.Values{})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return fmt.Errorf("unable to decode webhdfs %s response of %s: %v", op, name, err)
+	}
+	return nil
+}
+
+// get sends the webhdfs operation on the path, the response body is closed unless the status is 200
+func (w *webHDFSFileSystem) get(ctx context.Context, name string, op string, params url.Values) (*http.Response, error) {
+	params.Set("op", op)
+	if w.config.UserProxy != "" {
+		params.Set("doas", w.config.UserProxy)
+	}
+	requestURL := strings.TrimSuffix(w.config.Namenode, "/") + webHDFSPath + (&url.URL{Path: name}).EscapedPath() +
+		"?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range w.config.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var remoteErr struct {
+			RemoteException struct {
+				Exception string `json:"exception"`
+				Message   string `json:"message"`
+			} `json:"RemoteException"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&remoteErr) == nil && remoteErr.RemoteException.Exception != "" {
+			return nil, fmt.Errorf("webhdfs %s of %s failed with %s: %s", op, name,
+				remoteErr.RemoteException.Exception, remoteErr.RemoteException.Message)
+		}
+		return nil, fmt.Errorf("webhdfs %s of %s failed with status code %d", op, name, resp.StatusCode)
+	}
+	return resp, nil
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hdfscredential "github.com/kserve/kserve/pkg/credentials/hdfs"
+	"github.com/onsi/gomega"
+)
+
+// newWebHDFSServer stubs the webhdfs api of a namenode with the files, the file contents are redirected to a
+// datanode path of the same server as webhdfs does
+func newWebHDFSServer(files map[string]string, requests *[]*http.Request) *httptest.Server {
+	var mu sync.Mutex
+	fileStatus := func(suffix string, name string) map[string]interface{} {
+		if contents, ok := files[name]; ok {
+			return map[string]interface{}{"pathSuffix": suffix, "type": "FILE", "length": len(contents),
+				"modificationTime": 1660000000000}
+		}
+		return map[string]interface{}{"pathSuffix": suffix, "type": "DIRECTORY", "length": 0}
+	}
+	notFound := func(w http.ResponseWriter, name string) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"RemoteException":{"exception":"FileNotFoundException","message":"File %s does not exist."}}`, name)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*requests = append(*requests, r)
+		mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/datanode") {
+			name := strings.TrimPrefix(r.URL.Path, "/datanode")
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			fmt.Fprint(w, files[name][offset:])
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, webHDFSPath)
+		var children []string
+		for file := range files {
+			if strings.HasPrefix(file, name+"/") {
+				children = append(children, strings.SplitN(strings.TrimPrefix(file, name+"/"), "/", 2)[0])
+			}
+		}
+		_, isFile := files[name]
+		if !isFile && len(children) == 0 {
+			notFound(w, name)
+			return
+		}
+		switch r.URL.Query().Get("op") {
+		case "GETFILESTATUS":
+			json.NewEncoder(w).Encode(map[string]interface{}{"FileStatus": fileStatus("", name)})
+		case "LISTSTATUS":
+			sort.Strings(children)
+			statuses := []map[string]interface{}{}
+			for i, child := range children {
+				if i == 0 || children[i-1] != child {
+					statuses = append(statuses, fileStatus(child, path.Join(name, child)))
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"FileStatuses": map[string]interface{}{"FileStatus": statuses}})
+		case "OPEN":
+			http.Redirect(w, r, "/datanode"+name+"?"+r.URL.RawQuery, http.StatusTemporaryRedirect)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestWebHDFSProviderDownloadModel(t *testing.T) {
+	files := map[string]string{
+		"/models/model1/model.bin":        modelContents,
+		"/models/model1/1/variables.data": "Variables",
+		"/models/model2.bin":              "Other Model",
+		"/user/root/model3/model.bin":     modelContents,
+	}
+	scenarios := map[string]struct {
+		storageUri    string
+		rootPath      string
+		userProxy     string
+		partial       string
+		expectedFiles map[string]string
+		expectedErr   string
+	}{
+		"Dir": {
+			storageUri: "webhdfs://models/model1",
+			expectedFiles: map[string]string{
+				"model.bin":        modelContents,
+				"1/variables.data": "Variables",
+			},
+		},
+		"File": {
+			storageUri:    "hdfs://models/model2.bin",
+			expectedFiles: map[string]string{"model2.bin": "Other Model"},
+		},
+		"RootPathAndUserProxy": {
+			storageUri:    "webhdfs://model3",
+			rootPath:      "/user/root",
+			userProxy:     "root",
+			expectedFiles: map[string]string{"model.bin": modelContents},
+		},
+		"Resumed": {
+			storageUri:    "webhdfs://models/model1",
+			partial:       modelContents[:5],
+			expectedFiles: map[string]string{"model.bin": modelContents},
+		},
+		"NotFound": {
+			storageUri:  "webhdfs://models/model4",
+			expectedErr: "FileNotFoundException",
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			var requests []*http.Request
+			server := newWebHDFSServer(files, &requests)
+			defer server.Close()
+			modelDir, _ := ioutil.TempDir("", "webhdfs")
+			defer os.RemoveAll(modelDir)
+			if scenario.partial != "" {
+				writePartialFile(g, filepath.Join(modelDir, "model1", "model.bin"), scenario.partial,
+					fileVersion(&webHDFSFileStatus{Length: int64(len(modelContents)), ModificationTime: 1660000000000}))
+			}
+			provider := &WebHDFSProvider{
+				Client: server.Client(),
+				Config: HDFSConfig{
+					Namenode:  server.URL,
+					RootPath:  scenario.rootPath,
+					UserProxy: scenario.userProxy,
+					Headers:   map[string]string{"X-Test": "test"},
+				},
+			}
+
+			err := provider.DownloadModel(modelDir, "model1", scenario.storageUri)
+			if scenario.expectedErr != "" {
+				g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(scenario.expectedErr)))
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			for file, expected := range scenario.expectedFiles {
+				contents, err := ioutil.ReadFile(filepath.Join(modelDir, "model1", file))
+				g.Expect(err).To(gomega.BeNil())
+				g.Expect(string(contents)).To(gomega.Equal(expected))
+			}
+			for _, r := range requests {
+				if strings.HasPrefix(r.URL.Path, webHDFSPath) {
+					g.Expect(r.Header.Get("X-Test")).To(gomega.Equal("test"))
+					g.Expect(r.URL.Query().Get("doas")).To(gomega.Equal(scenario.userProxy))
+				}
+				if scenario.partial != "" && r.URL.Query().Get("op") == "OPEN" && strings.HasSuffix(r.URL.Path, "model.bin") {
+					g.Expect(r.URL.Query().Get("offset")).To(gomega.Equal("5"))
+				}
+			}
+		})
+	}
+}
+
+func TestWebHDFSProviderModelSize(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var requests []*http.Request
+	server := newWebHDFSServer(map[string]string{
+		"/models/model1/model.bin":        modelContents,
+		"/models/model1/1/variables.data": "Variables",
+	}, &requests)
+	defer server.Close()
+	provider := &WebHDFSProvider{Client: server.Client(), Config: HDFSConfig{Namenode: server.URL}}
+
+	size, err := provider.ModelSize("webhdfs://models/model1")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(size).To(gomega.Equal(int64(len(modelContents) + len("Variables"))))
+}
+
+func TestLoadHDFSConfig(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	secretDir := t.TempDir()
+	for key, value := range map[string]string{
+		hdfscredential.HdfsNamenode:      "https://namenode:9871\n",
+		hdfscredential.HdfsRootPath:      "/user/root",
+		hdfscredential.UserProxy:         "root",
+		hdfscredential.Headers:           `{"X-Test": "test"}`,
+		hdfscredential.TlsSkipVerify:     "True",
+		hdfscredential.KerberosPrincipal: "account@REALM",
+		hdfscredential.KerberosKeytab:    "AAA=",
+	} {
+		g.Expect(ioutil.WriteFile(filepath.Join(secretDir, key), []byte(value), 0644)).To(gomega.Succeed())
+	}
+
+	config, err := LoadHDFSConfig(secretDir)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(*config).To(gomega.Equal(HDFSConfig{
+		Namenode:          "https://namenode:9871",
+		RootPath:          "/user/root",
+		UserProxy:         "root",
+		Headers:           map[string]string{"X-Test": "test"},
+		TLSSkipVerify:     true,
+		KerberosPrincipal: "account@REALM",
+		KerberosKeytab:    filepath.Join(secretDir, hdfscredential.KerberosKeytab),
+	}))
+	g.Expect(config.IsWebHDFS()).To(gomega.BeTrue())
+	g.Expect(config.Path("hdfs://models/model1")).To(gomega.Equal("/user/root/models/model1"))
+	g.Expect(config.Path("hdfs:///models/model1")).To(gomega.Equal("/models/model1"))
+	g.Expect((&HDFSConfig{Namenode: "namenode1:8020,namenode2:8020"}).IsWebHDFS()).To(gomega.BeFalse())
+	g.Expect((&HDFSConfig{}).Path("webhdfs://models/model1")).To(gomega.Equal("/models/model1"))
+
+	_, err = LoadHDFSConfig(t.TempDir())
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("no HDFS_NAMENODE found")))
+}
+
+func TestWebHDFSTimeout(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	// the namenode does not answer the metadata requests and the datanode stalls after the first bytes of the file
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("op") == "OPEN" {
+			fmt.Fprint(w, "Model")
+			w.(http.Flusher).Flush()
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	config := &HDFSConfig{Namenode: server.URL, Timeout: 100 * time.Millisecond}
+	client, err := NewWebHDFSClient(config)
+	g.Expect(err).To(gomega.BeNil())
+	fileSystem := &webHDFSFileSystem{client: client, config: config}
+
+	start := time.Now()
+	_, err = fileSystem.Stat("/models/model1")
+	g.Expect(err).NotTo(gomega.BeNil())
+	reader, err := fileSystem.OpenAt("/models/model1/model.bin", 0)
+	g.Expect(err).To(gomega.BeNil())
+	defer reader.Close()
+	contents, err := ioutil.ReadAll(reader)
+	g.Expect(err).NotTo(gomega.BeNil())
+	g.Expect(string(contents)).To(gomega.Equal("Model"))
+	g.Expect(time.Since(start)).To(gomega.BeNumerically("<", 5*time.Second))
+}
+
+func TestDialWithDeadline(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	// the namenode accepts the connection but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).To(gomega.BeNil())
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			ioutil.ReadAll(conn)
+		}
+	}()
+
+	conn, err := dialWithDeadline(100*time.Millisecond)(context.Background(), "tcp", listener.Addr().String())
+	g.Expect(err).To(gomega.BeNil())
+	defer conn.Close()
+	_, err = conn.Write([]byte("request"))
+	g.Expect(err).To(gomega.BeNil())
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	g.Expect(errors.As(err, &netErr) && netErr.Timeout()).To(gomega.BeTrue())
+	g.Expect(time.Since(start)).To(gomega.BeNumerically("<", 5*time.Second))
+}
@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/kserve/kserve/pkg/agent/storage"
 	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -37,9 +38,14 @@ func SyncModelDir(modelDir string, logger *zap.SugaredLogger) (map[string]modelW
 	logger.Infof("Syncing from model dir %s", modelDir)
 	modelTracker := make(map[string]modelWrapper)
 	err := filepath.Walk(modelDir, func(path string, info os.FileInfo, err error) error {
+		// the models in the staging dir have not been completely downloaded, the previous dir holds the versions
+		// replaced by an update which was interrupted
+		if info.IsDir() && (info.Name() == storage.StagingDirName || info.Name() == PreviousDirName) {
+			return filepath.SkipDir
+		}
 		if !info.IsDir() {
 			fileName := info.Name()
-			if strings.HasPrefix(fileName, "SUCCESS.") {
+			if strings.HasPrefix(fileName, SuccessFilePrefix) {
 				logger.Infof("Syncing from model success file %v", fileName)
 				dir := filepath.Dir(path)
 				dirSplit := strings.Split(dir, "/")
@@ -53,6 +59,7 @@ func SyncModelDir(modelDir string, logger *zap.SugaredLogger) (map[string]modelW
 					return errors.Wrapf(err, "failed to parse success file")
 				}
 				byteValue, err := ioutil.ReadAll(jsonFile)
+				jsonFile.Close()
 				if err != nil {
 					return errors.Wrapf(err, "failed to read from model spec")
 				}
@@ -61,6 +68,9 @@ func SyncModelDir(modelDir string, logger *zap.SugaredLogger) (map[string]modelW
 				if err != nil {
 					return errors.Wrapf(err, "failed to unmarshal model spec")
 				}
+				if err := migrateSuccessFile(path, modelSpec); err != nil {
+					logger.Errorf("Failed to migrate success file %s, model %s will be downloaded again: %v", path, modelName, err)
+				}
 				modelTracker[dirSplit[len(dirSplit)-1]] = modelWrapper{
 					Spec:  modelSpec,
 					stale: true,
@@ -75,3 +85,13 @@ func SyncModelDir(modelDir string, logger *zap.SugaredLogger) (map[string]modelW
 	}
 	return modelTracker, nil
 }
+
+// migrateSuccessFile renames a success file named after an earlier hash of the model spec, e.g. written by an earlier
+// version of the agent, to the current name so that the model is not downloaded again
+func migrateSuccessFile(path string, modelSpec *v1alpha1.ModelSpec) error {
+	successFile := filepath.Join(filepath.Dir(path), successFileName(modelSpec))
+	if path == successFile {
+		return nil
+	}
+	return os.Rename(path, successFile)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kserve/kserve/pkg/agent/storage"
+	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	"github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestSuccessFileName(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelSpec := &v1alpha1.ModelSpec{
+		StorageURI: "s3://models/model1",
+		Framework:  "sklearn",
+		Memory:     resource.MustParse("1Gi"),
+	}
+	// the name must not change across agent versions, or every model is downloaded again after an upgrade
+	g.Expect(successFileName(modelSpec)).To(gomega.Equal(
+		"SUCCESS.v1-bfe51746f03d7783022356a6f80c775f7e7f883feabb98cce16c1ab35265c97f"))
+
+	// the memory does not change the downloaded model
+	g.Expect(successFileName(&v1alpha1.ModelSpec{
+		StorageURI: "s3://models/model1",
+		Framework:  "sklearn",
+		Memory:     resource.MustParse("1024Mi"),
+	})).To(gomega.Equal(successFileName(modelSpec)))
+	g.Expect(successFileName(&v1alpha1.ModelSpec{
+		StorageURI: "s3://models/model1s",
+		Framework:  "klearn",
+	})).NotTo(gomega.Equal(successFileName(modelSpec)))
+}
+
+func TestSyncModelDirMigration(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	modelDir, err := ioutil.TempDir("", "syncer")
+	g.Expect(err).To(gomega.BeNil())
+	defer os.RemoveAll(modelDir)
+	modelSpec := &v1alpha1.ModelSpec{
+		StorageURI: "s3://models/model1",
+		Framework:  "sklearn",
+		Memory:     resource.MustParse("1Gi"),
+	}
+	encodedJson, _ := json.Marshal(modelSpec)
+	// the success file of an earlier agent version is named after the hash of the formatted spec
+	legacySuccessFile := filepath.Join(modelDir, "model1", SuccessFilePrefix+storage.AsSha256(modelSpec))
+	g.Expect(os.MkdirAll(filepath.Dir(legacySuccessFile), 0777)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(legacySuccessFile, encodedJson, 0644)).To(gomega.Succeed())
+	// a model left in the staging dir is not recovered
+	stagingSuccessFile := filepath.Join(modelDir, storage.StagingDirName, "model2", successFileName(modelSpec))
+	g.Expect(os.MkdirAll(filepath.Dir(stagingSuccessFile), 0777)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(stagingSuccessFile, encodedJson, 0644)).To(gomega.Succeed())
+
+	// nor is the previous version of an update
+	previousSuccessFile := filepath.Join(modelDir, PreviousDirName, "model1", successFileName(&v1alpha1.ModelSpec{
+		StorageURI: "s3://models/model1v0",
+		Framework:  "sklearn",
+	}))
+	g.Expect(os.MkdirAll(filepath.Dir(previousSuccessFile), 0777)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(previousSuccessFile, []byte(`{"storageUri":"s3://models/model1v0"}`), 0644)).
+		To(gomega.Succeed())
+
+	zapLogger, _ := zap.NewProduction()
+	modelTracker, err := SyncModelDir(modelDir, zapLogger.Sugar())
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(modelTracker).To(gomega.HaveLen(1))
+	g.Expect(modelTracker).To(gomega.HaveKey("model1"))
+	g.Expect(modelTracker["model1"].Spec.StorageURI).To(gomega.Equal("s3://models/model1"))
+	g.Expect(legacySuccessFile).NotTo(gomega.BeAnExistingFile())
+	g.Expect(filepath.Join(modelDir, "model1", successFileName(modelSpec))).To(gomega.BeAnExistingFile())
+}
@@ -17,24 +17,52 @@ limitations under the License.
 package agent
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/google/go-cmp/cmp"
 	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
 	"github.com/kserve/kserve/pkg/constants"
 	"github.com/kserve/kserve/pkg/modelconfig"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	// ErrModelNotTracked is returned for a model which is not in the model config
+	ErrModelNotTracked = errors.New("model is not tracked by the agent")
+	// ErrWatcherNotStarted is returned for a re-pull requested while the models are pulled on startup
+	ErrWatcherNotStarted = errors.New("agent is pulling the models on startup")
+	// ErrRepullPending is returned for a re-pull requested while a re-pull of the model is queued
+	ErrRepullPending = errors.New("a re-pull of the model is already queued")
+	// ErrModelEventsFull is returned for a re-pull requested while the model events are backed up
+	ErrModelEventsFull = errors.New("agent is busy processing the model events")
 )
 
 type Watcher struct {
 	configDir    string
 	ModelTracker map[string]modelWrapper
 	ModelEvents  chan ModelOp
-	logger       *zap.SugaredLogger
+	// mu guards the model tracker, started and the pending re-pulls, which the management api reads while the
+	// config is synced. The model events are never sent while it is held, the puller may not be taking them.
+	mu      *sync.RWMutex
+	started bool
+	// repulls holds the models with a re-pull queued which the puller has not started yet
+	repulls map[string]bool
+	// syncMu orders the model events of the config syncs
+	syncMu *sync.Mutex
+	logger *zap.SugaredLogger
 }
 
 func NewWatcher(configDir string, modelDir string, logger *zap.SugaredLogger) Watcher {
@@ -46,6 +74,9 @@ func NewWatcher(configDir string, modelDir string, logger *zap.SugaredLogger) Wa
 		configDir:    configDir,
 		ModelTracker: modelTracker,
 		ModelEvents:  make(chan ModelOp, 100),
+		mu:           &sync.RWMutex{},
+		repulls:      map[string]bool{},
+		syncMu:       &sync.Mutex{},
 		logger:       logger,
 	}
 	modelConfigFile := fmt.Sprintf("%s/%s", configDir, constants.ModelConfigFileName)
@@ -59,97 +90,182 @@ func NewWatcher(configDir string, modelDir string, logger *zap.SugaredLogger) Wa
 type modelWrapper struct {
 	Spec  *v1alpha1.ModelSpec
 	stale bool
+	// retry is the retry token of the model config, a new token requests another attempt of a failed model
+	retry string
 }
 
 func (w *Watcher) syncModelConfig(modelConfigFile string, initializing bool) error {
 	file, err := ioutil.ReadFile(modelConfigFile)
 	if err != nil {
 		return err
-	} else {
-		modelConfigs := make(modelconfig.ModelConfigs, 0)
-		err = json.Unmarshal(file, &modelConfigs)
-		if err != nil {
-			return err
-		} else {
-			w.parseConfig(modelConfigs, initializing)
-		}
 	}
+	return w.syncModelConfigData(file, initializing)
+}
+
+func (w *Watcher) syncModelConfigData(data []byte, initializing bool) error {
+	modelConfigs := make(modelconfig.ModelConfigs, 0)
+	if err := json.Unmarshal(data, &modelConfigs); err != nil {
+		return err
+	}
+	w.parseConfig(modelConfigs, initializing)
 	return nil
 }
 
-func (w *Watcher) Start() {
+func (w *Watcher) setStarted() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.started = true
+}
+
+// Start watches the model config file of the projected ConfigMap in the config dir until the context is done. The
+// kubelet swaps the ..data symlink of the config dir when the ConfigMap changes, which takes up to its sync period.
+func (w *Watcher) Start(ctx context.Context) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		w.logger.Error(err, "Failed to create model dir watcher")
 		panic(err)
 	}
 	defer watcher.Close()
+	w.setStarted()
 	if err = watcher.Add(w.configDir); err != nil {
 		w.logger.Error(err, "Failed to add watcher config dir")
 	}
 	w.logger.Info("Start to watch model config event")
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				isCreate := event.Op&fsnotify.Create != 0
-				eventPath := filepath.Clean(event.Name)
-				isDataDir := filepath.Base(eventPath) == "..data"
-				// TODO: Should we use atomic integer or timestamp??
-				if isDataDir && isCreate {
-					w.logger.Infof("Processing event %s", event)
-					symlink, _ := filepath.EvalSymlinks(eventPath)
-					modelConfigFile := filepath.Join(symlink, constants.ModelConfigFileName)
-					err := w.syncModelConfig(modelConfigFile, false)
-					if err != nil {
-						w.logger.Error(err, "Failed to sync model config file")
-					}
-				}
-			case err, ok := <-watcher.Errors:
-				if ok { // 'Errors' channel is not closed
-					w.logger.Error(err, "watcher error")
-				}
-				if !ok {
-					return
+	// the config may have changed while the models were pulled on startup
+	modelConfigFile := filepath.Join(w.configDir, constants.ModelConfigFileName)
+	if err := w.syncModelConfig(modelConfigFile, false); err != nil {
+		w.logger.Error(err, "Failed to sync model config file")
+	}
+	w.logger.Infof("Watching %s", modelConfigFile)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			isCreate := event.Op&fsnotify.Create != 0
+			eventPath := filepath.Clean(event.Name)
+			isDataDir := filepath.Base(eventPath) == "..data"
+			// TODO: Should we use atomic integer or timestamp??
+			if isDataDir && isCreate {
+				w.logger.Infof("Processing event %s", event)
+				symlink, _ := filepath.EvalSymlinks(eventPath)
+				modelConfigFile := filepath.Join(symlink, constants.ModelConfigFileName)
+				err := w.syncModelConfig(modelConfigFile, false)
+				if err != nil {
+					w.logger.Error(err, "Failed to sync model config file")
 				}
 			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Error(err, "watcher error")
 		}
-	}()
-	// Add a first create event to the channel to force initial sync
-	watchPath := filepath.Join(w.configDir, "../data", constants.ModelConfigFileName)
-	watcher.Events <- fsnotify.Event{
-		Name: filepath.Join(w.configDir, "..data/"+constants.ModelConfigFileName),
-		Op:   fsnotify.Create,
 	}
-	w.logger.Infof("Watching %s", watchPath)
-	<-done
+}
+
+// StartInformer watches the model ConfigMap through the Kubernetes API until the context is done, which picks up
+// a change within seconds rather than the kubelet sync period. An error is returned when the ConfigMap can not be
+// listed and watched, e.g. when the service account of the pod is not allowed to, so that the caller can fall back
+// to Start.
+func (w *Watcher) StartInformer(ctx context.Context, client kubernetes.Interface, namespace string, name string) error {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	// the informer retries a forbidden list or watch forever, check the permissions first
+	if _, err := configMaps.List(ctx, metav1.ListOptions{FieldSelector: selector}); err != nil {
+		return fmt.Errorf("while listing model config %s/%s: %s", namespace, name, err)
+	}
+	probe, err := configMaps.Watch(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return fmt.Errorf("while watching model config %s/%s: %s", namespace, name, err)
+	}
+	probe.Stop()
+
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = selector
+		}))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.syncConfigMap(obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// the informer also updates the ConfigMap when it lists again after its watch expired
+			if oldObj.(*corev1.ConfigMap).Data[constants.ModelConfigFileName] !=
+				newObj.(*corev1.ConfigMap).Data[constants.ModelConfigFileName] {
+				w.syncConfigMap(newObj)
+			}
+		},
+		// a deleted ConfigMap keeps the models, the projected ConfigMap is not updated either
+		DeleteFunc: func(obj interface{}) {
+			w.logger.Errorf("Model config %s/%s is deleted", namespace, name)
+		},
+	})
+	w.setStarted()
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("model config %s/%s informer did not sync", namespace, name)
+	}
+	w.logger.Infof("Watching model config %s/%s", namespace, name)
+	return nil
+}
+
+func (w *Watcher) syncConfigMap(obj interface{}) {
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return
+	}
+	w.logger.Infof("Processing model config %s/%s version %s", configMap.Namespace, configMap.Name,
+		configMap.ResourceVersion)
+	if err := w.syncModelConfigData([]byte(configMap.Data[constants.ModelConfigFileName]), false); err != nil {
+		w.logger.Errorf("Failed to sync model config %s/%s: %v", configMap.Namespace, configMap.Name, err)
+	}
 }
 
 func (w *Watcher) parseConfig(modelConfigs modelconfig.ModelConfigs, initializing bool) {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	for _, modelOp := range w.diffConfig(modelConfigs, initializing) {
+		w.ModelEvents <- modelOp
+	}
+}
+
+// diffConfig updates the model tracker with the model config and returns the model ops of the changes
+func (w *Watcher) diffConfig(modelConfigs modelconfig.ModelConfigs, initializing bool) []ModelOp {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var modelOps []ModelOp
 	for _, modelConfig := range modelConfigs {
-		name, spec := modelConfig.Name, modelConfig.Spec
+		name, spec, retry := modelConfig.Name, modelConfig.Spec, modelConfig.Retry
 		existing, exists := w.ModelTracker[name]
 		if !exists {
 			// New - add
-			w.ModelTracker[name] = modelWrapper{Spec: &spec}
-			w.modelAdded(name, &spec, initializing)
+			w.ModelTracker[name] = modelWrapper{Spec: &spec, retry: retry}
+			modelOps = append(modelOps, w.modelAdded(name, &spec, initializing))
 		} else if !cmp.Equal(spec, *existing.Spec) {
 			w.ModelTracker[name] = modelWrapper{
-				Spec:  existing.Spec,
+				Spec:  &spec,
 				stale: false,
+				retry: retry,
 			}
-			// Changed - replace
-			w.modelRemoved(name)
-			w.modelAdded(name, &spec, initializing)
+			// Changed - add the new version, the puller replaces the previous version once the new one is loaded
+			modelOps = append(modelOps, w.modelAdded(name, &spec, initializing))
 		} else if cmp.Equal(spec, *existing.Spec) {
 			// This model didn't change, mark the stale flag to false
 			w.ModelTracker[name] = modelWrapper{
 				Spec:  existing.Spec,
 				stale: false,
+				retry: retry,
+			}
+			// The retry token of the models synced from the model dir is not known on startup
+			if retry != existing.retry && !initializing {
+				// Retry requested - add again, the model files already downloaded are kept
+				w.logger.Infof("retry requested for model %s", name)
+				modelOps = append(modelOps, w.modelAdded(name, &spec, initializing))
 			}
 		}
 	}
@@ -157,7 +273,7 @@ func (w *Watcher) parseConfig(modelConfigs modelconfig.ModelConfigs, initializin
 		if wrapper.stale {
 			// Remove the models that are marked as stale
 			delete(w.ModelTracker, name)
-			w.modelRemoved(name)
+			modelOps = append(modelOps, w.modelRemoved(name))
 		} else {
 			// Mark all the models as stale by default, when the next CREATE event is triggered
 			// the watcher will mark stale: false to all the models that didn't change so they won't
@@ -165,14 +281,17 @@ func (w *Watcher) parseConfig(modelConfigs modelconfig.ModelConfigs, initializin
 			w.ModelTracker[name] = modelWrapper{
 				Spec:  wrapper.Spec,
 				stale: true,
+				retry: wrapper.retry,
 			}
 		}
 	}
+	modelsTracked.Set(float64(len(w.ModelTracker)))
+	return modelOps
 }
 
-func (w *Watcher) modelAdded(name string, spec *v1alpha1.ModelSpec, initializing bool) {
+func (w *Watcher) modelAdded(name string, spec *v1alpha1.ModelSpec, initializing bool) ModelOp {
 	w.logger.Infof("adding model %s", name)
-	w.ModelEvents <- ModelOp{
+	return ModelOp{
 		OnStartup: initializing,
 		ModelName: name,
 		Op:        Add,
@@ -180,10 +299,66 @@ func (w *Watcher) modelAdded(name string, spec *v1alpha1.ModelSpec, initializing
 	}
 }
 
-func (w *Watcher) modelRemoved(name string) {
+func (w *Watcher) modelRemoved(name string) ModelOp {
 	w.logger.Infof("removing model %s", name)
-	w.ModelEvents <- ModelOp{
+	return ModelOp{
 		ModelName: name,
 		Op:        Remove,
 	}
 }
+
+// TrackedModels returns the spec of every model in the model config
+func (w *Watcher) TrackedModels() map[string]*v1alpha1.ModelSpec {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	models := make(map[string]*v1alpha1.ModelSpec, len(w.ModelTracker))
+	for name, wrapper := range w.ModelTracker {
+		models[name] = wrapper.Spec
+	}
+	return models
+}
+
+// Repull downloads and loads the model again even when it is already downloaded, e.g. when its files were
+// corrupted on disk. It is only accepted once the models pulled on startup are processed. A re-pull requested while
+// another one is queued for the model is refused rather than queued again, as is a re-pull requested while the model
+// events are backed up, so that a caller can not block the config syncs.
+func (w *Watcher) Repull(name string) error {
+	w.mu.Lock()
+	wrapper, ok := w.ModelTracker[name]
+	switch {
+	case !ok:
+		w.mu.Unlock()
+		return ErrModelNotTracked
+	case !w.started:
+		w.mu.Unlock()
+		return ErrWatcherNotStarted
+	case w.repulls[name]:
+		w.mu.Unlock()
+		return ErrRepullPending
+	}
+	w.repulls[name] = true
+	w.mu.Unlock()
+
+	modelOp := ModelOp{
+		ModelName: name,
+		Op:        Add,
+		Spec:      wrapper.Spec,
+		Repull:    true,
+		started:   func() { w.repullStarted(name) },
+	}
+	select {
+	case w.ModelEvents <- modelOp:
+		w.logger.Infof("re-pull requested for model %s", name)
+		return nil
+	default:
+		w.repullStarted(name)
+		return ErrModelEventsFull
+	}
+}
+
+// repullStarted accepts the next re-pull of the model
+func (w *Watcher) repullStarted(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.repulls, name)
+}
@@ -27,11 +27,15 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	gstorage "cloud.google.com/go/storage"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/kserve/kserve/pkg/agent/mocks"
+	"github.com/kserve/kserve/pkg/agent/modelserver"
+	"github.com/kserve/kserve/pkg/agent/status"
 	"github.com/kserve/kserve/pkg/agent/storage"
 	"github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
 	"github.com/kserve/kserve/pkg/constants"
@@ -39,9 +43,18 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
 )
 
+// testModelServer is the v2 model server the puller loads the models on, the tests do not run one
+var testModelServer = modelserver.NewV2Adapter("http://localhost:8080")
+
 var _ = Describe("Watcher", func() {
 	var modelDir string
 	var sugar *zap.SugaredLogger
@@ -111,7 +124,8 @@ var _ = Describe("Watcher", func() {
 						},
 						Logger: sugar,
 					},
-					logger: sugar,
+					modelServer: testModelServer,
+					logger:      sugar,
 				}
 				puller.waitGroup.wg.Add(len(watcher.ModelEvents))
 				go puller.processCommands(watcher.ModelEvents)
@@ -129,6 +143,66 @@ var _ = Describe("Watcher", func() {
 		})
 	})
 
+	Describe("Watch the model config through the Kubernetes API", func() {
+		modelConfigMap := func(modelConfigs modelconfig.ModelConfigs) *corev1.ConfigMap {
+			data, _ := json.Marshal(modelConfigs)
+			return &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "modelconfig-sklearn-0", Namespace: "default"},
+				Data:       map[string]string{constants.ModelConfigFileName: string(data)},
+			}
+		}
+		model1 := modelconfig.ModelConfig{
+			Name: "model1",
+			Spec: v1alpha1.ModelSpec{StorageURI: "s3://models/model1", Framework: "sklearn"},
+		}
+		model2 := modelconfig.ModelConfig{
+			Name: "model2",
+			Spec: v1alpha1.ModelSpec{StorageURI: "s3://models/model2", Framework: "sklearn"},
+		}
+
+		Context("When the ConfigMap changes", func() {
+			It("should add and remove the models", func() {
+				client := fake.NewSimpleClientset(modelConfigMap(modelconfig.ModelConfigs{model1}))
+				var watches int32
+				client.PrependWatchReactor("configmaps", func(action ktesting.Action) (bool, watch.Interface, error) {
+					atomic.AddInt32(&watches, 1)
+					return false, nil, nil
+				})
+				watcher := NewWatcher(filepath.Join(modelDir, "configs"), modelDir, sugar)
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				Expect(watcher.StartInformer(ctx, client, "default", "modelconfig-sklearn-0")).To(Succeed())
+				Eventually(watcher.ModelEvents).Should(Receive(And(
+					HaveField("ModelName", "model1"), HaveField("Op", Add))))
+				// the fake client only sends the changes to the watches started before, the first one checks the
+				// permissions
+				Eventually(func() int32 { return atomic.LoadInt32(&watches) }).Should(Equal(int32(2)))
+
+				_, err := client.CoreV1().ConfigMaps("default").Update(ctx,
+					modelConfigMap(modelconfig.ModelConfigs{model2}), metav1.UpdateOptions{})
+				Expect(err).To(BeNil())
+				Eventually(watcher.ModelEvents).Should(Receive(And(
+					HaveField("ModelName", "model2"), HaveField("Op", Add))))
+				Eventually(watcher.ModelEvents).Should(Receive(And(
+					HaveField("ModelName", "model1"), HaveField("Op", Remove))))
+				Expect(watcher.TrackedModels()).To(HaveLen(1))
+			})
+		})
+
+		Context("When the ConfigMap can not be watched", func() {
+			It("should fail so that the config dir is watched instead", func() {
+				client := fake.NewSimpleClientset(modelConfigMap(modelconfig.ModelConfigs{model1}))
+				client.PrependWatchReactor("configmaps", func(action ktesting.Action) (bool, watch.Interface, error) {
+					return true, nil, apierrors.NewForbidden(corev1.Resource("configmaps"), "", fmt.Errorf("rbac"))
+				})
+				watcher := NewWatcher(filepath.Join(modelDir, "configs"), modelDir, sugar)
+				Expect(watcher.StartInformer(context.Background(), client, "default", "modelconfig-sklearn-0")).
+					To(MatchError(ContainSubstring("forbidden")))
+				Expect(watcher.ModelEvents).To(BeEmpty())
+			})
+		})
+	})
+
 	Describe("Watch model config changes", func() {
 		Context("When new models are added", func() {
 			It("Should download and load the new models", func() {
@@ -150,7 +224,8 @@ var _ = Describe("Watcher", func() {
 						},
 						Logger: sugar,
 					},
-					logger: sugar,
+					modelServer: testModelServer,
+					logger:      sugar,
 				}
 				go puller.processCommands(watcher.ModelEvents)
 				modelConfigs := modelconfig.ModelConfigs{
@@ -196,7 +271,8 @@ var _ = Describe("Watcher", func() {
 						},
 						Logger: sugar,
 					},
-					logger: sugar,
+					modelServer: testModelServer,
+					logger:      sugar,
 				}
 				go puller.processCommands(watcher.ModelEvents)
 				modelConfigs := modelconfig.ModelConfigs{
@@ -254,7 +330,8 @@ var _ = Describe("Watcher", func() {
 						},
 						Logger: sugar,
 					},
-					logger: sugar,
+					modelServer: testModelServer,
+					logger:      sugar,
 				}
 				puller.waitGroup.wg.Add(len(watcher.ModelEvents))
 				go puller.processCommands(watcher.ModelEvents)
@@ -297,7 +374,8 @@ var _ = Describe("Watcher", func() {
 				Eventually(func() int { return len(puller.channelMap) }).Should(Equal(0))
 				Eventually(func() int { return puller.opStats["model1"][Add] }).Should(Equal(1))
 				Eventually(func() int { return puller.opStats["model2"][Add] }).Should(Equal(2))
-				Eventually(func() int { return puller.opStats["model2"][Remove] }).Should(Equal(1))
+				// the new version replaces the previous one without removing the model
+				Expect(puller.opStats["model2"][Remove]).To(Equal(0))
 			})
 		})
 
@@ -328,7 +406,8 @@ var _ = Describe("Watcher", func() {
 						},
 						Logger: sugar,
 					},
-					logger: sugar,
+					modelServer: testModelServer,
+					logger:      sugar,
 				}
 				go puller.processCommands(watcher.ModelEvents)
 				modelConfigs := modelconfig.ModelConfigs{
@@ -497,7 +576,8 @@ var _ = Describe("Watcher", func() {
 						},
 						Logger: sugar,
 					},
-					logger: sugar,
+					modelServer: testModelServer,
+					logger:      sugar,
 				}
 				go puller.processCommands(watcher.ModelEvents)
 				Eventually(func() int { return len(puller.channelMap) }).Should(Equal(0))
@@ -526,7 +606,8 @@ var _ = Describe("Watcher", func() {
 						},
 						Logger: sugar,
 					},
-					logger: sugar,
+					modelServer: testModelServer,
+					logger:      sugar,
 				}
 				modelConfigs := modelconfig.ModelConfigs{
 					{
@@ -553,6 +634,211 @@ var _ = Describe("Watcher", func() {
 				Expect(puller.opStats["model2"][Add]).Should(Equal(1))
 			})
 		})
+
+		Context("Model Status", func() {
+			It("should publish the failure of a model which can't be downloaded", func() {
+				defer GinkgoRecover()
+				watcher := NewWatcher("/tmp/configs", modelDir, sugar)
+				modelStatus := status.NewStore()
+				puller := Puller{
+					channelMap:  make(map[string]*ModelChannel),
+					completions: make(chan *ModelOp, 4),
+					opStats:     make(map[string]map[OpType]int),
+					waitGroup:   WaitGroupWrapper{sync.WaitGroup{}},
+					Downloader: &Downloader{
+						ModelDir:  modelDir + "/test1",
+						Providers: map[storage.Protocol]storage.Provider{},
+						Logger:    sugar,
+					},
+					modelStatus: modelStatus,
+					modelServer: testModelServer,
+					logger:      sugar,
+				}
+				go puller.processCommands(watcher.ModelEvents)
+				modelConfigs := modelconfig.ModelConfigs{
+					{
+						Name: "model1",
+						Spec: v1alpha1.ModelSpec{
+							StorageURI: "foo://models/model1",
+							Framework:  "sklearn",
+						},
+					},
+				}
+				watcher.parseConfig(modelConfigs, false)
+				Eventually(func() int { return puller.opStats["model1"][Add] }).Should(Equal(1))
+				modelState, ok := modelStatus.Get("model1")
+				Expect(ok).To(BeTrue())
+				Expect(modelState.State).To(Equal(status.ModelFailedToLoad))
+				Expect(modelState.Message).To(ContainSubstring("unsupported protocol"))
+			})
+		})
+
+		Context("Model Retry", func() {
+			newPuller := func(modelStatus *status.Store, retryConfig RetryConfig) *Puller {
+				return &Puller{
+					channelMap:  make(map[string]*ModelChannel),
+					completions: make(chan *ModelOp, 4),
+					opStats:     make(map[string]map[OpType]int),
+					waitGroup:   WaitGroupWrapper{sync.WaitGroup{}},
+					Downloader: &Downloader{
+						ModelDir:  modelDir + "/test1",
+						Providers: map[storage.Protocol]storage.Provider{},
+						Logger:    sugar,
+					},
+					modelStatus: modelStatus,
+					retryConfig: retryConfig,
+					modelServer: testModelServer,
+					logger:      sugar,
+				}
+			}
+			modelConfigs := modelconfig.ModelConfigs{
+				{
+					Name: "model1",
+					Spec: v1alpha1.ModelSpec{
+						StorageURI: "foo://models/model1",
+						Framework:  "sklearn",
+					},
+				},
+			}
+
+			It("should dead letter the model once the attempts are exhausted", func() {
+				defer GinkgoRecover()
+				watcher := NewWatcher("/tmp/configs", modelDir, sugar)
+				modelStatus := status.NewStore()
+				puller := newPuller(modelStatus, RetryConfig{
+					MaxAttempts:    3,
+					InitialBackoff: time.Millisecond,
+					MaxBackoff:     10 * time.Millisecond,
+				})
+				go puller.processCommands(watcher.ModelEvents)
+				watcher.parseConfig(modelConfigs, false)
+				Eventually(func() int { return puller.opStats["model1"][Add] }).Should(Equal(1))
+				modelState, _ := modelStatus.Get("model1")
+				Expect(modelState.State).To(Equal(status.ModelFailedToLoad))
+				Expect(modelState.Message).To(ContainSubstring("failed after 3 attempts"))
+			})
+
+			It("should cancel the retries when the model is removed", func() {
+				defer GinkgoRecover()
+				watcher := NewWatcher("/tmp/configs", modelDir, sugar)
+				modelStatus := status.NewStore()
+				puller := newPuller(modelStatus, RetryConfig{
+					MaxAttempts:    3,
+					InitialBackoff: time.Minute,
+					MaxBackoff:     time.Minute,
+				})
+				go puller.processCommands(watcher.ModelEvents)
+				watcher.parseConfig(modelConfigs, false)
+				Eventually(func() status.ModelState {
+					modelState, _ := modelStatus.Get("model1")
+					return modelState.State
+				}).Should(Equal(status.ModelRetrying))
+				watcher.parseConfig(modelconfig.ModelConfigs{}, false)
+				Eventually(func() int { return puller.opStats["model1"][Remove] }).Should(Equal(1))
+				Expect(puller.opStats["model1"][Add]).To(Equal(1))
+			})
+
+			It("should attempt the model again when a retry is requested", func() {
+				defer GinkgoRecover()
+				watcher := NewWatcher("/tmp/configs", modelDir, sugar)
+				puller := newPuller(status.NewStore(), RetryConfig{})
+				go puller.processCommands(watcher.ModelEvents)
+				watcher.parseConfig(modelConfigs, false)
+				Eventually(func() int { return puller.opStats["model1"][Add] }).Should(Equal(1))
+				// an unchanged config does not add the model again
+				watcher.parseConfig(modelConfigs, false)
+				retryConfigs := modelconfig.ModelConfigs{modelConfigs[0]}
+				retryConfigs[0].Retry = "1"
+				watcher.parseConfig(retryConfigs, false)
+				Eventually(func() int { return puller.opStats["model1"][Add] }).Should(Equal(2))
+				Consistently(func() int { return puller.opStats["model1"][Add] }).Should(Equal(2))
+			})
+		})
+
+		Context("Model Update", func() {
+			It("should replace the previous version once the new version is loaded", func() {
+				defer GinkgoRecover()
+				ctx := context.Background()
+				client := mocks.NewMockClient()
+				bkt := client.Bucket("testBucket")
+				Expect(bkt.Create(ctx, "test", nil)).To(Succeed())
+				for _, version := range []string{"v1", "v2", "v3"} {
+					w := bkt.Object(version + "/model.pt").NewWriter(ctx)
+					fmt.Fprint(w, version)
+				}
+
+				// the fake model server fails to load v3 and records the loaded versions
+				var mu sync.Mutex
+				var requests []string
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					contents, _ := ioutil.ReadFile(filepath.Join(modelDir, "model1", "model.pt"))
+					mu.Lock()
+					requests = append(requests, filepath.Base(r.URL.Path)+" "+string(contents))
+					mu.Unlock()
+					if string(contents) == "v3" {
+						w.WriteHeader(http.StatusInternalServerError)
+					}
+				}))
+				defer server.Close()
+				loadRequests := func() []string {
+					mu.Lock()
+					defer mu.Unlock()
+					return append([]string{}, requests...)
+				}
+
+				watcher := NewWatcher("/tmp/configs", modelDir, sugar)
+				modelStatus := status.NewStore()
+				puller := Puller{
+					channelMap:  make(map[string]*ModelChannel),
+					completions: make(chan *ModelOp, 4),
+					opStats:     make(map[string]map[OpType]int),
+					waitGroup:   WaitGroupWrapper{sync.WaitGroup{}},
+					Downloader: &Downloader{
+						ModelDir: modelDir,
+						Providers: map[storage.Protocol]storage.Provider{
+							storage.GCS: &storage.GCSProvider{Client: client},
+						},
+						Logger: sugar,
+					},
+					modelStatus: modelStatus,
+					modelServer: modelserver.NewV2Adapter(server.URL),
+					logger:      sugar,
+				}
+				go puller.processCommands(watcher.ModelEvents)
+				modelConfigs := func(version string) modelconfig.ModelConfigs {
+					return modelconfig.ModelConfigs{
+						{
+							Name: "model1",
+							Spec: v1alpha1.ModelSpec{
+								StorageURI: "gs://testBucket/" + version,
+								Framework:  "sklearn",
+							},
+						},
+					}
+				}
+				previousPath := filepath.Join(modelDir, PreviousDirName, "model1")
+
+				watcher.parseConfig(modelConfigs("v1"), false)
+				Eventually(func() int { return puller.opStats["model1"][Add] }).Should(Equal(1))
+				watcher.parseConfig(modelConfigs("v2"), false)
+				Eventually(func() int { return puller.opStats["model1"][Add] }).Should(Equal(2))
+				Expect(loadRequests()).To(Equal([]string{"load v1", "load v2"}))
+				Expect(previousPath).NotTo(BeADirectory())
+
+				// v2 is still served when v3 fails to load
+				watcher.parseConfig(modelConfigs("v3"), false)
+				Eventually(func() int { return puller.opStats["model1"][Add] }).Should(Equal(3))
+				Expect(loadRequests()).To(Equal([]string{"load v1", "load v2", "load v3", "load v2"}))
+				contents, err := ioutil.ReadFile(filepath.Join(modelDir, "model1", "model.pt"))
+				Expect(err).To(BeNil())
+				Expect(string(contents)).To(Equal("v2"))
+				Expect(previousPath).NotTo(BeADirectory())
+				modelState, _ := modelStatus.Get("model1")
+				Expect(modelState.State).To(Equal(status.ModelFailedToLoad))
+				Expect(modelState.Message).To(ContainSubstring("the previous version is still served"))
+				Expect(puller.opStats["model1"][Remove]).To(Equal(0))
+			})
+		})
 	})
 
 	Describe("Use HTTP(S) Downloader", func() {
@@ -733,7 +1019,8 @@ var _ = Describe("Watcher", func() {
 							},
 							Logger: sugar,
 						},
-						logger: sugar,
+						modelServer: testModelServer,
+						logger:      sugar,
 					}
 					go puller.processCommands(watcher.ModelEvents)
 					Eventually(func() int { return len(puller.channelMap) }).Should(Equal(0))
@@ -45,6 +45,61 @@ type InferenceGraphSpec struct {
 	// Map of InferenceGraph router nodes
 	// Each node defines the router which can be different routing types
 	Nodes map[string]InferenceRouter `json:"nodes"`
+	// Logger spec of the router, when set the router logs the requests and responses of the graph
+	// and of each of its steps
+	// +optional
+	Logger *LoggerSpec `json:"logger,omitempty"`
+}
+
+// LoggerType controls the scope of log publishing
+// +k8s:openapi-gen=true
+// +kubebuilder:validation:Enum=all;request;response
+type LoggerType string
+
+// LoggerType Enum
+const (
+	// Logger mode to log both request and response
+	LogAll LoggerType = "all"
+	// Logger mode to log only request
+	LogRequest LoggerType = "request"
+	// Logger mode to log only response
+	LogResponse LoggerType = "response"
+)
+
+// LoggerSpec specifies optional payload logging for the InferenceGraph router, it mirrors the
+// InferenceService logger spec
+// +k8s:openapi-gen=true
+type LoggerSpec struct {
+	// URL to send logging events
+	// +optional
+	URL *string `json:"url,omitempty"`
+	// Specifies the scope of the loggers. <br />
+	// Valid values are: <br />
+	// - "all" (default): log both request and response; <br />
+	// - "request": log only request; <br />
+	// - "response": log only response <br />
+	// +optional
+	Mode LoggerType `json:"mode,omitempty"`
+	// Fraction of requests to log between 0 and 1 such as "0.25", defaults to "1" which logs every request.
+	// The steps of a graph request are sampled together with the request.
+	// +optional
+	SampleRate *string `json:"sampleRate,omitempty"`
+	// Requests with this header set to "true" are always logged regardless of the sample rate
+	// +optional
+	ForceSampleHeader *string `json:"forceSampleHeader,omitempty"`
+	// Max size in bytes of the logged request and response bodies, larger bodies are truncated.
+	// Defaults to logging the whole body.
+	// +optional
+	MaxBodyBytes *int64 `json:"maxBodyBytes,omitempty"`
+	// JSON paths of the request and response fields to redact before they are logged,
+	// e.g. "$.instances[*].ssn" or "$.parameters.token"
+	// +optional
+	RedactFields []string `json:"redactFields,omitempty"`
+	// Name of a secret in the InferenceGraph namespace holding the credentials used to connect to the logger url.
+	// The optional keys ca.crt, tls.crt and tls.key configure TLS and mutual TLS, token configures bearer auth
+	// and username and password configure basic auth.
+	// +optional
+	SecretName *string `json:"secretName,omitempty"`
 }
 
 // InferenceRouterType constant for inference routing types
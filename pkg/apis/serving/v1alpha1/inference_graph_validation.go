@@ -21,8 +21,11 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"regexp"
+	"strings"
 
+	"github.com/kserve/kserve/pkg/utils"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -42,6 +45,8 @@ const (
 	TargetNotProvidedError = "Step %d (\"%s\") in node \"%s\" of InferenceGraph \"%s\" does not specify an inference target"
 	// InvalidTargetError defines the error message for inference graph target specifies more than one of nodeName, serviceName, serviceUrl
 	InvalidTargetError = "Step %d (\"%s\") in node \"%s\" of InferenceGraph \"%s\" specifies more than one of nodeName, serviceName, serviceUrl"
+	// InvalidLoggerError defines the error message for an invalid router logger spec
+	InvalidLoggerError = "Logger of InferenceGraph \"%s\" is invalid: %s"
 )
 
 const (
@@ -83,6 +88,10 @@ func (ig *InferenceGraph) ValidateCreate() error {
 	if err := validateInferenceGraphSplitterWeight(ig); err != nil {
 		return err
 	}
+
+	if err := validateInferenceGraphLogger(ig); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -182,3 +191,34 @@ func validateInferenceGraphSplitterWeight(ig *InferenceGraph) error {
 	}
 	return nil
 }
+
+// Validation of inference graph router logger
+func validateInferenceGraphLogger(ig *InferenceGraph) error {
+	logger := ig.Spec.Logger
+	if logger == nil {
+		return nil
+	}
+	// the mode is not defaulted by a webhook, the router logs both request and response when it is empty
+	if !(logger.Mode == "" || logger.Mode == LogAll || logger.Mode == LogRequest || logger.Mode == LogResponse) {
+		return fmt.Errorf(InvalidLoggerError, ig.Name, fmt.Sprintf("invalid mode %q", logger.Mode))
+	}
+	if logger.SampleRate != nil {
+		if _, err := utils.ParseSampleRate(*logger.SampleRate); err != nil {
+			return fmt.Errorf(InvalidLoggerError, ig.Name, fmt.Sprintf("sampleRate: %s", err))
+		}
+	}
+	if logger.MaxBodyBytes != nil && *logger.MaxBodyBytes < 0 {
+		return fmt.Errorf(InvalidLoggerError, ig.Name, "maxBodyBytes cannot be less than 0")
+	}
+	for _, field := range logger.RedactFields {
+		if _, err := utils.ParseJSONPath(field); err != nil {
+			return fmt.Errorf(InvalidLoggerError, ig.Name, fmt.Sprintf("redactFields %q: %s", field, err))
+		}
+	}
+	if logger.SecretName != nil {
+		if errs := validation.IsDNS1123Subdomain(*logger.SecretName); len(errs) > 0 {
+			return fmt.Errorf(InvalidLoggerError, ig.Name, fmt.Sprintf("secretName %q: %s", *logger.SecretName, strings.Join(errs, ", ")))
+		}
+	}
+	return nil
+}
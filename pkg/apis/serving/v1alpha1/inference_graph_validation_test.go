@@ -45,6 +45,7 @@ func TestInferenceGraph_ValidateCreate(t *testing.T) {
 		ig      InferenceGraph
 		update  map[string]string
 		nodes   map[string]InferenceRouter
+		logger  *LoggerSpec
 		matcher types.GomegaMatcher
 	}{
 		"simple": {
@@ -242,6 +243,50 @@ func TestInferenceGraph_ValidateCreate(t *testing.T) {
 			},
 			matcher: gomega.MatchError(fmt.Errorf(DuplicateStepNameError, GraphRootNodeName, "foo-bar", "step1")),
 		},
+		"valid logger": {
+			ig: makeTestInferenceGraph(),
+			nodes: map[string]InferenceRouter{
+				GraphRootNodeName: {},
+			},
+			logger: &LoggerSpec{
+				URL:          proto.String("http://message-dumper.default"),
+				Mode:         LogRequest,
+				SampleRate:   proto.String("0.5"),
+				RedactFields: []string{"$.instances[*].ssn"},
+				SecretName:   proto.String("logger-credentials"),
+			},
+			matcher: gomega.MatchError(nil),
+		},
+		"invalid logger mode": {
+			ig: makeTestInferenceGraph(),
+			nodes: map[string]InferenceRouter{
+				GraphRootNodeName: {},
+			},
+			logger: &LoggerSpec{
+				Mode: "none",
+			},
+			matcher: gomega.MatchError(fmt.Errorf(InvalidLoggerError, "foo-bar", `invalid mode "none"`)),
+		},
+		"invalid logger sample rate": {
+			ig: makeTestInferenceGraph(),
+			nodes: map[string]InferenceRouter{
+				GraphRootNodeName: {},
+			},
+			logger: &LoggerSpec{
+				SampleRate: proto.String("2"),
+			},
+			matcher: gomega.MatchError(fmt.Errorf(InvalidLoggerError, "foo-bar", `sampleRate: sample rate "2" is not between 0 and 1`)),
+		},
+		"invalid logger sample rate format": {
+			ig: makeTestInferenceGraph(),
+			nodes: map[string]InferenceRouter{
+				GraphRootNodeName: {},
+			},
+			logger: &LoggerSpec{
+				SampleRate: proto.String("half"),
+			},
+			matcher: gomega.MatchError(fmt.Errorf(InvalidLoggerError, "foo-bar", `sampleRate: sample rate "half" is not a number`)),
+		},
 	}
 
 	for testName, scenario := range scenarios {
@@ -251,6 +296,7 @@ func TestInferenceGraph_ValidateCreate(t *testing.T) {
 				ig.update(igField, value)
 			}
 			ig.Spec.Nodes = scenario.nodes
+			ig.Spec.Logger = scenario.logger
 			res := scenario.ig.ValidateCreate()
 			if !g.Expect(gomega.MatchError(res)).To(gomega.Equal(scenario.matcher)) {
 				t.Errorf("got %t, want %t", res, scenario.matcher)
@@ -45,6 +45,9 @@ const (
 	MemoryResourceAvailable apis.ConditionType = "MemoryResourceAvailable"
 	// IsMMSPredictor is set when inference service predictor is set to multi-model serving
 	IsMMSPredictor apis.ConditionType = "IsMMSPredictor"
+	// ModelLoaded is set from the load state the model agents report for the trained model. It is not part of the
+	// ready condition set, a trained model is ready once it is added to the model config.
+	ModelLoaded apis.ConditionType = "ModelLoaded"
 )
 
 // TrainedModel Ready condition is depending on inference service readiness condition
@@ -109,6 +109,34 @@ func TestValidateCreate(t *testing.T) {
 			},
 			matcher: gomega.MatchError(fmt.Errorf(InvalidTmNameFormatError, "abc 123", TmRegexp)),
 		},
+		"hdfs storageURI": {
+			tm: makeTestTrainModel(),
+			update: map[string]string{
+				storageURI: "hdfs://kfserving/sklearn/iris",
+			},
+			matcher: gomega.MatchError(nil),
+		},
+		"webhdfs storageURI": {
+			tm: makeTestTrainModel(),
+			update: map[string]string{
+				storageURI: "webhdfs://kfserving/sklearn/iris",
+			},
+			matcher: gomega.MatchError(nil),
+		},
+		"pvc storageURI": {
+			tm: makeTestTrainModel(),
+			update: map[string]string{
+				storageURI: "pvc://models/sklearn/iris",
+			},
+			matcher: gomega.MatchError(nil),
+		},
+		"file storageURI": {
+			tm: makeTestTrainModel(),
+			update: map[string]string{
+				storageURI: "file:///mnt/models/sklearn/iris",
+			},
+			matcher: gomega.MatchError(nil),
+		},
 		"invalid storageURI prefix": {
 			tm: makeTestTrainModel(),
 			update: map[string]string{
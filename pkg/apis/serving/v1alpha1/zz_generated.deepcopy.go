@@ -179,6 +179,11 @@ func (in *InferenceGraphSpec) DeepCopyInto(out *InferenceGraphSpec) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Logger != nil {
+		in, out := &in.Logger, &out.Logger
+		*out = new(LoggerSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InferenceGraphSpec.
@@ -270,6 +275,51 @@ func (in *InferenceTarget) DeepCopy() *InferenceTarget {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoggerSpec) DeepCopyInto(out *LoggerSpec) {
+	*out = *in
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(string)
+		**out = **in
+	}
+	if in.SampleRate != nil {
+		in, out := &in.SampleRate, &out.SampleRate
+		*out = new(string)
+		**out = **in
+	}
+	if in.ForceSampleHeader != nil {
+		in, out := &in.ForceSampleHeader, &out.ForceSampleHeader
+		*out = new(string)
+		**out = **in
+	}
+	if in.MaxBodyBytes != nil {
+		in, out := &in.MaxBodyBytes, &out.MaxBodyBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.RedactFields != nil {
+		in, out := &in.RedactFields, &out.RedactFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecretName != nil {
+		in, out := &in.SecretName, &out.SecretName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoggerSpec.
+func (in *LoggerSpec) DeepCopy() *LoggerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LoggerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModelSpec) DeepCopyInto(out *ModelSpec) {
 	*out = *in
@@ -27,6 +27,7 @@ import (
 	"github.com/kserve/kserve/pkg/utils"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // Known error messages
@@ -38,6 +39,10 @@ const (
 	UnsupportedStorageURIFormatError    = "storageUri, must be one of: [%s] or match https://{}.blob.core.windows.net/{}/{} or be an absolute or relative local path. StorageUri [%s] is not supported."
 	UnsupportedStorageSpecFormatError   = "storage.spec.type, must be one of: [%s]. storage.spec.type [%s] is not supported."
 	InvalidLoggerType                   = "Invalid logger type"
+	InvalidLoggerSampleRateError        = "Invalid logger sampleRate: %s"
+	InvalidLoggerMaxBodyBytesError      = "Logger maxBodyBytes cannot be less than 0"
+	InvalidLoggerRedactFieldError       = "Invalid logger redactFields %q: %s"
+	InvalidLoggerSecretNameError        = "Invalid logger secretName %q: %s"
 	InvalidISVCNameFormatError          = "The InferenceService \"%s\" is invalid: a InferenceService name must consist of lower case alphanumeric characters or '-', and must start with alphabetical character. (e.g. \"my-name\" or \"abc-123\", regex used for validation is '%s')"
 	MaxWorkersShouldBeLessThanMaxError  = "Workers cannot be greater than %d"
 	InvalidWorkerArgument               = "Invalid workers argument"
@@ -211,6 +216,28 @@ func validateLogger(logger *LoggerSpec) error {
 		if !(logger.Mode == LogAll || logger.Mode == LogRequest || logger.Mode == LogResponse) {
 			return fmt.Errorf(InvalidLoggerType)
 		}
+		if logger.SampleRate != nil {
+			if _, err := utils.ParseSampleRate(*logger.SampleRate); err != nil {
+				return fmt.Errorf(InvalidLoggerSampleRateError, err)
+			}
+		}
+		if logger.MaxBodyBytes != nil && *logger.MaxBodyBytes < 0 {
+			return fmt.Errorf(InvalidLoggerMaxBodyBytesError)
+		}
+		for _, field := range logger.RedactFields {
+			// the fields are passed to the agent as a comma separated list
+			if strings.Contains(field, ",") {
+				return fmt.Errorf(InvalidLoggerRedactFieldError, field, "must not contain a comma")
+			}
+			if _, err := utils.ParseJSONPath(field); err != nil {
+				return fmt.Errorf(InvalidLoggerRedactFieldError, field, err)
+			}
+		}
+		if logger.SecretName != nil {
+			if errs := validation.IsDNS1123Subdomain(*logger.SecretName); len(errs) > 0 {
+				return fmt.Errorf(InvalidLoggerSecretNameError, *logger.SecretName, strings.Join(errs, ", "))
+			}
+		}
 	}
 	return nil
 }
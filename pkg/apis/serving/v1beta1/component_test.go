@@ -148,6 +148,64 @@ func TestComponentExtensionSpec_validateLogger(t *testing.T) {
 			logger:  nil,
 			matcher: gomega.BeNil(),
 		},
+		"LoggerWithPayloadFilters": {
+			logger: &LoggerSpec{
+				Mode:         LogAll,
+				SampleRate:   proto.String("0.1"),
+				MaxBodyBytes: proto.Int64(1024),
+				RedactFields: []string{"$.instances[*].ssn", "$.parameters.token"},
+			},
+			matcher: gomega.BeNil(),
+		},
+		"InvalidSampleRate": {
+			logger: &LoggerSpec{
+				Mode:       LogAll,
+				SampleRate: proto.String("1.5"),
+			},
+			matcher: gomega.MatchError(fmt.Errorf(InvalidLoggerSampleRateError, `sample rate "1.5" is not between 0 and 1`)),
+		},
+		"SampleRateIsNotANumber": {
+			logger: &LoggerSpec{
+				Mode:       LogAll,
+				SampleRate: proto.String("10%"),
+			},
+			matcher: gomega.MatchError(fmt.Errorf(InvalidLoggerSampleRateError, `sample rate "10%" is not a number`)),
+		},
+		"InvalidMaxBodyBytes": {
+			logger: &LoggerSpec{
+				Mode:         LogAll,
+				MaxBodyBytes: proto.Int64(-1),
+			},
+			matcher: gomega.MatchError(fmt.Errorf(InvalidLoggerMaxBodyBytesError)),
+		},
+		"InvalidRedactField": {
+			logger: &LoggerSpec{
+				Mode:         LogAll,
+				RedactFields: []string{"instances"},
+			},
+			matcher: gomega.HaveOccurred(),
+		},
+		"RedactFieldWithComma": {
+			logger: &LoggerSpec{
+				Mode:         LogAll,
+				RedactFields: []string{"$.a,b"},
+			},
+			matcher: gomega.HaveOccurred(),
+		},
+		"LoggerWithSecret": {
+			logger: &LoggerSpec{
+				Mode:       LogAll,
+				SecretName: proto.String("logger-credentials"),
+			},
+			matcher: gomega.BeNil(),
+		},
+		"InvalidSecretName": {
+			logger: &LoggerSpec{
+				Mode:       LogAll,
+				SecretName: proto.String("Logger_Credentials"),
+			},
+			matcher: gomega.HaveOccurred(),
+		},
 	}
 	for name, scenario := range scenarios {
 		t.Run(name, func(t *testing.T) {
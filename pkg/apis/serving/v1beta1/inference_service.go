@@ -61,6 +61,26 @@ type LoggerSpec struct {
 	// - "response": log only response <br />
 	// +optional
 	Mode LoggerType `json:"mode,omitempty"`
+	// Fraction of requests to log between 0 and 1 such as "0.25", defaults to "1" which logs every request.
+	// The request and response of an inference are sampled together.
+	// +optional
+	SampleRate *string `json:"sampleRate,omitempty"`
+	// Requests with this header set to "true" are always logged regardless of the sample rate
+	// +optional
+	ForceSampleHeader *string `json:"forceSampleHeader,omitempty"`
+	// Max size in bytes of the logged request and response bodies, larger bodies are truncated.
+	// Defaults to logging the whole body.
+	// +optional
+	MaxBodyBytes *int64 `json:"maxBodyBytes,omitempty"`
+	// JSON paths of the request and response fields to redact before they are logged,
+	// e.g. "$.instances[*].ssn" or "$.parameters.token"
+	// +optional
+	RedactFields []string `json:"redactFields,omitempty"`
+	// Name of a secret in the InferenceService namespace holding the credentials used to connect to the logger url.
+	// The optional keys ca.crt, tls.crt and tls.key configure TLS and mutual TLS, token configures bearer auth
+	// and username and password configure basic auth.
+	// +optional
+	SecretName *string `json:"secretName,omitempty"`
 }
 
 // Batcher specifies optional payload batching available for all components
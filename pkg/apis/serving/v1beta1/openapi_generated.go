@@ -40,6 +40,7 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 		"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.InferenceRouter":           schema_pkg_apis_serving_v1alpha1_InferenceRouter(ref),
 		"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.InferenceStep":             schema_pkg_apis_serving_v1alpha1_InferenceStep(ref),
 		"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.InferenceTarget":           schema_pkg_apis_serving_v1alpha1_InferenceTarget(ref),
+		"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.LoggerSpec":                schema_pkg_apis_serving_v1alpha1_LoggerSpec(ref),
 		"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.ModelSpec":                 schema_pkg_apis_serving_v1alpha1_ModelSpec(ref),
 		"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.ServingRuntime":            schema_pkg_apis_serving_v1alpha1_ServingRuntime(ref),
 		"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.ServingRuntimeList":        schema_pkg_apis_serving_v1alpha1_ServingRuntimeList(ref),
@@ -370,12 +371,18 @@ func schema_pkg_apis_serving_v1alpha1_InferenceGraphSpec(ref common.ReferenceCal
 							},
 						},
 					},
+					"logger": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Logger spec of the router, when set the router logs the requests and responses of the graph and of each of its steps",
+							Ref:         ref("github.com/kserve/kserve/pkg/apis/serving/v1alpha1.LoggerSpec"),
+						},
+					},
 				},
 				Required: []string{"nodes"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.InferenceRouter"},
+			"github.com/kserve/kserve/pkg/apis/serving/v1alpha1.InferenceRouter", "github.com/kserve/kserve/pkg/apis/serving/v1alpha1.LoggerSpec"},
 	}
 }
 
@@ -577,6 +584,76 @@ func schema_pkg_apis_serving_v1alpha1_InferenceTarget(ref common.ReferenceCallba
 	}
 }
 
+func schema_pkg_apis_serving_v1alpha1_LoggerSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "LoggerSpec specifies optional payload logging for the InferenceGraph router, it mirrors the InferenceService logger spec",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"url": {
+						SchemaProps: spec.SchemaProps{
+							Description: "URL to send logging events",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"mode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Specifies the scope of the loggers. <br /> Valid values are: <br /> - \"all\" (default): log both request and response; <br /> - \"request\": log only request; <br /> - \"response\": log only response <br />",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"sampleRate": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Fraction of requests to log between 0 and 1 such as \"0.25\", defaults to \"1\" which logs every request. The steps of a graph request are sampled together with the request.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"forceSampleHeader": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Requests with this header set to \"true\" are always logged regardless of the sample rate",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"maxBodyBytes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Max size in bytes of the logged request and response bodies, larger bodies are truncated. Defaults to logging the whole body.",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"redactFields": {
+						SchemaProps: spec.SchemaProps{
+							Description: "JSON paths of the request and response fields to redact before they are logged, e.g. \"$.instances[*].ssn\" or \"$.parameters.token\"",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"secretName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of a secret in the InferenceGraph namespace holding the credentials used to connect to the logger url. The optional keys ca.crt, tls.crt and tls.key configure TLS and mutual TLS, token configures bearer auth and username and password configure basic auth.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 func schema_pkg_apis_serving_v1alpha1_ModelSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -5027,6 +5104,49 @@ func schema_pkg_apis_serving_v1beta1_LoggerSpec(ref common.ReferenceCallback) co
 							Format:      "",
 						},
 					},
+					"sampleRate": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Fraction of requests to log between 0 and 1 such as \"0.25\", defaults to \"1\" which logs every request. The request and response of an inference are sampled together.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"forceSampleHeader": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Requests with this header set to \"true\" are always logged regardless of the sample rate",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"maxBodyBytes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Max size in bytes of the logged request and response bodies, larger bodies are truncated. Defaults to logging the whole body.",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"redactFields": {
+						SchemaProps: spec.SchemaProps{
+							Description: "JSON paths of the request and response fields to redact before they are logged, e.g. \"$.instances[*].ssn\" or \"$.parameters.token\"",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"secretName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of a secret in the InferenceService namespace holding the credentials used to connect to the logger url. The optional keys ca.crt, tls.crt and tls.key configure TLS and mutual TLS, token configures bearer auth and username and password configure basic auth.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
 			},
 		},
@@ -586,6 +586,31 @@ func (in *LoggerSpec) DeepCopyInto(out *LoggerSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.SampleRate != nil {
+		in, out := &in.SampleRate, &out.SampleRate
+		*out = new(string)
+		**out = **in
+	}
+	if in.ForceSampleHeader != nil {
+		in, out := &in.ForceSampleHeader, &out.ForceSampleHeader
+		*out = new(string)
+		**out = **in
+	}
+	if in.MaxBodyBytes != nil {
+		in, out := &in.MaxBodyBytes, &out.MaxBodyBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.RedactFields != nil {
+		in, out := &in.RedactFields, &out.RedactFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecretName != nil {
+		in, out := &in.SecretName, &out.SecretName
+		*out = new(string)
+		**out = **in
+	}
 	return
 }
 
@@ -22,10 +22,13 @@ import (
 	"encoding/json"
 	"github.com/satori/go.uuid"
 	"go.uber.org/zap"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -33,6 +36,9 @@ const (
 	SleepTime    = time.Microsecond * 100
 	MaxBatchSize = 32
 	MaxLatency   = 5000
+	MaxQueueSize = 1000
+	// BatchIdHeader carries the id of the batch a request was served in
+	BatchIdHeader = "X-Batch-Id"
 )
 
 type Request struct {
@@ -44,37 +50,40 @@ type Input struct {
 	Path         string
 	Instances    *[]interface{}
 	ChannelOut   *chan Response
+	Enqueued     time.Time
 }
 
 type InputInfo struct {
 	ChannelOut *chan Response
 	Index      []int
+	Enqueued   time.Time
 }
 
+// Response is the per input result of a batch prediction. On success Fields holds the
+// upstream response fields and Predictions the predictions for this input, otherwise
+// Body holds the upstream error as is.
 type Response struct {
-	Message     string        `json:"message"`
-	BatchID     string        `json:"batchId"`
-	Predictions []interface{} `json:"predictions"`
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	Fields      map[string]json.RawMessage
+	Predictions []json.RawMessage
+	BatchID     string
 }
 
 type ResponseError struct {
 	Message string `json:"message"`
 }
 
-type PredictionResponse struct {
-	Predictions []interface{} `json:"predictions"`
-}
-
 type BatcherInfo struct {
-	Path               string
-	BatchID            string
-	Request            *http.Request
-	Instances          []interface{}
-	PredictionResponse PredictionResponse
-	ContextMap         map[*context.Context]InputInfo
-	Start              time.Time
-	Now                time.Time
-	CurrentInputLen    int
+	Path            string
+	BatchID         string
+	Request         *http.Request
+	Instances       []interface{}
+	ContextMap      map[*context.Context]InputInfo
+	Start           time.Time
+	Now             time.Time
+	CurrentInputLen int
 }
 
 func GetNowTime() time.Time {
@@ -89,12 +98,31 @@ func (batcherInfo *BatcherInfo) InitializeInfo() {
 	batcherInfo.BatchID = ""
 	batcherInfo.CurrentInputLen = 0
 	batcherInfo.Instances = make([]interface{}, 0)
-	batcherInfo.PredictionResponse = PredictionResponse{}
 	batcherInfo.ContextMap = make(map[*context.Context]InputInfo)
 	batcherInfo.Start = GetNowTime()
 	batcherInfo.Now = batcherInfo.Start
 }
 
+func errorResponse(statusCode int, batchID string, message string) Response {
+	body, _ := json.Marshal(ResponseError{
+		Message: message,
+	})
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       body,
+		BatchID:    batchID,
+	}
+}
+
+func (handler *BatchHandler) sendToAll(res Response) {
+	for _, v := range handler.batcherInfo.ContextMap {
+		*v.ChannelOut <- res
+	}
+}
+
 func (handler *BatchHandler) batchPredict() {
 	jsonStr, _ := json.Marshal(Request{
 		handler.batcherInfo.Instances,
@@ -102,51 +130,51 @@ func (handler *BatchHandler) batchPredict() {
 	reader := bytes.NewReader(jsonStr)
 	r := httptest.NewRequest("POST", handler.batcherInfo.Path, reader)
 	rr := httptest.NewRecorder()
+	start := GetNowTime()
+	batchSize.Observe(float64(len(handler.batcherInfo.Instances)))
+	for _, v := range handler.batcherInfo.ContextMap {
+		queueWaitSeconds.Observe(start.Sub(v.Enqueued).Seconds())
+	}
 	handler.next.ServeHTTP(rr, r)
+	upstreamLatencySeconds.WithLabelValues(strconv.Itoa(rr.Code)).Observe(GetNowTime().Sub(start).Seconds())
 	responseBody := rr.Body.Bytes()
+	handler.batcherInfo.BatchID = GenerateUUID()
 	if rr.Code != http.StatusOK {
+		// pass the upstream error back to every request in the batch unchanged
 		handler.log.Errorf("error response with code %v", rr)
-		for _, v := range handler.batcherInfo.ContextMap {
-			res := Response{
-				Message:     string(responseBody),
-				BatchID:     "",
-				Predictions: nil,
-			}
-			*v.ChannelOut <- res
-		}
+		header := rr.Header().Clone()
+		header.Del("Content-Length")
+		handler.sendToAll(Response{
+			StatusCode: rr.Code,
+			Header:     header,
+			Body:       responseBody,
+			BatchID:    handler.batcherInfo.BatchID,
+		})
+		handler.batcherInfo.InitializeInfo()
+		return
+	}
+	// keep every field of the upstream response and only split the predictions
+	var fields map[string]json.RawMessage
+	var predictions []json.RawMessage
+	if err := json.Unmarshal(responseBody, &fields); err != nil {
+		handler.sendToAll(errorResponse(http.StatusInternalServerError, handler.batcherInfo.BatchID, err.Error()))
+	} else if err := json.Unmarshal(fields["predictions"], &predictions); err != nil {
+		handler.sendToAll(errorResponse(http.StatusInternalServerError, handler.batcherInfo.BatchID,
+			"can't Unmarshal predictions: "+err.Error()))
+	} else if len(predictions) != len(handler.batcherInfo.Instances) {
+		handler.sendToAll(errorResponse(http.StatusInternalServerError, handler.batcherInfo.BatchID,
+			"size of prediction is not equal to the size of instances"))
 	} else {
-		handler.batcherInfo.BatchID = GenerateUUID()
-		err := json.Unmarshal(responseBody, &handler.batcherInfo.PredictionResponse)
-		if err != nil {
-			for _, v := range handler.batcherInfo.ContextMap {
-				res := Response{
-					Message: err.Error(),
-					BatchID: handler.batcherInfo.BatchID,
-				}
-				*v.ChannelOut <- res
+		for _, v := range handler.batcherInfo.ContextMap {
+			subset := make([]json.RawMessage, 0, len(v.Index))
+			for _, i := range v.Index {
+				subset = append(subset, predictions[i])
 			}
-		} else {
-			if len(handler.batcherInfo.PredictionResponse.Predictions) != len(handler.batcherInfo.Instances) {
-				for _, v := range handler.batcherInfo.ContextMap {
-					res := Response{
-						Message: "size of prediction is not equal to the size of instances",
-						BatchID: handler.batcherInfo.BatchID,
-					}
-					*v.ChannelOut <- res
-				}
-			} else {
-				for _, v := range handler.batcherInfo.ContextMap {
-					predictions := make([]interface{}, 0)
-					for _, i := range v.Index {
-						predictions = append(predictions, handler.batcherInfo.PredictionResponse.Predictions[i])
-					}
-					res := Response{
-						Message:     "",
-						BatchID:     handler.batcherInfo.BatchID,
-						Predictions: predictions,
-					}
-					*v.ChannelOut <- res
-				}
+			*v.ChannelOut <- Response{
+				StatusCode:  http.StatusOK,
+				Fields:      fields,
+				Predictions: subset,
+				BatchID:     handler.batcherInfo.BatchID,
 			}
 		}
 	}
@@ -158,6 +186,13 @@ func (handler *BatchHandler) batch() {
 	for {
 		select {
 		case req := <-handler.channelIn:
+			// flush the current batch first if the input does not fit, so a batch never exceeds MaxBatchSize
+			if handler.batcherInfo.CurrentInputLen > 0 &&
+				handler.batcherInfo.CurrentInputLen+len(*req.Instances) > handler.MaxBatchSize {
+				flushesTotal.WithLabelValues(FlushReasonSize).Inc()
+				handler.log.Infof("batch predict with size %d %s", len(handler.batcherInfo.Instances), handler.batcherInfo.Path)
+				handler.batchPredict()
+			}
 			if len(handler.batcherInfo.Instances) == 0 {
 				handler.batcherInfo.Start = GetNowTime()
 			}
@@ -171,17 +206,22 @@ func (handler *BatchHandler) batch() {
 			handler.batcherInfo.ContextMap[req.ContextInput] = InputInfo{
 				req.ChannelOut,
 				index,
+				req.Enqueued,
 			}
 			handler.batcherInfo.CurrentInputLen = len(handler.batcherInfo.Instances)
 		case <-time.After(SleepTime):
 		}
 		handler.batcherInfo.Now = GetNowTime()
-		if handler.batcherInfo.CurrentInputLen >= handler.MaxBatchSize ||
-			(handler.batcherInfo.Now.Sub(handler.batcherInfo.Start).Milliseconds() >= int64(handler.MaxLatency) &&
-				handler.batcherInfo.CurrentInputLen > 0) {
-			handler.log.Infof("batch predict with size %d %s", len(handler.batcherInfo.Instances), handler.batcherInfo.Path)
-			handler.batchPredict()
+		if handler.batcherInfo.CurrentInputLen >= handler.MaxBatchSize {
+			flushesTotal.WithLabelValues(FlushReasonSize).Inc()
+		} else if handler.batcherInfo.Now.Sub(handler.batcherInfo.Start).Milliseconds() >= int64(handler.MaxLatency) &&
+			handler.batcherInfo.CurrentInputLen > 0 {
+			flushesTotal.WithLabelValues(FlushReasonLatency).Inc()
+		} else {
+			continue
 		}
+		handler.log.Infof("batch predict with size %d %s", len(handler.batcherInfo.Instances), handler.batcherInfo.Path)
+		handler.batchPredict()
 	}
 }
 
@@ -197,26 +237,97 @@ func (handler *BatchHandler) Consume() {
 }
 
 type BatchHandler struct {
-	next         http.Handler
-	log          *zap.SugaredLogger
-	channelIn    chan Input
-	MaxBatchSize int
-	MaxLatency   int
-	batcherInfo  BatcherInfo
+	next           http.Handler
+	log            *zap.SugaredLogger
+	channelIn      chan Input
+	MaxBatchSize   int
+	MaxLatency     int
+	MaxQueueSize   int
+	MaxPayloadSize int64
+	batcherInfo    BatcherInfo
+	// queueMu serializes the enqueuing of the chunks of a request so the queue capacity checked for all of them
+	// can not be taken by another request in between
+	queueMu sync.Mutex
 }
 
-func New(maxBatchSize int, maxLatency int, handler http.Handler, logger *zap.SugaredLogger) *BatchHandler {
+// New creates a batch handler. maxQueueSize bounds the number of inputs waiting to be batched,
+// requests are rejected once the queue is full. maxPayloadSize limits the request body size in
+// bytes, 0 means no limit.
+func New(maxBatchSize int, maxLatency int, maxQueueSize int, maxPayloadSize int64, handler http.Handler,
+	logger *zap.SugaredLogger) *BatchHandler {
+	if maxBatchSize <= 0 {
+		maxBatchSize = MaxBatchSize
+	}
+	if maxLatency <= 0 {
+		maxLatency = MaxLatency
+	}
+	if maxQueueSize <= 0 {
+		maxQueueSize = MaxQueueSize
+	}
 	batchHandler := BatchHandler{
-		next:         handler,
-		log:          logger,
-		channelIn:    make(chan Input),
-		MaxBatchSize: maxBatchSize,
-		MaxLatency:   maxLatency,
+		next:           handler,
+		log:            logger,
+		channelIn:      make(chan Input, maxQueueSize),
+		MaxBatchSize:   maxBatchSize,
+		MaxLatency:     maxLatency,
+		MaxQueueSize:   maxQueueSize,
+		MaxPayloadSize: maxPayloadSize,
 	}
 	go batchHandler.Consume()
 	return &batchHandler
 }
 
+// splitInstances splits the instances into chunks of at most size instances
+func splitInstances(instances []interface{}, size int) [][]interface{} {
+	chunks := make([][]interface{}, 0, (len(instances)+size-1)/size)
+	for size < len(instances) {
+		chunks = append(chunks, instances[:size:size])
+		instances = instances[size:]
+	}
+	return append(chunks, instances)
+}
+
+func (handler *BatchHandler) writeResponse(w http.ResponseWriter, responses []Response) {
+	// fail the whole request if any of its inputs failed
+	for _, response := range responses {
+		if response.StatusCode != http.StatusOK {
+			for key, values := range response.Header {
+				w.Header()[key] = values
+			}
+			if response.BatchID != "" {
+				w.Header().Set(BatchIdHeader, response.BatchID)
+			}
+			w.WriteHeader(response.StatusCode)
+			if _, err := w.Write(response.Body); err != nil {
+				handler.log.Errorf("failed to write response: %v", err)
+			}
+			return
+		}
+	}
+	fields := make(map[string]json.RawMessage, len(responses[0].Fields))
+	for key, value := range responses[0].Fields {
+		fields[key] = value
+	}
+	predictions := make([]json.RawMessage, 0)
+	for i, response := range responses {
+		predictions = append(predictions, response.Predictions...)
+		if i == 0 || response.BatchID != responses[i-1].BatchID {
+			w.Header().Add(BatchIdHeader, response.BatchID)
+		}
+	}
+	fields["predictions"], _ = json.Marshal(predictions)
+	body, err := json.Marshal(fields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(body); err != nil {
+		handler.log.Errorf("failed to write response: %v", err)
+	}
+}
+
 func (handler *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// only batch predict requests
 	var predictVerb = regexp.MustCompile(`:predict$`)
@@ -227,11 +338,25 @@ func (handler *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var req Request
 	var err error
 	// Read Payload
-	body, err := ioutil.ReadAll(r.Body)
+	if handler.MaxPayloadSize > 0 && r.ContentLength > handler.MaxPayloadSize {
+		rejectedTotal.WithLabelValues(RejectReasonPayloadTooLarge).Inc()
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	reader := io.Reader(r.Body)
+	if handler.MaxPayloadSize > 0 {
+		reader = io.LimitReader(r.Body, handler.MaxPayloadSize+1)
+	}
+	body, err := ioutil.ReadAll(reader)
 	if err != nil {
 		http.Error(w, "can't read body", http.StatusBadRequest)
 		return
 	}
+	if handler.MaxPayloadSize > 0 && int64(len(body)) > handler.MaxPayloadSize {
+		rejectedTotal.WithLabelValues(RejectReasonPayloadTooLarge).Inc()
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err = json.Unmarshal(body, &req); err != nil {
 		http.Error(w, "can't Unmarshal body", http.StatusBadRequest)
 		return
@@ -241,24 +366,43 @@ func (handler *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	handler.log.Infof("serving request %s", r.URL.Path)
-	var ctx = context.Background()
-	var chl = make(chan Response)
-	handler.channelIn <- Input{
-		&ctx,
-		r.URL.Path,
-		&req.Instances,
-		&chl,
-	}
-
-	response := <-chl
-	close(chl)
-	rspbytes, err := json.Marshal(response)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// requests larger than the max batch size are split across batches
+	chunks := splitInstances(req.Instances, handler.MaxBatchSize)
+	if len(chunks) > handler.MaxQueueSize {
+		rejectedTotal.WithLabelValues(RejectReasonPayloadTooLarge).Inc()
+		http.Error(w, "too many instances in the request", http.StatusRequestEntityTooLarge)
+		return
 	}
-	_, err = w.Write(rspbytes)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// reserve the queue capacity for every chunk before enqueuing any, so a rejected request never has some of its
+	// chunks predicted
+	handler.queueMu.Lock()
+	if cap(handler.channelIn)-len(handler.channelIn) < len(chunks) {
+		handler.queueMu.Unlock()
+		rejectedTotal.WithLabelValues(RejectReasonQueueFull).Inc()
+		handler.log.Warnf("batcher queue is full, rejecting request %s", r.URL.Path)
+		http.Error(w, "batcher queue is full", http.StatusTooManyRequests)
 		return
 	}
+	channels := make([]chan Response, 0, len(chunks))
+	for i := range chunks {
+		var ctx = context.Background()
+		var chl = make(chan Response, 1)
+		// the batch loop only takes from the queue, so the reserved capacity is still free
+		handler.channelIn <- Input{
+			&ctx,
+			r.URL.Path,
+			&chunks[i],
+			&chl,
+			GetNowTime(),
+		}
+		channels = append(channels, chl)
+	}
+	handler.queueMu.Unlock()
+
+	responses := make([]Response, 0, len(channels))
+	for _, chl := range channels {
+		responses = append(responses, <-chl)
+		close(chl)
+	}
+	handler.writeResponse(w, responses)
 }
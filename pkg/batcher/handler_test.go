@@ -21,6 +21,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"io/ioutil"
 	pkglogging "knative.dev/pkg/logging"
 	"net/http"
@@ -31,7 +33,11 @@ import (
 	"testing"
 )
 
-func serveRequest(batchHandler *BatchHandler, wg *sync.WaitGroup, index int) {
+type PredictionResponse struct {
+	Predictions []interface{} `json:"predictions"`
+}
+
+func serveRequest(batchHandler *BatchHandler, wg *sync.WaitGroup, index int) *http.Response {
 	defer wg.Done()
 	instances := fmt.Sprintf("{\"instances\": [[%d, %d, %d]]}", index, index, index)
 	predictorRequest := []byte(instances)
@@ -42,9 +48,8 @@ func serveRequest(batchHandler *BatchHandler, wg *sync.WaitGroup, index int) {
 	batchHandler.ServeHTTP(w, r)
 
 	b2, _ := ioutil.ReadAll(w.Result().Body)
-	var res Response
-	_ = json.Unmarshal(b2, &res)
-	fmt.Printf("Got response %v\n", res)
+	fmt.Printf("Got response %v\n", string(b2))
+	return w.Result()
 }
 
 func TestBatcher(t *testing.T) {
@@ -52,7 +57,7 @@ func TestBatcher(t *testing.T) {
 
 	logger, _ := pkglogging.NewLogger("", "INFO")
 
-	responseChan := make(chan Response)
+	responseChan := make(chan PredictionResponse)
 	// Start a local HTTP server
 	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		b, err := ioutil.ReadAll(req.Body)
@@ -61,7 +66,7 @@ func TestBatcher(t *testing.T) {
 		err = json.Unmarshal(b, &request)
 		g.Expect(err).To(gomega.BeNil())
 		logger.Infof("Get request %v", string(b))
-		response := Response{
+		response := PredictionResponse{
 			Predictions: request.Instances,
 		}
 		responseChan <- response
@@ -76,7 +81,7 @@ func TestBatcher(t *testing.T) {
 	logger.Infof("predictor url %s", predictorSvcUrl)
 	g.Expect(err).To(gomega.BeNil())
 	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
-	batchHandler := New(32, 50, httpProxy, logger)
+	batchHandler := New(32, 50, 0, 0, httpProxy, logger)
 	var wg sync.WaitGroup
 	for i := 0; i < 10; i++ {
 		wg.Add(1)
@@ -93,7 +98,7 @@ func TestBatcherFail(t *testing.T) {
 
 	logger, _ := pkglogging.NewLogger("", "INFO")
 
-	responseChan := make(chan Response)
+	responseChan := make(chan PredictionResponse)
 	// Start a local HTTP server
 	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		b, err := ioutil.ReadAll(req.Body)
@@ -102,7 +107,7 @@ func TestBatcherFail(t *testing.T) {
 		err = json.Unmarshal(b, &request)
 		g.Expect(err).To(gomega.BeNil())
 		logger.Infof("Get request %v", string(b))
-		response := Response{}
+		response := PredictionResponse{}
 		responseChan <- response
 		responseBytes, err := json.Marshal(response)
 		g.Expect(err).To(gomega.BeNil())
@@ -116,7 +121,7 @@ func TestBatcherFail(t *testing.T) {
 	logger.Infof("predictor url %s", predictorSvcUrl)
 	g.Expect(err).To(gomega.BeNil())
 	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
-	batchHandler := New(32, 50, httpProxy, logger)
+	batchHandler := New(32, 50, 0, 0, httpProxy, logger)
 	var wg sync.WaitGroup
 	for i := 0; i < 10; i++ {
 		wg.Add(1)
@@ -133,7 +138,7 @@ func TestBatcherDefaults(t *testing.T) {
 
 	logger, _ := pkglogging.NewLogger("", "INFO")
 
-	responseChan := make(chan Response)
+	responseChan := make(chan PredictionResponse)
 	// Start a local HTTP server
 	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		b, err := ioutil.ReadAll(req.Body)
@@ -142,7 +147,7 @@ func TestBatcherDefaults(t *testing.T) {
 		err = json.Unmarshal(b, &request)
 		g.Expect(err).To(gomega.BeNil())
 		logger.Infof("Get request %v", string(b))
-		response := Response{
+		response := PredictionResponse{
 			Predictions: request.Instances,
 		}
 		responseChan <- response
@@ -158,7 +163,7 @@ func TestBatcherDefaults(t *testing.T) {
 	logger.Infof("predictor url %s", predictorSvcUrl)
 	g.Expect(err).To(gomega.BeNil())
 	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
-	batchHandler := New(-1, -1, httpProxy, logger)
+	batchHandler := New(-1, -1, -1, 0, httpProxy, logger)
 	var wg sync.WaitGroup
 	for i := 0; i < 10; i++ {
 		wg.Add(1)
@@ -169,4 +174,355 @@ func TestBatcherDefaults(t *testing.T) {
 	wg.Wait()
 	g.Expect(batchHandler.MaxBatchSize).To(gomega.Equal(MaxBatchSize))
 	g.Expect(batchHandler.MaxLatency).To(gomega.Equal(MaxLatency))
+	g.Expect(batchHandler.MaxQueueSize).To(gomega.Equal(MaxQueueSize))
+}
+
+// Tests that upstream status codes are passed back on failure
+func TestBatcherUpstreamStatusCode(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, err := rw.Write([]byte(`{"error":"model not ready"}`))
+		g.Expect(err).To(gomega.BeNil())
+	}))
+	defer predictor.Close()
+	predictorSvcUrl, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
+	batchHandler := New(2, 50, 0, 0, httpProxy, logger)
+
+	r := httptest.NewRequest("POST", "/v1/models/test:predict", bytes.NewReader([]byte(`{"instances": [[1, 2, 3]]}`)))
+	w := httptest.NewRecorder()
+	batchHandler.ServeHTTP(w, r)
+
+	g.Expect(w.Code).To(gomega.Equal(http.StatusServiceUnavailable))
+	g.Expect(w.Header().Get("Content-Type")).To(gomega.Equal("application/json"))
+	g.Expect(w.Header().Get(BatchIdHeader)).NotTo(gomega.BeEmpty())
+	g.Expect(w.Body.String()).To(gomega.Equal(`{"error":"model not ready"}`))
+}
+
+// Tests that the requests of a failed batch get the id of the batch
+func TestBatcherUpstreamErrorBatchId(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, err := rw.Write([]byte(`{"error":"out of memory"}`))
+		g.Expect(err).To(gomega.BeNil())
+	}))
+	defer predictor.Close()
+	predictorSvcUrl, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
+	// the batch is only sent once both requests are queued
+	batchHandler := New(2, 10000, 0, 0, httpProxy, logger)
+
+	recorders := make([]*httptest.ResponseRecorder, 2)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			r := httptest.NewRequest("POST", "/v1/models/test:predict", bytes.NewReader([]byte(`{"instances": [[1, 2, 3]]}`)))
+			batchHandler.ServeHTTP(w, r)
+		}(recorders[i])
+	}
+	wg.Wait()
+
+	for _, w := range recorders {
+		g.Expect(w.Code).To(gomega.Equal(http.StatusInternalServerError))
+		g.Expect(w.Body.String()).To(gomega.Equal(`{"error":"out of memory"}`))
+	}
+	g.Expect(recorders[0].Header().Get(BatchIdHeader)).NotTo(gomega.BeEmpty())
+	g.Expect(recorders[1].Header().Get(BatchIdHeader)).To(gomega.Equal(recorders[0].Header().Get(BatchIdHeader)))
+}
+
+// Tests that fields other than predictions are carried through to each request
+func TestBatcherPreservesResponseFields(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := ioutil.ReadAll(req.Body)
+		g.Expect(err).To(gomega.BeNil())
+		var request Request
+		err = json.Unmarshal(b, &request)
+		g.Expect(err).To(gomega.BeNil())
+		responseBytes, err := json.Marshal(map[string]interface{}{
+			"model_name":  "test",
+			"predictions": request.Instances,
+		})
+		g.Expect(err).To(gomega.BeNil())
+		_, err = rw.Write(responseBytes)
+		g.Expect(err).To(gomega.BeNil())
+	}))
+	defer predictor.Close()
+	predictorSvcUrl, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
+	batchHandler := New(32, 50, 0, 0, httpProxy, logger)
+
+	var wg sync.WaitGroup
+	results := make([]*httptest.ResponseRecorder, 4)
+	for i := range results {
+		wg.Add(1)
+		results[i] = httptest.NewRecorder()
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"instances": [[%d], [%d]]}`, i, i)
+			r := httptest.NewRequest("POST", "/v1/models/test:predict", bytes.NewReader([]byte(body)))
+			batchHandler.ServeHTTP(results[i], r)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, w := range results {
+		g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+		g.Expect(w.Header().Get(BatchIdHeader)).NotTo(gomega.BeEmpty())
+		var res map[string]interface{}
+		g.Expect(json.Unmarshal(w.Body.Bytes(), &res)).To(gomega.Succeed())
+		g.Expect(res["model_name"]).To(gomega.Equal("test"))
+		g.Expect(res["predictions"]).To(gomega.Equal([]interface{}{
+			[]interface{}{float64(i)}, []interface{}{float64(i)},
+		}))
+		g.Expect(res).NotTo(gomega.HaveKey("batchId"))
+	}
+}
+
+// Tests that batch size, queue wait and flush reason are recorded
+func TestBatcherMetrics(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+	registry := prometheus.NewRegistry()
+	g.Expect(RegisterMetrics(registry)).To(gomega.Succeed())
+
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := ioutil.ReadAll(req.Body)
+		g.Expect(err).To(gomega.BeNil())
+		var request Request
+		err = json.Unmarshal(b, &request)
+		g.Expect(err).To(gomega.BeNil())
+		responseBytes, err := json.Marshal(PredictionResponse{
+			Predictions: request.Instances,
+		})
+		g.Expect(err).To(gomega.BeNil())
+		_, err = rw.Write(responseBytes)
+		g.Expect(err).To(gomega.BeNil())
+	}))
+	defer predictor.Close()
+	predictorSvcUrl, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
+
+	sizeFlushes := testutil.ToFloat64(flushesTotal.WithLabelValues(FlushReasonSize))
+	latencyFlushes := testutil.ToFloat64(flushesTotal.WithLabelValues(FlushReasonLatency))
+
+	// a single instance with a batch size of one is flushed by size
+	batchHandler := New(1, 5000, 0, 0, httpProxy, logger)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	serveRequest(batchHandler, &wg, 0)
+	g.Expect(testutil.ToFloat64(flushesTotal.WithLabelValues(FlushReasonSize))).To(gomega.Equal(sizeFlushes + 1))
+
+	// a single instance with a large batch size is flushed by latency
+	batchHandler = New(32, 10, 0, 0, httpProxy, logger)
+	wg.Add(1)
+	serveRequest(batchHandler, &wg, 0)
+	g.Expect(testutil.ToFloat64(flushesTotal.WithLabelValues(FlushReasonLatency))).To(gomega.Equal(latencyFlushes + 1))
+
+	families, err := registry.Gather()
+	g.Expect(err).To(gomega.BeNil())
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	g.Expect(names).To(gomega.ContainElements(
+		"kserve_batcher_batch_size",
+		"kserve_batcher_queue_wait_seconds",
+		"kserve_batcher_upstream_latency_seconds",
+		"kserve_batcher_flushes_total",
+	))
+}
+
+// Tests that requests larger than the max batch size are split across batches
+func TestBatcherSplitsLargeRequests(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	var mu sync.Mutex
+	batchSizes := make([]int, 0)
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := ioutil.ReadAll(req.Body)
+		g.Expect(err).To(gomega.BeNil())
+		var request Request
+		err = json.Unmarshal(b, &request)
+		g.Expect(err).To(gomega.BeNil())
+		mu.Lock()
+		batchSizes = append(batchSizes, len(request.Instances))
+		mu.Unlock()
+		responseBytes, err := json.Marshal(PredictionResponse{
+			Predictions: request.Instances,
+		})
+		g.Expect(err).To(gomega.BeNil())
+		_, err = rw.Write(responseBytes)
+		g.Expect(err).To(gomega.BeNil())
+	}))
+	defer predictor.Close()
+	predictorSvcUrl, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
+	batchHandler := New(2, 50, 0, 0, httpProxy, logger)
+
+	r := httptest.NewRequest("POST", "/v1/models/test:predict", bytes.NewReader([]byte(`{"instances": [1, 2, 3, 4, 5]}`)))
+	w := httptest.NewRecorder()
+	batchHandler.ServeHTTP(w, r)
+
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(gomega.MatchJSON(`{"predictions": [1, 2, 3, 4, 5]}`))
+	g.Expect(w.Header().Values(BatchIdHeader)).To(gomega.HaveLen(3))
+	mu.Lock()
+	defer mu.Unlock()
+	g.Expect(batchSizes).To(gomega.Equal([]int{2, 2, 1}))
+}
+
+// Tests that requests are rejected when the batcher queue is full
+func TestBatcherQueueFull(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	called := make(chan struct{}, 1)
+	release := make(chan struct{})
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := ioutil.ReadAll(req.Body)
+		g.Expect(err).To(gomega.BeNil())
+		var request Request
+		err = json.Unmarshal(b, &request)
+		g.Expect(err).To(gomega.BeNil())
+		called <- struct{}{}
+		<-release
+		responseBytes, err := json.Marshal(PredictionResponse{
+			Predictions: request.Instances,
+		})
+		g.Expect(err).To(gomega.BeNil())
+		_, err = rw.Write(responseBytes)
+		g.Expect(err).To(gomega.BeNil())
+	}))
+	defer predictor.Close()
+	predictorSvcUrl, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
+	batchHandler := New(1, 5000, 1, 0, httpProxy, logger)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// the first request is being predicted and the second one waits in the queue
+	go serveRequest(batchHandler, &wg, 0)
+	<-called
+	go serveRequest(batchHandler, &wg, 1)
+	g.Eventually(func() int { return len(batchHandler.channelIn) }).Should(gomega.Equal(1))
+
+	r := httptest.NewRequest("POST", "/v1/models/test:predict", bytes.NewReader([]byte(`{"instances": [[2, 2, 2]]}`)))
+	w := httptest.NewRecorder()
+	batchHandler.ServeHTTP(w, r)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusTooManyRequests))
+
+	close(release)
+	<-called
+	wg.Wait()
+}
+
+// Tests that a split request which does not fit in the queue is rejected without any of its chunks being predicted
+func TestBatcherQueueFullSplitRequest(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	called := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var predicted []interface{}
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := ioutil.ReadAll(req.Body)
+		g.Expect(err).To(gomega.BeNil())
+		var request Request
+		err = json.Unmarshal(b, &request)
+		g.Expect(err).To(gomega.BeNil())
+		mu.Lock()
+		predicted = append(predicted, request.Instances...)
+		mu.Unlock()
+		called <- struct{}{}
+		<-release
+		responseBytes, err := json.Marshal(PredictionResponse{
+			Predictions: request.Instances,
+		})
+		g.Expect(err).To(gomega.BeNil())
+		_, err = rw.Write(responseBytes)
+		g.Expect(err).To(gomega.BeNil())
+	}))
+	defer predictor.Close()
+	predictorSvcUrl, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
+	batchHandler := New(1, 5000, 2, 0, httpProxy, logger)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// the first request is being predicted and the second one takes one of the two queue slots
+	go serveRequest(batchHandler, &wg, 0)
+	<-called
+	go serveRequest(batchHandler, &wg, 1)
+	g.Eventually(func() int { return len(batchHandler.channelIn) }).Should(gomega.Equal(1))
+
+	// the request is split in two chunks and only one slot is left
+	r := httptest.NewRequest("POST", "/v1/models/test:predict",
+		bytes.NewReader([]byte(`{"instances": [[2, 2, 2], [3, 3, 3]]}`)))
+	w := httptest.NewRecorder()
+	batchHandler.ServeHTTP(w, r)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusTooManyRequests))
+	g.Expect(len(batchHandler.channelIn)).To(gomega.Equal(1))
+
+	close(release)
+	<-called
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	g.Expect(predicted).To(gomega.HaveLen(2))
+}
+
+// Tests that requests larger than the max payload size are rejected
+func TestBatcherMaxPayloadSize(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		t.Error("request should not reach the predictor")
+	}))
+	defer predictor.Close()
+	predictorSvcUrl, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+	httpProxy := httputil.NewSingleHostReverseProxy(predictorSvcUrl)
+	batchHandler := New(32, 50, 0, 16, httpProxy, logger)
+
+	r := httptest.NewRequest("POST", "/v1/models/test:predict", bytes.NewReader([]byte(`{"instances": [[1, 2, 3]]}`)))
+	w := httptest.NewRecorder()
+	batchHandler.ServeHTTP(w, r)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusRequestEntityTooLarge))
+
+	// unknown content length is limited while reading the body
+	r = httptest.NewRequest("POST", "/v1/models/test:predict", bytes.NewReader([]byte(`{"instances": [[1, 2, 3]]}`)))
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	batchHandler.ServeHTTP(w, r)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusRequestEntityTooLarge))
 }
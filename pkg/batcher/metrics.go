@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "kserve"
+	metricsSubsystem = "batcher"
+
+	// flush reasons
+	FlushReasonSize    = "size"
+	FlushReasonLatency = "latency"
+
+	// reject reasons
+	RejectReasonQueueFull       = "queue_full"
+	RejectReasonPayloadTooLarge = "payload_too_large"
+)
+
+var (
+	batchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "batch_size",
+		Help:      "Number of instances in each batch sent to the model server.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	queueWaitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "queue_wait_seconds",
+		Help:      "Time a request waits in the batcher before its batch is sent to the model server.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+	upstreamLatencySeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "upstream_latency_seconds",
+		Help:      "Latency of the model server for each batch.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"code"})
+	flushesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "flushes_total",
+		Help:      "Number of batches sent to the model server by flush reason.",
+	}, []string{"reason"})
+	rejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "rejected_requests_total",
+		Help:      "Number of requests rejected by the batcher by reason.",
+	}, []string{"reason"})
+)
+
+// RegisterMetrics registers the batcher metrics with the given registerer
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{batchSize, queueWaitSeconds, upstreamLatencySeconds, flushesTotal,
+		rejectedTotal} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -50,11 +50,16 @@ var (
 // InferenceGraph Constants
 const (
 	RouterHeadersPropagateEnvVar = "PROPAGATE_HEADERS"
+	// the secret of the router logger is mounted at the same path as the one of the agent logger
+	RouterLoggerCredentialsVolumeName = "kserve-logger-credentials"
+	RouterLoggerCredentialsMountPath  = "/var/run/secrets/kserve/logger"
 )
 
 // TrainedModel Constants
 var (
 	TrainedModelAllocated = KServeAPIGroupName + "/" + "trainedmodel-allocated"
+	// TrainedModelRetryLoadAnnotationKey triggers a new download and load of a failed model whenever its value changes
+	TrainedModelRetryLoadAnnotationKey = KServeAPIGroupName + "/" + "retry-load"
 )
 
 // InferenceService MultiModel Constants
@@ -69,6 +74,17 @@ const (
 	AgentEnableFlag       = "--enable-puller"
 	AgentConfigDirArgName = "--config-dir"
 	AgentModelDirArgName  = "--model-dir"
+	// AgentModelServerProtocolArgName selects the adapter the agent loads the models with
+	AgentModelServerProtocolArgName = "--model-server-protocol"
+	AgentModelServerEndpointArgName = "--model-server-endpoint"
+	// AgentModelConfigNameArgName is the model ConfigMap the agent watches through the Kubernetes API
+	AgentModelConfigNameArgName = "--model-config-name"
+	// AgentPodNamespaceEnvName is the env the agent reads the namespace of the model ConfigMap from
+	AgentPodNamespaceEnvName = "POD_NAMESPACE"
+	// AgentManagementPortArgName is the port the agent serves the model status and management api on, apart from
+	// the agent port which proxies the inference requests
+	AgentManagementPortArgName = "--management-port"
+	AgentManagementPortName    = "agent-mgmt"
 )
 
 // InferenceService Annotations
@@ -91,6 +107,23 @@ var (
 	DefaultPrometheusPath                       = "/metrics"
 	QueueProxyAggregatePrometheusMetricsPort    = "9088"
 	DefaultPodPrometheusPort                    = "9090"
+	// AgentModelServerProtocolAnnotationKey selects the model management api the model agent of a multi-model
+	// InferenceService loads the models with, one of v2, torchserve, tfserving or triton-grpc
+	AgentModelServerProtocolAnnotationKey = KServeAPIGroupName + "/model-server-protocol"
+	// AgentModelServerEndpointAnnotationKey overrides the default model management endpoint of the protocol
+	AgentModelServerEndpointAnnotationKey = KServeAPIGroupName + "/model-server-endpoint"
+	// AgentModelConfigWatchAnnotationKey selects how the model agent watches the model config, "api" watches the
+	// ConfigMap through the Kubernetes API and "file" (the default) watches the ConfigMap mounted in the agent
+	AgentModelConfigWatchAnnotationKey = KServeAPIGroupName + "/model-config-watch"
+	// AgentModelPVCsAnnotationKey lists the PersistentVolumeClaims, separated by commas, which the TrainedModels of a
+	// multi-model InferenceService are pulled from with pvc:// uris, they are mounted read-only in the model agent
+	AgentModelPVCsAnnotationKey = KServeAPIGroupName + "/model-pvcs"
+)
+
+// Model config watch modes of the model agent
+const (
+	AgentModelConfigWatchAPI  = "api"
+	AgentModelConfigWatchFile = "file"
 )
 
 // InferenceService Internal Annotations
@@ -103,6 +136,11 @@ var (
 	LoggerInternalAnnotationKey                      = InferenceServiceInternalAnnotationsPrefix + "/logger"
 	LoggerSinkUrlInternalAnnotationKey               = InferenceServiceInternalAnnotationsPrefix + "/logger-sink-url"
 	LoggerModeInternalAnnotationKey                  = InferenceServiceInternalAnnotationsPrefix + "/logger-mode"
+	LoggerSampleRateInternalAnnotationKey            = InferenceServiceInternalAnnotationsPrefix + "/logger-sample-rate"
+	LoggerForceSampleHeaderInternalAnnotationKey     = InferenceServiceInternalAnnotationsPrefix + "/logger-force-sample-header"
+	LoggerMaxBodyBytesInternalAnnotationKey          = InferenceServiceInternalAnnotationsPrefix + "/logger-max-body-bytes"
+	LoggerRedactFieldsInternalAnnotationKey          = InferenceServiceInternalAnnotationsPrefix + "/logger-redact-fields"
+	LoggerSecretNameInternalAnnotationKey            = InferenceServiceInternalAnnotationsPrefix + "/logger-secret-name"
 	BatcherInternalAnnotationKey                     = InferenceServiceInternalAnnotationsPrefix + "/batcher"
 	BatcherMaxBatchSizeInternalAnnotationKey         = InferenceServiceInternalAnnotationsPrefix + "/batcher-max-batchsize"
 	BatcherMaxLatencyInternalAnnotationKey           = InferenceServiceInternalAnnotationsPrefix + "/batcher-max-latency"
@@ -196,6 +234,8 @@ const (
 	KServeContainerPrometheusMetricsPortEnvVarKey     = "KSERVE_CONTAINER_PROMETHEUS_METRICS_PORT"
 	KServeContainerPrometheusMetricsPathEnvVarKey     = "KSERVE_CONTAINER_PROMETHEUS_METRICS_PATH"
 	QueueProxyAggregatePrometheusMetricsPortEnvVarKey = "AGGREGATE_PROMETHEUS_METRICS_PORT"
+	AgentPrometheusMetricsPortEnvVarKey               = "KSERVE_AGENT_PROMETHEUS_METRICS_PORT"
+	AgentPrometheusMetricsPathEnvVarKey               = "KSERVE_AGENT_PROMETHEUS_METRICS_PATH"
 )
 
 type InferenceServiceComponent string
@@ -242,6 +282,9 @@ const (
 	InferenceServiceDefaultHttpPort     = "8080"
 	InferenceServiceDefaultAgentPortStr = "9081"
 	InferenceServiceDefaultAgentPort    = 9081
+	AgentDefaultMetricsPortStr          = "9089"
+	AgentDefaultManagementPortStr       = "9082"
+	AgentDefaultManagementPort          = 9082
 	CommonDefaultHttpPort               = 80
 )
 
@@ -289,6 +332,10 @@ const (
 	ModelDirVolumeName    = "model-dir"
 	ModelConfigDir        = "/mnt/configs"
 	ModelDir              = DefaultModelLocalMountPath
+	// ModelPVCVolumeNamePrefix is the prefix of the volumes of the PVCs the models are pulled from, which are mounted
+	// in ModelPVCDir/<pvc name>
+	ModelPVCVolumeNamePrefix = "model-pvc-"
+	ModelPVCDir              = "/mnt/pvc"
 )
 
 var (
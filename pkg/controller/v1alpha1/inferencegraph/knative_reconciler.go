@@ -183,6 +183,29 @@ func createKnativeService(componentMeta metav1.ObjectMeta, graph *v1alpha1api.In
 		}
 	}
 
+	// The router logs the graph payloads when a logger is specified, the events are tagged with the graph name
+	if logger := graph.Spec.Logger; logger != nil {
+		container := &service.Spec.ConfigurationSpec.Template.Spec.PodSpec.Containers[0]
+		container.Args = append(container.Args, "--graph-name", graph.Name, "--namespace", graph.Namespace)
+		if logger.SecretName != nil {
+			container.Args = append(container.Args, "--log-credentials-dir", constants.RouterLoggerCredentialsMountPath)
+			container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+				Name:      constants.RouterLoggerCredentialsVolumeName,
+				MountPath: constants.RouterLoggerCredentialsMountPath,
+				ReadOnly:  true,
+			})
+			podSpec := &service.Spec.ConfigurationSpec.Template.Spec.PodSpec
+			podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+				Name: constants.RouterLoggerCredentialsVolumeName,
+				VolumeSource: v1.VolumeSource{
+					Secret: &v1.SecretVolumeSource{
+						SecretName: *logger.SecretName,
+					},
+				},
+			})
+		}
+	}
+
 	//Call setDefaults on desired knative service here to avoid diffs generated because knative defaulter webhook is
 	//called when creating or updating the knative service
 	service.SetDefaults(context.TODO())
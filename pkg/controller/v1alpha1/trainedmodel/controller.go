@@ -22,14 +22,17 @@ limitations under the License.
 // +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;update
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch;delete
 package trainedmodel
 
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/kserve/kserve/pkg/agent/status"
 	v1alpha1api "github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
 	v1beta1api "github.com/kserve/kserve/pkg/apis/serving/v1beta1"
 	"github.com/kserve/kserve/pkg/constants"
@@ -57,6 +60,9 @@ const (
 	IsNotMMSPredictor          = "Inference Service \"%s\" predictor is not configured for multi-model serving. Trained Model \"%s\" cannot deploy"
 )
 
+// modelStatusRequeueInterval is how often the agents of the predictor pods are polled for the status of the model
+const modelStatusRequeueInterval = 10 * time.Second
+
 var log = logf.Log.WithName("TrainedModel controller")
 
 // TrainedModelReconciler reconciles a TrainedModel object
@@ -66,6 +72,8 @@ type TrainedModelReconciler struct {
 	Scheme                *runtime.Scheme
 	Recorder              record.EventRecorder
 	ModelConfigReconciler *modelconfig.ModelConfigReconciler
+	// ModelStatusClient reads the model load state from the agents, a default client is used when nil
+	ModelStatusClient *status.Client
 }
 
 func (r *TrainedModelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -147,7 +155,10 @@ func (r *TrainedModelReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	if err := r.ModelConfigReconciler.Reconcile(req, tm); err != nil {
 		return ctrl.Result{}, err
 	}
-	return ctrl.Result{}, nil
+
+	// The agents pick the model up from the configmap asynchronously and may evict it or fail to load it again once
+	// it is loaded, keep polling them so that the ModelLoaded condition follows the model
+	return ctrl.Result{RequeueAfter: modelStatusRequeueInterval}, nil
 }
 
 func (r *TrainedModelReconciler) updateStatus(req ctrl.Request, desiredModel *v1alpha1api.TrainedModel) error {
@@ -285,6 +296,21 @@ func (r *TrainedModelReconciler) updateConditions(req ctrl.Request, tm *v1alpha1
 		conditionErr = fmt.Errorf(MemoryResourceNotAvailable, isvc.Name, tm.Name)
 	}
 
+	// Update Model Loaded condition from the agents of the predictor pods
+	if conditionErr == nil {
+		statuses, err := r.getModelStatuses(context.TODO(), isvc, tm)
+		if err != nil {
+			return err
+		}
+		condition := modelLoadedCondition(statuses, status.SpecVersion(&tm.Spec.Model))
+		previous := tm.Status.GetCondition(v1alpha1api.ModelLoaded)
+		if condition.Status == v1.ConditionFalse && (previous == nil || previous.Reason != condition.Reason) {
+			r.Recorder.Eventf(tm, v1.EventTypeWarning, condition.Reason,
+				"TrainedModel %q is not loaded: %s", tm.Name, condition.Message)
+		}
+		tm.Status.SetCondition(v1alpha1api.ModelLoaded, condition)
+	}
+
 	if statusErr := r.Status().Update(context.TODO(), tm); statusErr != nil {
 		r.Log.Error(statusErr, "Failed to update TrainedModel condition", "TrainedModel", tm.Name)
 		r.Recorder.Eventf(tm, v1.EventTypeWarning, "UpdateFailed",
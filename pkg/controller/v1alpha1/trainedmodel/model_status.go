@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trainedmodel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/kserve/kserve/pkg/agent/status"
+	v1alpha1api "github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	v1beta1api "github.com/kserve/kserve/pkg/apis/serving/v1beta1"
+	"github.com/kserve/kserve/pkg/constants"
+	v1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ModelStatusUnavailable is the reason of the ModelLoaded condition when the status of a pod could not be read
+	ModelStatusUnavailable = "ModelStatusUnavailable"
+	// NoPredictorPods is the reason of the ModelLoaded condition when the predictor has no running pods
+	NoPredictorPods = "NoPredictorPods"
+	// ModelPending is the reason of the ModelLoaded condition when an agent has not picked up the model, or the
+	// current version of the model, yet
+	ModelPending = "Pending"
+)
+
+// podModelStatus is the status of the model on a single predictor pod, status is nil when the agent does not
+// know about the model yet
+type podModelStatus struct {
+	pod    string
+	status *status.ModelStatus
+	err    error
+}
+
+// getModelStatuses reads the status of the model from the agent of every running predictor pod
+func (r *TrainedModelReconciler) getModelStatuses(ctx context.Context, isvc *v1beta1api.InferenceService,
+	tm *v1alpha1api.TrainedModel) ([]podModelStatus, error) {
+	pods := &v1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(isvc.Namespace), client.MatchingLabels{
+		constants.InferenceServicePodLabelKey: isvc.Name,
+		constants.KServiceComponentLabel:      string(constants.Predictor),
+	}); err != nil {
+		return nil, err
+	}
+	statusClient := r.ModelStatusClient
+	if statusClient == nil {
+		statusClient = status.NewClient()
+	}
+	var statuses []podModelStatus
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning || pod.Status.PodIP == "" || !hasAgentContainer(&pod) {
+			continue
+		}
+		host := net.JoinHostPort(pod.Status.PodIP, constants.AgentDefaultManagementPortStr)
+		modelStatus, err := statusClient.GetModelStatus(ctx, host, tm.Name)
+		statuses = append(statuses, podModelStatus{pod: pod.Name, status: modelStatus, err: err})
+	}
+	return statuses, nil
+}
+
+func hasAgentContainer(pod *v1.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == constants.AgentContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+// modelLoadedCondition aggregates the status of the version of the model on the predictor pods, the model is loaded
+// once the version is loaded on every pod and not loaded as soon as it failed or was evicted on any pod. The status
+// of another version, e.g. of the version before an update, is pending.
+func modelLoadedCondition(statuses []podModelStatus, version string) *apis.Condition {
+	if len(statuses) == 0 {
+		return &apis.Condition{
+			Type:    v1alpha1api.ModelLoaded,
+			Status:  v1.ConditionUnknown,
+			Reason:  NoPredictorPods,
+			Message: "Inference Service predictor has no running pods",
+		}
+	}
+	var failed, evicted, unknown []string
+	reason := ""
+	for _, podStatus := range statuses {
+		switch {
+		case podStatus.err != nil:
+			unknown = append(unknown, fmt.Sprintf("%s: %s", podStatus.pod, podStatus.err))
+			if reason == "" {
+				reason = ModelStatusUnavailable
+			}
+		case podStatus.status == nil || podStatus.status.Version != version:
+			unknown = append(unknown, fmt.Sprintf("%s: %s", podStatus.pod, ModelPending))
+			if reason == "" {
+				reason = ModelPending
+			}
+		case podStatus.status.State == status.ModelFailedToLoad:
+			failed = append(failed, fmt.Sprintf("%s: %s", podStatus.pod, podStatus.status.Message))
+		case podStatus.status.State == status.ModelEvicted:
+			evicted = append(evicted, fmt.Sprintf("%s: %s", podStatus.pod, podStatus.status.Message))
+		case podStatus.status.State != status.ModelLoaded:
+			unknown = append(unknown, fmt.Sprintf("%s: %s", podStatus.pod, podStatus.status.State))
+			if reason == "" {
+				reason = string(podStatus.status.State)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return &apis.Condition{
+			Type:    v1alpha1api.ModelLoaded,
+			Status:  v1.ConditionFalse,
+			Reason:  string(status.ModelFailedToLoad),
+			Message: strings.Join(failed, "; "),
+		}
+	}
+	if len(evicted) > 0 {
+		return &apis.Condition{
+			Type:    v1alpha1api.ModelLoaded,
+			Status:  v1.ConditionFalse,
+			Reason:  string(status.ModelEvicted),
+			Message: strings.Join(evicted, "; "),
+		}
+	}
+	if len(unknown) > 0 {
+		return &apis.Condition{
+			Type:    v1alpha1api.ModelLoaded,
+			Status:  v1.ConditionUnknown,
+			Reason:  reason,
+			Message: strings.Join(unknown, "; "),
+		}
+	}
+	return &apis.Condition{
+		Type:   v1alpha1api.ModelLoaded,
+		Status: v1.ConditionTrue,
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trainedmodel
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kserve/kserve/pkg/agent/status"
+	v1alpha1api "github.com/kserve/kserve/pkg/apis/serving/v1alpha1"
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestModelLoadedCondition(t *testing.T) {
+	version := "v1-new"
+	loaded := &status.ModelStatus{Name: "model1", Version: version, State: status.ModelLoaded}
+	loading := &status.ModelStatus{Name: "model1", Version: version, State: status.ModelLoading}
+	failed := &status.ModelStatus{Name: "model1", Version: version, State: status.ModelFailedToLoad, Message: "out of memory"}
+	evicted := &status.ModelStatus{Name: "model1", Version: version, State: status.ModelEvicted, Message: "evicted to free disk space for model model2"}
+	// the status left over from the version before an update
+	previousLoaded := &status.ModelStatus{Name: "model1", Version: "v1-old", State: status.ModelLoaded}
+
+	scenarios := map[string]struct {
+		statuses        []podModelStatus
+		expectedStatus  v1.ConditionStatus
+		expectedReason  string
+		expectedMessage string
+	}{
+		"NoPods": {
+			statuses:       nil,
+			expectedStatus: v1.ConditionUnknown,
+			expectedReason: NoPredictorPods,
+		},
+		"AllLoaded": {
+			statuses: []podModelStatus{
+				{pod: "pod1", status: loaded},
+				{pod: "pod2", status: loaded},
+			},
+			expectedStatus: v1.ConditionTrue,
+		},
+		"Loading": {
+			statuses: []podModelStatus{
+				{pod: "pod1", status: loaded},
+				{pod: "pod2", status: loading},
+			},
+			expectedStatus:  v1.ConditionUnknown,
+			expectedReason:  string(status.ModelLoading),
+			expectedMessage: "pod2: Loading",
+		},
+		"Pending": {
+			statuses: []podModelStatus{
+				{pod: "pod1"},
+			},
+			expectedStatus:  v1.ConditionUnknown,
+			expectedReason:  ModelPending,
+			expectedMessage: "pod1: Pending",
+		},
+		"PreviousVersionLoaded": {
+			statuses: []podModelStatus{
+				{pod: "pod1", status: loaded},
+				{pod: "pod2", status: previousLoaded},
+			},
+			expectedStatus:  v1.ConditionUnknown,
+			expectedReason:  ModelPending,
+			expectedMessage: "pod2: Pending",
+		},
+		"Unavailable": {
+			statuses: []podModelStatus{
+				{pod: "pod1", err: fmt.Errorf("connection refused")},
+			},
+			expectedStatus:  v1.ConditionUnknown,
+			expectedReason:  ModelStatusUnavailable,
+			expectedMessage: "pod1: connection refused",
+		},
+		"FailedOnOnePod": {
+			statuses: []podModelStatus{
+				{pod: "pod1", status: loading},
+				{pod: "pod2", status: failed},
+			},
+			expectedStatus:  v1.ConditionFalse,
+			expectedReason:  string(status.ModelFailedToLoad),
+			expectedMessage: "pod2: out of memory",
+		},
+		"EvictedOnOnePod": {
+			statuses: []podModelStatus{
+				{pod: "pod1", status: loaded},
+				{pod: "pod2", status: evicted},
+			},
+			expectedStatus:  v1.ConditionFalse,
+			expectedReason:  string(status.ModelEvicted),
+			expectedMessage: "pod2: evicted to free disk space for model model2",
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			condition := modelLoadedCondition(scenario.statuses, version)
+			g.Expect(condition.Status).To(gomega.Equal(scenario.expectedStatus))
+			g.Expect(condition.Reason).To(gomega.Equal(scenario.expectedReason))
+			if scenario.expectedMessage != "" {
+				g.Expect(condition.Message).To(gomega.Equal(scenario.expectedMessage))
+			}
+		})
+	}
+}
+
+func TestModelLoadedConditionAfterLoaded(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	tm := &v1alpha1api.TrainedModel{}
+	version := status.SpecVersion(&tm.Spec.Model)
+	tm.Status.SetCondition(v1alpha1api.ModelLoaded, modelLoadedCondition([]podModelStatus{
+		{pod: "pod1", status: &status.ModelStatus{Name: "model1", Version: version, State: status.ModelLoaded}},
+	}, version))
+	g.Expect(tm.Status.IsConditionReady(v1alpha1api.ModelLoaded)).To(gomega.BeTrue())
+
+	// the model is evicted on a later poll of the loaded model
+	tm.Status.SetCondition(v1alpha1api.ModelLoaded, modelLoadedCondition([]podModelStatus{
+		{pod: "pod1", status: &status.ModelStatus{Name: "model1", Version: version, State: status.ModelEvicted,
+			Message: "evicted to free disk space for model model2"}},
+	}, version))
+	g.Expect(tm.Status.IsConditionReady(v1alpha1api.ModelLoaded)).To(gomega.BeFalse())
+	condition := tm.Status.GetCondition(v1alpha1api.ModelLoaded)
+	g.Expect(condition.Status).To(gomega.Equal(v1.ConditionFalse))
+	g.Expect(condition.Reason).To(gomega.Equal(string(status.ModelEvicted)))
+	g.Expect(condition.Message).To(gomega.Equal("pod1: evicted to free disk space for model model2"))
+}
+
+func TestModelLoadedConditionAfterUpdate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	tm := &v1alpha1api.TrainedModel{Spec: v1alpha1api.TrainedModelSpec{
+		Model: v1alpha1api.ModelSpec{StorageURI: "s3://models/model1/v1", Framework: "sklearn"},
+	}}
+	previous := status.SpecVersion(&tm.Spec.Model)
+	tm.Spec.Model.StorageURI = "s3://models/model1/v2"
+	version := status.SpecVersion(&tm.Spec.Model)
+	g.Expect(version).NotTo(gomega.Equal(previous))
+
+	// the agent still reports the previous version as loaded
+	condition := modelLoadedCondition([]podModelStatus{
+		{pod: "pod1", status: &status.ModelStatus{Name: "model1", Version: previous, State: status.ModelLoaded}},
+	}, version)
+	g.Expect(condition.Status).To(gomega.Equal(v1.ConditionUnknown))
+	g.Expect(condition.Reason).To(gomega.Equal(ModelPending))
+
+	// the update failed, the previous version is still served
+	condition = modelLoadedCondition([]podModelStatus{
+		{pod: "pod1", status: &status.ModelStatus{Name: "model1", Version: version, State: status.ModelFailedToLoad,
+			Message: "failed after 5 attempts: out of memory, the previous version is still served"}},
+	}, version)
+	g.Expect(condition.Status).To(gomega.Equal(v1.ConditionFalse))
+	g.Expect(condition.Reason).To(gomega.Equal(string(status.ModelFailedToLoad)))
+}
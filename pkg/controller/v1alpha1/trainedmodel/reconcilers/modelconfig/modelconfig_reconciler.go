@@ -74,7 +74,8 @@ func (c *ModelConfigReconciler) Reconcile(req ctrl.Request, tm *v1alpha1api.Trai
 		}
 	} else {
 		// A TrainedModel is created or updated, add or update the model from the model configmap
-		modelConfig := modelconfig.ModelConfig{Name: tm.Name, Spec: tm.Spec.Model}
+		modelConfig := modelconfig.ModelConfig{Name: tm.Name, Spec: tm.Spec.Model,
+			Retry: tm.Annotations[constants.TrainedModelRetryLoadAnnotationKey]}
 		updatedConfigs := []modelconfig.ModelConfig{modelConfig}
 		configDelta := modelconfig.NewConfigsDelta(updatedConfigs, nil)
 		err := configDelta.Process(desiredModelConfig)
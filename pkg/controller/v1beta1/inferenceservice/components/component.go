@@ -63,6 +63,21 @@ func addLoggerAnnotations(logger *v1beta1.LoggerSpec, annotations map[string]str
 			annotations[constants.LoggerSinkUrlInternalAnnotationKey] = *logger.URL
 		}
 		annotations[constants.LoggerModeInternalAnnotationKey] = string(logger.Mode)
+		if logger.SampleRate != nil {
+			annotations[constants.LoggerSampleRateInternalAnnotationKey] = *logger.SampleRate
+		}
+		if logger.ForceSampleHeader != nil {
+			annotations[constants.LoggerForceSampleHeaderInternalAnnotationKey] = *logger.ForceSampleHeader
+		}
+		if logger.MaxBodyBytes != nil {
+			annotations[constants.LoggerMaxBodyBytesInternalAnnotationKey] = strconv.FormatInt(*logger.MaxBodyBytes, 10)
+		}
+		if len(logger.RedactFields) > 0 {
+			annotations[constants.LoggerRedactFieldsInternalAnnotationKey] = strings.Join(logger.RedactFields, ",")
+		}
+		if logger.SecretName != nil {
+			annotations[constants.LoggerSecretNameInternalAnnotationKey] = *logger.SecretName
+		}
 		return true
 	}
 	return false
@@ -23,11 +23,14 @@ import (
 const (
 	HdfsNamenode      = "HDFS_NAMENODE"
 	HdfsRootPath      = "HDFS_ROOTPATH"
+	UserProxy         = "USER_PROXY"
+	Headers           = "HEADERS"
 	KerberosPrincipal = "KERBEROS_PRINCIPAL"
 	KerberosKeytab    = "KERBEROS_KEYTAB"
 	TlsCert           = "TLS_CERT"
 	TlsKey            = "TLS_KEY"
 	TlsCa             = "TLS_CA"
+	TlsSkipVerify     = "TLS_SKIP_VERIFY"
 	MountPath         = "/var/secrets/kserve-hdfscreds"
 	HdfsVolumeName    = "hdfs-secrets"
 )
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/cloudevents/sdk-go"
+	cehttp "github.com/cloudevents/sdk-go/pkg/cloudevents/transport/http"
+)
+
+// CloudEventsSink posts binary or structured mode CloudEvents over HTTP, the credentials configure TLS and
+// the Authorization header
+type CloudEventsSink struct {
+	ceCtx       context.Context
+	client      cloudevents.Client
+	eventConfig EventConfig
+}
+
+var _ Sink = (*CloudEventsSink)(nil)
+
+func NewCloudEventsSink(logUrl *url.URL, credentials *Credentials, eventConfig EventConfig) (*CloudEventsSink, error) {
+	encoding, ctxEncoding := cloudevents.HTTPBinaryV1, cloudevents.Binary
+	if eventConfig.Encoding == EncodingStructured {
+		encoding, ctxEncoding = cloudevents.HTTPStructuredV1, cloudevents.Structured
+	}
+	options := []cehttp.Option{
+		cloudevents.WithTarget(logUrl.String()),
+		cloudevents.WithEncoding(encoding),
+	}
+	if transport := credentials.httpTransport(); transport != nil {
+		options = append(options, cehttp.WithHTTPTransport(transport))
+	}
+	if authorization := credentials.authorization(); authorization != "" {
+		options = append(options, cehttp.WithHeader("Authorization", authorization))
+	}
+	t, err := cloudevents.NewHTTPTransport(options...)
+	if err != nil {
+		return nil, fmt.Errorf("while creating http transport: %s", err)
+	}
+
+	c, err := cloudevents.NewClient(t,
+		cloudevents.WithTimeNow(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("while creating new cloudevents client: %s", err)
+	}
+	return &CloudEventsSink{
+		ceCtx:       cloudevents.ContextWithEncoding(context.Background(), ctxEncoding),
+		client:      c,
+		eventConfig: eventConfig,
+	}, nil
+}
+
+func (s *CloudEventsSink) Send(logReq LogRequest) error {
+	event, err := toCloudEvent(logReq, s.eventConfig)
+	if err != nil {
+		return err
+	}
+	if _, _, err := s.client.Send(s.ceCtx, event); err != nil {
+		return fmt.Errorf("while sending event: %s", err)
+	}
+	return nil
+}
+
+func (s *CloudEventsSink) Close() error {
+	return nil
+}
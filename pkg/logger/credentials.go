@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files read from the credentials dir, they match the keys of the logger secret mounted by the agent injector
+const (
+	CredentialsCAFile       = "ca.crt"
+	CredentialsCertFile     = "tls.crt"
+	CredentialsKeyFile      = "tls.key"
+	CredentialsTokenFile    = "token"
+	CredentialsUsernameFile = "username"
+	CredentialsPasswordFile = "password"
+)
+
+// Credentials configure TLS and authentication for the connections to the log url
+type Credentials struct {
+	// TLSConfig holds the CA bundle and the client certificate, nil uses the system roots
+	TLSConfig *tls.Config
+	// Token is sent as a bearer token
+	Token string
+	// Username and Password are sent as basic auth
+	Username string
+	Password string
+}
+
+// LoadCredentials reads the credentials from dir, every file is optional. A client certificate requires
+// its key and a token can't be combined with basic auth.
+func LoadCredentials(dir string) (*Credentials, error) {
+	credentials := &Credentials{}
+	files := map[string][]byte{}
+	for _, name := range []string{CredentialsCAFile, CredentialsCertFile, CredentialsKeyFile,
+		CredentialsTokenFile, CredentialsUsernameFile, CredentialsPasswordFile} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("while reading logger credentials %s: %s", name, err)
+		}
+		files[name] = data
+	}
+
+	_, hasCA := files[CredentialsCAFile]
+	_, hasCert := files[CredentialsCertFile]
+	_, hasKey := files[CredentialsKeyFile]
+	if hasCert != hasKey {
+		return nil, fmt.Errorf("logger credentials need both %s and %s for a client certificate", CredentialsCertFile, CredentialsKeyFile)
+	}
+	if hasCA || hasCert {
+		credentials.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if hasCA {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(files[CredentialsCAFile]) {
+			return nil, fmt.Errorf("no certificates found in logger credentials %s", CredentialsCAFile)
+		}
+		credentials.TLSConfig.RootCAs = pool
+	}
+	if hasCert {
+		cert, err := tls.X509KeyPair(files[CredentialsCertFile], files[CredentialsKeyFile])
+		if err != nil {
+			return nil, fmt.Errorf("while loading logger client certificate: %s", err)
+		}
+		credentials.TLSConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// secrets often end with a newline which is not part of the value
+	credentials.Token = strings.TrimSpace(string(files[CredentialsTokenFile]))
+	credentials.Username = strings.TrimSpace(string(files[CredentialsUsernameFile]))
+	credentials.Password = strings.TrimRight(string(files[CredentialsPasswordFile]), "\r\n")
+	if credentials.Token != "" && (credentials.Username != "" || credentials.Password != "") {
+		return nil, fmt.Errorf("logger credentials can't have both a %s and a %s/%s", CredentialsTokenFile,
+			CredentialsUsernameFile, CredentialsPasswordFile)
+	}
+	if credentials.Password != "" && credentials.Username == "" {
+		return nil, fmt.Errorf("logger credentials have a %s without a %s", CredentialsPasswordFile, CredentialsUsernameFile)
+	}
+	return credentials, nil
+}
+
+// authorization returns the Authorization header value, empty when there is no token or basic auth
+func (c *Credentials) authorization() string {
+	if c == nil {
+		return ""
+	}
+	if c.Token != "" {
+		return "Bearer " + c.Token
+	}
+	if c.Username != "" {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+	}
+	return ""
+}
+
+// httpTransport returns the transport used to post to the log url, nil means the default transport
+func (c *Credentials) httpTransport() http.RoundTripper {
+	if c == nil || c.TLSConfig == nil {
+		return nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = c.TLSConfig
+	return transport
+}
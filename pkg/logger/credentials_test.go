@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+// newClientCertificate returns a self signed client certificate and its key as PEM
+func newClientCertificate(g *gomega.WithT) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).To(gomega.BeNil())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kserve-agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).To(gomega.BeNil())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	g.Expect(err).To(gomega.BeNil())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func writeCredentials(g *gomega.WithT, dir string, files map[string][]byte) {
+	for name, data := range files {
+		g.Expect(ioutil.WriteFile(filepath.Join(dir, name), data, 0600)).To(gomega.Succeed())
+	}
+}
+
+func TestLoadCredentials(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	cert, key := newClientCertificate(g)
+	scenarios := map[string]struct {
+		files         map[string][]byte
+		valid         bool
+		tls           bool
+		authorization string
+	}{
+		"Empty": {
+			valid: true,
+		},
+		"Token": {
+			files:         map[string][]byte{CredentialsTokenFile: []byte("abc\n")},
+			valid:         true,
+			authorization: "Bearer abc",
+		},
+		"BasicAuth": {
+			files:         map[string][]byte{CredentialsUsernameFile: []byte("user"), CredentialsPasswordFile: []byte("pass")},
+			valid:         true,
+			authorization: "Basic dXNlcjpwYXNz",
+		},
+		"ClientCertificate": {
+			files: map[string][]byte{CredentialsCAFile: cert, CredentialsCertFile: cert, CredentialsKeyFile: key},
+			valid: true,
+			tls:   true,
+		},
+		"TokenAndBasicAuth": {
+			files: map[string][]byte{CredentialsTokenFile: []byte("abc"), CredentialsUsernameFile: []byte("user")},
+		},
+		"PasswordWithoutUsername": {
+			files: map[string][]byte{CredentialsPasswordFile: []byte("pass")},
+		},
+		"CertificateWithoutKey": {
+			files: map[string][]byte{CredentialsCertFile: cert},
+		},
+		"InvalidCA": {
+			files: map[string][]byte{CredentialsCAFile: []byte("not a certificate")},
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeCredentials(g, dir, scenario.files)
+			credentials, err := LoadCredentials(dir)
+			if !scenario.valid {
+				g.Expect(err).NotTo(gomega.BeNil())
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(credentials.TLSConfig != nil).To(gomega.Equal(scenario.tls))
+			g.Expect(credentials.authorization()).To(gomega.Equal(scenario.authorization))
+		})
+	}
+}
+
+func TestCloudEventsSinkCredentials(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	cert, key := newClientCertificate(g)
+	clientCAs := x509.NewCertPool()
+	g.Expect(clientCAs.AppendCertsFromPEM(cert)).To(gomega.BeTrue())
+
+	authorizations := make(chan string, 1)
+	logSvc := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		authorizations <- req.Header.Get("Authorization")
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	logSvc.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	logSvc.StartTLS()
+	defer logSvc.Close()
+	logSvcUrl, _ := url.Parse(logSvc.URL)
+
+	// without credentials the server certificate is not trusted
+	sink, err := NewCloudEventsSink(logSvcUrl, nil, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(sink.Send(testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`))).NotTo(gomega.Succeed())
+
+	dir := t.TempDir()
+	writeCredentials(g, dir, map[string][]byte{
+		CredentialsCAFile:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: logSvc.Certificate().Raw}),
+		CredentialsCertFile:  cert,
+		CredentialsKeyFile:   key,
+		CredentialsTokenFile: []byte("abc"),
+	})
+	credentials, err := LoadCredentials(dir)
+	g.Expect(err).To(gomega.BeNil())
+	sink, err = NewCloudEventsSink(logSvcUrl, credentials, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(sink.Send(testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`))).To(gomega.Succeed())
+	g.Expect(<-authorizations).To(gomega.Equal("Bearer abc"))
+}
@@ -17,32 +17,380 @@ limitations under the License.
 package logger
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
 	"go.uber.org/zap"
 )
 
-var WorkerQueue chan chan LogRequest
+// DropPolicy decides which log request is dropped when the work queue is full
+type DropPolicy string
+
+const (
+	// DropNewest drops the log request being queued
+	DropNewest DropPolicy = "newest"
+	// DropOldest drops the oldest queued log request to make room for the new one
+	DropOldest DropPolicy = "oldest"
+)
+
+const (
+	DefaultMaxRetries          = 3
+	DefaultRetryBackoff        = 100 * time.Millisecond
+	DefaultMaxRetryBackoff     = 10 * time.Second
+	DefaultSpoolReplayInterval = 30 * time.Second
+)
 
-func StartDispatcher(nworkers int, logger *zap.SugaredLogger) {
-	// First, initialize the channel we are going to but the workers' work channels into.
-	WorkerQueue = make(chan chan LogRequest, nworkers)
+// DeliveryConfig configures how log requests are queued, retried and spooled
+type DeliveryConfig struct {
+	// QueueSize is the number of log requests buffered for the workers
+	QueueSize int
+	// DropPolicy decides which log request is dropped when the queue is full
+	DropPolicy DropPolicy
+	// MaxRetries is the number of times a failed send is retried
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, it doubles on every retry up to MaxRetryBackoff
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// SpoolDir keeps the log requests which could not be delivered after all retries, they are resent
+	// every SpoolReplayInterval. The spool is disabled when empty.
+	SpoolDir string
+	// SpoolMaxBytes limits the size of the spool dir, 0 means no limit
+	SpoolMaxBytes       int64
+	SpoolReplayInterval time.Duration
+	// Credentials configure TLS and authentication for the log url, nil connects without credentials
+	Credentials *Credentials
+	// Event configures the CloudEvents sent by the sinks of the dispatcher
+	Event EventConfig
+}
 
-	// Now, create all of our workers.
-	for i := 0; i < nworkers; i++ {
-		logger.Info("Starting worker ", i+1)
-		worker := NewWorker(i+1, WorkerQueue, logger)
-		worker.Start()
+// DefaultDeliveryConfig returns the default delivery config of a dispatcher
+func DefaultDeliveryConfig() DeliveryConfig {
+	return DeliveryConfig{
+		QueueSize:           LoggerWorkerQueueSize,
+		DropPolicy:          DropNewest,
+		MaxRetries:          DefaultMaxRetries,
+		RetryBackoff:        DefaultRetryBackoff,
+		MaxRetryBackoff:     DefaultMaxRetryBackoff,
+		SpoolReplayInterval: DefaultSpoolReplayInterval,
+		Event:               DefaultEventConfig(),
 	}
+}
 
-	go func() {
-		for {
-			select {
-			case work := <-WorkQueue:
-				go func() {
-					worker := <-WorkerQueue
+func (c DeliveryConfig) validate() error {
+	switch c.DropPolicy {
+	case DropNewest, DropOldest:
+	default:
+		return fmt.Errorf("invalid drop policy %q, must be %q or %q", c.DropPolicy, DropNewest, DropOldest)
+	}
+	if c.QueueSize <= 0 {
+		return fmt.Errorf("invalid queue size %d", c.QueueSize)
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("invalid max retries %d", c.MaxRetries)
+	}
+	if c.MaxRetries > 0 && (c.RetryBackoff <= 0 || c.MaxRetryBackoff < c.RetryBackoff) {
+		return fmt.Errorf("invalid retry backoff %s with max retry backoff %s", c.RetryBackoff, c.MaxRetryBackoff)
+	}
+	if c.SpoolDir != "" && (c.SpoolMaxBytes < 0 || c.SpoolReplayInterval <= 0) {
+		return fmt.Errorf("invalid spool max bytes %d with replay interval %s", c.SpoolMaxBytes, c.SpoolReplayInterval)
+	}
+	return c.Event.validate()
+}
 
-					worker <- work
-				}()
+var ErrDispatcherStopped = errors.New("log dispatcher is stopped")
+
+// Dispatcher queues log requests and delivers them to their sinks with a pool of workers
+type Dispatcher struct {
+	log         *zap.SugaredLogger
+	nworkers    int
+	config      DeliveryConfig
+	spool       *Spool
+	sinks       *sinkCache
+	workQueue   chan LogRequest
+	workerQueue chan chan LogRequest
+	quit        chan bool
+	wg          sync.WaitGroup
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	// pending counts the queued and in flight log requests, drained is closed once it drops to zero
+	pending int
+	drained chan struct{}
+}
+
+// NewDispatcher creates a dispatcher delivering log requests with nworkers workers, it does not deliver
+// anything until it is started
+func NewDispatcher(nworkers int, config DeliveryConfig, logger *zap.SugaredLogger) (*Dispatcher, error) {
+	if nworkers <= 0 {
+		return nil, fmt.Errorf("invalid number of workers %d", nworkers)
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	var spool *Spool
+	if config.SpoolDir != "" {
+		var err error
+		if spool, err = NewSpool(config.SpoolDir, config.SpoolMaxBytes); err != nil {
+			return nil, err
+		}
+	}
+	return &Dispatcher{
+		log:         logger,
+		nworkers:    nworkers,
+		config:      config,
+		spool:       spool,
+		sinks:       newSinkCache(config.Credentials, config.Event, logger),
+		workQueue:   make(chan LogRequest, config.QueueSize),
+		workerQueue: make(chan chan LogRequest, nworkers),
+		quit:        make(chan bool),
+	}, nil
+}
+
+// Start starts the workers and, when there is a spool, resends the spooled log requests
+func (d *Dispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.started || d.stopped {
+		return
+	}
+	d.started = true
+
+	for i := 0; i < d.nworkers; i++ {
+		d.log.Info("Starting worker ", i+1)
+		worker := NewWorker(i+1, d)
+		d.wg.Add(1)
+		go worker.Start()
+	}
+
+	// Only take work off the queue once a worker is free so that a slow log url fills up the
+	// bounded queue instead of piling up goroutines.
+	d.wg.Add(1)
+	go d.dispatch()
+
+	if d.spool != nil {
+		d.wg.Add(1)
+		go d.replaySpool()
+	}
+}
+
+func (d *Dispatcher) dispatch() {
+	defer d.wg.Done()
+	for {
+		select {
+		case work := <-d.workQueue:
+			select {
+			case worker := <-d.workerQueue:
+				select {
+				case worker <- work:
+				case <-d.quit:
+					d.abandon(work)
+					return
+				}
+			case <-d.quit:
+				d.abandon(work)
+				return
 			}
+		case <-d.quit:
+			return
 		}
-	}()
+	}
+}
+
+// GetSink returns the sink for the log url, creating it on first use. The sinks are closed when the
+// dispatcher is stopped.
+func (d *Dispatcher) GetSink(logUrl *url.URL) (Sink, error) {
+	return d.sinks.get(logUrl)
+}
+
+// QueueLogRequest queues the log request without blocking, when the queue is full a log request is
+// dropped according to the drop policy and ErrQueueFull is returned if it was the given one.
+func (d *Dispatcher) QueueLogRequest(req LogRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return ErrDispatcherStopped
+	}
+	select {
+	case d.workQueue <- req:
+		d.pending++
+		return nil
+	default:
+	}
+	if d.config.DropPolicy == DropOldest {
+		select {
+		case <-d.workQueue:
+			d.pending--
+			droppedTotal.WithLabelValues(DropReasonQueueFull).Inc()
+		default:
+		}
+		select {
+		case d.workQueue <- req:
+			d.pending++
+			return nil
+		default:
+		}
+	}
+	droppedTotal.WithLabelValues(DropReasonQueueFull).Inc()
+	return ErrQueueFull
+}
+
+// done marks a queued log request as delivered, spooled or dropped
+func (d *Dispatcher) done() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending--
+	if d.pending == 0 && d.drained != nil {
+		close(d.drained)
+		d.drained = nil
+	}
+}
+
+// Flush waits until all the queued log requests are delivered, spooled or dropped
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	if d.pending == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.drained == nil {
+		d.drained = make(chan struct{})
+	}
+	drained := d.drained
+	d.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("while flushing log events: %s", ctx.Err())
+	}
+}
+
+// Stop stops accepting log requests, flushes the queued ones until ctx is done and then stops the workers
+// and closes the sinks. Log requests still queued when ctx is done are spooled if there is a spool and
+// dropped otherwise.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return nil
+	}
+	d.stopped = true
+	started := d.started
+	d.mu.Unlock()
+
+	var err error
+	if started {
+		err = d.Flush(ctx)
+	}
+	close(d.quit)
+	d.wg.Wait()
+	for {
+		select {
+		case work := <-d.workQueue:
+			d.abandon(work)
+			continue
+		default:
+		}
+		break
+	}
+	if closeErr := d.sinks.close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (d *Dispatcher) send(logReq LogRequest) error {
+	sink, err := d.sinks.get(logReq.Url)
+	if err != nil {
+		return fmt.Errorf("while getting sink: %s", err)
+	}
+	return sink.Send(logReq)
+}
+
+// deliver sends the log request retrying with exponential backoff, once the retries are exhausted or the
+// dispatcher is stopping the log request is written to the spool if there is one and dropped otherwise.
+func (d *Dispatcher) deliver(logReq LogRequest) {
+	backoff := d.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := d.send(logReq)
+		if err == nil {
+			sentTotal.Inc()
+			return
+		}
+		if attempt >= d.config.MaxRetries {
+			d.log.Errorf("Failed to send log event after %d attempts, url: %s, requestId: %s: %v",
+				attempt+1, logReq.Url.String(), logReq.Id, err)
+			break
+		}
+		d.log.Warnf("Failed to send log event, retrying in %s, url: %s, requestId: %s: %v",
+			backoff, logReq.Url.String(), logReq.Id, err)
+		retriedTotal.Inc()
+		select {
+		case <-time.After(backoff):
+		case <-d.quit:
+			d.spoolOrDrop(logReq, DropReasonShutdown)
+			return
+		}
+		if backoff *= 2; backoff > d.config.MaxRetryBackoff {
+			backoff = d.config.MaxRetryBackoff
+		}
+	}
+	d.spoolOrDrop(logReq, DropReasonRetriesExhausted)
+}
+
+// abandon spools or drops a queued log request which won't be delivered because the dispatcher is stopping
+func (d *Dispatcher) abandon(logReq LogRequest) {
+	d.spoolOrDrop(logReq, DropReasonShutdown)
+	d.done()
+}
+
+func (d *Dispatcher) spoolOrDrop(logReq LogRequest, reason string) {
+	if d.spool == nil {
+		droppedTotal.WithLabelValues(reason).Inc()
+		return
+	}
+	if err := d.spool.Put(logReq); err != nil {
+		d.log.Errorf("Failed to spool log event, requestId: %s: %v", logReq.Id, err)
+		if errors.Is(err, ErrSpoolFull) {
+			droppedTotal.WithLabelValues(DropReasonSpoolFull).Inc()
+		} else {
+			droppedTotal.WithLabelValues(reason).Inc()
+		}
+		return
+	}
+	spooledTotal.Inc()
+}
+
+// replaySpool resends the spooled log requests on start up and then every replay interval
+func (d *Dispatcher) replaySpool() {
+	defer d.wg.Done()
+	send := func(logReq LogRequest) error {
+		if err := d.send(logReq); err != nil {
+			return err
+		}
+		sentTotal.Inc()
+		return nil
+	}
+	ticker := time.NewTicker(d.config.SpoolReplayInterval)
+	defer ticker.Stop()
+	for {
+		sent, err := d.spool.Replay(send)
+		if sent > 0 {
+			d.log.Infof("Resent %d spooled log events", sent)
+		}
+		if err != nil {
+			d.log.Warnf("Failed to resend spooled log events: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-d.quit:
+			return
+		}
+	}
 }
@@ -0,0 +1,303 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	pkglogging "knative.dev/pkg/logging"
+)
+
+func startTestDispatcher(t *testing.T, nworkers int, logger *zap.SugaredLogger) *Dispatcher {
+	return startTestDispatcherWithConfig(t, nworkers, DefaultDeliveryConfig(), logger)
+}
+
+func startTestDispatcherWithConfig(t *testing.T, nworkers int, config DeliveryConfig,
+	logger *zap.SugaredLogger) *Dispatcher {
+	dispatcher, err := NewDispatcher(nworkers, config, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		dispatcher.Stop(ctx)
+	})
+	return dispatcher
+}
+
+func TestQueueLogRequestDropPolicy(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	scenarios := map[string]struct {
+		policy   DropPolicy
+		expected []string
+	}{
+		"newest": {policy: DropNewest, expected: []string{"1", "2"}},
+		"oldest": {policy: DropOldest, expected: []string{"2", "3"}},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultDeliveryConfig()
+			config.QueueSize = 2
+			config.DropPolicy = scenario.policy
+			// the dispatcher is not started so the queued requests stay in the queue
+			dispatcher, err := NewDispatcher(1, config, logger)
+			g.Expect(err).To(gomega.BeNil())
+			dropped := testutil.ToFloat64(droppedTotal.WithLabelValues(DropReasonQueueFull))
+
+			g.Expect(dispatcher.QueueLogRequest(LogRequest{Id: "1"})).To(gomega.Succeed())
+			g.Expect(dispatcher.QueueLogRequest(LogRequest{Id: "2"})).To(gomega.Succeed())
+			err = dispatcher.QueueLogRequest(LogRequest{Id: "3"})
+			if scenario.policy == DropNewest {
+				g.Expect(err).To(gomega.Equal(ErrQueueFull))
+			} else {
+				g.Expect(err).To(gomega.BeNil())
+			}
+			g.Expect(testutil.ToFloat64(droppedTotal.WithLabelValues(DropReasonQueueFull))).To(gomega.Equal(dropped + 1))
+			g.Expect(dispatcher.pending).To(gomega.Equal(2))
+			g.Expect((<-dispatcher.workQueue).Id).To(gomega.Equal(scenario.expected[0]))
+			g.Expect((<-dispatcher.workQueue).Id).To(gomega.Equal(scenario.expected[1]))
+		})
+	}
+}
+
+func TestDeliveryConfigValidate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(DefaultDeliveryConfig().validate()).To(gomega.Succeed())
+	config := DefaultDeliveryConfig()
+	config.DropPolicy = "block"
+	g.Expect(config.validate()).NotTo(gomega.Succeed())
+	config = DefaultDeliveryConfig()
+	config.QueueSize = 0
+	g.Expect(config.validate()).NotTo(gomega.Succeed())
+	config = DefaultDeliveryConfig()
+	config.MaxRetryBackoff = config.RetryBackoff / 2
+	g.Expect(config.validate()).NotTo(gomega.Succeed())
+}
+
+func TestWorkerRetries(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	var requests int32
+	logSvc := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// fail the first two attempts
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer logSvc.Close()
+	logSvcUrl, _ := url.Parse(logSvc.URL)
+
+	config := DefaultDeliveryConfig()
+	config.RetryBackoff = time.Millisecond
+	dispatcher, err := NewDispatcher(1, config, logger)
+	g.Expect(err).To(gomega.BeNil())
+	sent := testutil.ToFloat64(sentTotal)
+	retried := testutil.ToFloat64(retriedTotal)
+
+	logReq := testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`)
+	logReq.Url = logSvcUrl
+	dispatcher.deliver(logReq)
+	g.Expect(atomic.LoadInt32(&requests)).To(gomega.Equal(int32(3)))
+	g.Expect(testutil.ToFloat64(sentTotal)).To(gomega.Equal(sent + 1))
+	g.Expect(testutil.ToFloat64(retriedTotal)).To(gomega.Equal(retried + 2))
+}
+
+func TestWorkerSpool(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	var available int32
+	received := make(chan string, 10)
+	logSvc := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(&available) == 0 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received <- req.Header.Get(CloudEventsIdHeader)
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer logSvc.Close()
+	logSvcUrl, _ := url.Parse(logSvc.URL)
+
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	config := DefaultDeliveryConfig()
+	config.MaxRetries = 1
+	config.RetryBackoff = time.Millisecond
+	config.SpoolDir = spoolDir
+	dispatcher, err := NewDispatcher(1, config, logger)
+	g.Expect(err).To(gomega.BeNil())
+	spooled := testutil.ToFloat64(spooledTotal)
+
+	for _, id := range []string{"1", "2"} {
+		logReq := testLogRequest(id, InferenceRequest, `{"instances":[[1,2,3]]}`)
+		logReq.Url = logSvcUrl
+		dispatcher.deliver(logReq)
+	}
+	g.Expect(testutil.ToFloat64(spooledTotal)).To(gomega.Equal(spooled + 2))
+	files, err := os.ReadDir(spoolDir)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(files).To(gomega.HaveLen(2))
+
+	// a new spool picks up the spooled requests, as it would after an agent restart
+	spool, err := NewSpool(spoolDir, 0)
+	g.Expect(err).To(gomega.BeNil())
+	atomic.StoreInt32(&available, 1)
+	sent, err := spool.Replay(dispatcher.send)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(sent).To(gomega.Equal(2))
+	g.Expect(<-received).To(gomega.Equal("1"))
+	g.Expect(<-received).To(gomega.Equal("2"))
+	files, err = os.ReadDir(spoolDir)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(files).To(gomega.BeEmpty())
+}
+
+func TestDispatcherStop(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	var received int32
+	logSvc := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&received, 1)
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer logSvc.Close()
+	logSvcUrl, _ := url.Parse(logSvc.URL)
+
+	dispatcher, err := NewDispatcher(2, DefaultDeliveryConfig(), logger)
+	g.Expect(err).To(gomega.BeNil())
+	dispatcher.Start()
+	for i := 0; i < 20; i++ {
+		logReq := testLogRequest(fmt.Sprint(i), InferenceRequest, `{"instances":[[1,2,3]]}`)
+		logReq.Url = logSvcUrl
+		g.Expect(dispatcher.QueueLogRequest(logReq)).To(gomega.Succeed())
+	}
+
+	// the queued requests are delivered before the dispatcher stops
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	g.Expect(dispatcher.Stop(ctx)).To(gomega.Succeed())
+	g.Expect(atomic.LoadInt32(&received)).To(gomega.Equal(int32(20)))
+	g.Expect(dispatcher.QueueLogRequest(testLogRequest("21", InferenceRequest, `{}`))).To(gomega.Equal(ErrDispatcherStopped))
+	g.Expect(dispatcher.Stop(ctx)).To(gomega.Succeed())
+}
+
+func TestDispatchersEventConfig(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	events := make(chan *http.Request, 2)
+	logSvc := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		events <- req
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer logSvc.Close()
+	logSvcUrl, _ := url.Parse(logSvc.URL)
+
+	// two dispatchers of the same process send their events with their own config
+	structured := DefaultDeliveryConfig()
+	structured.Event.Encoding = EncodingStructured
+	structured.Event.RequestType = "com.example.graph.request"
+	structuredDispatcher := startTestDispatcherWithConfig(t, 1, structured, logger)
+	binaryDispatcher := startTestDispatcher(t, 1, logger)
+
+	logReq := testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`)
+	logReq.Url = logSvcUrl
+	g.Expect(structuredDispatcher.QueueLogRequest(logReq)).To(gomega.Succeed())
+	var req *http.Request
+	g.Eventually(events).Should(gomega.Receive(&req))
+	g.Expect(req.Header.Get("Content-Type")).To(gomega.HavePrefix(StructuredContentType))
+	g.Expect(req.Header.Get("Ce-Type")).To(gomega.BeEmpty())
+
+	g.Expect(binaryDispatcher.QueueLogRequest(logReq)).To(gomega.Succeed())
+	g.Eventually(events).Should(gomega.Receive(&req))
+	g.Expect(req.Header.Get("Content-Type")).To(gomega.Equal("application/json"))
+	g.Expect(req.Header.Get("Ce-Type")).To(gomega.Equal(CEInferenceRequest))
+}
+
+func TestDispatcherStopTimeoutSpools(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	logSvc := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer logSvc.Close()
+	logSvcUrl, _ := url.Parse(logSvc.URL)
+
+	spoolDir := t.TempDir()
+	config := DefaultDeliveryConfig()
+	config.MaxRetries = 100
+	config.RetryBackoff = 50 * time.Millisecond
+	config.SpoolDir = spoolDir
+	config.SpoolReplayInterval = time.Hour
+	dispatcher, err := NewDispatcher(1, config, logger)
+	g.Expect(err).To(gomega.BeNil())
+	dispatcher.Start()
+	for i := 0; i < 3; i++ {
+		logReq := testLogRequest(fmt.Sprint(i), InferenceRequest, `{"instances":[[1,2,3]]}`)
+		logReq.Url = logSvcUrl
+		g.Expect(dispatcher.QueueLogRequest(logReq)).To(gomega.Succeed())
+	}
+
+	// the log url never recovers so the flush times out and the undelivered requests are spooled
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	g.Expect(dispatcher.Stop(ctx)).NotTo(gomega.Succeed())
+	files, err := os.ReadDir(spoolDir)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(files).To(gomega.HaveLen(3))
+	g.Expect(dispatcher.pending).To(gomega.Equal(0))
+}
+
+func TestSpoolMaxBytes(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	spool, err := NewSpool(t.TempDir(), 400)
+	g.Expect(err).To(gomega.BeNil())
+	logReq := testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`)
+	logReq.Url, _ = url.Parse("http://logger.default")
+	g.Expect(spool.Put(logReq)).To(gomega.Succeed())
+	g.Expect(spool.Put(logReq)).To(gomega.Equal(ErrSpoolFull))
+
+	// the spool has room again once the spooled request is resent
+	sent, err := spool.Replay(func(LogRequest) error { return nil })
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(sent).To(gomega.Equal(1))
+	g.Expect(spool.Put(logReq)).To(gomega.Succeed())
+}
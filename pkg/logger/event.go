@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Encoding is the CloudEvents content mode used by the http and kafka sinks
+type Encoding string
+
+const (
+	// EncodingBinary sends the payload as the message body and the event attributes as headers
+	EncodingBinary Encoding = "binary"
+	// EncodingStructured sends the whole event, attributes and payload, as a json message body
+	EncodingStructured Encoding = "structured"
+
+	// StructuredContentType is the content type of structured mode events
+	StructuredContentType = "application/cloudevents+json"
+
+	CEInferenceEnvelope = "org.kubeflow.serving.inference.envelope"
+	// EnvelopeVersion is bumped whenever a field of the envelope changes in an incompatible way
+	EnvelopeVersion = "v1"
+)
+
+// EventConfig configures the CloudEvents sent by the sinks of a dispatcher
+type EventConfig struct {
+	Encoding Encoding
+	// event types of the request, response and envelope events
+	RequestType  string
+	ResponseType string
+	EnvelopeType string
+	// Source overrides the source uri of the events when set
+	Source string
+	// DataSchema is set as the dataschema attribute so consumers can look up the payload schema in a registry
+	DataSchema string
+	// Envelope correlates the request and response of an inference into a single envelope event
+	Envelope bool
+}
+
+func DefaultEventConfig() EventConfig {
+	return EventConfig{
+		Encoding:     EncodingBinary,
+		RequestType:  CEInferenceRequest,
+		ResponseType: CEInferenceResponse,
+		EnvelopeType: CEInferenceEnvelope,
+	}
+}
+
+func (c EventConfig) validate() error {
+	switch c.Encoding {
+	case EncodingBinary, EncodingStructured:
+	default:
+		return fmt.Errorf("invalid encoding %q, must be %q or %q", c.Encoding, EncodingBinary, EncodingStructured)
+	}
+	if c.RequestType == "" || c.ResponseType == "" || c.EnvelopeType == "" {
+		return fmt.Errorf("event types can't be empty")
+	}
+	if c.Source != "" {
+		if _, err := url.Parse(c.Source); err != nil {
+			return fmt.Errorf("invalid event source %q: %s", c.Source, err)
+		}
+	}
+	if c.DataSchema != "" {
+		if _, err := url.Parse(c.DataSchema); err != nil {
+			return fmt.Errorf("invalid data schema %q: %s", c.DataSchema, err)
+		}
+	}
+	return nil
+}
+
+// Envelope holds the request and response of an inference correlated by their Ce-Id
+type Envelope struct {
+	Version  string           `json:"version"`
+	Id       string           `json:"id"`
+	Request  *EnvelopeMessage `json:"request,omitempty"`
+	Response *EnvelopeMessage `json:"response,omitempty"`
+}
+
+// EnvelopeMessage is one side of the inference, json payloads are embedded as is and other payloads are
+// base64 encoded in BodyBase64.
+type EnvelopeMessage struct {
+	ContentType string          `json:"contentType,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	BodyBase64  []byte          `json:"bodyBase64,omitempty"`
+	// StatusCode and LatencyMs are only set for responses
+	StatusCode int   `json:"statusCode,omitempty"`
+	LatencyMs  int64 `json:"latencyMs,omitempty"`
+}
+
+func newEnvelopeMessage(contentType string, body []byte) *EnvelopeMessage {
+	message := &EnvelopeMessage{ContentType: contentType}
+	if json.Valid(body) {
+		message.Body = body
+	} else if len(body) > 0 {
+		message.BodyBase64 = body
+	}
+	return message
+}
+
+// newEnvelopeLogRequest builds the envelope log request from the request and response log requests of an
+// inference, either of them may be nil when only one side is logged.
+func newEnvelopeLogRequest(request *LogRequest, response *LogRequest) (LogRequest, error) {
+	logReq := request
+	if logReq == nil {
+		logReq = response
+	}
+	envelope := Envelope{
+		Version: EnvelopeVersion,
+		Id:      logReq.Id,
+	}
+	if request != nil {
+		envelope.Request = newEnvelopeMessage(request.ContentType, *request.Bytes)
+	}
+	if response != nil {
+		envelope.Response = newEnvelopeMessage(response.ContentType, *response.Bytes)
+		envelope.Response.StatusCode = response.StatusCode
+		envelope.Response.LatencyMs = response.Latency.Milliseconds()
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return LogRequest{}, fmt.Errorf("while encoding envelope: %s", err)
+	}
+	envelopeReq := *logReq
+	envelopeReq.Bytes = &data
+	envelopeReq.ContentType = "application/json"
+	envelopeReq.ReqType = InferenceEnvelope
+	if response != nil {
+		envelopeReq.StatusCode = response.StatusCode
+		envelopeReq.Latency = response.Latency
+	}
+	return envelopeReq, nil
+}
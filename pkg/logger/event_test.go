@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestEventConfigValidate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scenarios := map[string]struct {
+		update func(config *EventConfig)
+		valid  bool
+	}{
+		"Default": {
+			update: func(config *EventConfig) {},
+			valid:  true,
+		},
+		"Structured": {
+			update: func(config *EventConfig) { config.Encoding = EncodingStructured },
+			valid:  true,
+		},
+		"UnknownEncoding": {
+			update: func(config *EventConfig) { config.Encoding = "batched" },
+		},
+		"EmptyType": {
+			update: func(config *EventConfig) { config.RequestType = "" },
+		},
+		"InvalidSource": {
+			update: func(config *EventConfig) { config.Source = "http://a b:x" },
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultEventConfig()
+			scenario.update(&config)
+			if scenario.valid {
+				g.Expect(config.validate()).To(gomega.Succeed())
+			} else {
+				g.Expect(config.validate()).NotTo(gomega.Succeed())
+			}
+		})
+	}
+}
+
+func TestToCloudEventConfig(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	config := DefaultEventConfig()
+	config.RequestType = "com.example.inference.request"
+	config.Source = "urn:kserve:sklearn-iris"
+	config.DataSchema = "https://registry.example.com/schemas/iris/1"
+	g.Expect(config.validate()).To(gomega.Succeed())
+
+	event, err := toCloudEvent(testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`), config)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(event.Type()).To(gomega.Equal("com.example.inference.request"))
+	g.Expect(event.Source()).To(gomega.Equal("urn:kserve:sklearn-iris"))
+	g.Expect(event.DataSchema()).To(gomega.Equal("https://registry.example.com/schemas/iris/1"))
+}
+
+func TestNewEnvelopeLogRequest(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	request := testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`)
+	response := testLogRequest("1", InferenceResponse, "model not ready")
+	response.ContentType = "text/plain"
+	response.StatusCode = 503
+	response.Latency = 42 * time.Millisecond
+
+	logReq, err := newEnvelopeLogRequest(&request, &response)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(logReq.ReqType).To(gomega.Equal(InferenceEnvelope))
+	g.Expect(logReq.Id).To(gomega.Equal("1"))
+	g.Expect(logReq.StatusCode).To(gomega.Equal(503))
+
+	envelope := Envelope{}
+	g.Expect(json.Unmarshal(*logReq.Bytes, &envelope)).To(gomega.Succeed())
+	g.Expect(envelope.Version).To(gomega.Equal(EnvelopeVersion))
+	g.Expect(envelope.Id).To(gomega.Equal("1"))
+	g.Expect(string(envelope.Request.Body)).To(gomega.Equal(`{"instances":[[1,2,3]]}`))
+	g.Expect(envelope.Request.BodyBase64).To(gomega.BeNil())
+	g.Expect(envelope.Response.Body).To(gomega.BeNil())
+	g.Expect(string(envelope.Response.BodyBase64)).To(gomega.Equal("model not ready"))
+	g.Expect(envelope.Response.StatusCode).To(gomega.Equal(503))
+	g.Expect(envelope.Response.LatencyMs).To(gomega.Equal(int64(42)))
+
+	event, err := toCloudEvent(logReq, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(event.Type()).To(gomega.Equal(CEInferenceEnvelope))
+	g.Expect(event.Extensions()).To(gomega.HaveKey(StatusCodeAttr))
+
+	requestOnly, err := newEnvelopeLogRequest(&request, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(string(*requestOnly.Bytes)).NotTo(gomega.ContainSubstring(`"response"`))
+	event, err = toCloudEvent(requestOnly, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(event.Extensions()).NotTo(gomega.HaveKey(StatusCodeAttr))
+}
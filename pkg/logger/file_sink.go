@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const (
+	// FileMaxBytesParam is the size in bytes after which the log file is rotated
+	FileMaxBytesParam = "maxBytes"
+	// FileMaxBackupsParam is the number of rotated log files to keep
+	FileMaxBackupsParam = "maxBackups"
+
+	DefaultFileMaxBytes   = 100 * 1024 * 1024
+	DefaultFileMaxBackups = 5
+)
+
+// FileSink appends structured mode CloudEvents as json lines to a local file, the url has the form
+// file:///path/to/file?maxBytes=104857600&maxBackups=5. Once the file reaches maxBytes it is renamed
+// to file.1, file.1 to file.2 and so on, keeping at most maxBackups rotated files.
+type FileSink struct {
+	path        string
+	maxBytes    int64
+	maxBackups  int
+	eventConfig EventConfig
+	mu          sync.Mutex
+	file        *os.File
+	size        int64
+}
+
+var _ Sink = (*FileSink)(nil)
+
+func NewFileSink(logUrl *url.URL, eventConfig EventConfig) (*FileSink, error) {
+	if logUrl.Path == "" {
+		return nil, fmt.Errorf("no file path in log url %s", logUrl.String())
+	}
+	sink := &FileSink{
+		path:        logUrl.Path,
+		maxBytes:    DefaultFileMaxBytes,
+		maxBackups:  DefaultFileMaxBackups,
+		eventConfig: eventConfig,
+	}
+	query := logUrl.Query()
+	if value := query.Get(FileMaxBytesParam); value != "" {
+		maxBytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxBytes <= 0 {
+			return nil, fmt.Errorf("invalid %s %q in log url", FileMaxBytesParam, value)
+		}
+		sink.maxBytes = maxBytes
+	}
+	if value := query.Get(FileMaxBackupsParam); value != "" {
+		maxBackups, err := strconv.Atoi(value)
+		if err != nil || maxBackups < 0 {
+			return nil, fmt.Errorf("invalid %s %q in log url", FileMaxBackupsParam, value)
+		}
+		sink.maxBackups = maxBackups
+	}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (s *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("while creating log dir: %s", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("while opening log file: %s", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("while reading log file info: %s", err)
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("while closing log file: %s", err)
+	}
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("while removing log file: %s", err)
+		}
+		return s.open()
+	}
+	for i := s.maxBackups - 1; i > 0; i-- {
+		backup := fmt.Sprintf("%s.%d", s.path, i)
+		if _, err := os.Stat(backup); err == nil {
+			if err := os.Rename(backup, fmt.Sprintf("%s.%d", s.path, i+1)); err != nil {
+				return fmt.Errorf("while rotating log file: %s", err)
+			}
+		}
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return fmt.Errorf("while rotating log file: %s", err)
+	}
+	return s.open()
+}
+
+func (s *FileSink) Send(logReq LogRequest) error {
+	event, err := toCloudEvent(logReq, s.eventConfig)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("while encoding event: %s", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("while writing log file: %s", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
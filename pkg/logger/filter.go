@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/kserve/kserve/pkg/utils"
+)
+
+const (
+	// RedactedValue replaces the redacted fields in the logged payloads
+	RedactedValue = "[REDACTED]"
+	// DefaultMaxCaptureBytes bounds how much of a response is held in memory for logging
+	DefaultMaxCaptureBytes int64 = 16 << 20
+)
+
+// PayloadFilter decides which inferences are logged and redacts and truncates their payloads
+type PayloadFilter struct {
+	sampleRate        float64
+	forceSampleHeader string
+	maxBodyBytes      int64
+	redactPaths       []utils.JSONPath
+}
+
+// NewPayloadFilter creates a filter logging the sampleRate fraction of inferences, a maxBodyBytes of 0 logs the
+// whole payload. Inferences with the forceSampleHeader set to true are always logged.
+func NewPayloadFilter(sampleRate float64, forceSampleHeader string, maxBodyBytes int64, redactFields []string) (*PayloadFilter, error) {
+	if sampleRate < 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("invalid sample rate %v, must be between 0 and 1", sampleRate)
+	}
+	if maxBodyBytes < 0 {
+		return nil, fmt.Errorf("invalid max body bytes %d", maxBodyBytes)
+	}
+	filter := &PayloadFilter{
+		sampleRate:        sampleRate,
+		forceSampleHeader: forceSampleHeader,
+		maxBodyBytes:      maxBodyBytes,
+	}
+	for _, field := range redactFields {
+		path, err := utils.ParseJSONPath(field)
+		if err != nil {
+			return nil, err
+		}
+		filter.redactPaths = append(filter.redactPaths, path)
+	}
+	return filter, nil
+}
+
+// Sample decides whether the inference is logged. The decision is derived from the inference id so that every
+// component handling the same Ce-Id makes the same decision.
+func (f *PayloadFilter) Sample(r *http.Request, id string) bool {
+	if f == nil || f.sampleRate >= 1 {
+		return true
+	}
+	if f.forceSampleHeader != "" && strings.EqualFold(r.Header.Get(f.forceSampleHeader), "true") {
+		return true
+	}
+	if f.sampleRate <= 0 {
+		return false
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(id))
+	return float64(hash.Sum64())/math.MaxUint64 < f.sampleRate
+}
+
+// Filter returns the payload to log with the redacted fields replaced and truncated to the max body size
+func (f *PayloadFilter) Filter(body []byte) []byte {
+	if f == nil {
+		return body
+	}
+	if len(f.redactPaths) > 0 {
+		body = f.redact(body)
+	}
+	if f.maxBodyBytes > 0 && int64(len(body)) > f.maxBodyBytes {
+		body = body[:f.maxBodyBytes]
+	}
+	return body
+}
+
+// redact replaces the redacted fields of a json payload, other payloads are returned as is
+func (f *PayloadFilter) redact(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// keep numbers as they were sent instead of converting them to float64
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return body
+	}
+	replaced := 0
+	for _, path := range f.redactPaths {
+		replaced += path.Replace(doc, RedactedValue)
+	}
+	if replaced == 0 {
+		return body
+	}
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// CaptureLimit returns how many bytes of a response have to be captured to log it. Redaction needs the whole
+// json document so the default capture limit applies whenever fields are redacted.
+func (f *PayloadFilter) CaptureLimit() int64 {
+	if f != nil && f.maxBodyBytes > 0 && len(f.redactPaths) == 0 {
+		return f.maxBodyBytes
+	}
+	return DefaultMaxCaptureBytes
+}
+
+// FilterTruncated filters a payload of which only the first CaptureLimit bytes were captured. A partial json
+// document can't be redacted so nothing is logged rather than leaking the fields to redact.
+func (f *PayloadFilter) FilterTruncated(body []byte) []byte {
+	if f != nil && len(f.redactPaths) > 0 {
+		return []byte{}
+	}
+	return f.Filter(body)
+}
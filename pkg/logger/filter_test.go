@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestNewPayloadFilter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	_, err := NewPayloadFilter(1, "", 0, []string{"$.instances[*].ssn"})
+	g.Expect(err).To(gomega.BeNil())
+	_, err = NewPayloadFilter(-0.1, "", 0, nil)
+	g.Expect(err).NotTo(gomega.BeNil())
+	_, err = NewPayloadFilter(1, "", -1, nil)
+	g.Expect(err).NotTo(gomega.BeNil())
+	_, err = NewPayloadFilter(1, "", 0, []string{"instances"})
+	g.Expect(err).NotTo(gomega.BeNil())
+}
+
+func TestPayloadFilterSample(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	r := httptest.NewRequest("POST", "http://a", nil)
+
+	var nilFilter *PayloadFilter
+	g.Expect(nilFilter.Sample(r, "1")).To(gomega.BeTrue())
+
+	none, _ := NewPayloadFilter(0, "X-Force-Log", 0, nil)
+	g.Expect(none.Sample(r, "1")).To(gomega.BeFalse())
+	forced := httptest.NewRequest("POST", "http://a", nil)
+	forced.Header.Set("X-Force-Log", "true")
+	g.Expect(none.Sample(forced, "1")).To(gomega.BeTrue())
+
+	tenth, _ := NewPayloadFilter(0.1, "", 0, nil)
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("request-%d", i)
+		decision := tenth.Sample(r, id)
+		// the same id always gets the same decision
+		g.Expect(tenth.Sample(r, id)).To(gomega.Equal(decision))
+		if decision {
+			sampled++
+		}
+	}
+	g.Expect(sampled).To(gomega.BeNumerically("~", 1000, 150))
+}
+
+func TestPayloadFilterFilter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scenarios := map[string]struct {
+		maxBodyBytes int64
+		redactFields []string
+		body         string
+		expected     string
+	}{
+		"Unfiltered": {
+			body:     `{"instances":[[1,2,3]]}`,
+			expected: `{"instances":[[1,2,3]]}`,
+		},
+		"Truncated": {
+			maxBodyBytes: 10,
+			body:         `{"instances":[[1,2,3]]}`,
+			expected:     `{"instance`,
+		},
+		"Redacted": {
+			redactFields: []string{"$.instances[*].ssn", "$.parameters.token"},
+			body:         `{"instances":[{"ssn":"123-45-6789","income":12345678901234567890}],"parameters":{"token":"abc"}}`,
+			expected:     `{"instances":[{"income":12345678901234567890,"ssn":"[REDACTED]"}],"parameters":{"token":"[REDACTED]"}}`,
+		},
+		"RedactedThenTruncated": {
+			maxBodyBytes: 20,
+			redactFields: []string{"$.ssn"},
+			body:         `{"ssn":"123-45-6789"}`,
+			expected:     `{"ssn":"[REDACTED]"}`,
+		},
+		"NotJson": {
+			redactFields: []string{"$.ssn"},
+			body:         `ssn=123-45-6789`,
+			expected:     `ssn=123-45-6789`,
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			filter, err := NewPayloadFilter(1, "", scenario.maxBodyBytes, scenario.redactFields)
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(string(filter.Filter([]byte(scenario.body)))).To(gomega.Equal(scenario.expected))
+		})
+	}
+}
+
+func TestPayloadFilterCaptureLimit(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	var nilFilter *PayloadFilter
+	g.Expect(nilFilter.CaptureLimit()).To(gomega.Equal(DefaultMaxCaptureBytes))
+	g.Expect(string(nilFilter.FilterTruncated([]byte("abc")))).To(gomega.Equal("abc"))
+
+	truncating, _ := NewPayloadFilter(1, "", 10, nil)
+	g.Expect(truncating.CaptureLimit()).To(gomega.Equal(int64(10)))
+	g.Expect(string(truncating.FilterTruncated([]byte(`{"instances":[`)))).To(gomega.Equal(`{"instance`))
+
+	// redaction needs the whole document so partial payloads are not logged
+	redacting, _ := NewPayloadFilter(1, "", 10, []string{"$.ssn"})
+	g.Expect(redacting.CaptureLimit()).To(gomega.Equal(DefaultMaxCaptureBytes))
+	g.Expect(redacting.FilterTruncated([]byte(`{"ssn":"123`))).To(gomega.BeEmpty())
+}
@@ -20,8 +20,9 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
-	"net/http/httptest"
 	"net/url"
+	"regexp"
+	"time"
 
 	"github.com/go-logr/logr"
 	guuid "github.com/google/uuid"
@@ -40,11 +41,13 @@ type LoggerHandler struct {
 	namespace        string
 	component        string
 	endpoint         string
+	filter           *PayloadFilter
+	dispatcher       *Dispatcher
 	next             http.Handler
 }
 
 func New(logUrl *url.URL, sourceUri *url.URL, logMode v1beta1.LoggerType,
-	inferenceService string, namespace string, endpoint string, component string, next http.Handler) http.Handler {
+	inferenceService string, namespace string, endpoint string, component string, filter *PayloadFilter, dispatcher *Dispatcher, next http.Handler) http.Handler {
 	logf.SetLogger(zap.New())
 	return &LoggerHandler{
 		log:              logf.Log.WithName("Logger"),
@@ -55,10 +58,28 @@ func New(logUrl *url.URL, sourceUri *url.URL, logMode v1beta1.LoggerType,
 		namespace:        namespace,
 		component:        component,
 		endpoint:         endpoint,
+		filter:           filter,
+		dispatcher:       dispatcher,
 		next:             next,
 	}
 }
 
+var (
+	v1ModelPathRegex = regexp.MustCompile(`^/v1/models/([^/:]+)`)
+	v2ModelPathRegex = regexp.MustCompile(`^/v2/models/([^/]+)(?:/versions/([^/]+))?`)
+)
+
+// parseModel returns the model name and version from a v1 or v2 inference protocol path
+func parseModel(path string) (string, string) {
+	if match := v2ModelPathRegex.FindStringSubmatch(path); match != nil {
+		return match[1], match[2]
+	}
+	if match := v1ModelPathRegex.FindStringSubmatch(path); match != nil {
+		return match[1], ""
+	}
+	return "", ""
+}
+
 func getOrCreateID(r *http.Request) string {
 	id := r.Header.Get(CloudEventsIdHeader)
 	if id == "" {
@@ -84,12 +105,18 @@ func (eh *LoggerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Get or Create an ID
 	id := getOrCreateID(r)
+	sampled := eh.filter.Sample(r, id)
+	modelName, modelVersion := parseModel(r.URL.Path)
 	contentType := r.Header.Get("Content-Type")
+	// with envelopes the request is held back and logged together with the response
+	envelope := eh.dispatcher.config.Event.Envelope
+	var requestLog *LogRequest
 	// log Request
-	if eh.logMode == v1beta1.LogAll || eh.logMode == v1beta1.LogRequest {
-		if err := QueueLogRequest(LogRequest{
+	if sampled && (eh.logMode == v1beta1.LogAll || eh.logMode == v1beta1.LogRequest) {
+		logBody := eh.filter.Filter(body)
+		requestLog = &LogRequest{
 			Url:              eh.logUrl,
-			Bytes:            &body,
+			Bytes:            &logBody,
 			ContentType:      contentType,
 			ReqType:          InferenceRequest,
 			Id:               id,
@@ -98,46 +125,67 @@ func (eh *LoggerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			Namespace:        eh.namespace,
 			Endpoint:         eh.endpoint,
 			Component:        eh.component,
-		}); err != nil {
-			eh.log.Error(err, "Failed to log request")
+			ModelName:        modelName,
+			ModelVersion:     modelVersion,
+			Path:             r.URL.Path,
+		}
+		if !envelope {
+			if err := eh.dispatcher.QueueLogRequest(*requestLog); err != nil {
+				eh.log.Error(err, "Failed to log request")
+			}
 		}
 	}
 
-	// Proxy Request
+	// Proxy Request, the response is streamed to the client while a copy is captured for logging
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-	rr := httptest.NewRecorder()
-	eh.next.ServeHTTP(rr, r)
-	responseBody := rr.Body.Bytes()
-	contentType = rr.Header().Get("Content-Type")
-	if contentType != "" {
-		w.Header().Set("Content-Type", contentType)
+	tee := newTeeResponseWriter(w, eh.filter.CaptureLimit())
+	start := time.Now()
+	eh.next.ServeHTTP(tee, r)
+	latency := time.Since(start)
+	if tee.status >= http.StatusBadRequest {
+		eh.log.Info("Failed to proxy request", "status code", tee.status)
 	}
-	// log response if OK
-	if rr.Code == http.StatusOK {
-		if eh.logMode == v1beta1.LogAll || eh.logMode == v1beta1.LogResponse {
-			if err := QueueLogRequest(LogRequest{
-				Url:              eh.logUrl,
-				Bytes:            &responseBody,
-				ContentType:      contentType,
-				ReqType:          InferenceResponse,
-				Id:               id,
-				SourceUri:        eh.sourceUri,
-				InferenceService: eh.inferenceService,
-				Namespace:        eh.namespace,
-				Endpoint:         eh.endpoint,
-				Component:        eh.component,
-			}); err != nil {
-				eh.log.Error(err, "Failed to log response")
-			}
+	// log response, error responses are logged too so that they show up in the audit trail
+	if sampled && (eh.logMode == v1beta1.LogAll || eh.logMode == v1beta1.LogResponse) {
+		var logBody []byte
+		if tee.truncated {
+			logBody = eh.filter.FilterTruncated(tee.body.Bytes())
+		} else {
+			logBody = eh.filter.Filter(tee.body.Bytes())
 		}
-	} else {
-		eh.log.Info("Failed to proxy request", "status code", rr.Code)
+		responseLog := &LogRequest{
+			Url:              eh.logUrl,
+			Bytes:            &logBody,
+			ContentType:      tee.Header().Get("Content-Type"),
+			ReqType:          InferenceResponse,
+			Id:               id,
+			SourceUri:        eh.sourceUri,
+			InferenceService: eh.inferenceService,
+			Namespace:        eh.namespace,
+			Endpoint:         eh.endpoint,
+			Component:        eh.component,
+			ModelName:        modelName,
+			ModelVersion:     modelVersion,
+			Path:             r.URL.Path,
+			StatusCode:       tee.status,
+			Latency:          latency,
+		}
+		if envelope {
+			eh.logEnvelope(requestLog, responseLog)
+		} else if err := eh.dispatcher.QueueLogRequest(*responseLog); err != nil {
+			eh.log.Error(err, "Failed to log response")
+		}
+	} else if envelope && requestLog != nil {
+		eh.logEnvelope(requestLog, nil)
 	}
+}
 
-	w.WriteHeader(rr.Code)
-	_, err = w.Write(rr.Body.Bytes())
+func (eh *LoggerHandler) logEnvelope(requestLog *LogRequest, responseLog *LogRequest) {
+	logReq, err := newEnvelopeLogRequest(requestLog, responseLog)
+	if err == nil {
+		err = eh.dispatcher.QueueLogRequest(logReq)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		eh.log.Error(err, "Failed to log envelope")
 	}
 }
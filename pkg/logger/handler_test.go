@@ -18,12 +18,16 @@ package logger
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/kserve/kserve/pkg/apis/serving/v1beta1"
 	"github.com/onsi/gomega"
@@ -76,9 +80,9 @@ func TestLogger(t *testing.T) {
 	targetUri, err := url.Parse(predictor.URL)
 	g.Expect(err).To(gomega.BeNil())
 
-	StartDispatcher(5, logger)
+	dispatcher := startTestDispatcher(t, 5, logger)
 	httpProxy := httputil.NewSingleHostReverseProxy(targetUri)
-	oh := New(logSvcUrl, sourceUri, v1beta1.LogAll, "mymodel", "default", "default", "default", httpProxy)
+	oh := New(logSvcUrl, sourceUri, v1beta1.LogAll, "mymodel", "default", "default", "default", nil, dispatcher, httpProxy)
 
 	oh.ServeHTTP(w, r)
 
@@ -119,11 +123,213 @@ func TestBadResponse(t *testing.T) {
 	targetUri, err := url.Parse(predictor.URL)
 	g.Expect(err).To(gomega.BeNil())
 
-	StartDispatcher(1, logger)
+	dispatcher := startTestDispatcher(t, 1, logger)
 	httpProxy := httputil.NewSingleHostReverseProxy(targetUri)
-	oh := New(logSvcUrl, sourceUri, v1beta1.LogAll, "mymodel", "default", "default", "default", httpProxy)
+	oh := New(logSvcUrl, sourceUri, v1beta1.LogAll, "mymodel", "default", "default", "default", nil, dispatcher, httpProxy)
 
 	oh.ServeHTTP(w, r)
 	g.Expect(w.Code).To(gomega.Equal(400))
 	g.Expect(w.Body.String()).To(gomega.Equal(predictorResponse))
 }
+
+func TestErrorResponseLogged(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	predictorRequest := []byte(`{"inputs":[]}`)
+	events := make(chan http.Header, 2)
+	logSvc := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		events <- req.Header
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer logSvc.Close()
+
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		http.Error(rw, "model not ready", http.StatusServiceUnavailable)
+	}))
+	defer predictor.Close()
+
+	r := httptest.NewRequest("POST", "http://a/v2/models/mymodel/versions/2/infer", bytes.NewReader(predictorRequest))
+	w := httptest.NewRecorder()
+	logger, _ := pkglogging.NewLogger("", "INFO")
+	logSvcUrl, err := url.Parse(logSvc.URL)
+	g.Expect(err).To(gomega.BeNil())
+	sourceUri, err := url.Parse("http://localhost:9081/")
+	g.Expect(err).To(gomega.BeNil())
+	targetUri, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+
+	dispatcher := startTestDispatcher(t, 1, logger)
+	httpProxy := httputil.NewSingleHostReverseProxy(targetUri)
+	oh := New(logSvcUrl, sourceUri, v1beta1.LogAll, "mymodel", "default", "default", "predictor", nil, dispatcher, httpProxy)
+
+	oh.ServeHTTP(w, r)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusServiceUnavailable))
+
+	headers := map[string]http.Header{}
+	for i := 0; i < 2; i++ {
+		header := <-events
+		headers[header.Get("Ce-Type")] = header
+	}
+	request := headers[CEInferenceRequest]
+	g.Expect(request).NotTo(gomega.BeNil())
+	g.Expect(request.Get("Ce-Modelname")).To(gomega.Equal("mymodel"))
+	g.Expect(request.Get("Ce-Modelversion")).To(gomega.Equal("2"))
+	g.Expect(request.Get("Ce-Path")).To(gomega.Equal("/v2/models/mymodel/versions/2/infer"))
+	g.Expect(request.Get("Ce-Statuscode")).To(gomega.BeEmpty())
+
+	response := headers[CEInferenceResponse]
+	g.Expect(response).NotTo(gomega.BeNil())
+	g.Expect(response.Get("Ce-Statuscode")).To(gomega.Equal("503"))
+	g.Expect(response.Get("Ce-Modelname")).To(gomega.Equal("mymodel"))
+	latency, err := strconv.Atoi(response.Get("Ce-Latencyms"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(latency).To(gomega.BeNumerically(">=", 5))
+}
+
+func TestParseModel(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scenarios := map[string]struct {
+		path    string
+		name    string
+		version string
+	}{
+		"V1Predict":    {path: "/v1/models/sklearn-iris:predict", name: "sklearn-iris"},
+		"V1Explain":    {path: "/v1/models/sklearn-iris:explain", name: "sklearn-iris"},
+		"V1Metadata":   {path: "/v1/models/sklearn-iris", name: "sklearn-iris"},
+		"V2Infer":      {path: "/v2/models/sklearn-iris/infer", name: "sklearn-iris"},
+		"V2Versioned":  {path: "/v2/models/sklearn-iris/versions/3/infer", name: "sklearn-iris", version: "3"},
+		"UnknownRoute": {path: "/predict"},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			modelName, modelVersion := parseModel(scenario.path)
+			g.Expect(modelName).To(gomega.Equal(scenario.name))
+			g.Expect(modelVersion).To(gomega.Equal(scenario.version))
+		})
+	}
+}
+
+func TestStreamingResponse(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	events := make(chan []byte, 2)
+	logSvc := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		if req.Header.Get("Ce-Type") == CEInferenceResponse {
+			events <- b
+		}
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer logSvc.Close()
+
+	release := make(chan struct{})
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("X-Model-Id", "mymodel")
+		rw.Header().Set("Trailer", "X-Inference-Time")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("data: first\n\n"))
+		rw.(http.Flusher).Flush()
+		<-release
+		rw.Write([]byte("data: second\n\n"))
+		rw.Header().Set("X-Inference-Time", "12ms")
+	}))
+	defer predictor.Close()
+
+	logger, _ := pkglogging.NewLogger("", "INFO")
+	logSvcUrl, err := url.Parse(logSvc.URL)
+	g.Expect(err).To(gomega.BeNil())
+	sourceUri, err := url.Parse("http://localhost:9081/")
+	g.Expect(err).To(gomega.BeNil())
+	targetUri, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+
+	dispatcher := startTestDispatcher(t, 1, logger)
+	httpProxy := httputil.NewSingleHostReverseProxy(targetUri)
+	httpProxy.FlushInterval = -1
+	oh := New(logSvcUrl, sourceUri, v1beta1.LogResponse, "mymodel", "default", "default", "predictor", nil, dispatcher, httpProxy)
+	agent := httptest.NewServer(oh)
+	defer agent.Close()
+
+	resp, err := http.Post(agent.URL+"/v1/models/mymodel:predict", "application/json", bytes.NewReader([]byte(`{}`)))
+	g.Expect(err).To(gomega.BeNil())
+	defer resp.Body.Close()
+	g.Expect(resp.Header.Get("Content-Type")).To(gomega.Equal("text/event-stream"))
+	g.Expect(resp.Header.Get("X-Model-Id")).To(gomega.Equal("mymodel"))
+
+	// the first event reaches the client while the predictor is still streaming
+	first := make([]byte, len("data: first\n\n"))
+	_, err = io.ReadFull(resp.Body, first)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(string(first)).To(gomega.Equal("data: first\n\n"))
+	close(release)
+
+	rest, err := ioutil.ReadAll(resp.Body)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(string(rest)).To(gomega.Equal("data: second\n\n"))
+	g.Expect(resp.Trailer.Get("X-Inference-Time")).To(gomega.Equal("12ms"))
+	g.Eventually(events).Should(gomega.Receive(gomega.Equal([]byte("data: first\n\ndata: second\n\n"))))
+}
+
+func TestEnvelopeLogged(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	config := DefaultDeliveryConfig()
+	config.Event.Encoding = EncodingStructured
+	config.Event.Envelope = true
+
+	type received struct {
+		contentType string
+		body        []byte
+	}
+	events := make(chan received, 2)
+	logSvc := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		events <- received{contentType: req.Header.Get("Content-Type"), body: b}
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer logSvc.Close()
+
+	predictor := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"predictions":[1]}`))
+	}))
+	defer predictor.Close()
+
+	r := httptest.NewRequest("POST", "http://a/v1/models/mymodel:predict", bytes.NewReader([]byte(`{"instances":[[1,2,3]]}`)))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(CloudEventsIdHeader, "inference-1")
+	w := httptest.NewRecorder()
+	logger, _ := pkglogging.NewLogger("", "INFO")
+	logSvcUrl, err := url.Parse(logSvc.URL)
+	g.Expect(err).To(gomega.BeNil())
+	sourceUri, err := url.Parse("http://localhost:9081/")
+	g.Expect(err).To(gomega.BeNil())
+	targetUri, err := url.Parse(predictor.URL)
+	g.Expect(err).To(gomega.BeNil())
+
+	dispatcher := startTestDispatcherWithConfig(t, 1, config, logger)
+	httpProxy := httputil.NewSingleHostReverseProxy(targetUri)
+	oh := New(logSvcUrl, sourceUri, v1beta1.LogAll, "mymodel", "default", "default", "predictor", nil, dispatcher, httpProxy)
+	oh.ServeHTTP(w, r)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+
+	// a single structured event holds both the request and the response
+	var event received
+	g.Eventually(events).Should(gomega.Receive(&event))
+	g.Consistently(events, 100*time.Millisecond).ShouldNot(gomega.Receive())
+	g.Expect(event.contentType).To(gomega.HavePrefix(StructuredContentType))
+	decoded := struct {
+		Id   string   `json:"id"`
+		Type string   `json:"type"`
+		Data Envelope `json:"data"`
+	}{}
+	g.Expect(json.Unmarshal(event.body, &decoded)).To(gomega.Succeed())
+	g.Expect(decoded.Id).To(gomega.Equal("inference-1"))
+	g.Expect(decoded.Type).To(gomega.Equal(CEInferenceEnvelope))
+	g.Expect(decoded.Data.Id).To(gomega.Equal("inference-1"))
+	g.Expect(string(decoded.Data.Request.Body)).To(gomega.Equal(`{"instances":[[1,2,3]]}`))
+	g.Expect(string(decoded.Data.Response.Body)).To(gomega.Equal(`{"predictions":[1]}`))
+	g.Expect(decoded.Data.Response.StatusCode).To(gomega.Equal(http.StatusOK))
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudevents/sdk-go"
+)
+
+const (
+	// KafkaBrokersParam lists additional brokers, e.g. kafka://broker-0:9092/topic?brokers=broker-1:9092,broker-2:9092
+	KafkaBrokersParam = "brokers"
+	// cloud events kafka protocol binding header prefix
+	kafkaHeaderPrefix = "ce_"
+	// wait before connecting again after the producer could not be created, it doubles on every failure
+	kafkaConnectBackoff    = time.Second
+	kafkaMaxConnectBackoff = time.Minute
+)
+
+// KafkaSink produces binary or structured mode CloudEvents to a kafka topic, the url has the form
+// kafka://broker:port/topic. The producer connects to the brokers on the first send so that the agent
+// starts while kafka is down, the log requests sent until it is reachable fail and are retried, spooled
+// or dropped by the dispatcher.
+type KafkaSink struct {
+	topic       string
+	brokers     []string
+	config      *sarama.Config
+	eventConfig EventConfig
+
+	mu       sync.Mutex
+	producer sarama.SyncProducer
+	closed   bool
+	// the producer is not created again before connectAt after a failed attempt
+	connectAt      time.Time
+	connectBackoff time.Duration
+	connectErr     error
+}
+
+var _ Sink = (*KafkaSink)(nil)
+
+func NewKafkaSink(logUrl *url.URL, credentials *Credentials, eventConfig EventConfig) (*KafkaSink, error) {
+	topic := strings.Trim(logUrl.Path, "/")
+	if topic == "" {
+		return nil, fmt.Errorf("no kafka topic in log url %s", logUrl.String())
+	}
+	brokers := []string{logUrl.Host}
+	if extraBrokers := logUrl.Query().Get(KafkaBrokersParam); extraBrokers != "" {
+		brokers = append(brokers, strings.Split(extraBrokers, ",")...)
+	}
+	config := sarama.NewConfig()
+	config.ClientID = "kserve-agent"
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	if credentials != nil {
+		if credentials.TLSConfig != nil {
+			config.Net.TLS.Enable = true
+			config.Net.TLS.Config = credentials.TLSConfig
+		}
+		// basic auth maps to SASL/PLAIN and a bearer token to SASL/OAUTHBEARER
+		if credentials.Username != "" {
+			config.Net.SASL.Enable = true
+			config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+			config.Net.SASL.User = credentials.Username
+			config.Net.SASL.Password = credentials.Password
+		} else if credentials.Token != "" {
+			config.Net.SASL.Enable = true
+			config.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+			config.Net.SASL.TokenProvider = staticTokenProvider(credentials.Token)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka producer config: %s", err)
+	}
+	return &KafkaSink{
+		topic:          topic,
+		brokers:        brokers,
+		config:         config,
+		eventConfig:    eventConfig,
+		connectBackoff: kafkaConnectBackoff,
+	}, nil
+}
+
+// getProducer returns the producer, creating it when the connect backoff after the last failure is over
+func (s *KafkaSink) getProducer() (sarama.SyncProducer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("kafka sink is closed")
+	}
+	if s.producer != nil {
+		return s.producer, nil
+	}
+	if time.Now().Before(s.connectAt) {
+		return nil, fmt.Errorf("kafka producer is unavailable until %s: %s", s.connectAt.Format(time.RFC3339), s.connectErr)
+	}
+	producer, err := sarama.NewSyncProducer(s.brokers, s.config)
+	if err != nil {
+		s.connectErr = err
+		s.connectAt = time.Now().Add(s.connectBackoff)
+		if s.connectBackoff *= 2; s.connectBackoff > kafkaMaxConnectBackoff {
+			s.connectBackoff = kafkaMaxConnectBackoff
+		}
+		return nil, fmt.Errorf("while creating kafka producer: %s", err)
+	}
+	s.producer = producer
+	s.connectBackoff = kafkaConnectBackoff
+	return producer, nil
+}
+
+func (s *KafkaSink) Send(logReq LogRequest) error {
+	event, err := toCloudEvent(logReq, s.eventConfig)
+	if err != nil {
+		return err
+	}
+	message, err := kafkaMessage(s.topic, event, s.eventConfig.Encoding == EncodingStructured)
+	if err != nil {
+		return err
+	}
+	producer, err := s.getProducer()
+	if err != nil {
+		return err
+	}
+	if _, _, err := producer.SendMessage(message); err != nil {
+		return fmt.Errorf("while producing kafka message: %s", err)
+	}
+	return nil
+}
+
+// kafkaMessage encodes the event following the CloudEvents kafka protocol binding, keyed by the event id so
+// that the events of an inference land on the same partition
+func kafkaMessage(topic string, event cloudevents.Event, structured bool) (*sarama.ProducerMessage, error) {
+	message := &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(event.ID()),
+	}
+	if structured {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("while encoding event: %s", err)
+		}
+		message.Value = sarama.ByteEncoder(value)
+		message.Headers = []sarama.RecordHeader{{Key: []byte("content-type"), Value: []byte(StructuredContentType)}}
+		return message, nil
+	}
+	data, err := event.DataBytes()
+	if err != nil {
+		return nil, fmt.Errorf("while reading event data: %s", err)
+	}
+	message.Value = sarama.ByteEncoder(data)
+	message.Headers = kafkaHeaders(event)
+	return message, nil
+}
+
+// kafkaHeaders maps the event attributes to headers following the CloudEvents kafka protocol binding
+func kafkaHeaders(event cloudevents.Event) []sarama.RecordHeader {
+	headers := []sarama.RecordHeader{
+		{Key: []byte(kafkaHeaderPrefix + "specversion"), Value: []byte(event.SpecVersion())},
+		{Key: []byte(kafkaHeaderPrefix + "id"), Value: []byte(event.ID())},
+		{Key: []byte(kafkaHeaderPrefix + "type"), Value: []byte(event.Type())},
+		{Key: []byte(kafkaHeaderPrefix + "source"), Value: []byte(event.Source())},
+		{Key: []byte(kafkaHeaderPrefix + "time"), Value: []byte(event.Time().UTC().Format(time.RFC3339Nano))},
+	}
+	if dataSchema := event.DataSchema(); dataSchema != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(kafkaHeaderPrefix + "dataschema"), Value: []byte(dataSchema)})
+	}
+	if contentType := event.DataContentType(); contentType != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte("content-type"), Value: []byte(contentType)})
+	}
+	for name, value := range event.Extensions() {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(kafkaHeaderPrefix + name), Value: []byte(fmt.Sprint(value))})
+	}
+	return headers
+}
+
+type staticTokenProvider string
+
+func (p staticTokenProvider) Token() (*sarama.AccessToken, error) {
+	return &sarama.AccessToken{Token: string(p)}, nil
+}
+
+func (s *KafkaSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.producer == nil {
+		return nil
+	}
+	return s.producer.Close()
+}
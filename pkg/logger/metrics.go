@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "kserve"
+	metricsSubsystem = "logger"
+
+	// drop reasons
+	DropReasonQueueFull        = "queue_full"
+	DropReasonRetriesExhausted = "retries_exhausted"
+	DropReasonSpoolFull        = "spool_full"
+	DropReasonShutdown         = "shutdown"
+)
+
+var (
+	sentTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "events_sent_total",
+		Help:      "Number of log events delivered to the log url.",
+	})
+	retriedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "events_retried_total",
+		Help:      "Number of retried log event deliveries.",
+	})
+	droppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "events_dropped_total",
+		Help:      "Number of log events dropped by reason.",
+	}, []string{"reason"})
+	spooledTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "events_spooled_total",
+		Help:      "Number of undelivered log events written to the spool dir.",
+	})
+)
+
+// RegisterMetrics registers the logger metrics with the given registerer
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{sentTotal, retriedTotal, droppedTotal, spooledTotal} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gstorage "cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	guuid "github.com/google/uuid"
+	gcscredential "github.com/kserve/kserve/pkg/credentials/gcs"
+	s3credential "github.com/kserve/kserve/pkg/credentials/s3"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+)
+
+const (
+	// ObjectBatchSizeParam is the number of events buffered before they are uploaded as one object
+	ObjectBatchSizeParam = "batchSize"
+	// ObjectFlushIntervalParam is the max time events are buffered before they are uploaded, e.g. 30s
+	ObjectFlushIntervalParam = "flushInterval"
+
+	DefaultObjectBatchSize     = 1000
+	DefaultObjectFlushInterval = 60 * time.Second
+)
+
+// ObjectUploader uploads an object to a bucket
+type ObjectUploader interface {
+	Upload(ctx context.Context, bucket string, key string, body []byte) error
+}
+
+// ObjectSink buffers structured mode CloudEvents as json lines and uploads them in batches to S3 or GCS
+// compatible object storage, the url has the form s3://bucket/prefix or gs://bucket/prefix. Each batch is
+// written to <prefix>/<yyyy>/<mm>/<dd>/<timestamp>-<uuid>.jsonl.
+type ObjectSink struct {
+	Log           *zap.SugaredLogger
+	bucket        string
+	prefix        string
+	uploader      ObjectUploader
+	batchSize     int
+	flushInterval time.Duration
+	eventConfig   EventConfig
+	mu            sync.Mutex
+	buffer        bytes.Buffer
+	count         int
+	quit          chan struct{}
+	done          chan struct{}
+}
+
+var _ Sink = (*ObjectSink)(nil)
+
+func NewObjectSink(logUrl *url.URL, eventConfig EventConfig, logger *zap.SugaredLogger) (*ObjectSink, error) {
+	var uploader ObjectUploader
+	var err error
+	switch logUrl.Scheme {
+	case S3Scheme:
+		uploader, err = newS3Uploader()
+	case GCSScheme:
+		uploader, err = newGCSUploader()
+	default:
+		err = fmt.Errorf("unsupported object storage scheme %q", logUrl.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewObjectSinkWithUploader(logUrl, uploader, eventConfig, logger)
+}
+
+// NewObjectSinkWithUploader creates an object sink which uploads the batches with the given uploader
+func NewObjectSinkWithUploader(logUrl *url.URL, uploader ObjectUploader, eventConfig EventConfig,
+	logger *zap.SugaredLogger) (*ObjectSink, error) {
+	if logUrl.Host == "" {
+		return nil, fmt.Errorf("no bucket in log url %s", logUrl.String())
+	}
+	sink := &ObjectSink{
+		Log:           logger,
+		bucket:        logUrl.Host,
+		prefix:        strings.Trim(logUrl.Path, "/"),
+		uploader:      uploader,
+		batchSize:     DefaultObjectBatchSize,
+		flushInterval: DefaultObjectFlushInterval,
+		eventConfig:   eventConfig,
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	query := logUrl.Query()
+	if value := query.Get(ObjectBatchSizeParam); value != "" {
+		batchSize, err := strconv.Atoi(value)
+		if err != nil || batchSize <= 0 {
+			return nil, fmt.Errorf("invalid %s %q in log url", ObjectBatchSizeParam, value)
+		}
+		sink.batchSize = batchSize
+	}
+	if value := query.Get(ObjectFlushIntervalParam); value != "" {
+		flushInterval, err := time.ParseDuration(value)
+		if err != nil || flushInterval <= 0 {
+			return nil, fmt.Errorf("invalid %s %q in log url", ObjectFlushIntervalParam, value)
+		}
+		sink.flushInterval = flushInterval
+	}
+	go sink.flushLoop()
+	return sink, nil
+}
+
+func (s *ObjectSink) flushLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if err := s.flush(); err != nil {
+				s.Log.Errorf("Failed to upload log batch to %s: %v", s.bucket, err)
+			}
+			s.mu.Unlock()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// flush uploads the buffered events, the caller must hold the lock
+func (s *ObjectSink) flush() error {
+	if s.count == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	key := path.Join(s.prefix, now.Format("2006/01/02"),
+		fmt.Sprintf("%d-%s.jsonl", now.UnixNano(), guuid.New().String()))
+	body := make([]byte, s.buffer.Len())
+	copy(body, s.buffer.Bytes())
+	s.buffer.Reset()
+	s.count = 0
+	if err := s.uploader.Upload(context.Background(), s.bucket, key, body); err != nil {
+		return fmt.Errorf("while uploading %s: %s", key, err)
+	}
+	return nil
+}
+
+func (s *ObjectSink) Send(logReq LogRequest) error {
+	event, err := toCloudEvent(logReq, s.eventConfig)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("while encoding event: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffer.Write(line)
+	s.buffer.WriteByte('\n')
+	s.count++
+	if s.count >= s.batchSize {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *ObjectSink) Close() error {
+	close(s.quit)
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+type s3Uploader struct {
+	uploader s3manageriface.UploaderAPI
+}
+
+func newS3Uploader() (*s3Uploader, error) {
+	region, _ := os.LookupEnv(s3credential.AWSRegion)
+	useVirtualBucket := true
+	if value, ok := os.LookupEnv(s3credential.S3UseVirtualBucket); ok && strings.ToLower(value) == "false" {
+		useVirtualBucket = false
+	}
+	awsConfig := aws.Config{
+		Region:           aws.String(region),
+		S3ForcePathStyle: aws.Bool(!useVirtualBucket),
+	}
+	if endpoint, ok := os.LookupEnv(s3credential.AWSEndpointUrl); ok {
+		awsConfig.Endpoint = aws.String(endpoint)
+	}
+	if useAnonCred, ok := os.LookupEnv(s3credential.AWSAnonymousCredential); ok && strings.ToLower(useAnonCred) == "true" {
+		awsConfig.Credentials = credentials.AnonymousCredentials
+	}
+	sess, err := session.NewSession(&awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("while creating s3 session: %s", err)
+	}
+	return &s3Uploader{
+		uploader: s3manager.NewUploader(sess),
+	}, nil
+}
+
+func (u *s3Uploader) Upload(ctx context.Context, bucket string, key string, body []byte) error {
+	_, err := u.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	return err
+}
+
+type gcsUploader struct {
+	client *gstorage.Client
+}
+
+func newGCSUploader() (*gcsUploader, error) {
+	var client *gstorage.Client
+	var err error
+	ctx := context.Background()
+	if _, ok := os.LookupEnv(gcscredential.GCSCredentialEnvKey); ok {
+		// picks up the service account key from GOOGLE_APPLICATION_CREDENTIALS
+		client, err = gstorage.NewClient(ctx)
+	} else {
+		client, err = gstorage.NewClient(ctx, option.WithoutAuthentication())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while creating gcs client: %s", err)
+	}
+	return &gcsUploader{
+		client: client,
+	}, nil
+}
+
+func (u *gcsUploader) Upload(ctx context.Context, bucket string, key string, body []byte) error {
+	writer := u.client.Bucket(bucket).Object(key).NewWriter(ctx)
+	writer.ContentType = "application/x-ndjson"
+	if _, err := writer.Write(body); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
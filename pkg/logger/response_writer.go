@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// teeResponseWriter streams the response to the client while capturing up to limit bytes of the body for
+// logging. Headers and trailers are written straight to the wrapped writer so they all reach the client.
+type teeResponseWriter struct {
+	http.ResponseWriter
+	limit       int64
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+	truncated   bool
+}
+
+var (
+	_ http.Flusher  = (*teeResponseWriter)(nil)
+	_ http.Hijacker = (*teeResponseWriter)(nil)
+)
+
+func newTeeResponseWriter(w http.ResponseWriter, limit int64) *teeResponseWriter {
+	return &teeResponseWriter{
+		ResponseWriter: w,
+		limit:          limit,
+		status:         http.StatusOK,
+	}
+}
+
+func (w *teeResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = code
+	// informational responses are followed by the final response header
+	if code >= http.StatusOK || code == http.StatusSwitchingProtocols {
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *teeResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if room := w.limit - int64(w.body.Len()); room > 0 {
+		if int64(len(b)) > room {
+			w.body.Write(b[:room])
+			w.truncated = true
+		} else {
+			w.body.Write(b)
+		}
+	} else if len(b) > 0 {
+		w.truncated = true
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client so that streamed responses are not held back by the logger
+func (w *teeResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *teeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer %T does not support hijacking", w.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *teeResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestTeeResponseWriter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scenarios := map[string]struct {
+		limit     int64
+		writes    []string
+		status    int
+		captured  string
+		truncated bool
+	}{
+		"DefaultStatus": {
+			limit:    100,
+			writes:   []string{"hello", " world"},
+			status:   http.StatusOK,
+			captured: "hello world",
+		},
+		"TruncatedWithinWrite": {
+			limit:     8,
+			writes:    []string{"hello", " world"},
+			status:    http.StatusOK,
+			captured:  "hello wo",
+			truncated: true,
+		},
+		"TruncatedAtWriteBoundary": {
+			limit:     5,
+			writes:    []string{"hello", " world"},
+			status:    http.StatusOK,
+			captured:  "hello",
+			truncated: true,
+		},
+		"ErrorStatus": {
+			limit:    100,
+			writes:   []string{"model not ready"},
+			status:   http.StatusServiceUnavailable,
+			captured: "model not ready",
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tee := newTeeResponseWriter(rr, scenario.limit)
+			tee.Header().Set("X-Model-Id", "mymodel")
+			if scenario.status != http.StatusOK {
+				tee.WriteHeader(scenario.status)
+			}
+			full := ""
+			for _, write := range scenario.writes {
+				_, err := tee.Write([]byte(write))
+				g.Expect(err).To(gomega.BeNil())
+				full += write
+			}
+			tee.Flush()
+			g.Expect(tee.status).To(gomega.Equal(scenario.status))
+			g.Expect(tee.body.String()).To(gomega.Equal(scenario.captured))
+			g.Expect(tee.truncated).To(gomega.Equal(scenario.truncated))
+			// the client always gets the whole response
+			g.Expect(rr.Code).To(gomega.Equal(scenario.status))
+			g.Expect(rr.Body.String()).To(gomega.Equal(full))
+			g.Expect(rr.Header().Get("X-Model-Id")).To(gomega.Equal("mymodel"))
+			g.Expect(rr.Flushed).To(gomega.BeTrue())
+		})
+	}
+}
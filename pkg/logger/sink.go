@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go"
+	"go.uber.org/zap"
+)
+
+// Sink delivers log requests to a destination
+type Sink interface {
+	// Send delivers a single log request
+	Send(logReq LogRequest) error
+	// Close flushes any buffered log requests and releases the sink
+	Close() error
+}
+
+const (
+	HttpScheme  = "http"
+	HttpsScheme = "https"
+	KafkaScheme = "kafka"
+	FileScheme  = "file"
+	S3Scheme    = "s3"
+	GCSScheme   = "gs"
+)
+
+// NewSink creates the sink for the log url, the sink is selected by the url scheme. The credentials are used
+// by the http and kafka sinks and may be nil, the event config sets the CloudEvents the sink sends.
+func NewSink(logUrl *url.URL, credentials *Credentials, eventConfig EventConfig,
+	logger *zap.SugaredLogger) (Sink, error) {
+	switch logUrl.Scheme {
+	case HttpScheme, HttpsScheme:
+		return NewCloudEventsSink(logUrl, credentials, eventConfig)
+	case KafkaScheme:
+		return NewKafkaSink(logUrl, credentials, eventConfig)
+	case FileScheme:
+		return NewFileSink(logUrl, eventConfig)
+	case S3Scheme, GCSScheme:
+		return NewObjectSink(logUrl, eventConfig, logger)
+	default:
+		return nil, fmt.Errorf("unsupported log url scheme %q", logUrl.Scheme)
+	}
+}
+
+// sinkCache keeps one sink per log url so that connections and buffers are shared by the workers
+type sinkCache struct {
+	credentials *Credentials
+	eventConfig EventConfig
+	logger      *zap.SugaredLogger
+	mu          sync.Mutex
+	sinks       map[string]Sink
+}
+
+func newSinkCache(credentials *Credentials, eventConfig EventConfig, logger *zap.SugaredLogger) *sinkCache {
+	return &sinkCache{
+		credentials: credentials,
+		eventConfig: eventConfig,
+		logger:      logger,
+		sinks:       map[string]Sink{},
+	}
+}
+
+// get returns the sink for the log url, creating it on first use
+func (c *sinkCache) get(logUrl *url.URL) (Sink, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sink, ok := c.sinks[logUrl.String()]; ok {
+		return sink, nil
+	}
+	sink, err := NewSink(logUrl, c.credentials, c.eventConfig, c.logger)
+	if err != nil {
+		return nil, err
+	}
+	c.sinks[logUrl.String()] = sink
+	return sink, nil
+}
+
+// close flushes and closes all the cached sinks
+func (c *sinkCache) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for key, sink := range c.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("while closing sink %s: %s", key, err))
+		}
+		delete(c.sinks, key)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+func toCloudEvent(logReq LogRequest, config EventConfig) (cloudevents.Event, error) {
+	event := cloudevents.NewEvent(cloudevents.VersionV1)
+	event.SetID(logReq.Id)
+	event.SetTime(time.Now())
+	switch logReq.ReqType {
+	case InferenceRequest:
+		event.SetType(config.RequestType)
+	case InferenceEnvelope:
+		event.SetType(config.EnvelopeType)
+	default:
+		event.SetType(config.ResponseType)
+	}
+
+	event.SetExtension(InferenceServiceAttr, logReq.InferenceService)
+	event.SetExtension(NamespaceAttr, logReq.Namespace)
+	event.SetExtension(ComponentAttr, logReq.Component)
+	event.SetExtension(EndpointAttr, logReq.Endpoint)
+	if logReq.ModelName != "" {
+		event.SetExtension(ModelNameAttr, logReq.ModelName)
+	}
+	if logReq.ModelVersion != "" {
+		event.SetExtension(ModelVersionAttr, logReq.ModelVersion)
+	}
+	if logReq.Path != "" {
+		event.SetExtension(PathAttr, logReq.Path)
+	}
+	if logReq.InferenceGraph != "" {
+		event.SetExtension(InferenceGraphAttr, logReq.InferenceGraph)
+	}
+	if logReq.NodeName != "" {
+		event.SetExtension(NodeNameAttr, logReq.NodeName)
+	}
+	if logReq.StepName != "" {
+		event.SetExtension(StepNameAttr, logReq.StepName)
+	}
+	if logReq.ReqType == InferenceResponse || (logReq.ReqType == InferenceEnvelope && logReq.StatusCode != 0) {
+		event.SetExtension(StatusCodeAttr, logReq.StatusCode)
+		event.SetExtension(LatencyMsAttr, logReq.Latency.Milliseconds())
+	}
+
+	if config.Source != "" {
+		event.SetSource(config.Source)
+	} else {
+		event.SetSource(logReq.SourceUri.String())
+	}
+	if config.DataSchema != "" {
+		event.SetDataSchema(config.DataSchema)
+	}
+	if logReq.ContentType != "" {
+		event.SetDataContentType(logReq.ContentType)
+	}
+	if err := event.SetData(*logReq.Bytes); err != nil {
+		return event, fmt.Errorf("while setting cloudevents data: %s", err)
+	}
+	// json payloads are embedded as json rather than base64 in structured mode events, the bytes are kept as
+	// they are so binary mode events are unchanged
+	if strings.HasSuffix(event.DataMediaType(), "json") && json.Valid(*logReq.Bytes) {
+		event.DataBinary = false
+	}
+	return event, nil
+}
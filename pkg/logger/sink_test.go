@@ -0,0 +1,332 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/onsi/gomega"
+	pkglogging "knative.dev/pkg/logging"
+)
+
+func testLogRequest(id string, reqType LogRequestType, body string) LogRequest {
+	bytes := []byte(body)
+	sourceUri, _ := url.Parse("http://localhost:9081/")
+	return LogRequest{
+		Bytes:            &bytes,
+		ContentType:      "application/json",
+		ReqType:          reqType,
+		Id:               id,
+		SourceUri:        sourceUri,
+		InferenceService: "mymodel",
+		Namespace:        "default",
+		Component:        "predictor",
+		Endpoint:         "default",
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+	scenarios := map[string]struct {
+		url      string
+		expected interface{}
+		err      bool
+	}{
+		"http":              {url: "http://logger.default", expected: &CloudEventsSink{}},
+		"https":             {url: "https://logger.default", expected: &CloudEventsSink{}},
+		"file":              {url: "file://" + filepath.Join(t.TempDir(), "log.jsonl"), expected: &FileSink{}},
+		"s3":                {url: "s3://bucket/logs", expected: &ObjectSink{}},
+		"kafka":             {url: "kafka://localhost:9092/inference-logs", expected: &KafkaSink{}},
+		"kafkaWithoutTopic": {url: "kafka://localhost:9092", err: true},
+		"fileBadMaxBytes":   {url: "file:///tmp/log.jsonl?maxBytes=abc", err: true},
+		"s3BadBatchSize":    {url: "s3://bucket/logs?batchSize=0", err: true},
+		"unsupported":       {url: "ftp://logger.default", err: true},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			logUrl, err := url.Parse(scenario.url)
+			g.Expect(err).To(gomega.BeNil())
+			sink, err := NewSink(logUrl, nil, DefaultEventConfig(), logger)
+			if scenario.err {
+				g.Expect(err).NotTo(gomega.BeNil())
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(sink).To(gomega.BeAssignableToTypeOf(scenario.expected))
+			g.Expect(sink.Close()).To(gomega.Succeed())
+		})
+	}
+}
+
+func TestKafkaSink(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("inference-logs", 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3).
+			SetError("inference-logs", 0, sarama.ErrNoError),
+	})
+
+	logUrl, err := url.Parse(fmt.Sprintf("kafka://%s/inference-logs", broker.Addr()))
+	g.Expect(err).To(gomega.BeNil())
+	sink, err := NewKafkaSink(logUrl, nil, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	defer sink.Close()
+
+	g.Expect(sink.Send(testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`))).To(gomega.Succeed())
+	produced := false
+	for _, item := range broker.History() {
+		if _, ok := item.Request.(*sarama.ProduceRequest); ok {
+			produced = true
+		}
+	}
+	g.Expect(produced).To(gomega.BeTrue())
+}
+
+func TestKafkaSinkConnectsLazily(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	// the broker is not listening when the sink is created and on the first send
+	broker := sarama.NewMockBroker(t, 1)
+	addr := broker.Addr()
+	broker.Close()
+
+	logUrl, err := url.Parse(fmt.Sprintf("kafka://%s/inference-logs", addr))
+	g.Expect(err).To(gomega.BeNil())
+	sink, err := NewKafkaSink(logUrl, nil, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	defer sink.Close()
+	sink.config.Metadata.Retry.Max = 0
+
+	logReq := testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`)
+	g.Expect(sink.Send(logReq)).NotTo(gomega.Succeed())
+	g.Expect(sink.connectAt).To(gomega.BeTemporally(">", time.Now()))
+	g.Expect(sink.connectBackoff).To(gomega.Equal(2 * kafkaConnectBackoff))
+	// no connection is attempted again before the backoff is over
+	err = sink.Send(logReq)
+	g.Expect(err).NotTo(gomega.BeNil())
+	g.Expect(err.Error()).To(gomega.ContainSubstring("unavailable"))
+
+	broker = sarama.NewMockBrokerAddr(t, 1, addr)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("inference-logs", 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3).
+			SetError("inference-logs", 0, sarama.ErrNoError),
+	})
+	sink.mu.Lock()
+	sink.connectAt = time.Time{}
+	sink.mu.Unlock()
+	g.Expect(sink.Send(logReq)).To(gomega.Succeed())
+	g.Expect(sink.connectBackoff).To(gomega.Equal(kafkaConnectBackoff))
+}
+
+func TestKafkaHeaders(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logReq := testLogRequest("1", InferenceResponse, `{"predictions":[1]}`)
+	logReq.ModelName = "mymodel"
+	logReq.StatusCode = 200
+	logReq.Latency = 42 * time.Millisecond
+	event, err := toCloudEvent(logReq, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	headers := map[string]string{}
+	for _, header := range kafkaHeaders(event) {
+		headers[string(header.Key)] = string(header.Value)
+	}
+	g.Expect(headers).To(gomega.HaveKeyWithValue("ce_id", "1"))
+	g.Expect(headers).To(gomega.HaveKeyWithValue("ce_specversion", "1.0"))
+	g.Expect(headers).To(gomega.HaveKeyWithValue("ce_type", CEInferenceResponse))
+	g.Expect(headers).To(gomega.HaveKeyWithValue("ce_source", "http://localhost:9081/"))
+	g.Expect(headers).To(gomega.HaveKeyWithValue("ce_"+InferenceServiceAttr, "mymodel"))
+	g.Expect(headers).To(gomega.HaveKeyWithValue("content-type", "application/json"))
+	g.Expect(headers).To(gomega.HaveKeyWithValue("ce_"+ModelNameAttr, "mymodel"))
+	g.Expect(headers).To(gomega.HaveKeyWithValue("ce_"+StatusCodeAttr, "200"))
+	g.Expect(headers).To(gomega.HaveKeyWithValue("ce_"+LatencyMsAttr, "42"))
+	g.Expect(headers).To(gomega.HaveKey("ce_time"))
+}
+
+func TestGraphAttributes(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logReq := testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`)
+	event, err := toCloudEvent(logReq, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(event.Extensions()).NotTo(gomega.HaveKey(InferenceGraphAttr))
+	g.Expect(event.Extensions()).NotTo(gomega.HaveKey(NodeNameAttr))
+	g.Expect(event.Extensions()).NotTo(gomega.HaveKey(StepNameAttr))
+
+	logReq.InferenceGraph = "mygraph"
+	logReq.NodeName = "root"
+	logReq.StepName = "step1"
+	event, err = toCloudEvent(logReq, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(event.Extensions()).To(gomega.HaveKeyWithValue(InferenceGraphAttr, "mygraph"))
+	g.Expect(event.Extensions()).To(gomega.HaveKeyWithValue(NodeNameAttr, "root"))
+	g.Expect(event.Extensions()).To(gomega.HaveKeyWithValue(StepNameAttr, "step1"))
+}
+
+func TestKafkaMessage(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	event, err := toCloudEvent(testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`), DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+
+	binary, err := kafkaMessage("inference-logs", event, false)
+	g.Expect(err).To(gomega.BeNil())
+	key, _ := binary.Key.Encode()
+	g.Expect(string(key)).To(gomega.Equal("1"))
+	value, _ := binary.Value.Encode()
+	g.Expect(string(value)).To(gomega.Equal(`{"instances":[[1,2,3]]}`))
+
+	structured, err := kafkaMessage("inference-logs", event, true)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(structured.Headers).To(gomega.ConsistOf(sarama.RecordHeader{Key: []byte("content-type"), Value: []byte(StructuredContentType)}))
+	value, _ = structured.Value.Encode()
+	decoded := map[string]interface{}{}
+	g.Expect(json.Unmarshal(value, &decoded)).To(gomega.Succeed())
+	g.Expect(decoded).To(gomega.HaveKeyWithValue("id", "1"))
+	g.Expect(decoded).To(gomega.HaveKeyWithValue("type", CEInferenceRequest))
+	g.Expect(decoded).To(gomega.HaveKey("data"))
+}
+
+func readLines(g *gomega.WithT, path string) []map[string]interface{} {
+	file, err := os.Open(path)
+	g.Expect(err).To(gomega.BeNil())
+	defer file.Close()
+	lines := make([]map[string]interface{}, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		g.Expect(json.Unmarshal(scanner.Bytes(), &line)).To(gomega.Succeed())
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	path := filepath.Join(t.TempDir(), "logs", "payloads.jsonl")
+	logUrl, err := url.Parse(fmt.Sprintf("file://%s?maxBytes=600&maxBackups=2", path))
+	g.Expect(err).To(gomega.BeNil())
+	sink, err := NewFileSink(logUrl, DefaultEventConfig())
+	g.Expect(err).To(gomega.BeNil())
+
+	for i := 0; i < 6; i++ {
+		g.Expect(sink.Send(testLogRequest(fmt.Sprint(i), InferenceRequest, `{"instances":[[1,2,3]]}`))).To(gomega.Succeed())
+	}
+	g.Expect(sink.Close()).To(gomega.Succeed())
+
+	g.Expect(path + ".1").To(gomega.BeAnExistingFile())
+	g.Expect(path + ".2").To(gomega.BeAnExistingFile())
+	g.Expect(path + ".3").NotTo(gomega.BeAnExistingFile())
+	for _, file := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(file)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(info.Size()).To(gomega.BeNumerically("<=", 600))
+	}
+	lines := readLines(g, path)
+	g.Expect(lines).NotTo(gomega.BeEmpty())
+	last := lines[len(lines)-1]
+	g.Expect(last["id"]).To(gomega.Equal("5"))
+	g.Expect(last["type"]).To(gomega.Equal(CEInferenceRequest))
+	g.Expect(last[InferenceServiceAttr]).To(gomega.Equal("mymodel"))
+}
+
+type fakeUploader struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (u *fakeUploader) Upload(ctx context.Context, bucket string, key string, body []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.objects[bucket+"/"+key] = body
+	return nil
+}
+
+func (u *fakeUploader) keys() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	keys := make([]string, 0, len(u.objects))
+	for key := range u.objects {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestObjectSink(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	uploader := &fakeUploader{objects: map[string][]byte{}}
+	logUrl, err := url.Parse("s3://bucket/inference/logs?batchSize=2&flushInterval=1h")
+	g.Expect(err).To(gomega.BeNil())
+	sink, err := NewObjectSinkWithUploader(logUrl, uploader, DefaultEventConfig(), logger)
+	g.Expect(err).To(gomega.BeNil())
+
+	// a full batch is uploaded straight away
+	g.Expect(sink.Send(testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`))).To(gomega.Succeed())
+	g.Expect(uploader.keys()).To(gomega.BeEmpty())
+	g.Expect(sink.Send(testLogRequest("1", InferenceResponse, `{"predictions":[1]}`))).To(gomega.Succeed())
+	g.Expect(uploader.keys()).To(gomega.HaveLen(1))
+	key := uploader.keys()[0]
+	g.Expect(key).To(gomega.HavePrefix("bucket/inference/logs/" + time.Now().UTC().Format("2006/01/02") + "/"))
+	g.Expect(key).To(gomega.HaveSuffix(".jsonl"))
+	g.Expect(bytes.Count(uploader.objects[key], []byte("\n"))).To(gomega.Equal(2))
+
+	// the remaining events are uploaded on close
+	g.Expect(sink.Send(testLogRequest("2", InferenceRequest, `{"instances":[[1,2,3]]}`))).To(gomega.Succeed())
+	g.Expect(sink.Close()).To(gomega.Succeed())
+	g.Expect(uploader.keys()).To(gomega.HaveLen(2))
+}
+
+func TestObjectSinkFlushInterval(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger, _ := pkglogging.NewLogger("", "INFO")
+
+	uploader := &fakeUploader{objects: map[string][]byte{}}
+	logUrl, err := url.Parse("gs://bucket?flushInterval=50ms")
+	g.Expect(err).To(gomega.BeNil())
+	sink, err := NewObjectSinkWithUploader(logUrl, uploader, DefaultEventConfig(), logger)
+	g.Expect(err).To(gomega.BeNil())
+	defer sink.Close()
+
+	g.Expect(sink.Send(testLogRequest("1", InferenceRequest, `{"instances":[[1,2,3]]}`))).To(gomega.Succeed())
+	g.Eventually(uploader.keys).Should(gomega.HaveLen(1))
+	g.Expect(strings.HasPrefix(uploader.keys()[0], "bucket/")).To(gomega.BeTrue())
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	guuid "github.com/google/uuid"
+)
+
+const (
+	spoolFileSuffix = ".json"
+	spoolTmpSuffix  = ".tmp"
+)
+
+var ErrSpoolFull = errors.New("spool is full")
+
+// Spool persists log requests which could not be delivered so that they can be resent later, each log
+// request is written to its own file and the files are replayed in the order they were written.
+type Spool struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+	size     int64
+}
+
+type spoolEntry struct {
+	Url              string         `json:"url"`
+	Bytes            []byte         `json:"bytes"`
+	ContentType      string         `json:"contentType,omitempty"`
+	ReqType          LogRequestType `json:"reqType"`
+	Id               string         `json:"id"`
+	SourceUri        string         `json:"sourceUri"`
+	InferenceService string         `json:"inferenceService,omitempty"`
+	Namespace        string         `json:"namespace,omitempty"`
+	Component        string         `json:"component,omitempty"`
+	Endpoint         string         `json:"endpoint,omitempty"`
+	ModelName        string         `json:"modelName,omitempty"`
+	ModelVersion     string         `json:"modelVersion,omitempty"`
+	Path             string         `json:"path,omitempty"`
+	InferenceGraph   string         `json:"inferenceGraph,omitempty"`
+	NodeName         string         `json:"nodeName,omitempty"`
+	StepName         string         `json:"stepName,omitempty"`
+	StatusCode       int            `json:"statusCode,omitempty"`
+	Latency          time.Duration  `json:"latency,omitempty"`
+}
+
+// NewSpool creates the spool dir if needed, a maxBytes of 0 means the spool is unbounded
+func NewSpool(dir string, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("while creating spool dir: %s", err)
+	}
+	// clean up files left over by an agent which stopped while writing to the spool
+	if tmpFiles, err := filepath.Glob(filepath.Join(dir, "*"+spoolTmpSuffix)); err == nil {
+		for _, tmpFile := range tmpFiles {
+			os.Remove(tmpFile)
+		}
+	}
+	spool := &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}
+	files, err := spool.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			spool.size += info.Size()
+		}
+	}
+	return spool, nil
+}
+
+// files lists the spooled files, oldest first
+func (s *Spool) files() ([]string, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("while reading spool dir: %s", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), spoolFileSuffix) {
+			continue
+		}
+		files = append(files, filepath.Join(s.dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Put writes the log request to the spool
+func (s *Spool) Put(logReq LogRequest) error {
+	entry := spoolEntry{
+		ContentType:      logReq.ContentType,
+		ReqType:          logReq.ReqType,
+		Id:               logReq.Id,
+		InferenceService: logReq.InferenceService,
+		Namespace:        logReq.Namespace,
+		Component:        logReq.Component,
+		Endpoint:         logReq.Endpoint,
+		ModelName:        logReq.ModelName,
+		ModelVersion:     logReq.ModelVersion,
+		Path:             logReq.Path,
+		InferenceGraph:   logReq.InferenceGraph,
+		NodeName:         logReq.NodeName,
+		StepName:         logReq.StepName,
+		StatusCode:       logReq.StatusCode,
+		Latency:          logReq.Latency,
+	}
+	if logReq.Url != nil {
+		entry.Url = logReq.Url.String()
+	}
+	if logReq.SourceUri != nil {
+		entry.SourceUri = logReq.SourceUri.String()
+	}
+	if logReq.Bytes != nil {
+		entry.Bytes = *logReq.Bytes
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("while encoding spool entry: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxBytes > 0 && s.size+int64(len(data)) > s.maxBytes {
+		return ErrSpoolFull
+	}
+	// the zero padded timestamp keeps the file names in write order
+	name := fmt.Sprintf("%020d-%s", time.Now().UnixNano(), guuid.New().String())
+	tmpFile := filepath.Join(s.dir, name+spoolTmpSuffix)
+	if err := ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("while writing spool file: %s", err)
+	}
+	if err := os.Rename(tmpFile, filepath.Join(s.dir, name+spoolFileSuffix)); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("while writing spool file: %s", err)
+	}
+	s.size += int64(len(data))
+	return nil
+}
+
+// Replay resends the spooled log requests oldest first and removes the ones sent successfully, it stops at
+// the first failure so that the remaining requests are retried on the next replay.
+func (s *Spool) Replay(send func(LogRequest) error) (int, error) {
+	s.mu.Lock()
+	files, err := s.files()
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, file := range files {
+		logReq, size, err := readSpoolFile(file)
+		if err != nil {
+			// a corrupt entry can never be delivered so drop it instead of blocking the spool
+			s.remove(file, size)
+			return sent, err
+		}
+		if err := send(logReq); err != nil {
+			return sent, err
+		}
+		s.remove(file, size)
+		sent++
+	}
+	return sent, nil
+}
+
+func (s *Spool) remove(file string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(file); err == nil {
+		s.size -= size
+	}
+}
+
+func readSpoolFile(file string) (LogRequest, int64, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return LogRequest{}, 0, fmt.Errorf("while reading spool file %s: %s", file, err)
+	}
+	size := int64(len(data))
+	entry := spoolEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return LogRequest{}, size, fmt.Errorf("while decoding spool file %s: %s", file, err)
+	}
+	logUrl, err := url.Parse(entry.Url)
+	if err != nil {
+		return LogRequest{}, size, fmt.Errorf("while parsing log url in spool file %s: %s", file, err)
+	}
+	sourceUri, err := url.Parse(entry.SourceUri)
+	if err != nil {
+		return LogRequest{}, size, fmt.Errorf("while parsing source uri in spool file %s: %s", file, err)
+	}
+	return LogRequest{
+		Url:              logUrl,
+		Bytes:            &entry.Bytes,
+		ContentType:      entry.ContentType,
+		ReqType:          entry.ReqType,
+		Id:               entry.Id,
+		SourceUri:        sourceUri,
+		InferenceService: entry.InferenceService,
+		Namespace:        entry.Namespace,
+		Component:        entry.Component,
+		Endpoint:         entry.Endpoint,
+		ModelName:        entry.ModelName,
+		ModelVersion:     entry.ModelVersion,
+		Path:             entry.Path,
+		InferenceGraph:   entry.InferenceGraph,
+		NodeName:         entry.NodeName,
+		StepName:         entry.StepName,
+		StatusCode:       entry.StatusCode,
+		Latency:          entry.Latency,
+	}, size, nil
+}
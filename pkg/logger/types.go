@@ -18,6 +18,7 @@ package logger
 
 import (
 	"net/url"
+	"time"
 )
 
 type LogRequestType string
@@ -25,6 +26,8 @@ type LogRequestType string
 const (
 	InferenceRequest  LogRequestType = "Request"
 	InferenceResponse LogRequestType = "Response"
+	// InferenceEnvelope holds both the request and the response of an inference
+	InferenceEnvelope LogRequestType = "Envelope"
 )
 
 type LogRequest struct {
@@ -38,4 +41,15 @@ type LogRequest struct {
 	Namespace        string
 	Component        string
 	Endpoint         string
+	ModelName        string
+	ModelVersion     string
+	Path             string
+	// InferenceGraph, NodeName and StepName are only set by the inference graph router, the node and step
+	// are empty for the events of the whole graph request
+	InferenceGraph string
+	NodeName       string
+	StepName       string
+	// StatusCode and Latency are only set for responses and envelopes
+	StatusCode int
+	Latency    time.Duration
 }
@@ -17,13 +17,7 @@ limitations under the License.
 package logger
 
 import (
-	"context"
-	"fmt"
-	"github.com/cloudevents/sdk-go"
-	"github.com/cloudevents/sdk-go/pkg/cloudevents/transport"
-	"go.uber.org/zap"
-	"net/http"
-	"time"
+	"errors"
 )
 
 const (
@@ -36,123 +30,62 @@ const (
 	NamespaceAttr        = "namespace"
 	ComponentAttr        = "component"
 	//endpoint would be either default or canary
-	EndpointAttr = "endpoint"
+	EndpointAttr     = "endpoint"
+	ModelNameAttr    = "modelname"
+	ModelVersionAttr = "modelversion"
+	PathAttr         = "path"
+	// graph attributes are set on the events of the inference graph router
+	InferenceGraphAttr = "inferencegraphname"
+	NodeNameAttr       = "nodename"
+	StepNameAttr       = "stepname"
+	// response only attributes
+	StatusCodeAttr = "statuscode"
+	LatencyMsAttr  = "latencyms"
 
 	LoggerWorkerQueueSize = 100
 	CloudEventsIdHeader   = "Ce-Id"
 )
 
-// A buffered channel that we can send work requests on.
-var WorkQueue = make(chan LogRequest, LoggerWorkerQueueSize)
+var ErrQueueFull = errors.New("log queue is full, dropped log request")
 
-func QueueLogRequest(req LogRequest) error {
-	WorkQueue <- req
-	return nil
-}
-
-// NewWorker creates, and returns a new Worker object. Its only argument
-// is a channel that the worker can add itself to whenever it is done its
-// work.
-func NewWorker(id int, workerQueue chan chan LogRequest, logger *zap.SugaredLogger) Worker {
+// NewWorker creates, and returns a new Worker object. The worker takes its
+// work from the dispatcher once it is started.
+func NewWorker(id int, dispatcher *Dispatcher) Worker {
 	// Create, and return the worker.
 	return Worker{
-		Log:         logger,
-		ID:          id,
-		Work:        make(chan LogRequest),
-		WorkerQueue: workerQueue,
-		QuitChan:    make(chan bool),
-		Client: http.Client{
-			Timeout: 60 * time.Second,
-		},
-		CeCtx: cloudevents.ContextWithEncoding(context.Background(), cloudevents.Binary),
+		ID:         id,
+		Work:       make(chan LogRequest),
+		dispatcher: dispatcher,
 	}
 }
 
 type Worker struct {
-	Log         *zap.SugaredLogger
-	ID          int
-	Work        chan LogRequest
-	WorkerQueue chan chan LogRequest
-	QuitChan    chan bool
-	Client      http.Client
-	CeCtx       context.Context
-	CeTransport transport.Transport
+	ID         int
+	Work       chan LogRequest
+	dispatcher *Dispatcher
 }
 
-func (w *Worker) sendCloudEvent(logReq LogRequest) error {
-	t, err := cloudevents.NewHTTPTransport(
-		cloudevents.WithTarget(logReq.Url.String()),
-		cloudevents.WithEncoding(cloudevents.HTTPBinaryV1),
-	)
-
-	if err != nil {
-		return fmt.Errorf("while creating http transport: %s", err)
-	}
-
-	c, err := cloudevents.NewClient(t,
-		cloudevents.WithTimeNow(),
-	)
-	if err != nil {
-		return fmt.Errorf("while creating new cloudevents client: %s", err)
-	}
-	event := cloudevents.NewEvent(cloudevents.VersionV1)
-	event.SetID(logReq.Id)
-	if logReq.ReqType == InferenceRequest {
-		event.SetType(CEInferenceRequest)
-	} else {
-		event.SetType(CEInferenceResponse)
-	}
-
-	event.SetExtension(InferenceServiceAttr, logReq.InferenceService)
-	event.SetExtension(NamespaceAttr, logReq.Namespace)
-	event.SetExtension(ComponentAttr, logReq.Component)
-	event.SetExtension(EndpointAttr, logReq.Endpoint)
-
-	event.SetSource(logReq.SourceUri.String())
-	if logReq.ContentType != "" {
-		event.SetDataContentType(logReq.ContentType)
-	}
-	if err := event.SetData(*logReq.Bytes); err != nil {
-		return fmt.Errorf("while setting cloudevents data: %s", err)
-	}
-
-	if _, _, err := c.Send(w.CeCtx, event); err != nil {
-		return fmt.Errorf("while sending event: %s", err)
-	}
-	return nil
-}
-
-// This function "starts" the worker by starting a goroutine, that is
-// an infinite "for-select" loop.
+// Start runs the worker until the dispatcher is stopped, it is meant to
+// be run in its own goroutine.
 func (w *Worker) Start() {
-	go func() {
-		for {
-			// Add ourselves into the worker queue.
-			w.WorkerQueue <- w.Work
-
-			select {
-			case work := <-w.Work:
-				// Receive a work request.
-				w.Log.Infof("Received work request %d, url: %s, requestId: %s", w.ID, work.Url.String(), work.Id)
-
-				if err := w.sendCloudEvent(work); err != nil {
-					w.Log.Error(err, "Failed to send cloud event, url: %s", work.Url.String())
-				}
-
-			case <-w.QuitChan:
-				// We have been asked to stop.
-				fmt.Printf("worker %d stopping\n", w.ID)
-				return
-			}
+	d := w.dispatcher
+	defer d.wg.Done()
+	for {
+		// Add ourselves into the worker queue, it has room for all the workers.
+		d.workerQueue <- w.Work
+
+		select {
+		case work := <-w.Work:
+			// Receive a work request.
+			d.log.Infof("Received work request %d, url: %s, requestId: %s", w.ID, work.Url.String(), work.Id)
+
+			d.deliver(work)
+			d.done()
+
+		case <-d.quit:
+			// We have been asked to stop.
+			d.log.Infof("Worker %d stopping", w.ID)
+			return
 		}
-	}()
-}
-
-// Stop tells the worker to stop listening for work requests.
-//
-// Note that the worker will only stop *after* it has finished its work.
-func (w *Worker) Stop() {
-	go func() {
-		w.QuitChan <- true
-	}()
+	}
 }
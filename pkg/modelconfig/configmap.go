@@ -34,6 +34,9 @@ var json = jsoniter.ConfigCompatibleWithStandardLibrary
 type ModelConfig struct {
 	Name string             `json:"modelName"`
 	Spec v1alpha1.ModelSpec `json:"modelSpec"`
+	// Retry is copied from the retry-load annotation of the TrainedModel, the agent downloads and loads the model
+	// again when it changes
+	Retry string `json:"retry,omitempty"`
 }
 
 type ModelConfigs []ModelConfig
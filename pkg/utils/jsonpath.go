@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed JSON path made of field names, array indexes and wildcards,
+// e.g. $.instances[*].ssn, $.inputs[0].data or $.parameters.*
+type JSONPath []jsonPathSegment
+
+type jsonPathSegment struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParseJSONPath parses a JSON path starting with $
+func ParseJSONPath(path string) (JSONPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("json path %q must start with $", path)
+	}
+	var segments JSONPath
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			field := rest[1 : end+1]
+			if field == "" {
+				return nil, fmt.Errorf("json path %q has an empty field name", path)
+			}
+			if field == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			} else {
+				segments = append(segments, jsonPathSegment{field: field})
+			}
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q has an unclosed [", path)
+			}
+			index := rest[1:end]
+			if index == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			} else {
+				i, err := strconv.Atoi(index)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("json path %q has an invalid array index %q", path, index)
+				}
+				segments = append(segments, jsonPathSegment{index: i, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("json path %q is invalid at %q", path, rest)
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("json path %q does not select a field", path)
+	}
+	return segments, nil
+}
+
+// Replace sets every value matched by the path in the decoded json document to value
+// and returns the number of replaced values
+func (p JSONPath) Replace(doc interface{}, value interface{}) int {
+	if len(p) == 0 {
+		return 0
+	}
+	segment, rest := p[0], p[1:]
+	replaced := 0
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if segment.isIndex {
+			return 0
+		}
+		for key, child := range node {
+			if !segment.wildcard && key != segment.field {
+				continue
+			}
+			if len(rest) == 0 {
+				node[key] = value
+				replaced++
+			} else {
+				replaced += rest.Replace(child, value)
+			}
+		}
+	case []interface{}:
+		if !segment.isIndex && !segment.wildcard {
+			return 0
+		}
+		for i, child := range node {
+			if segment.isIndex && i != segment.index {
+				continue
+			}
+			if len(rest) == 0 {
+				node[i] = value
+				replaced++
+			} else {
+				replaced += rest.Replace(child, value)
+			}
+		}
+	}
+	return replaced
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The KServe Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scenarios := map[string]struct {
+		path     string
+		expected JSONPath
+		err      bool
+	}{
+		"Field":         {path: "$.token", expected: JSONPath{{field: "token"}}},
+		"NestedField":   {path: "$.parameters.token", expected: JSONPath{{field: "parameters"}, {field: "token"}}},
+		"ArrayWildcard": {path: "$.instances[*].ssn", expected: JSONPath{{field: "instances"}, {wildcard: true}, {field: "ssn"}}},
+		"ArrayIndex":    {path: "$.inputs[0].data", expected: JSONPath{{field: "inputs"}, {index: 0, isIndex: true}, {field: "data"}}},
+		"FieldWildcard": {path: "$.parameters.*", expected: JSONPath{{field: "parameters"}, {wildcard: true}}},
+		"NoRoot":        {path: "instances", err: true},
+		"RootOnly":      {path: "$", err: true},
+		"EmptyField":    {path: "$..ssn", err: true},
+		"UnclosedIndex": {path: "$.instances[0", err: true},
+		"InvalidIndex":  {path: "$.instances[-1]", err: true},
+		"InvalidSyntax": {path: "$instances", err: true},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			path, err := ParseJSONPath(scenario.path)
+			if scenario.err {
+				g.Expect(err).NotTo(gomega.BeNil())
+				return
+			}
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(path).To(gomega.Equal(scenario.expected))
+		})
+	}
+}
+
+func TestJSONPathReplace(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scenarios := map[string]struct {
+		path     string
+		doc      string
+		expected string
+		replaced int
+	}{
+		"Field": {
+			path:     "$.parameters.token",
+			doc:      `{"parameters":{"token":"secret","temperature":1}}`,
+			expected: `{"parameters":{"temperature":1,"token":"***"}}`,
+			replaced: 1,
+		},
+		"ArrayWildcard": {
+			path:     "$.instances[*].ssn",
+			doc:      `{"instances":[{"ssn":"1","age":20},{"ssn":"2","age":30}]}`,
+			expected: `{"instances":[{"age":20,"ssn":"***"},{"age":30,"ssn":"***"}]}`,
+			replaced: 2,
+		},
+		"ArrayIndex": {
+			path:     "$.instances[1]",
+			doc:      `{"instances":[[1,2],[3,4]]}`,
+			expected: `{"instances":[[1,2],"***"]}`,
+			replaced: 1,
+		},
+		"NoMatch": {
+			path:     "$.instances[*].ssn",
+			doc:      `{"inputs":[{"ssn":"1"}]}`,
+			expected: `{"inputs":[{"ssn":"1"}]}`,
+			replaced: 0,
+		},
+		"TypeMismatch": {
+			path:     "$.instances.ssn",
+			doc:      `{"instances":[{"ssn":"1"}]}`,
+			expected: `{"instances":[{"ssn":"1"}]}`,
+			replaced: 0,
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			path, err := ParseJSONPath(scenario.path)
+			g.Expect(err).To(gomega.BeNil())
+			var doc interface{}
+			g.Expect(json.Unmarshal([]byte(scenario.doc), &doc)).To(gomega.Succeed())
+			g.Expect(path.Replace(doc, "***")).To(gomega.Equal(scenario.replaced))
+			actual, err := json.Marshal(doc)
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(string(actual)).To(gomega.MatchJSON(scenario.expected))
+		})
+	}
+}
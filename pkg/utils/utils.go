@@ -17,6 +17,8 @@ limitations under the License.
 package utils
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/kserve/kserve/pkg/constants"
@@ -137,3 +139,15 @@ func MergeEnvs(baseEnvs []v1.EnvVar, overrideEnvs []v1.EnvVar) []v1.EnvVar {
 
 	return append(baseEnvs, extra...)
 }
+
+// ParseSampleRate parses a logger sample rate such as "0.25", which must be a number between 0 and 1
+func ParseSampleRate(rate string) (float64, error) {
+	value, err := strconv.ParseFloat(rate, 64)
+	if err != nil {
+		return 0, fmt.Errorf("sample rate %q is not a number", rate)
+	}
+	if !(value >= 0 && value <= 1) {
+		return 0, fmt.Errorf("sample rate %q is not between 0 and 1", rate)
+	}
+	return value, nil
+}
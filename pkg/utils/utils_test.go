@@ -541,3 +541,33 @@ func TestIsPrefixSupported(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSampleRate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scenarios := map[string]struct {
+		input    string
+		expected float64
+		err      bool
+	}{
+		"Fraction":   {input: "0.25", expected: 0.25},
+		"Zero":       {input: "0", expected: 0},
+		"One":        {input: "1", expected: 1},
+		"Empty":      {input: "", err: true},
+		"NotANumber": {input: "half", err: true},
+		"Percent":    {input: "25%", err: true},
+		"AboveOne":   {input: "1.5", err: true},
+		"BelowZero":  {input: "-0.1", err: true},
+		"NaN":        {input: "NaN", err: true},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParseSampleRate(scenario.input)
+			if scenario.err {
+				g.Expect(err).Should(gomega.HaveOccurred())
+				return
+			}
+			g.Expect(err).ShouldNot(gomega.HaveOccurred())
+			g.Expect(res).Should(gomega.Equal(scenario.expected))
+		})
+	}
+}
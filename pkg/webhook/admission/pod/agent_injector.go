@@ -19,6 +19,8 @@ package pod
 import (
 	"encoding/json"
 	"fmt"
+	"path"
+	"strconv"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -31,14 +33,31 @@ import (
 )
 
 const (
-	LoggerConfigMapKeyName         = "logger"
-	LoggerArgumentLogUrl           = "--log-url"
-	LoggerArgumentSourceUri        = "--source-uri"
-	LoggerArgumentMode             = "--log-mode"
-	LoggerArgumentInferenceService = "--inference-service"
-	LoggerArgumentNamespace        = "--namespace"
-	LoggerArgumentEndpoint         = "--endpoint"
-	LoggerArgumentComponent        = "--component"
+	LoggerConfigMapKeyName          = "logger"
+	LoggerArgumentLogUrl            = "--log-url"
+	LoggerArgumentSourceUri         = "--source-uri"
+	LoggerArgumentMode              = "--log-mode"
+	LoggerArgumentInferenceService  = "--inference-service"
+	LoggerArgumentNamespace         = "--namespace"
+	LoggerArgumentEndpoint          = "--endpoint"
+	LoggerArgumentComponent         = "--component"
+	LoggerArgumentSampleRate        = "--log-sample-rate"
+	LoggerArgumentForceSampleHeader = "--log-force-sample-header"
+	LoggerArgumentMaxBodyBytes      = "--log-max-body-bytes"
+	LoggerArgumentRedactFields      = "--log-redact-fields"
+	LoggerArgumentQueueSize         = "--log-queue-size"
+	LoggerArgumentDropPolicy        = "--log-drop-policy"
+	LoggerArgumentMaxRetries        = "--log-max-retries"
+	LoggerArgumentSpoolDir          = "--log-spool-dir"
+	LoggerArgumentEncoding          = "--log-encoding"
+	LoggerArgumentRequestType       = "--log-request-type"
+	LoggerArgumentResponseType      = "--log-response-type"
+	LoggerArgumentDataSchema        = "--log-data-schema"
+	LoggerArgumentEnvelope          = "--log-envelope"
+	LoggerArgumentCredentialsDir    = "--log-credentials-dir"
+	LoggerSpoolVolumeName           = "kserve-logger-spool"
+	LoggerCredentialsVolumeName     = "kserve-logger-credentials"
+	LoggerCredentialsMountPath      = "/var/run/secrets/kserve/logger"
 )
 
 type AgentConfig struct {
@@ -56,6 +75,19 @@ type LoggerConfig struct {
 	MemoryRequest string `json:"memoryRequest"`
 	MemoryLimit   string `json:"memoryLimit"`
 	DefaultUrl    string `json:"defaultUrl"`
+	// log delivery settings, the agent defaults are used when not set
+	QueueSize  int    `json:"queueSize,omitempty"`
+	DropPolicy string `json:"dropPolicy,omitempty"`
+	MaxRetries *int   `json:"maxRetries,omitempty"`
+	// SpoolDir is backed by an emptyDir volume so undelivered log events survive agent restarts
+	SpoolDir string `json:"spoolDir,omitempty"`
+	// CloudEvents settings, Encoding is either binary or structured
+	Encoding          string `json:"encoding,omitempty"`
+	RequestEventType  string `json:"requestEventType,omitempty"`
+	ResponseEventType string `json:"responseEventType,omitempty"`
+	DataSchema        string `json:"dataSchema,omitempty"`
+	// Envelope logs the request and response of an inference as a single event
+	Envelope bool `json:"envelope,omitempty"`
 }
 
 type AgentInjector struct {
@@ -134,6 +166,7 @@ func (ag *AgentInjector) InjectAgent(pod *v1.Pod) error {
 	}
 
 	var args []string
+	watchModelConfigAPI := false
 	if injectPuller {
 		args = append(args, constants.AgentEnableFlag)
 		modelConfig, ok := pod.ObjectMeta.Annotations[constants.AgentModelConfigMountPathAnnotationKey]
@@ -147,6 +180,30 @@ func (ag *AgentInjector) InjectAgent(pod *v1.Pod) error {
 			args = append(args, constants.AgentModelDirArgName)
 			args = append(args, modelDir)
 		}
+
+		protocol, ok := pod.ObjectMeta.Annotations[constants.AgentModelServerProtocolAnnotationKey]
+		if ok {
+			args = append(args, constants.AgentModelServerProtocolArgName)
+			args = append(args, protocol)
+		}
+
+		endpoint, ok := pod.ObjectMeta.Annotations[constants.AgentModelServerEndpointAnnotationKey]
+		if ok {
+			args = append(args, constants.AgentModelServerEndpointArgName)
+			args = append(args, endpoint)
+		}
+
+		// the config dir stays mounted, the agent falls back to it when it is not allowed to watch the ConfigMap
+		modelConfigName, ok := pod.ObjectMeta.Annotations[constants.AgentModelConfigVolumeNameAnnotationKey]
+		if ok && pod.ObjectMeta.Annotations[constants.AgentModelConfigWatchAnnotationKey] == constants.AgentModelConfigWatchAPI {
+			args = append(args, constants.AgentModelConfigNameArgName)
+			args = append(args, modelConfigName)
+			watchModelConfigAPI = true
+		}
+
+		// the controller reads the model status from the management port of the pod
+		args = append(args, constants.AgentManagementPortArgName)
+		args = append(args, constants.AgentDefaultManagementPortStr)
 	}
 	// Only inject if the batcher required annotations are set
 	if injectBatcher {
@@ -196,6 +253,52 @@ func (ag *AgentInjector) InjectAgent(pod *v1.Pod) error {
 			LoggerArgumentComponent,
 			component,
 		}
+		payloadArgs := []struct {
+			annotation string
+			argument   string
+		}{
+			{constants.LoggerSampleRateInternalAnnotationKey, LoggerArgumentSampleRate},
+			{constants.LoggerForceSampleHeaderInternalAnnotationKey, LoggerArgumentForceSampleHeader},
+			{constants.LoggerMaxBodyBytesInternalAnnotationKey, LoggerArgumentMaxBodyBytes},
+			{constants.LoggerRedactFieldsInternalAnnotationKey, LoggerArgumentRedactFields},
+		}
+		for _, payloadArg := range payloadArgs {
+			if value, ok := pod.ObjectMeta.Annotations[payloadArg.annotation]; ok {
+				loggerArgs = append(loggerArgs, payloadArg.argument, value)
+			}
+		}
+		if ag.loggerConfig.QueueSize > 0 {
+			loggerArgs = append(loggerArgs, LoggerArgumentQueueSize, strconv.Itoa(ag.loggerConfig.QueueSize))
+		}
+		if ag.loggerConfig.DropPolicy != "" {
+			loggerArgs = append(loggerArgs, LoggerArgumentDropPolicy, ag.loggerConfig.DropPolicy)
+		}
+		if ag.loggerConfig.MaxRetries != nil {
+			loggerArgs = append(loggerArgs, LoggerArgumentMaxRetries, strconv.Itoa(*ag.loggerConfig.MaxRetries))
+		}
+		if ag.loggerConfig.SpoolDir != "" {
+			loggerArgs = append(loggerArgs, LoggerArgumentSpoolDir, ag.loggerConfig.SpoolDir)
+		}
+		eventArgs := []struct {
+			value    string
+			argument string
+		}{
+			{ag.loggerConfig.Encoding, LoggerArgumentEncoding},
+			{ag.loggerConfig.RequestEventType, LoggerArgumentRequestType},
+			{ag.loggerConfig.ResponseEventType, LoggerArgumentResponseType},
+			{ag.loggerConfig.DataSchema, LoggerArgumentDataSchema},
+		}
+		for _, eventArg := range eventArgs {
+			if eventArg.value != "" {
+				loggerArgs = append(loggerArgs, eventArg.argument, eventArg.value)
+			}
+		}
+		if ag.loggerConfig.Envelope {
+			loggerArgs = append(loggerArgs, LoggerArgumentEnvelope+"=true")
+		}
+		if _, ok := pod.ObjectMeta.Annotations[constants.LoggerSecretNameInternalAnnotationKey]; ok {
+			loggerArgs = append(loggerArgs, LoggerArgumentCredentialsDir, LoggerCredentialsMountPath)
+		}
 		args = append(args, loggerArgs...)
 	}
 
@@ -221,6 +324,15 @@ func (ag *AgentInjector) InjectAgent(pod *v1.Pod) error {
 		}
 	}
 
+	if watchModelConfigAPI {
+		agentEnvs = append(agentEnvs, v1.EnvVar{
+			Name: constants.AgentPodNamespaceEnvName,
+			ValueFrom: &v1.EnvVarSource{
+				FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+			},
+		})
+	}
+
 	if !queueProxyAvailable {
 		readinessProbeJson, err := json.Marshal(pod.Spec.Containers[0].ReadinessProbe)
 		if err != nil {
@@ -253,13 +365,7 @@ func (ag *AgentInjector) InjectAgent(pod *v1.Pod) error {
 				v1.ResourceMemory: resource.MustParse(ag.agentConfig.MemoryRequest),
 			},
 		},
-		Ports: []v1.ContainerPort{
-			{
-				Name:          "agent-port",
-				ContainerPort: constants.InferenceServiceDefaultAgentPort,
-				Protocol:      "TCP",
-			},
-		},
+		Ports:           agentPorts(injectPuller),
 		SecurityContext: securityContext,
 		Env:             agentEnvs,
 		ReadinessProbe: &v1.Probe{
@@ -292,6 +398,27 @@ func (ag *AgentInjector) InjectAgent(pod *v1.Pod) error {
 	// Add container to the spec
 	pod.Spec.Containers = append(pod.Spec.Containers, *agentContainer)
 
+	if injectLogger && ag.loggerConfig.SpoolDir != "" {
+		spoolVolume := v1.Volume{
+			Name: LoggerSpoolVolumeName,
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{},
+			},
+		}
+		mountVolumeToContainer(constants.AgentContainerName, pod, spoolVolume, ag.loggerConfig.SpoolDir)
+	}
+	if secretName, ok := pod.ObjectMeta.Annotations[constants.LoggerSecretNameInternalAnnotationKey]; injectLogger && ok {
+		credentialsVolume := v1.Volume{
+			Name: LoggerCredentialsVolumeName,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: secretName,
+				},
+			},
+		}
+		mountReadOnlyVolumeToContainer(constants.AgentContainerName, pod, credentialsVolume, LoggerCredentialsMountPath)
+	}
+
 	if _, ok := pod.ObjectMeta.Annotations[constants.AgentShouldInjectAnnotationKey]; ok {
 		// Mount the modelDir volume to the pod and model agent container
 		err := mountModelDir(pod)
@@ -303,6 +430,8 @@ func (ag *AgentInjector) InjectAgent(pod *v1.Pod) error {
 		if err != nil {
 			return err
 		}
+		// Mount the PVCs the models are pulled from to the model agent and model server containers
+		mountModelPVCs(pod)
 	}
 
 	return nil
@@ -343,7 +472,45 @@ func mountModelConfig(pod *v1.Pod) error {
 	return fmt.Errorf("can not find %v label", constants.AgentModelConfigVolumeNameAnnotationKey)
 }
 
+// mountModelPVCs mounts the PVCs of the pvc:// models read-only in ModelPVCDir/<pvc name> of the model agent container.
+// They are mounted in the model server container as well since the agent may symlink the model files.
+func mountModelPVCs(pod *v1.Pod) {
+	pvcNames, ok := pod.ObjectMeta.Annotations[constants.AgentModelPVCsAnnotationKey]
+	if !ok {
+		return
+	}
+	mounted := map[string]bool{}
+	for _, pvcName := range strings.Split(pvcNames, ",") {
+		pvcName = strings.TrimSpace(pvcName)
+		if pvcName == "" || mounted[pvcName] {
+			continue
+		}
+		// the pvc names may not be valid volume names, the volumes are numbered instead
+		pvcVolume := v1.Volume{
+			Name: fmt.Sprintf("%s%d", constants.ModelPVCVolumeNamePrefix, len(mounted)),
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvcName,
+					ReadOnly:  true,
+				},
+			},
+		}
+		mountPath := path.Join(constants.ModelPVCDir, pvcName)
+		mountReadOnlyVolumeToContainer(constants.AgentContainerName, pod, pvcVolume, mountPath)
+		mountReadOnlyVolumeToContainer(constants.InferenceServiceContainerName, pod, pvcVolume, mountPath)
+		mounted[pvcName] = true
+	}
+}
+
 func mountVolumeToContainer(containerName string, pod *v1.Pod, additionalVolume v1.Volume, mountPath string) {
+	mountVolume(containerName, pod, additionalVolume, mountPath, false)
+}
+
+func mountReadOnlyVolumeToContainer(containerName string, pod *v1.Pod, additionalVolume v1.Volume, mountPath string) {
+	mountVolume(containerName, pod, additionalVolume, mountPath, true)
+}
+
+func mountVolume(containerName string, pod *v1.Pod, additionalVolume v1.Volume, mountPath string, readOnly bool) {
 	pod.Spec.Volumes = appendVolume(pod.Spec.Volumes, additionalVolume)
 	var mountedContainers []v1.Container
 	for _, container := range pod.Spec.Containers {
@@ -353,7 +520,7 @@ func mountVolumeToContainer(containerName string, pod *v1.Pod, additionalVolume
 			}
 			container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
 				Name:      additionalVolume.Name,
-				ReadOnly:  false,
+				ReadOnly:  readOnly,
 				MountPath: mountPath,
 			})
 		}
@@ -374,3 +541,22 @@ func appendVolume(existingVolumes []v1.Volume, additionalVolume v1.Volume) []v1.
 	updatedVolumes := append(existingVolumes, additionalVolume)
 	return updatedVolumes
 }
+
+// agentPorts returns the ports of the agent container, the management port is only served by the model puller
+func agentPorts(injectPuller bool) []v1.ContainerPort {
+	ports := []v1.ContainerPort{
+		{
+			Name:          "agent-port",
+			ContainerPort: constants.InferenceServiceDefaultAgentPort,
+			Protocol:      "TCP",
+		},
+	}
+	if injectPuller {
+		ports = append(ports, v1.ContainerPort{
+			Name:          constants.AgentManagementPortName,
+			ContainerPort: constants.AgentDefaultManagementPort,
+			Protocol:      "TCP",
+		})
+	}
+	return ports
+}
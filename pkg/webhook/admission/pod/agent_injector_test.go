@@ -156,13 +156,19 @@ func TestAgentInjector(t *testing.T) {
 									MountPath: constants.ModelConfigDir,
 								},
 							},
-							Args: []string{"--enable-puller", "--config-dir", "/mnt/configs", "--model-dir", "/mnt/models"},
+							Args: []string{"--enable-puller", "--config-dir", "/mnt/configs", "--model-dir", "/mnt/models",
+								"--management-port", "9082"},
 							Ports: []v1.ContainerPort{
 								{
 									Name:          "agent-port",
 									ContainerPort: constants.InferenceServiceDefaultAgentPort,
 									Protocol:      "TCP",
 								},
+								{
+									Name:          "agent-mgmt",
+									ContainerPort: constants.AgentDefaultManagementPort,
+									Protocol:      "TCP",
+								},
 							},
 							Env: []v1.EnvVar{{Name: "SERVING_READINESS_PROBE", Value: "{\"tcpSocket\":{\"port\":8080},\"timeoutSeconds\":1,\"periodSeconds\":10,\"successThreshold\":1,\"failureThreshold\":3}"}},
 							ReadinessProbe: &v1.Probe{
@@ -526,6 +532,350 @@ func TestAgentInjector(t *testing.T) {
 	}
 }
 
+func TestAgentInjectorLoggerDelivery(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	maxRetries := 0
+	deliveryConfig := &LoggerConfig{
+		Image:      "gcr.io/kfserving/agent:latest",
+		QueueSize:  500,
+		DropPolicy: "oldest",
+		MaxRetries: &maxRetries,
+		SpoolDir:   "/var/spool/kserve",
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.LoggerInternalAnnotationKey:        "true",
+				constants.LoggerSinkUrlInternalAnnotationKey: "http://httpbin.org/",
+				constants.LoggerModeInternalAnnotationKey:    string(v1beta1.LogAll),
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "sklearn",
+			}},
+		},
+	}
+	credentialBuilder := credentials.NewCredentialBulder(c, &v1.ConfigMap{
+		Data: map[string]string{},
+	})
+	injector := &AgentInjector{
+		credentialBuilder,
+		agentConfig,
+		deliveryConfig,
+		batcherTestConfig,
+	}
+	g.Expect(injector.InjectAgent(pod)).To(gomega.Succeed())
+	g.Expect(pod.Spec.Containers).To(gomega.HaveLen(2))
+	agentContainer := pod.Spec.Containers[1]
+	g.Expect(agentContainer.Args).To(gomega.ContainElements(
+		LoggerArgumentQueueSize, "500",
+		LoggerArgumentDropPolicy, "oldest",
+		LoggerArgumentMaxRetries, "0",
+		LoggerArgumentSpoolDir, "/var/spool/kserve",
+	))
+	g.Expect(agentContainer.VolumeMounts).To(gomega.ContainElement(v1.VolumeMount{
+		Name:      LoggerSpoolVolumeName,
+		MountPath: "/var/spool/kserve",
+	}))
+	g.Expect(pod.Spec.Volumes).To(gomega.ContainElement(v1.Volume{
+		Name: LoggerSpoolVolumeName,
+		VolumeSource: v1.VolumeSource{
+			EmptyDir: &v1.EmptyDirVolumeSource{},
+		},
+	}))
+}
+
+func TestAgentInjectorLoggerPayloadArgs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.LoggerInternalAnnotationKey:                  "true",
+				constants.LoggerSinkUrlInternalAnnotationKey:           "http://httpbin.org/",
+				constants.LoggerModeInternalAnnotationKey:              string(v1beta1.LogAll),
+				constants.LoggerSampleRateInternalAnnotationKey:        "0.25",
+				constants.LoggerForceSampleHeaderInternalAnnotationKey: "X-Debug",
+				constants.LoggerMaxBodyBytesInternalAnnotationKey:      "1024",
+				constants.LoggerRedactFieldsInternalAnnotationKey:      "$.instances[*].ssn,$.parameters.token",
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "sklearn",
+			}},
+		},
+	}
+	credentialBuilder := credentials.NewCredentialBulder(c, &v1.ConfigMap{
+		Data: map[string]string{},
+	})
+	injector := &AgentInjector{
+		credentialBuilder,
+		agentConfig,
+		loggerConfig,
+		batcherTestConfig,
+	}
+	g.Expect(injector.InjectAgent(pod)).To(gomega.Succeed())
+	g.Expect(pod.Spec.Containers[1].Args).To(gomega.ContainElements(
+		LoggerArgumentSampleRate, "0.25",
+		LoggerArgumentForceSampleHeader, "X-Debug",
+		LoggerArgumentMaxBodyBytes, "1024",
+		LoggerArgumentRedactFields, "$.instances[*].ssn,$.parameters.token",
+	))
+}
+
+func TestAgentInjectorModelServerArgs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.AgentShouldInjectAnnotationKey:        "true",
+				constants.AgentModelServerProtocolAnnotationKey: "triton-grpc",
+				constants.AgentModelServerEndpointAnnotationKey: "localhost:8001",
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "triton",
+			}},
+		},
+	}
+	credentialBuilder := credentials.NewCredentialBulder(c, &v1.ConfigMap{
+		Data: map[string]string{},
+	})
+	injector := &AgentInjector{
+		credentialBuilder,
+		agentConfig,
+		loggerConfig,
+		batcherTestConfig,
+	}
+	g.Expect(injector.InjectAgent(pod)).To(gomega.Succeed())
+	g.Expect(pod.Spec.Containers[1].Args).To(gomega.ContainElements(
+		constants.AgentModelServerProtocolArgName, "triton-grpc",
+		constants.AgentModelServerEndpointArgName, "localhost:8001",
+	))
+}
+
+func TestAgentInjectorModelConfigWatch(t *testing.T) {
+	scenarios := map[string]struct {
+		watch        string
+		expectedArgs bool
+	}{
+		"API": {
+			watch:        constants.AgentModelConfigWatchAPI,
+			expectedArgs: true,
+		},
+		"File": {
+			watch:        constants.AgentModelConfigWatchFile,
+			expectedArgs: false,
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "deployment",
+					Namespace: "default",
+					Annotations: map[string]string{
+						constants.AgentShouldInjectAnnotationKey:          "true",
+						constants.AgentModelConfigVolumeNameAnnotationKey: "modelconfig-deployment-0",
+						constants.AgentModelConfigMountPathAnnotationKey:  "/mnt/configs",
+						constants.AgentModelDirAnnotationKey:              "/mnt/models",
+						constants.AgentModelConfigWatchAnnotationKey:      scenario.watch,
+					},
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{
+						Name: "sklearn",
+					}},
+				},
+			}
+			credentialBuilder := credentials.NewCredentialBulder(c, &v1.ConfigMap{
+				Data: map[string]string{},
+			})
+			injector := &AgentInjector{
+				credentialBuilder,
+				agentConfig,
+				loggerConfig,
+				batcherTestConfig,
+			}
+			g.Expect(injector.InjectAgent(pod)).To(gomega.Succeed())
+			agentContainer := pod.Spec.Containers[1]
+			namespaceEnv := v1.EnvVar{
+				Name: constants.AgentPodNamespaceEnvName,
+				ValueFrom: &v1.EnvVarSource{
+					FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+				},
+			}
+			// the config dir is mounted either way as the fallback
+			g.Expect(agentContainer.VolumeMounts).To(gomega.ContainElement(gomega.HaveField("MountPath", constants.ModelConfigDir)))
+			if scenario.expectedArgs {
+				g.Expect(agentContainer.Args).To(gomega.ContainElements(
+					constants.AgentModelConfigNameArgName, "modelconfig-deployment-0"))
+				g.Expect(agentContainer.Env).To(gomega.ContainElement(namespaceEnv))
+			} else {
+				g.Expect(agentContainer.Args).NotTo(gomega.ContainElement(constants.AgentModelConfigNameArgName))
+				g.Expect(agentContainer.Env).NotTo(gomega.ContainElement(namespaceEnv))
+			}
+		})
+	}
+}
+
+func TestAgentInjectorModelPVCs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.AgentShouldInjectAnnotationKey:          "true",
+				constants.AgentModelConfigVolumeNameAnnotationKey: "modelconfig-deployment-0",
+				constants.AgentModelConfigMountPathAnnotationKey:  "/mnt/configs",
+				constants.AgentModelDirAnnotationKey:              "/mnt/models",
+				constants.AgentModelPVCsAnnotationKey:             "models, shared.models,models",
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: constants.InferenceServiceContainerName,
+			}},
+		},
+	}
+	credentialBuilder := credentials.NewCredentialBulder(c, &v1.ConfigMap{
+		Data: map[string]string{},
+	})
+	injector := &AgentInjector{
+		credentialBuilder,
+		agentConfig,
+		loggerConfig,
+		batcherTestConfig,
+	}
+	g.Expect(injector.InjectAgent(pod)).To(gomega.Succeed())
+	g.Expect(pod.Spec.Volumes).To(gomega.ContainElements(
+		v1.Volume{
+			Name: constants.ModelPVCVolumeNamePrefix + "0",
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "models", ReadOnly: true},
+			},
+		},
+		v1.Volume{
+			Name: constants.ModelPVCVolumeNamePrefix + "1",
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "shared.models", ReadOnly: true},
+			},
+		},
+	))
+	g.Expect(pod.Spec.Volumes).NotTo(gomega.ContainElement(gomega.HaveField("Name", constants.ModelPVCVolumeNamePrefix+"2")))
+	expectedMounts := []v1.VolumeMount{
+		{Name: constants.ModelPVCVolumeNamePrefix + "0", ReadOnly: true, MountPath: "/mnt/pvc/models"},
+		{Name: constants.ModelPVCVolumeNamePrefix + "1", ReadOnly: true, MountPath: "/mnt/pvc/shared.models"},
+	}
+	// the model server container mounts the pvcs for the symlinked model files
+	for _, container := range pod.Spec.Containers {
+		g.Expect(container.VolumeMounts).To(gomega.ContainElements(expectedMounts))
+	}
+}
+
+func TestAgentInjectorLoggerEventArgs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	eventConfig := &LoggerConfig{
+		Image:            "gcr.io/kfserving/agent:latest",
+		Encoding:         "structured",
+		RequestEventType: "com.example.inference.request",
+		DataSchema:       "https://registry.example.com/schemas/iris/1",
+		Envelope:         true,
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.LoggerInternalAnnotationKey:        "true",
+				constants.LoggerSinkUrlInternalAnnotationKey: "http://httpbin.org/",
+				constants.LoggerModeInternalAnnotationKey:    string(v1beta1.LogAll),
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "sklearn",
+			}},
+		},
+	}
+	credentialBuilder := credentials.NewCredentialBulder(c, &v1.ConfigMap{
+		Data: map[string]string{},
+	})
+	injector := &AgentInjector{
+		credentialBuilder,
+		agentConfig,
+		eventConfig,
+		batcherTestConfig,
+	}
+	g.Expect(injector.InjectAgent(pod)).To(gomega.Succeed())
+	args := pod.Spec.Containers[1].Args
+	g.Expect(args).To(gomega.ContainElements(
+		LoggerArgumentEncoding, "structured",
+		LoggerArgumentRequestType, "com.example.inference.request",
+		LoggerArgumentDataSchema, "https://registry.example.com/schemas/iris/1",
+		LoggerArgumentEnvelope+"=true",
+	))
+	g.Expect(args).NotTo(gomega.ContainElement(LoggerArgumentResponseType))
+}
+
+func TestAgentInjectorLoggerCredentials(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.LoggerInternalAnnotationKey:           "true",
+				constants.LoggerSinkUrlInternalAnnotationKey:    "https://collector.logging:8443/",
+				constants.LoggerModeInternalAnnotationKey:       string(v1beta1.LogAll),
+				constants.LoggerSecretNameInternalAnnotationKey: "logger-credentials",
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "sklearn",
+			}},
+		},
+	}
+	credentialBuilder := credentials.NewCredentialBulder(c, &v1.ConfigMap{
+		Data: map[string]string{},
+	})
+	injector := &AgentInjector{
+		credentialBuilder,
+		agentConfig,
+		loggerConfig,
+		batcherTestConfig,
+	}
+	g.Expect(injector.InjectAgent(pod)).To(gomega.Succeed())
+	agentContainer := pod.Spec.Containers[1]
+	g.Expect(agentContainer.Args).To(gomega.ContainElements(LoggerArgumentCredentialsDir, LoggerCredentialsMountPath))
+	g.Expect(agentContainer.VolumeMounts).To(gomega.ContainElement(v1.VolumeMount{
+		Name:      LoggerCredentialsVolumeName,
+		ReadOnly:  true,
+		MountPath: LoggerCredentialsMountPath,
+	}))
+	g.Expect(pod.Spec.Volumes).To(gomega.ContainElement(v1.Volume{
+		Name: LoggerCredentialsVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: "logger-credentials",
+			},
+		},
+	}))
+	// the model server container doesn't get the credentials
+	g.Expect(pod.Spec.Containers[0].VolumeMounts).To(gomega.BeEmpty())
+}
+
 func TestGetLoggerConfigs(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	cases := []struct {
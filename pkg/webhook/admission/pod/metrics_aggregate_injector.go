@@ -66,6 +66,14 @@ func setMetricAggregationEnvVars(pod *v1.Pod) {
 			pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, v1.EnvVar{Name: constants.KServeContainerPrometheusMetricsPortEnvVarKey, Value: kserveContainerPromPort})
 			pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, v1.EnvVar{Name: constants.KServeContainerPrometheusMetricsPathEnvVarKey, Value: kserveContainerPromPath})
 
+			// If the agent sidecar is injected, queue-proxy also scrapes the agent metrics (e.g. batcher metrics).
+			for _, c := range pod.Spec.Containers {
+				if c.Name == constants.AgentContainerName {
+					pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, v1.EnvVar{Name: constants.AgentPrometheusMetricsPortEnvVarKey, Value: constants.AgentDefaultMetricsPortStr})
+					pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, v1.EnvVar{Name: constants.AgentPrometheusMetricsPathEnvVarKey, Value: constants.DefaultPrometheusPath})
+				}
+			}
+
 			// Set the port that queue-proxy will use to expose the aggregate metrics.
 			pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, v1.EnvVar{Name: constants.QueueProxyAggregatePrometheusMetricsPortEnvVarKey, Value: constants.QueueProxyAggregatePrometheusMetricsPort})
 
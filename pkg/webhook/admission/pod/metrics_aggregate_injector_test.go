@@ -74,6 +74,57 @@ func TestInjectMetricsAggregator(t *testing.T) {
 				},
 			},
 		},
+		"EnableMetricAggWithAgent": {
+			original: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "deployment",
+					Namespace: "default",
+					Annotations: map[string]string{
+						constants.EnableMetricAggregation: "true",
+					},
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{
+						Name: "sklearn",
+					},
+						{
+							Name: "queue-proxy",
+						},
+						{
+							Name: constants.AgentContainerName,
+						},
+					},
+				},
+			},
+			expected: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "deployment",
+					Namespace: "default",
+					Annotations: map[string]string{
+						constants.EnableMetricAggregation: "true",
+					},
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{
+						Name: "sklearn",
+					},
+						{
+							Name: "queue-proxy",
+							Env: []v1.EnvVar{
+								{Name: constants.KServeContainerPrometheusMetricsPortEnvVarKey, Value: sklearnPrometheusPort},
+								{Name: constants.KServeContainerPrometheusMetricsPathEnvVarKey, Value: constants.DefaultPrometheusPath},
+								{Name: constants.AgentPrometheusMetricsPortEnvVarKey, Value: constants.AgentDefaultMetricsPortStr},
+								{Name: constants.AgentPrometheusMetricsPathEnvVarKey, Value: constants.DefaultPrometheusPath},
+								{Name: constants.QueueProxyAggregatePrometheusMetricsPortEnvVarKey, Value: constants.QueueProxyAggregatePrometheusMetricsPort},
+							},
+						},
+						{
+							Name: constants.AgentContainerName,
+						},
+					},
+				},
+			},
+		},
 		"EnableMetricAggNotSet": {
 			original: &v1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
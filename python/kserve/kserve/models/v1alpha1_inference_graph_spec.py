@@ -47,24 +47,50 @@ class V1alpha1InferenceGraphSpec(object):
                             and the value is json key in definition.
     """
     openapi_types = {
+        'logger': 'V1alpha1LoggerSpec',
         'nodes': 'dict(str, V1alpha1InferenceRouter)'
     }
 
     attribute_map = {
+        'logger': 'logger',
         'nodes': 'nodes'
     }
 
-    def __init__(self, nodes=None, local_vars_configuration=None):  # noqa: E501
+    def __init__(self, logger=None, nodes=None, local_vars_configuration=None):  # noqa: E501
         """V1alpha1InferenceGraphSpec - a model defined in OpenAPI"""  # noqa: E501
         if local_vars_configuration is None:
             local_vars_configuration = Configuration()
         self.local_vars_configuration = local_vars_configuration
 
+        self._logger = None
         self._nodes = None
         self.discriminator = None
 
+        if logger is not None:
+            self.logger = logger
         self.nodes = nodes
 
+    @property
+    def logger(self):
+        """Gets the logger of this V1alpha1InferenceGraphSpec.  # noqa: E501
+
+
+        :return: The logger of this V1alpha1InferenceGraphSpec.  # noqa: E501
+        :rtype: V1alpha1LoggerSpec
+        """
+        return self._logger
+
+    @logger.setter
+    def logger(self, logger):
+        """Sets the logger of this V1alpha1InferenceGraphSpec.
+
+
+        :param logger: The logger of this V1alpha1InferenceGraphSpec.  # noqa: E501
+        :type: V1alpha1LoggerSpec
+        """
+
+        self._logger = logger
+
     @property
     def nodes(self):
         """Gets the nodes of this V1alpha1InferenceGraphSpec.  # noqa: E501
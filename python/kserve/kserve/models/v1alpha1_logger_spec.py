@@ -0,0 +1,304 @@
+# Copyright 2022 The KServe Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# coding: utf-8
+
+"""
+    KServe
+
+    Python SDK for KServe  # noqa: E501
+
+    The version of the OpenAPI document: v0.1
+    Generated by: https://openapi-generator.tech
+"""
+
+
+import pprint
+import re  # noqa: F401
+
+import six
+
+from kserve.configuration import Configuration
+
+
+class V1alpha1LoggerSpec(object):
+    """NOTE: This class is auto generated by OpenAPI Generator.
+    Ref: https://openapi-generator.tech
+
+    Do not edit the class manually.
+    """
+
+    """
+    Attributes:
+      openapi_types (dict): The key is attribute name
+                            and the value is attribute type.
+      attribute_map (dict): The key is attribute name
+                            and the value is json key in definition.
+    """
+    openapi_types = {
+        'force_sample_header': 'str',
+        'max_body_bytes': 'int',
+        'mode': 'str',
+        'redact_fields': 'list[str]',
+        'sample_rate': 'str',
+        'secret_name': 'str',
+        'url': 'str'
+    }
+
+    attribute_map = {
+        'force_sample_header': 'forceSampleHeader',
+        'max_body_bytes': 'maxBodyBytes',
+        'mode': 'mode',
+        'redact_fields': 'redactFields',
+        'sample_rate': 'sampleRate',
+        'secret_name': 'secretName',
+        'url': 'url'
+    }
+
+    def __init__(self, force_sample_header=None, max_body_bytes=None, mode=None, redact_fields=None, sample_rate=None, secret_name=None, url=None, local_vars_configuration=None):  # noqa: E501
+        """V1alpha1LoggerSpec - a model defined in OpenAPI"""  # noqa: E501
+        if local_vars_configuration is None:
+            local_vars_configuration = Configuration()
+        self.local_vars_configuration = local_vars_configuration
+
+        self._force_sample_header = None
+        self._max_body_bytes = None
+        self._mode = None
+        self._redact_fields = None
+        self._sample_rate = None
+        self._secret_name = None
+        self._url = None
+        self.discriminator = None
+
+        if force_sample_header is not None:
+            self.force_sample_header = force_sample_header
+        if max_body_bytes is not None:
+            self.max_body_bytes = max_body_bytes
+        if mode is not None:
+            self.mode = mode
+        if redact_fields is not None:
+            self.redact_fields = redact_fields
+        if sample_rate is not None:
+            self.sample_rate = sample_rate
+        if secret_name is not None:
+            self.secret_name = secret_name
+        if url is not None:
+            self.url = url
+
+    @property
+    def force_sample_header(self):
+        """Gets the force_sample_header of this V1alpha1LoggerSpec.  # noqa: E501
+
+        Requests with this header set to \"true\" are always logged regardless of the sample rate  # noqa: E501
+
+        :return: The force_sample_header of this V1alpha1LoggerSpec.  # noqa: E501
+        :rtype: str
+        """
+        return self._force_sample_header
+
+    @force_sample_header.setter
+    def force_sample_header(self, force_sample_header):
+        """Sets the force_sample_header of this V1alpha1LoggerSpec.
+
+        Requests with this header set to \"true\" are always logged regardless of the sample rate  # noqa: E501
+
+        :param force_sample_header: The force_sample_header of this V1alpha1LoggerSpec.  # noqa: E501
+        :type: str
+        """
+
+        self._force_sample_header = force_sample_header
+
+    @property
+    def max_body_bytes(self):
+        """Gets the max_body_bytes of this V1alpha1LoggerSpec.  # noqa: E501
+
+        Max size in bytes of the logged request and response bodies, larger bodies are truncated. Defaults to logging the whole body.  # noqa: E501
+
+        :return: The max_body_bytes of this V1alpha1LoggerSpec.  # noqa: E501
+        :rtype: int
+        """
+        return self._max_body_bytes
+
+    @max_body_bytes.setter
+    def max_body_bytes(self, max_body_bytes):
+        """Sets the max_body_bytes of this V1alpha1LoggerSpec.
+
+        Max size in bytes of the logged request and response bodies, larger bodies are truncated. Defaults to logging the whole body.  # noqa: E501
+
+        :param max_body_bytes: The max_body_bytes of this V1alpha1LoggerSpec.  # noqa: E501
+        :type: int
+        """
+
+        self._max_body_bytes = max_body_bytes
+
+    @property
+    def mode(self):
+        """Gets the mode of this V1alpha1LoggerSpec.  # noqa: E501
+
+        Specifies the scope of the loggers. <br /> Valid values are: <br /> - \"all\" (default): log both request and response; <br /> - \"request\": log only request; <br /> - \"response\": log only response <br />  # noqa: E501
+
+        :return: The mode of this V1alpha1LoggerSpec.  # noqa: E501
+        :rtype: str
+        """
+        return self._mode
+
+    @mode.setter
+    def mode(self, mode):
+        """Sets the mode of this V1alpha1LoggerSpec.
+
+        Specifies the scope of the loggers. <br /> Valid values are: <br /> - \"all\" (default): log both request and response; <br /> - \"request\": log only request; <br /> - \"response\": log only response <br />  # noqa: E501
+
+        :param mode: The mode of this V1alpha1LoggerSpec.  # noqa: E501
+        :type: str
+        """
+
+        self._mode = mode
+
+    @property
+    def redact_fields(self):
+        """Gets the redact_fields of this V1alpha1LoggerSpec.  # noqa: E501
+
+        JSON paths of the request and response fields to redact before they are logged, e.g. \"$.instances[*].ssn\" or \"$.parameters.token\"  # noqa: E501
+
+        :return: The redact_fields of this V1alpha1LoggerSpec.  # noqa: E501
+        :rtype: list[str]
+        """
+        return self._redact_fields
+
+    @redact_fields.setter
+    def redact_fields(self, redact_fields):
+        """Sets the redact_fields of this V1alpha1LoggerSpec.
+
+        JSON paths of the request and response fields to redact before they are logged, e.g. \"$.instances[*].ssn\" or \"$.parameters.token\"  # noqa: E501
+
+        :param redact_fields: The redact_fields of this V1alpha1LoggerSpec.  # noqa: E501
+        :type: list[str]
+        """
+
+        self._redact_fields = redact_fields
+
+    @property
+    def sample_rate(self):
+        """Gets the sample_rate of this V1alpha1LoggerSpec.  # noqa: E501
+
+        Fraction of requests to log between 0 and 1 such as \"0.25\", defaults to \"1\" which logs every request. The steps of a graph request are sampled together with the request.  # noqa: E501
+
+        :return: The sample_rate of this V1alpha1LoggerSpec.  # noqa: E501
+        :rtype: str
+        """
+        return self._sample_rate
+
+    @sample_rate.setter
+    def sample_rate(self, sample_rate):
+        """Sets the sample_rate of this V1alpha1LoggerSpec.
+
+        Fraction of requests to log between 0 and 1 such as \"0.25\", defaults to \"1\" which logs every request. The steps of a graph request are sampled together with the request.  # noqa: E501
+
+        :param sample_rate: The sample_rate of this V1alpha1LoggerSpec.  # noqa: E501
+        :type: str
+        """
+
+        self._sample_rate = sample_rate
+
+    @property
+    def secret_name(self):
+        """Gets the secret_name of this V1alpha1LoggerSpec.  # noqa: E501
+
+        Name of a secret in the InferenceGraph namespace holding the credentials used to connect to the logger url. The optional keys ca.crt, tls.crt and tls.key configure TLS and mutual TLS, token configures bearer auth and username and password configure basic auth.  # noqa: E501
+
+        :return: The secret_name of this V1alpha1LoggerSpec.  # noqa: E501
+        :rtype: str
+        """
+        return self._secret_name
+
+    @secret_name.setter
+    def secret_name(self, secret_name):
+        """Sets the secret_name of this V1alpha1LoggerSpec.
+
+        Name of a secret in the InferenceGraph namespace holding the credentials used to connect to the logger url. The optional keys ca.crt, tls.crt and tls.key configure TLS and mutual TLS, token configures bearer auth and username and password configure basic auth.  # noqa: E501
+
+        :param secret_name: The secret_name of this V1alpha1LoggerSpec.  # noqa: E501
+        :type: str
+        """
+
+        self._secret_name = secret_name
+
+    @property
+    def url(self):
+        """Gets the url of this V1alpha1LoggerSpec.  # noqa: E501
+
+        URL to send logging events  # noqa: E501
+
+        :return: The url of this V1alpha1LoggerSpec.  # noqa: E501
+        :rtype: str
+        """
+        return self._url
+
+    @url.setter
+    def url(self, url):
+        """Sets the url of this V1alpha1LoggerSpec.
+
+        URL to send logging events  # noqa: E501
+
+        :param url: The url of this V1alpha1LoggerSpec.  # noqa: E501
+        :type: str
+        """
+
+        self._url = url
+
+    def to_dict(self):
+        """Returns the model properties as a dict"""
+        result = {}
+
+        for attr, _ in six.iteritems(self.openapi_types):
+            value = getattr(self, attr)
+            if isinstance(value, list):
+                result[attr] = list(map(
+                    lambda x: x.to_dict() if hasattr(x, "to_dict") else x,
+                    value
+                ))
+            elif hasattr(value, "to_dict"):
+                result[attr] = value.to_dict()
+            elif isinstance(value, dict):
+                result[attr] = dict(map(
+                    lambda item: (item[0], item[1].to_dict())
+                    if hasattr(item[1], "to_dict") else item,
+                    value.items()
+                ))
+            else:
+                result[attr] = value
+
+        return result
+
+    def to_str(self):
+        """Returns the string representation of the model"""
+        return pprint.pformat(self.to_dict())
+
+    def __repr__(self):
+        """For `print` and `pprint`"""
+        return self.to_str()
+
+    def __eq__(self, other):
+        """Returns true if both objects are equal"""
+        if not isinstance(other, V1alpha1LoggerSpec):
+            return False
+
+        return self.to_dict() == other.to_dict()
+
+    def __ne__(self, other):
+        """Returns true if both objects are not equal"""
+        if not isinstance(other, V1alpha1LoggerSpec):
+            return True
+
+        return self.to_dict() != other.to_dict()
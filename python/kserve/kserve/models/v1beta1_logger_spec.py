@@ -47,30 +47,101 @@ class V1beta1LoggerSpec(object):
                             and the value is json key in definition.
     """
     openapi_types = {
+        'force_sample_header': 'str',
+        'max_body_bytes': 'int',
         'mode': 'str',
+        'redact_fields': 'list[str]',
+        'sample_rate': 'str',
+        'secret_name': 'str',
         'url': 'str'
     }
 
     attribute_map = {
+        'force_sample_header': 'forceSampleHeader',
+        'max_body_bytes': 'maxBodyBytes',
         'mode': 'mode',
+        'redact_fields': 'redactFields',
+        'sample_rate': 'sampleRate',
+        'secret_name': 'secretName',
         'url': 'url'
     }
 
-    def __init__(self, mode=None, url=None, local_vars_configuration=None):  # noqa: E501
+    def __init__(self, force_sample_header=None, max_body_bytes=None, mode=None, redact_fields=None, sample_rate=None, secret_name=None, url=None, local_vars_configuration=None):  # noqa: E501
         """V1beta1LoggerSpec - a model defined in OpenAPI"""  # noqa: E501
         if local_vars_configuration is None:
             local_vars_configuration = Configuration()
         self.local_vars_configuration = local_vars_configuration
 
+        self._force_sample_header = None
+        self._max_body_bytes = None
         self._mode = None
+        self._redact_fields = None
+        self._sample_rate = None
+        self._secret_name = None
         self._url = None
         self.discriminator = None
 
+        if force_sample_header is not None:
+            self.force_sample_header = force_sample_header
+        if max_body_bytes is not None:
+            self.max_body_bytes = max_body_bytes
         if mode is not None:
             self.mode = mode
+        if redact_fields is not None:
+            self.redact_fields = redact_fields
+        if sample_rate is not None:
+            self.sample_rate = sample_rate
+        if secret_name is not None:
+            self.secret_name = secret_name
         if url is not None:
             self.url = url
 
+    @property
+    def force_sample_header(self):
+        """Gets the force_sample_header of this V1beta1LoggerSpec.  # noqa: E501
+
+        Requests with this header set to \"true\" are always logged regardless of the sample rate  # noqa: E501
+
+        :return: The force_sample_header of this V1beta1LoggerSpec.  # noqa: E501
+        :rtype: str
+        """
+        return self._force_sample_header
+
+    @force_sample_header.setter
+    def force_sample_header(self, force_sample_header):
+        """Sets the force_sample_header of this V1beta1LoggerSpec.
+
+        Requests with this header set to \"true\" are always logged regardless of the sample rate  # noqa: E501
+
+        :param force_sample_header: The force_sample_header of this V1beta1LoggerSpec.  # noqa: E501
+        :type: str
+        """
+
+        self._force_sample_header = force_sample_header
+
+    @property
+    def max_body_bytes(self):
+        """Gets the max_body_bytes of this V1beta1LoggerSpec.  # noqa: E501
+
+        Max size in bytes of the logged request and response bodies, larger bodies are truncated. Defaults to logging the whole body.  # noqa: E501
+
+        :return: The max_body_bytes of this V1beta1LoggerSpec.  # noqa: E501
+        :rtype: int
+        """
+        return self._max_body_bytes
+
+    @max_body_bytes.setter
+    def max_body_bytes(self, max_body_bytes):
+        """Sets the max_body_bytes of this V1beta1LoggerSpec.
+
+        Max size in bytes of the logged request and response bodies, larger bodies are truncated. Defaults to logging the whole body.  # noqa: E501
+
+        :param max_body_bytes: The max_body_bytes of this V1beta1LoggerSpec.  # noqa: E501
+        :type: int
+        """
+
+        self._max_body_bytes = max_body_bytes
+
     @property
     def mode(self):
         """Gets the mode of this V1beta1LoggerSpec.  # noqa: E501
@@ -94,6 +165,75 @@ class V1beta1LoggerSpec(object):
 
         self._mode = mode
 
+    @property
+    def redact_fields(self):
+        """Gets the redact_fields of this V1beta1LoggerSpec.  # noqa: E501
+
+        JSON paths of the request and response fields to redact before they are logged, e.g. \"$.instances[*].ssn\" or \"$.parameters.token\"  # noqa: E501
+
+        :return: The redact_fields of this V1beta1LoggerSpec.  # noqa: E501
+        :rtype: list[str]
+        """
+        return self._redact_fields
+
+    @redact_fields.setter
+    def redact_fields(self, redact_fields):
+        """Sets the redact_fields of this V1beta1LoggerSpec.
+
+        JSON paths of the request and response fields to redact before they are logged, e.g. \"$.instances[*].ssn\" or \"$.parameters.token\"  # noqa: E501
+
+        :param redact_fields: The redact_fields of this V1beta1LoggerSpec.  # noqa: E501
+        :type: list[str]
+        """
+
+        self._redact_fields = redact_fields
+
+    @property
+    def sample_rate(self):
+        """Gets the sample_rate of this V1beta1LoggerSpec.  # noqa: E501
+
+        Fraction of requests to log between 0 and 1 such as \"0.25\", defaults to \"1\" which logs every request. The request and response of an inference are sampled together.  # noqa: E501
+
+        :return: The sample_rate of this V1beta1LoggerSpec.  # noqa: E501
+        :rtype: str
+        """
+        return self._sample_rate
+
+    @sample_rate.setter
+    def sample_rate(self, sample_rate):
+        """Sets the sample_rate of this V1beta1LoggerSpec.
+
+        Fraction of requests to log between 0 and 1 such as \"0.25\", defaults to \"1\" which logs every request. The request and response of an inference are sampled together.  # noqa: E501
+
+        :param sample_rate: The sample_rate of this V1beta1LoggerSpec.  # noqa: E501
+        :type: str
+        """
+
+        self._sample_rate = sample_rate
+
+    @property
+    def secret_name(self):
+        """Gets the secret_name of this V1beta1LoggerSpec.  # noqa: E501
+
+        Name of a secret in the InferenceService namespace holding the credentials used to connect to the logger url. The optional keys ca.crt, tls.crt and tls.key configure TLS and mutual TLS, token configures bearer auth and username and password configure basic auth.  # noqa: E501
+
+        :return: The secret_name of this V1beta1LoggerSpec.  # noqa: E501
+        :rtype: str
+        """
+        return self._secret_name
+
+    @secret_name.setter
+    def secret_name(self, secret_name):
+        """Sets the secret_name of this V1beta1LoggerSpec.
+
+        Name of a secret in the InferenceService namespace holding the credentials used to connect to the logger url. The optional keys ca.crt, tls.crt and tls.key configure TLS and mutual TLS, token configures bearer auth and username and password configure basic auth.  # noqa: E501
+
+        :param secret_name: The secret_name of this V1beta1LoggerSpec.  # noqa: E501
+        :type: str
+        """
+
+        self._secret_name = secret_name
+
     @property
     def url(self):
         """Gets the url of this V1beta1LoggerSpec.  # noqa: E501
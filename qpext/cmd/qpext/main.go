@@ -43,6 +43,8 @@ const (
 	// aggregate scraping env vars from kserve/pkg/constants
 	KServeContainerPrometheusMetricsPortEnvVarKey     = "KSERVE_CONTAINER_PROMETHEUS_METRICS_PORT"
 	KServeContainerPrometheusMetricsPathEnvVarKey     = "KSERVE_CONTAINER_PROMETHEUS_METRICS_PATH"
+	AgentPrometheusMetricsPortEnvVarKey               = "KSERVE_AGENT_PROMETHEUS_METRICS_PORT"
+	AgentPrometheusMetricsPathEnvVarKey               = "KSERVE_AGENT_PROMETHEUS_METRICS_PATH"
 	QueueProxyAggregatePrometheusMetricsPortEnvVarKey = "AGGREGATE_PROMETHEUS_METRICS_PORT"
 	QueueProxyMetricsPort                             = "9091"
 	DefaultQueueProxyMetricsPath                      = "/metrics"
@@ -55,6 +57,8 @@ type ScrapeConfigurations struct {
 	QueueProxyPort string `json:"port"`
 	AppPort        string
 	AppPath        string
+	AgentPort      string
+	AgentPath      string
 }
 
 func getURL(port string, path string) string {
@@ -140,31 +144,39 @@ func scrape(url string, header http.Header, logger *zap.Logger) (io.ReadCloser,
 	return resp.Body, cancel, format, nil
 }
 
-func NewScrapeConfigs(logger *zap.Logger, queueProxyPort string, appPort string, appPath string) *ScrapeConfigurations {
+func NewScrapeConfigs(logger *zap.Logger, queueProxyPort string, appPort string, appPath string, agentPort string, agentPath string) *ScrapeConfigurations {
 	return &ScrapeConfigurations{
 		logger:         logger,
 		QueueProxyPath: DefaultQueueProxyMetricsPath,
 		QueueProxyPort: queueProxyPort,
 		AppPort:        appPort,
 		AppPath:        appPath,
+		AgentPort:      agentPort,
+		AgentPath:      agentPath,
 	}
 }
 
 func (sc *ScrapeConfigurations) handleStats(w http.ResponseWriter, r *http.Request) {
 	var err error
-	var queueProxy, application io.ReadCloser
-	var queueProxyCancel, appCancel context.CancelFunc
+	var queueProxy, application, agent io.ReadCloser
+	var queueProxyCancel, appCancel, agentCancel context.CancelFunc
 
 	defer func() {
 		if application != nil {
 			application.Close()
 		}
+		if agent != nil {
+			agent.Close()
+		}
 		if queueProxyCancel != nil {
 			queueProxyCancel()
 		}
 		if appCancel != nil {
 			appCancel()
 		}
+		if agentCancel != nil {
+			agentCancel()
+		}
 	}()
 
 	// Gather all the metrics we will merge
@@ -175,6 +187,14 @@ func (sc *ScrapeConfigurations) handleStats(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	// Scrape agent metrics if the agent sidecar is injected
+	if sc.AgentPort != "" {
+		agentURL := getURL(sc.AgentPort, sc.AgentPath)
+		if agent, agentCancel, _, err = scrape(agentURL, r.Header, sc.logger); err != nil {
+			sc.logger.Error("failed scraping agent metrics", zap.Error(err))
+		}
+	}
+
 	// Scrape app metrics if defined and capture their format
 	var format expfmt.Format
 	if sc.AppPort != "" {
@@ -203,6 +223,13 @@ func (sc *ScrapeConfigurations) handleStats(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	if agent != nil {
+		_, err = io.Copy(w, agent)
+		if err != nil {
+			sc.logger.Error("failed to scraping and writing agent sidecar metrics", zap.Error(err))
+		}
+	}
+
 	// App metrics must go last because if they are FmtOpenMetrics,
 	// they will have a trailing "# EOF" which terminates the full exposition
 	if application != nil {
@@ -223,6 +250,8 @@ func main() {
 		QueueProxyMetricsPort,
 		os.Getenv(KServeContainerPrometheusMetricsPortEnvVarKey),
 		os.Getenv(KServeContainerPrometheusMetricsPathEnvVarKey),
+		os.Getenv(AgentPrometheusMetricsPortEnvVarKey),
+		os.Getenv(AgentPrometheusMetricsPathEnvVarKey),
 	)
 	mux.HandleFunc(`/metrics`, sc.handleStats)
 	l, err := net.Listen("tcp", fmt.Sprintf(":%v", os.Getenv(QueueProxyAggregatePrometheusMetricsPortEnvVarKey)))
@@ -198,6 +198,39 @@ func TestHandleStats(t *testing.T) {
 
 }
 
+func TestHandleStatsWithAgent(t *testing.T) {
+	metricExample := `# TYPE my_metric counter
+	my_metric{} 0
+	`
+	agentMetricExample := `# TYPE kserve_batcher_flushes_total counter
+	kserve_batcher_flushes_total{reason="size"} 1
+	`
+	zapLogger := logger.InitializeLogger()
+	promRegistry = prometheus.NewRegistry()
+	rec := httptest.NewRecorder()
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(metricExample))
+		assert.NoError(t, err)
+	}))
+	defer app.Close()
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(agentMetricExample))
+		assert.NoError(t, err)
+	}))
+	defer agent.Close()
+
+	psc := NewScrapeConfigs(zapLogger, "", strings.Split(app.URL, ":")[2], "/metrics",
+		strings.Split(agent.URL, ":")[2], "/metrics")
+	req := &http.Request{}
+	psc.handleStats(rec, req)
+	assert.Equal(t, rec.Code, 200)
+	assert.Contains(t, rec.Body.String(), agentMetricExample+metricExample)
+
+	parser := expfmt.TextParser{}
+	_, err := parser.TextToMetricFamilies(strings.NewReader(rec.Body.String()))
+	assert.NoError(t, err)
+}
+
 func TestHandleStatsErr(t *testing.T) {
 	zapLogger := logger.InitializeLogger()
 	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -223,7 +256,7 @@ func TestHandleStatsErr(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			sc := NewScrapeConfigs(zapLogger, test.queueproxy, test.app, DefaultQueueProxyMetricsPath)
+			sc := NewScrapeConfigs(zapLogger, test.queueproxy, test.app, DefaultQueueProxyMetricsPath, "", "")
 			req := &http.Request{}
 			rec := httptest.NewRecorder()
 			sc.handleStats(rec, req)
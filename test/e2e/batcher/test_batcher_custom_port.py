@@ -78,10 +78,10 @@ def test_batcher_custom_port():
         raise e
     with futures.ThreadPoolExecutor(max_workers=4) as executor:
         future_res = [
-            executor.submit(lambda: predict_str(service_name, json.dumps(item))) for item in json_array
+            executor.submit(lambda: predict_str(service_name, json.dumps(item), with_headers=True)) for item in json_array
         ]
     results = [
-        f.result()["batchId"] for f in future_res
+        f.result()[1]["X-Batch-Id"] for f in future_res
     ]
     assert (all(x == results[0] for x in results))
     kserve_client.delete(service_name, KSERVE_TEST_NAMESPACE)
@@ -69,7 +69,7 @@ def predict(service_name, input_json, protocol_version="v1",
 
 
 def predict_str(service_name, input_json, protocol_version="v1",
-                version=constants.KSERVE_V1BETA1_VERSION, model_name=None):
+                version=constants.KSERVE_V1BETA1_VERSION, model_name=None, with_headers=False):
     kfs_client = KServeClient(
         config_file=os.environ.get("KUBECONFIG", "~/.kube/config"))
     isvc = kfs_client.get(
@@ -96,6 +96,8 @@ def predict_str(service_name, input_json, protocol_version="v1",
     response = requests.post(url, input_json, headers=headers)
     logging.info("Got response code %s, content %s", response.status_code, response.content)
     preds = json.loads(response.content.decode("utf-8"))
+    if with_headers:
+        return preds, response.headers
     return preds
 
 